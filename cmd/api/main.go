@@ -1,18 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/teomiscia/github-trending/internal/api"
 	"github.com/teomiscia/github-trending/internal/config"
 	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/messaging"
 )
 
 const (
 	maxRetries = 5
 	retryDelay = 5 * time.Second
+	// shutdownTimeout bounds the time in-flight requests have to complete on shutdown.
+	shutdownTimeout = 10 * time.Second
 )
 
 func main() {
@@ -74,8 +83,53 @@ func main() {
 		log.Fatalf("Failed to connect to MinIO after %d retries: %v", maxRetries, err)
 	}
 
-	server := api.NewServer(cfg, redisClient, postgresConnection, clickhouseConnection, minioConnection)
+	var qdrantConnection *database.QdrantConnection
+	for i := 0; i < maxRetries; i++ {
+		qdrantConnection, err = database.NewQdrantConnection(cfg.QdrantHost, cfg.QdrantPort)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to Qdrant: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to Qdrant after %d retries: %v", maxRetries, err)
+	}
+	defer qdrantConnection.Close()
 
-	log.Println("API Server started. Listening on :8080")
-	log.Fatal(server.Run(":8080"))
+	var mqConnection *messaging.Connection
+	for i := 0; i < maxRetries; i++ {
+		mqConnection, err = messaging.NewConnection(cfg.RabbitMQURL)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to RabbitMQ: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to RabbitMQ after %d retries: %v", maxRetries, err)
+	}
+	defer mqConnection.Close()
+
+	router, flushEvents := api.NewServer(cfg, redisClient, postgresConnection, clickhouseConnection, minioConnection, qdrantConnection, mqConnection)
+	server := &http.Server{Addr: ":8080", Handler: router}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		log.Println("API Server started. Listening on :8080")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("API server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down API server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down API server gracefully: %v", err)
+	}
+	// Write out the feedback events buffered by the last requests.
+	flushEvents()
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/similarity"
+)
+
+const (
+	maxRetries = 5
+	retryDelay = 5 * time.Second
+
+	// rebuildInterval is how often the co-view lists are rebuilt from scratch.
+	rebuildInterval = 24 * time.Hour
+	// Views older than viewWindow are ignored; newer ones lose half their weight every
+	// viewHalfLife.
+	viewWindow   = 90 * 24 * time.Hour
+	viewHalfLife = 14 * 24 * time.Hour
+	// maxSessionViews caps the views paired per session and minSessions is the number
+	// of sessions two repositories must share to be neighbours.
+	maxSessionViews = 50
+	minSessions     = 2
+)
+
+// The co-view service builds item-item collaborative filtering lists from
+// repository_views: for each repository, the repositories most often viewed in the same
+// sessions. The API mixes them with the content-based similarity lists.
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	var pgConnection *database.PostgresConnection
+	for i := 0; i < maxRetries; i++ {
+		pgConnection, err = database.NewPostgresConnection(cfg.PostgresHost, "5432", cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresDB)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to PostgreSQL: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL after %d retries: %v", maxRetries, err)
+	}
+	defer pgConnection.DB.Close()
+
+	log.Println("Co-view service started. Building co-view lists.")
+	buildCoViews(pgConnection, cfg)
+
+	ticker := time.NewTicker(rebuildInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		buildCoViews(pgConnection, cfg)
+	}
+}
+
+func buildCoViews(pgConnection *database.PostgresConnection, cfg *config.Config) {
+	now := time.Now()
+	views, err := pgConnection.GetRepositoryViewsSince(now.Add(-viewWindow))
+	if err != nil {
+		log.Printf("Failed to get repository views from PostgreSQL: %v", err)
+		return
+	}
+
+	neighbours := similarity.BuildCoViews(views, now, similarity.CoViewOptions{
+		HalfLife:        viewHalfLife,
+		MaxSessionViews: maxSessionViews,
+		MinSessions:     minSessions,
+		MaxNeighbours:   cfg.SimilarityListSize,
+	})
+
+	// Store the lists in the format of repository_similarity, so the API reads both alike.
+	lists := make(map[int64][]byte, len(neighbours))
+	for repoID, list := range neighbours {
+		scores := make([]redis.Z, len(list))
+		for i, n := range list {
+			scores[i] = redis.Z{Score: n.Score, Member: n.RepositoryID}
+		}
+		data, err := json.Marshal(scores)
+		if err != nil {
+			log.Printf("Failed to marshal co-views for repo %d: %v", repoID, err)
+			continue
+		}
+		lists[repoID] = data
+	}
+
+	if err := pgConnection.ReplaceRepositoryCoViews(lists); err != nil {
+		log.Printf("Failed to store co-views in PostgreSQL: %v", err)
+		return
+	}
+	log.Printf("Built co-view lists for %d repositories from %d views in %v.", len(lists), len(views), time.Since(now))
+}
@@ -1,11 +1,9 @@
 package main
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"time"
 
 	"github.com/teomiscia/github-trending/internal/config"
@@ -18,6 +16,8 @@ import (
 const (
 	maxRetries = 5
 	retryDelay = 5 * time.Second
+	// activityWindow is the period over which issue and pull request activity is summarised.
+	activityWindow = 30 * 24 * time.Hour
 )
 
 func main() {
@@ -58,13 +58,13 @@ func main() {
 	crawlQueueName := "repos_to_crawl"
 	processQueueName := "raw_data_to_process"
 
-	err = runCrawler(mqConnection, dbConnection, githubClient, http.DefaultClient, "https://raw.githubusercontent.com", crawlQueueName, processQueueName)
+	err = runCrawler(mqConnection, dbConnection, githubClient, crawlQueueName, processQueueName)
 	if err != nil {
 		log.Fatalf("Crawler service failed: %v", err)
 	}
 }
 
-func runCrawler(mqConnection messaging.MQConnection, dbConnection database.DBConnection, githubClient *github.GitHubClient, httpClient *http.Client, rawContentBaseURL, crawlQueueName, processQueueName string) error {
+func runCrawler(mqConnection messaging.MQConnection, dbConnection database.DBConnection, githubClient *github.GitHubClient, crawlQueueName, processQueueName string) error {
 	msgs, err := mqConnection.Consume(crawlQueueName)
 	if err != nil {
 		return fmt.Errorf("failed to start consuming from queue %s: %w", crawlQueueName, err)
@@ -95,12 +95,7 @@ func runCrawler(mqConnection messaging.MQConnection, dbConnection database.DBCon
 			continue
 		}
 
-		readmeURL := findReadme(httpClient, &repo, rawContentBaseURL)
-		if readmeURL != "" {
-			repo.ReadmeURL = sql.NullString{String: readmeURL, Valid: true}
-		} else {
-			repo.ReadmeURL = sql.NullString{Valid: false}
-		}
+		// The README is resolved by the processor through the GitHub readme endpoint.
 		repo.Tags, err = githubClient.GetTags(repo.FullName)
 		if err != nil {
 			log.Printf("Failed to get tags for %s: %v", repo.FullName, err)
@@ -113,6 +108,20 @@ func runCrawler(mqConnection messaging.MQConnection, dbConnection database.DBCon
 			d.Nack(false, true) // Requeue the message
 			continue
 		}
+		if repo.HasIssues {
+			// Activity is supplementary; a failure here should not block the crawl.
+			repo.Activity, err = githubClient.GetIssueActivity(repo.FullName, activityWindow)
+			if err != nil {
+				log.Printf("Failed to get issue activity for %s: %v", repo.FullName, err)
+			}
+		}
+		// Releases feed watch notifications and webhooks; the writer keeps the stored
+		// release when this fails. Unchanged releases are answered from the client's
+		// ETag cache without a request delay.
+		repo.LatestRelease, err = githubClient.GetLatestRelease(repo.FullName)
+		if err != nil {
+			log.Printf("Failed to get latest release for %s: %v", repo.FullName, err)
+		}
 
 		crawlResult := models.CrawlResult{
 			Repository:   repo,
@@ -138,28 +147,3 @@ func runCrawler(mqConnection messaging.MQConnection, dbConnection database.DBCon
 	}
 	return nil
 }
-
-func findReadme(client *http.Client, repo *models.Repository, rawContentBaseURL string) string {
-	readmeNames := []string{"README.md", "README.txt"}
-
-	for _, name := range readmeNames {
-		url := fmt.Sprintf("%s/%s/%s/%s", rawContentBaseURL, repo.FullName, repo.DefaultBranch, name)
-
-		resp, err := client.Head(url)
-		if err == nil && resp.StatusCode == http.StatusOK {
-			log.Printf("Found README for %s at %s", repo.FullName, url)
-			resp.Body.Close()
-			return url
-		}
-
-		if resp != nil {
-			log.Printf("Checked for README at %s, status code: %d", url, resp.StatusCode)
-			resp.Body.Close()
-		} else {
-			log.Printf("Error checking for README at %s: %v", url, err)
-		}
-	}
-
-	log.Printf("README not found for %s", repo.FullName)
-	return ""
-}
@@ -43,6 +43,10 @@ func (m *MockRabbitMQConnection) Publish(queueName string, body []byte) error {
 	return nil
 }
 
+func (m *MockRabbitMQConnection) NotifyClose(c chan *amqp.Error) chan *amqp.Error {
+	return c
+}
+
 func (m *MockRabbitMQConnection) Close() error {
 	close(m.consumeChan)
 	close(m.publishChan)
@@ -73,6 +77,7 @@ func (m *MockPostgresConnection) Close() error {
 
 func TestCrawlerIntegration(t *testing.T) {
 	// 1. Mock GitHub API Server
+	activityBase := time.Now().UTC().Truncate(time.Second)
 	mockGitHubServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case r.URL.Path == "/repos/eugeneware/gifencoder/tags":
@@ -81,6 +86,25 @@ func TestCrawlerIntegration(t *testing.T) {
 		case r.URL.Path == "/repos/eugeneware/gifencoder/languages":
 			w.WriteHeader(http.StatusOK)
 			fmt.Fprintln(w, `{"JavaScript": 10000, "HTML": 500}`)
+		case r.URL.Path == "/repos/eugeneware/gifencoder/issues":
+			created := activityBase.Add(-48 * time.Hour).Format(time.RFC3339)
+			closed := activityBase.Add(-24 * time.Hour).Format(time.RFC3339)
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `[
+				{"number": 1, "user": {"login": "alice"}, "created_at": %q, "closed_at": %q},
+				{"number": 2, "user": {"login": "bob"}, "created_at": %q, "closed_at": %q, "pull_request": {"merged_at": %q}},
+				{"number": 3, "user": {"login": "carol"}, "created_at": %q, "closed_at": %q, "pull_request": {"merged_at": null}}
+			]`, created, closed, created, closed, closed, created, closed)
+		case r.URL.Path == "/repos/eugeneware/gifencoder/issues/comments":
+			first := activityBase.Add(-47 * time.Hour).Format(time.RFC3339)
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `[
+				{"issue_url": "https://api.github.com/repos/eugeneware/gifencoder/issues/1", "user": {"login": "alice"}, "created_at": %q},
+				{"issue_url": "https://api.github.com/repos/eugeneware/gifencoder/issues/1", "user": {"login": "eugeneware"}, "created_at": %q}
+			]`, first, first)
+		case r.URL.Path == "/repos/eugeneware/gifencoder/releases/latest":
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, `{"tag_name": "v1.0.0", "name": "First", "html_url": "https://github.com/eugeneware/gifencoder/releases/tag/v1.0.0", "published_at": "2025-06-01T12:00:00Z"}`)
 		default:
 			t.Errorf("Unexpected GitHub API request: %s", r.URL.Path)
 			w.WriteHeader(http.StatusNotFound)
@@ -88,39 +112,25 @@ func TestCrawlerIntegration(t *testing.T) {
 	}))
 	defer mockGitHubServer.Close()
 
-	// 2. Mock raw.githubusercontent.com HTTP HEAD requests
-	mockRawGitHubServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "HEAD" && r.URL.Path == "/eugeneware/gifencoder/master/README.md" {
-			w.WriteHeader(http.StatusOK)
-		} else {
-			t.Errorf("Unexpected raw.githubusercontent.com request: %s %s", r.Method, r.URL.Path)
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
-	defer mockRawGitHubServer.Close()
-
-	// Override http.DefaultClient for findReadme
-	// originalDefaultClient := http.DefaultClient
-	// http.DefaultClient = mockRawGitHubServer.Client()
-	// defer func() { http.DefaultClient = originalDefaultClient }()
-
-	// 3. Mock RabbitMQ
+	// 2. Mock RabbitMQ
 	mockMQConnection := NewMockRabbitMQConnection()
 
-	// 4. Mock PostgreSQL
+	// 3. Mock PostgreSQL
 	mockDBConnection := &MockPostgresConnection{
 		LastCrawlTime: time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC), // Older than PushedAt
 	}
 
-	// 5. Initialize GitHub Client with mock server URL
+	// 4. Initialize GitHub Client with mock server URL
 	mockGitHubClient := github.NewGitHubClient("mock_token", mockGitHubServer.Client())
 	mockGitHubClient.SetBaseURL(mockGitHubServer.URL)
+	mockGitHubClient.SetRequestDelay(0)
 
-	// 6. Prepare the input message
+	// 5. Prepare the input message
 	sampleRepo := models.Repository{
 		ID:           13329152,
 		FullName:     "eugeneware/gifencoder",
 		DefaultBranch: "master",
+		HasIssues:    true,
 		PushedAt:     time.Date(2025, time.June, 6, 7, 9, 34, 0, time.UTC),
 	}
 	discoveryMsg := models.DiscoveryMessage{
@@ -140,7 +150,7 @@ func TestCrawlerIntegration(t *testing.T) {
 
 	// Run the crawler in a goroutine
 	go func() {
-		err := runCrawler(mockMQConnection, mockDBConnection, mockGitHubClient, mockRawGitHubServer.Client(), mockRawGitHubServer.URL, "repos_to_crawl", "raw_data_to_process")
+		err := runCrawler(mockMQConnection, mockDBConnection, mockGitHubClient, "repos_to_crawl", "raw_data_to_process")
 		if err != nil {
 			t.Errorf("runCrawler returned an error: %v", err)
 		}
@@ -161,8 +171,8 @@ func TestCrawlerIntegration(t *testing.T) {
 		if crawlResult.Repository.FullName != sampleRepo.FullName {
 			t.Errorf("Expected repository FullName %s, got %s", sampleRepo.FullName, crawlResult.Repository.FullName)
 		}
-		if crawlResult.Repository.ReadmeURL.String != fmt.Sprintf("%s/eugeneware/gifencoder/master/README.md", mockRawGitHubServer.URL) {
-			t.Errorf("Expected ReadmeURL %s, got %s", fmt.Sprintf("%s/eugeneware/gifencoder/master/README.md", mockRawGitHubServer.URL), crawlResult.Repository.ReadmeURL.String)
+		if crawlResult.Repository.ReadmeURL.Valid {
+			t.Errorf("Expected the README to be left for the processor to resolve, got %s", crawlResult.Repository.ReadmeURL.String)
 		}
 		if len(crawlResult.Repository.Tags) != 2 || crawlResult.Repository.Tags[0] != "v1.0.0" {
 			t.Errorf("Expected tags [v1.0.0, v0.9.0], got %v", crawlResult.Repository.Tags)
@@ -170,6 +180,25 @@ func TestCrawlerIntegration(t *testing.T) {
 		if crawlResult.Repository.Languages["JavaScript"] != 10000 || crawlResult.Repository.Languages["HTML"] != 500 {
 			t.Errorf("Expected languages {\"JavaScript\": 10000, \"HTML\": 500}, got %v", crawlResult.Repository.Languages)
 		}
+		if release := crawlResult.Repository.LatestRelease; release == nil || release.TagName != "v1.0.0" || release.PublishedAt.IsZero() {
+			t.Errorf("Expected the latest release v1.0.0, got %+v", release)
+		}
+		activity := crawlResult.Repository.Activity
+		if activity == nil {
+			t.Fatal("Expected issue activity to be collected")
+		}
+		if activity.IssuesOpened != 1 || activity.IssuesClosed != 1 {
+			t.Errorf("Expected 1 issue opened and closed, got %d opened and %d closed", activity.IssuesOpened, activity.IssuesClosed)
+		}
+		if activity.PullRequestsOpened != 2 || activity.PullRequestsClosed != 2 || activity.PullRequestsMerged != 1 {
+			t.Errorf("Expected 2 PRs opened, 2 closed and 1 merged, got %d, %d and %d", activity.PullRequestsOpened, activity.PullRequestsClosed, activity.PullRequestsMerged)
+		}
+		if activity.MergeRate != 0.5 {
+			t.Errorf("Expected merge rate 0.5, got %f", activity.MergeRate)
+		}
+		if activity.MedianFirstResponseSeconds != 3600 {
+			t.Errorf("Expected median first response of 3600 seconds, got %f", activity.MedianFirstResponseSeconds)
+		}
 		if crawlResult.DiscoveredAt != discoveryMsg.DiscoveredAt {
 			t.Errorf("Expected DiscoveredAt %v, got %v", discoveryMsg.DiscoveredAt, crawlResult.DiscoveredAt)
 		}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/digest"
+	"github.com/teomiscia/github-trending/internal/mailer"
+)
+
+const (
+	maxRetries = 5
+	retryDelay = 5 * time.Second
+
+	// Due digests are sent every sendInterval.
+	sendInterval = 15 * time.Minute
+)
+
+// The digest service emails the daily and weekly digests of the top trending
+// repositories to their subscribers.
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.SMTPHost == "" {
+		log.Fatal("SMTP_HOST is required to email digests")
+	}
+
+	var pgConnection *database.PostgresConnection
+	for i := 0; i < maxRetries; i++ {
+		pgConnection, err = database.NewPostgresConnection(cfg.PostgresHost, "5432", cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresDB)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to PostgreSQL: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL after %d retries: %v", maxRetries, err)
+	}
+	defer pgConnection.DB.Close()
+
+	var chConnection *database.ClickHouseConnection
+	for i := 0; i < maxRetries; i++ {
+		chConnection, err = database.NewClickHouseConnection(cfg.ClickHouseHost, cfg.ClickHousePort, cfg.ClickHouseUser, cfg.ClickHousePassword, cfg.ClickHouseDB)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to ClickHouse: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to ClickHouse after %d retries: %v", maxRetries, err)
+	}
+	defer chConnection.DB.Close()
+
+	emails := mailer.New(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	sender := digest.NewSender(pgConnection, chConnection, emails, cfg.PublicAPIURL)
+
+	log.Printf("Digest service started. Sending due digests every %v", sendInterval)
+
+	send(sender)
+	ticker := time.NewTicker(sendInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		send(sender)
+	}
+}
+
+func send(sender *digest.Sender) {
+	n, err := sender.Run()
+	if err != nil {
+		log.Printf("Failed to send digests: %v", err)
+	}
+	if n > 0 {
+		log.Printf("Sent %d digests", n)
+	}
+}
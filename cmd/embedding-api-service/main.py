@@ -1,3 +1,5 @@
+from typing import List
+
 from fastapi import FastAPI, HTTPException
 from pydantic import BaseModel
 from sentence_transformers import SentenceTransformer
@@ -5,12 +7,17 @@ import gc
 
 app = FastAPI()
 
+MODEL_NAME = 'all-MiniLM-L6-v2'
+
 # Load the pre-trained model at startup
-model = SentenceTransformer('all-MiniLM-L6-v2')
+model = SentenceTransformer(MODEL_NAME)
 
 class EmbedRequest(BaseModel):
     text: str
 
+class EmbedBatchRequest(BaseModel):
+    texts: List[str]
+
 @app.get("/health")
 def health_check():
     return {"status": "ok"}
@@ -21,9 +28,23 @@ def create_embedding(request: EmbedRequest):
         # Generate embedding
         embedding = model.encode(request.text, convert_to_tensor=False).tolist()
 
-        return {"embedding": embedding}
+        return {"embedding": embedding, "model": MODEL_NAME}
     except Exception as e:
         raise HTTPException(status_code=500, detail=str(e))
     finally:
         # Explicitly trigger garbage collection to free up memory.
         gc.collect()
+
+@app.post("/embed_batch")
+def create_embeddings(request: EmbedBatchRequest):
+    if not request.texts:
+        raise HTTPException(status_code=400, detail="texts must not be empty")
+    try:
+        # Encoding the texts together lets the model batch them on the device.
+        embeddings = model.encode(request.texts, convert_to_tensor=False).tolist()
+
+        return {"embeddings": embeddings, "model": MODEL_NAME}
+    except Exception as e:
+        raise HTTPException(status_code=500, detail=str(e))
+    finally:
+        gc.collect()
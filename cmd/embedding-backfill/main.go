@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/messaging"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+const (
+	embedQueueName = "readme_to_embed"
+	pageSize       = 500
+	pollInterval   = time.Minute
+	maxRetries     = 5
+	retryDelay     = 5 * time.Second
+)
+
+// The backfill job re-embeds every README from MinIO into the collections of the
+// configured embedding version while the aliases keep serving the previous version.
+// Once enough repositories have been re-embedded it switches the aliases and drops the
+// collections they served from.
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	var mqConnection messaging.MQConnection
+	for i := 0; i < maxRetries; i++ {
+		mqConnection, err = messaging.NewConnection(cfg.RabbitMQURL)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to RabbitMQ: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to RabbitMQ after %d retries: %v", maxRetries, err)
+	}
+	defer mqConnection.Close()
+
+	pgConnection, err := database.NewPostgresConnection(cfg.PostgresHost, "5432", cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresDB)
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pgConnection.DB.Close()
+
+	qdrantConnection, err := database.NewQdrantConnection(cfg.QdrantHost, cfg.QdrantPort)
+	if err != nil {
+		log.Fatalf("Failed to connect to Qdrant: %v", err)
+	}
+	defer qdrantConnection.Close()
+
+	ctx := context.Background()
+	for _, base := range database.AliasCollections {
+		collection := database.VersionedCollection(base, cfg.EmbeddingVersion)
+		if err := qdrantConnection.CreateCollection(ctx, collection, uint64(cfg.EmbeddingVectorSize)); err != nil {
+			log.Fatalf("Failed to create or verify Qdrant collection %s: %v", collection, err)
+		}
+	}
+
+	target := database.VersionedCollection(database.RepositoriesCollection, cfg.EmbeddingVersion)
+	current, err := qdrantConnection.GetAliasTarget(ctx, database.RepositoriesAlias)
+	if err != nil {
+		log.Fatalf("Failed to look up Qdrant alias %s: %v", database.RepositoriesAlias, err)
+	}
+	if current == target {
+		log.Printf("Alias %s already points at %s, nothing to backfill.", database.RepositoriesAlias, target)
+		return
+	}
+
+	queued, err := enqueueBackfill(mqConnection, pgConnection, cfg.EmbeddingVersion)
+	if err != nil {
+		log.Fatalf("Failed to enqueue backfill: %v", err)
+	}
+	log.Printf("Queued %d READMEs for embedding at version %d.", queued, cfg.EmbeddingVersion)
+
+	// Wait for the embedding service to catch up before switching readers over. The
+	// crawler keeps adding READMEs, so the new collections are never quite complete.
+	for {
+		embedded, total, err := pgConnection.GetEmbeddingVersionCoverage(cfg.EmbeddingVersion)
+		if err != nil {
+			log.Printf("Failed to get embedding coverage: %v", err)
+		} else {
+			log.Printf("Embedding version %d coverage: %d/%d", cfg.EmbeddingVersion, embedded, total)
+			if coverageReached(embedded, total, cfg.EmbeddingAliasThreshold) {
+				break
+			}
+		}
+		time.Sleep(pollInterval)
+	}
+
+	// Each alias is repointed in a single update, and the collection it served from is
+	// dropped only once nothing reads it.
+	for alias, base := range database.AliasCollections {
+		collection := database.VersionedCollection(base, cfg.EmbeddingVersion)
+		previous, err := qdrantConnection.SwitchAlias(ctx, alias, collection)
+		if err != nil {
+			log.Fatalf("Failed to switch Qdrant alias %s to %s: %v", alias, collection, err)
+		}
+		log.Printf("Switched Qdrant alias %s to %s.", alias, collection)
+		if previous == "" || previous == collection {
+			continue
+		}
+		if err := qdrantConnection.DeleteCollection(ctx, previous); err != nil {
+			log.Printf("Failed to delete Qdrant collection %s: %v", previous, err)
+			continue
+		}
+		log.Printf("Deleted Qdrant collection %s.", previous)
+	}
+}
+
+// coverageReached reports whether the share of repositories embedded at the new
+// version reached the threshold. No repository at all counts as covered.
+func coverageReached(embedded, total int, threshold float64) bool {
+	if total == 0 {
+		return true
+	}
+	return float64(embedded)/float64(total) >= threshold
+}
+
+// enqueueBackfill publishes an embed message for every repository not yet embedded at
+// the given version. The messages carry no content hash, so they are always embedded.
+func enqueueBackfill(mqConnection messaging.MQConnection, pgConnection *database.PostgresConnection, version int) (int, error) {
+	queued := 0
+	var afterID int64
+	for {
+		candidates, err := pgConnection.GetReembedCandidates(version, afterID, pageSize)
+		if err != nil {
+			return queued, fmt.Errorf("failed to get repositories to re-embed: %w", err)
+		}
+		if len(candidates) == 0 {
+			return queued, nil
+		}
+
+		for _, candidate := range candidates {
+			embedMsg := models.ReadmeEmbedMessage{
+				RepositoryID: candidate.RepositoryID,
+				MinioPath:    fmt.Sprintf("readmes/%d.md", candidate.RepositoryID),
+				Format:       candidate.ReadmeFormat,
+			}
+			embedMsgJSON, err := json.Marshal(embedMsg)
+			if err != nil {
+				return queued, fmt.Errorf("failed to marshal embed message for %d: %w", candidate.RepositoryID, err)
+			}
+			if err := mqConnection.Publish(embedQueueName, embedMsgJSON); err != nil {
+				return queued, fmt.Errorf("failed to publish embed message for %d: %w", candidate.RepositoryID, err)
+			}
+			queued++
+		}
+		afterID = candidates[len(candidates)-1].RepositoryID
+	}
+}
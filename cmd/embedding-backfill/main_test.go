@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestCoverageReached(t *testing.T) {
+	tests := []struct {
+		embedded, total int
+		want            bool
+	}{
+		{0, 0, true},
+		{94, 100, false},
+		{95, 100, true},
+		{100, 100, true},
+		// A few failed embeddings do not hold the switch back.
+		{9500, 9990, true},
+	}
+	for _, tt := range tests {
+		if got := coverageReached(tt.embedded, tt.total, 0.95); got != tt.want {
+			t.Errorf("coverageReached(%d, %d, 0.95) = %v, want %v", tt.embedded, tt.total, got, tt.want)
+		}
+	}
+}
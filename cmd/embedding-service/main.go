@@ -1,31 +1,55 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"runtime"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	qdrant_go_client "github.com/qdrant/go-client/qdrant"
 	"github.com/streadway/amqp"
 	"github.com/teomiscia/github-trending/internal/config"
 	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/embedding"
 	"github.com/teomiscia/github-trending/internal/messaging"
 	"github.com/teomiscia/github-trending/internal/models"
+	"github.com/teomiscia/github-trending/internal/readme"
 )
 
 const (
-	numWorkers = 20
-	queueName  = "readme_to_embed"
-	maxRetries = 5
-	retryDelay = 5 * time.Second
+	numWorkers          = 20
+	queueName           = "readme_to_embed"
+	deadLetterQueueName = "readme_to_embed_dead"
+	updatedQueueName    = "embedding_updated"
+	maxRetries          = 5
+	retryDelay          = 5 * time.Second
+	embeddingAPIURL     = "http://embedding-api-service"
+
+	// batchSize READMEs are embedded together; a partial batch is processed once
+	// batchWait has passed since its first message.
+	batchSize = 8
+	// maxTextsPerRequest caps the number of chunks sent in one embedding API call.
+	maxTextsPerRequest = 32
+	// maxDeliveryAttempts is how many times a message may fail transiently before it
+	// is dead-lettered.
+	maxDeliveryAttempts = 5
 )
 
+// batchWait is replaced in tests.
+var batchWait = 2 * time.Second
+
+// chunkIDNamespace derives stable point IDs for README chunks, so re-embedding a
+// README overwrites its chunks in place.
+var chunkIDNamespace = uuid.MustParse("48e40cdc-95ed-47b9-8774-63d4856443c5")
+
 func main() {
 	cfg, err := config.Load()
 	if err != nil {
@@ -79,18 +103,43 @@ func main() {
 	}
 	defer qdrantConnection.Close()
 
-	const vectorSize = 384
-	err = qdrantConnection.CreateCollection(context.Background(), "repositories", vectorSize)
+	// Embeddings are written to the collections of the configured version. On a fresh
+	// install the aliases readers use are pointed at them; otherwise the aliases keep
+	// serving the previous version until the backfill job switches them.
+	for alias, base := range database.AliasCollections {
+		collection := database.VersionedCollection(base, cfg.EmbeddingVersion)
+		if err := qdrantConnection.CreateCollection(context.Background(), collection, uint64(cfg.EmbeddingVectorSize)); err != nil {
+			log.Fatalf("Failed to create or verify Qdrant collection %s: %v", collection, err)
+		}
+		if err := qdrantConnection.EnsureAlias(context.Background(), alias, base, collection); err != nil {
+			log.Fatalf("Failed to create Qdrant alias %s: %v", alias, err)
+		}
+	}
+
+	// Retries and dead letters are published on their own connection, since the
+	// consuming connection is replaced whenever it drops.
+	var publisher messaging.MQConnection
+	for i := 0; i < maxRetries; i++ {
+		publisher, err = messaging.NewConnection(cfg.RabbitMQURL)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to RabbitMQ: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
 	if err != nil {
-		log.Fatalf("Failed to create or verify Qdrant collection: %v", err)
+		log.Fatalf("Failed to connect to RabbitMQ after %d retries: %v", maxRetries, err)
 	}
+	defer publisher.Close()
 
-	jobs := make(chan amqp.Delivery, numWorkers)
+	embedder := embedding.NewClient(embeddingAPIURL, nil)
+
+	jobs := make(chan amqp.Delivery, numWorkers*batchSize)
 	var wg sync.WaitGroup
 
 	for i := 1; i <= numWorkers; i++ {
 		wg.Add(1)
-		go worker(i, &wg, jobs, pgConnection, minioConnection, qdrantConnection)
+		go worker(i, &wg, jobs, cfg.EmbeddingVersion, publisher, pgConnection, minioConnection, qdrantConnection, embedder)
 	}
 
 	log.Printf("Embedding service started. Waiting for messages on queue: %s", queueName)
@@ -147,109 +196,358 @@ func main() {
 	}
 }
 
-func worker(id int, wg *sync.WaitGroup, jobs <-chan amqp.Delivery, pgConnection *database.PostgresConnection, minioConnection *database.MinioConnection, qdrantConnection *database.QdrantConnection) {
+// embedJob is a README message being embedded as part of a batch.
+type embedJob struct {
+	delivery   amqp.Delivery
+	msg        models.ReadmeEmbedMessage
+	content    []byte
+	chunks     []readme.Chunk
+	embeddings [][]float32
+	model      string
+	err        error
+}
+
+func worker(id int, wg *sync.WaitGroup, jobs <-chan amqp.Delivery, version int, publisher messaging.MQConnection, pgConnection *database.PostgresConnection, minioConnection *database.MinioConnection, qdrantConnection *database.QdrantConnection, embedder *embedding.Client) {
 	defer wg.Done()
-	for j := range jobs {
-		var embedMsg models.ReadmeEmbedMessage
-		if err := json.Unmarshal(j.Body, &embedMsg); err != nil {
-			log.Printf("Worker %d: Failed to unmarshal message: %v", id, err)
-			j.Nack(false, false) // Nack and don't requeue
-			continue
+	for {
+		batch, open := nextBatch(jobs)
+		if len(batch) > 0 {
+			processBatch(id, batch, version, publisher, pgConnection, minioConnection, qdrantConnection, embedder)
 		}
+		if !open {
+			return
+		}
+	}
+}
 
-		log.Printf("Worker %d: Received message for repository ID: %d", id, embedMsg.RepositoryID)
-		processMessage(id, embedMsg, pgConnection, minioConnection, qdrantConnection)
-		j.Ack(false) // Ack the message
-		log.Printf("Worker %d: Finished processing message for repository ID: %d", id, embedMsg.RepositoryID)
+// nextBatch blocks until a delivery arrives, then collects more until the batch is full
+// or batchWait has passed. It reports false once jobs is closed.
+func nextBatch(jobs <-chan amqp.Delivery) ([]amqp.Delivery, bool) {
+	d, ok := <-jobs
+	if !ok {
+		return nil, false
+	}
+	batch := []amqp.Delivery{d}
+	timeout := time.After(batchWait)
+	for len(batch) < batchSize {
+		select {
+		case d, ok := <-jobs:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, d)
+		case <-timeout:
+			return batch, true
+		}
 	}
+	return batch, true
 }
 
-func processMessage(
+func processBatch(
 	id int,
-	embedMsg models.ReadmeEmbedMessage,
+	deliveries []amqp.Delivery,
+	version int,
+	publisher messaging.MQConnection,
 	pgConnection *database.PostgresConnection,
 	minioConnection *database.MinioConnection,
 	qdrantConnection *database.QdrantConnection,
+	embedder *embedding.Client,
 ) {
-	// Explicitly nil the slice after use to help the GC
-	var readmeContent []byte
-	defer func() {
-		readmeContent = nil
-		runtime.GC()
-	}()
+	// READMEs can be large; release them as soon as the batch is done
+	defer runtime.GC()
+
+	var pending []*embedJob
+	for _, d := range deliveries {
+		var embedMsg models.ReadmeEmbedMessage
+		if err := json.Unmarshal(d.Body, &embedMsg); err != nil {
+			log.Printf("Worker %d: Failed to unmarshal message: %v", id, err)
+			d.Nack(false, false) // Nack and don't requeue
+			continue
+		}
+		log.Printf("Worker %d: Received message for repository ID: %d", id, embedMsg.RepositoryID)
+		job := &embedJob{delivery: d, msg: embedMsg}
 
+		// Skip the embedding call entirely when the README has not changed since it was
+		// last embedded at this version. Messages without a hash (e.g. backfills) are
+		// always embedded.
+		if embedMsg.ContentHash != "" {
+			embeddedHash, embeddedVersion, err := pgConnection.GetEmbeddedReadme(embedMsg.RepositoryID)
+			if err != nil {
+				log.Printf("Worker %d: Failed to get README hash for repo %d: %v", id, embedMsg.RepositoryID, err)
+			} else if embeddedHash == embedMsg.ContentHash && embeddedVersion == version {
+				log.Printf("Worker %d: README for repo %d is unchanged, skipping embedding.", id, embedMsg.RepositoryID)
+				d.Ack(false)
+				continue
+			}
+		}
+
+		job.content, job.err = loadReadme(id, embedMsg, minioConnection)
+		if job.err != nil {
+			handleFailure(id, job, publisher, pgConnection)
+			continue
+		}
+
+		// The embedding model truncates long inputs, so each section of the README is
+		// embedded separately and the repository vector is their length-weighted mean.
+		format := embedMsg.Format
+		if format == "" {
+			format = readme.FormatMarkdown
+		}
+		job.chunks = readme.Chunks(format, job.content, readme.DefaultChunkSize)
+		if len(job.chunks) == 0 {
+			log.Printf("Worker %d: README for repo %d has no text to embed", id, embedMsg.RepositoryID)
+			if err := pgConnection.RecordEmbeddingFailure(embedMsg.RepositoryID, database.EmbeddingStatusEmpty, "README has no text to embed"); err != nil {
+				log.Printf("Worker %d: Failed to record embedding status for repo %d: %v", id, embedMsg.RepositoryID, err)
+			}
+			d.Ack(false)
+			continue
+		}
+		pending = append(pending, job)
+	}
+
+	embedChunks(context.Background(), pending, embedder)
+
+	for _, job := range pending {
+		repoID := job.msg.RepositoryID
+		if job.err == nil {
+			job.err = storeEmbeddings(job, version, qdrantConnection)
+		}
+		if job.err != nil {
+			handleFailure(id, job, publisher, pgConnection)
+			continue
+		}
+
+		if err := pgConnection.UpdateReadmeHash(repoID, database.ContentHash(job.content)); err != nil {
+			log.Printf("Worker %d: Failed to record README hash for repo %d: %v", id, repoID, err)
+		}
+		if err := pgConnection.RecordEmbeddingSuccess(repoID, job.model, version, len(job.chunks)); err != nil {
+			log.Printf("Worker %d: Failed to record embedding status for repo %d: %v", id, repoID, err)
+		}
+		publishEmbeddingUpdated(id, repoID, version, publisher)
+		job.delivery.Ack(false)
+		log.Printf("Worker %d: Successfully processed and embedded README for repository ID: %d", id, repoID)
+	}
+}
+
+// publishEmbeddingUpdated notifies the similarity engine that a repository's vectors
+// changed. A lost event only delays the update until the next full sweep, so errors are
+// logged rather than failing the job.
+func publishEmbeddingUpdated(id int, repoID int64, version int, publisher messaging.MQConnection) {
+	body, err := json.Marshal(models.EmbeddingUpdatedMessage{RepositoryID: repoID, Version: version})
+	if err != nil {
+		log.Printf("Worker %d: Failed to marshal embedding updated event for repo %d: %v", id, repoID, err)
+		return
+	}
+	if err := publisher.Publish(updatedQueueName, body); err != nil {
+		log.Printf("Worker %d: Failed to publish embedding updated event for repo %d: %v", id, repoID, err)
+	}
+}
+
+// loadReadme reads the README from MinIO, falling back to its download URL and caching
+// the download in MinIO. A README that cannot be located at all is a permanent failure.
+func loadReadme(id int, embedMsg models.ReadmeEmbedMessage, minioConnection *database.MinioConnection) ([]byte, error) {
 	readmeContent, err := minioConnection.GetFile(context.Background(), embedMsg.MinioPath)
+	if err == nil {
+		return readmeContent, nil
+	}
+	log.Printf("Worker %d: Failed to get README from MinIO for %s: %v", id, embedMsg.MinioPath, err)
+	if embedMsg.DownloadURL == "" {
+		return nil, fmt.Errorf("%w: no README URL available for repo %d", embedding.ErrPermanent, embedMsg.RepositoryID)
+	}
+
+	log.Printf("Worker %d: Attempting to download README from %s", id, embedMsg.DownloadURL)
+	resp, err := http.Get(embedMsg.DownloadURL)
 	if err != nil {
-		log.Printf("Worker %d: Failed to get README from MinIO for %s: %v", id, embedMsg.MinioPath, err)
-		if embedMsg.DownloadURL != "" {
-			log.Printf("Worker %d: Attempting to download README from %s", id, embedMsg.DownloadURL)
-			resp, httpErr := http.Get(embedMsg.DownloadURL)
-			if httpErr != nil || resp.StatusCode != http.StatusOK {
-				log.Printf("Worker %d: Failed to download README from URL %s: %v", id, embedMsg.DownloadURL, httpErr)
-				return
+		return nil, fmt.Errorf("failed to download README from URL %s: %w", embedMsg.DownloadURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: README at %s no longer exists", embedding.ErrPermanent, embedMsg.DownloadURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download README from URL %s: status %d", embedMsg.DownloadURL, resp.StatusCode)
+	}
+	readmeContent, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read downloaded README content: %w", err)
+	}
+
+	if _, err := minioConnection.UploadFileWithHash(context.Background(), embedMsg.MinioPath, readmeContent, readme.ContentType(embedMsg.Format)); err != nil {
+		log.Printf("Worker %d: Failed to upload downloaded README to MinIO for %s: %v", id, embedMsg.MinioPath, err)
+	} else {
+		log.Printf("Worker %d: Successfully cached README for %s in MinIO", id, embedMsg.MinioPath)
+	}
+	return readmeContent, nil
+}
+
+// embedChunks embeds the chunks of every job, packing chunks from several READMEs into
+// each API call. A failed call fails every job that had a chunk in it.
+func embedChunks(ctx context.Context, jobs []*embedJob, embedder *embedding.Client) {
+	var texts []string
+	var owners []*embedJob
+	flush := func() {
+		if len(texts) == 0 {
+			return
+		}
+		result, err := embedder.EmbedBatch(ctx, texts)
+		for i, job := range owners {
+			if job.err != nil {
+				continue
 			}
-			defer resp.Body.Close()
-			readmeContent, err = io.ReadAll(resp.Body)
 			if err != nil {
-				log.Printf("Worker %d: Failed to read downloaded README content: %v", id, err)
-				return
+				job.err = err
+				continue
 			}
-			reader := bytes.NewReader(readmeContent)
-			_, err := minioConnection.UploadFile(context.Background(), embedMsg.MinioPath, reader, int64(len(readmeContent)), "text/markdown")
-			if err != nil {
-				log.Printf("Worker %d: Failed to upload downloaded README to MinIO for %s: %v", id, embedMsg.MinioPath, err)
-			} else {
-				log.Printf("Worker %d: Successfully cached README for %s in MinIO", id, embedMsg.MinioPath)
+			job.embeddings = append(job.embeddings, result.Embeddings[i])
+			job.model = result.Model
+		}
+		texts, owners = nil, nil
+	}
+
+	for _, job := range jobs {
+		for _, chunk := range job.chunks {
+			texts = append(texts, chunk.Text)
+			owners = append(owners, job)
+			if len(texts) == maxTextsPerRequest {
+				flush()
 			}
-		} else {
-			log.Printf("Worker %d: No README URL available for repo %d, skipping embedding.", id, embedMsg.RepositoryID)
-			return
 		}
 	}
+	flush()
+}
 
-	embedding := generateEmbedding(readmeContent)
+// storeEmbeddings replaces the README chunks of the job's repository in the Qdrant
+// collections of the given version and upserts the aggregated repository vector.
+func storeEmbeddings(job *embedJob, version int, qdrantConnection *database.QdrantConnection) error {
+	repoID := job.msg.RepositoryID
+	repositoriesCollection := database.VersionedCollection(database.RepositoriesCollection, version)
+	chunksCollection := database.VersionedCollection(database.ReadmeChunksCollection, version)
+	attributes := attributesPayload(job.msg, repositoriesCollection, qdrantConnection)
+
+	chunkPoints := make([]*qdrant_go_client.PointStruct, 0, len(job.chunks))
+	weights := make([]float64, 0, len(job.chunks))
+	for i, chunk := range job.chunks {
+		weights = append(weights, float64(len(chunk.Text)))
+		pointID := uuid.NewSHA1(chunkIDNamespace, []byte(fmt.Sprintf("%d:%d", repoID, i)))
+		payload := map[string]*qdrant_go_client.Value{
+			database.PayloadRepositoryID: {Kind: &qdrant_go_client.Value_IntegerValue{IntegerValue: repoID}},
+			"heading":                    {Kind: &qdrant_go_client.Value_StringValue{StringValue: chunk.Heading}},
+			"offset":                     {Kind: &qdrant_go_client.Value_IntegerValue{IntegerValue: int64(chunk.Offset)}},
+			"chunk_index":                {Kind: &qdrant_go_client.Value_IntegerValue{IntegerValue: int64(i)}},
+		}
+		for key, value := range attributes {
+			payload[key] = value
+		}
+		chunkPoints = append(chunkPoints, &qdrant_go_client.PointStruct{
+			Id:      &qdrant_go_client.PointId{PointIdOptions: &qdrant_go_client.PointId_Uuid{Uuid: pointID.String()}},
+			Vectors: &qdrant_go_client.Vectors{VectorsOptions: &qdrant_go_client.Vectors_Vector{Vector: &qdrant_go_client.Vector{Data: job.embeddings[i]}}},
+			Payload: payload,
+		})
+	}
+
+	// Drop the chunks of the previous README version first; it may have had more sections.
+	if err := qdrantConnection.DeleteRepositoryPoints(context.Background(), chunksCollection, repoID); err != nil {
+		return fmt.Errorf("failed to delete old README chunks from Qdrant: %w", err)
+	}
+	if err := qdrantConnection.UpsertVectors(context.Background(), chunksCollection, chunkPoints); err != nil {
+		return fmt.Errorf("failed to insert README chunks into Qdrant: %w", err)
+	}
 
 	points := []*qdrant_go_client.PointStruct{
 		{
-			Id:      &qdrant_go_client.PointId{PointIdOptions: &qdrant_go_client.PointId_Num{Num: uint64(embedMsg.RepositoryID)}},
-			Vectors: &qdrant_go_client.Vectors{VectorsOptions: &qdrant_go_client.Vectors_Vector{Vector: &qdrant_go_client.Vector{Data: embedding}}},
+			Id:      &qdrant_go_client.PointId{PointIdOptions: &qdrant_go_client.PointId_Num{Num: uint64(repoID)}},
+			Vectors: &qdrant_go_client.Vectors{VectorsOptions: &qdrant_go_client.Vectors_Vector{Vector: &qdrant_go_client.Vector{Data: aggregateEmbeddings(job.embeddings, weights)}}},
+			Payload: attributes,
 		},
 	}
-	if err := qdrantConnection.UpsertVectors(context.Background(), "repositories", points); err != nil {
-		log.Printf("Worker %d: Failed to insert embedding for repo %d into Qdrant: %v", id, embedMsg.RepositoryID, err)
-		return
+	if err := qdrantConnection.UpsertVectors(context.Background(), repositoriesCollection, points); err != nil {
+		return fmt.Errorf("failed to insert embedding into Qdrant: %w", err)
+	}
+	return nil
+}
+
+// attributesPayload returns the repository attribute payload for the job's points. The
+// processor sends the attributes along; other messages (backfills, re-embeds requested
+// by the similarity engine) keep the payload stored in the collection being written,
+// or in the serving collection, which the writer keeps in sync.
+func attributesPayload(embedMsg models.ReadmeEmbedMessage, repositoriesCollection string, qdrantConnection *database.QdrantConnection) map[string]*qdrant_go_client.Value {
+	if embedMsg.Attributes != nil {
+		return database.AttributesPayload(*embedMsg.Attributes)
+	}
+	for _, collection := range []string{repositoriesCollection, database.RepositoriesAlias} {
+		payloads, err := qdrantConnection.GetPayloads(context.Background(), collection, []uint64{uint64(embedMsg.RepositoryID)})
+		if err != nil {
+			log.Printf("Failed to get stored payload for repo %d from %s: %v", embedMsg.RepositoryID, collection, err)
+			continue
+		}
+		if payload, ok := payloads[uint64(embedMsg.RepositoryID)]; ok && len(payload) > 1 {
+			delete(payload, database.PayloadID)
+			return payload
+		}
 	}
+	return map[string]*qdrant_go_client.Value{}
+}
 
-	log.Printf("Worker %d: Successfully processed and embedded README for repository ID: %d", id, embedMsg.RepositoryID)
+// embeddingStatusRecorder records failed embeddings; it is implemented by
+// database.PostgresConnection.
+type embeddingStatusRecorder interface {
+	RecordEmbeddingFailure(repoID int64, status, reason string) error
 }
 
-func generateEmbedding(content []byte) []float32 {
-	requestBody, err := json.Marshal(map[string]string{
-		"text": string(content),
-	})
-	if err != nil {
-		log.Printf("Failed to marshal request body: %v", err)
-		return nil
+// handleFailure records a failed job and settles its delivery. Transient failures are
+// republished with an incremented attempt count; permanent failures and jobs that ran
+// out of attempts go to the dead-letter queue. If publishing fails the delivery is
+// requeued as is.
+func handleFailure(id int, job *embedJob, publisher messaging.MQConnection, pgConnection embeddingStatusRecorder) {
+	repoID := job.msg.RepositoryID
+	log.Printf("Worker %d: Failed to embed README for repo %d (attempt %d): %v", id, repoID, job.msg.Attempts+1, job.err)
+	if err := pgConnection.RecordEmbeddingFailure(repoID, database.EmbeddingStatusFailed, job.err.Error()); err != nil {
+		log.Printf("Worker %d: Failed to record embedding status for repo %d: %v", id, repoID, err)
 	}
 
-	resp, err := http.Post("http://embedding-api-service/embed", "application/json", bytes.NewBuffer(requestBody))
+	target := queueName
+	if errors.Is(job.err, embedding.ErrPermanent) || job.msg.Attempts+1 >= maxDeliveryAttempts {
+		target = deadLetterQueueName
+	}
+	retryMsg := job.msg
+	retryMsg.Attempts++
+	body, err := json.Marshal(retryMsg)
+	if err == nil {
+		err = publisher.Publish(target, body)
+	}
 	if err != nil {
-		log.Printf("Failed to call embedding API: %v", err)
-		return nil
+		log.Printf("Worker %d: Failed to publish message for repo %d to %s: %v. Requeueing.", id, repoID, target, err)
+		job.delivery.Nack(false, true)
+		return
 	}
-	defer resp.Body.Close()
+	if target == deadLetterQueueName {
+		log.Printf("Worker %d: Dead-lettered message for repo %d", id, repoID)
+	}
+	job.delivery.Ack(false)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		log.Printf("Embedding API returned non-OK status: %d, body: %s", resp.StatusCode, string(bodyBytes))
-		return nil
+// aggregateEmbeddings returns the weighted mean of the given vectors, normalised to
+// unit length so that cosine scores stay comparable with single-chunk READMEs.
+func aggregateEmbeddings(embeddings [][]float32, weights []float64) []float32 {
+	sum := make([]float64, len(embeddings[0]))
+	for i, embedding := range embeddings {
+		for j, v := range embedding {
+			sum[j] += weights[i] * float64(v)
+		}
 	}
 
-	var result map[string][]float32
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("Failed to decode embedding response: %v", err)
-		return nil
+	var norm float64
+	for _, v := range sum {
+		norm += v * v
 	}
+	norm = math.Sqrt(norm)
 
-	return result["embedding"]
+	aggregated := make([]float32, len(sum))
+	for j, v := range sum {
+		if norm > 0 {
+			v /= norm
+		}
+		aggregated[j] = float32(v)
+	}
+	return aggregated
 }
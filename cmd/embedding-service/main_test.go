@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/teomiscia/github-trending/internal/embedding"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// fakeAcknowledger records how deliveries are settled.
+type fakeAcknowledger struct {
+	acked    []uint64
+	nacked   []uint64
+	requeued []bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked = append(a.nacked, tag)
+	a.requeued = append(a.requeued, requeue)
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+// fakePublisher records published messages by queue.
+type fakePublisher struct {
+	published map[string][][]byte
+	err       error
+}
+
+func (p *fakePublisher) Consume(string) (<-chan amqp.Delivery, error) { return nil, nil }
+func (p *fakePublisher) Close() error                                 { return nil }
+func (p *fakePublisher) NotifyClose(c chan *amqp.Error) chan *amqp.Error {
+	return c
+}
+
+func (p *fakePublisher) Publish(queueName string, body []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.published == nil {
+		p.published = make(map[string][][]byte)
+	}
+	p.published[queueName] = append(p.published[queueName], body)
+	return nil
+}
+
+// fakeRecorder records embedding failures.
+type fakeRecorder struct {
+	failures []string
+}
+
+func (r *fakeRecorder) RecordEmbeddingFailure(repoID int64, status, reason string) error {
+	r.failures = append(r.failures, fmt.Sprintf("%d:%s", repoID, status))
+	return nil
+}
+
+func deliveries(n int, ack *fakeAcknowledger) chan amqp.Delivery {
+	jobs := make(chan amqp.Delivery, n)
+	for i := 1; i <= n; i++ {
+		jobs <- amqp.Delivery{Acknowledger: ack, DeliveryTag: uint64(i)}
+	}
+	return jobs
+}
+
+func TestNextBatchFlushesFullBatch(t *testing.T) {
+	batchWait = time.Hour
+	defer func() { batchWait = 2 * time.Second }()
+
+	jobs := deliveries(batchSize+1, &fakeAcknowledger{})
+	batch, open := nextBatch(jobs)
+	if len(batch) != batchSize || !open {
+		t.Fatalf("nextBatch() = %d deliveries, open %v; want %d, true", len(batch), open, batchSize)
+	}
+	if batch[0].DeliveryTag != 1 || batch[batchSize-1].DeliveryTag != batchSize {
+		t.Errorf("Expected deliveries 1 to %d in order, got %d to %d", batchSize, batch[0].DeliveryTag, batch[batchSize-1].DeliveryTag)
+	}
+}
+
+func TestNextBatchFlushesPartialBatchAfterWait(t *testing.T) {
+	batchWait = 10 * time.Millisecond
+	defer func() { batchWait = 2 * time.Second }()
+
+	jobs := deliveries(2, &fakeAcknowledger{})
+	start := time.Now()
+	batch, open := nextBatch(jobs)
+	if len(batch) != 2 || !open {
+		t.Fatalf("nextBatch() = %d deliveries, open %v; want 2, true", len(batch), open)
+	}
+	if elapsed := time.Since(start); elapsed < batchWait {
+		t.Errorf("Expected the partial batch to wait %v, returned after %v", batchWait, elapsed)
+	}
+}
+
+func TestNextBatchReportsClosedQueue(t *testing.T) {
+	jobs := deliveries(2, &fakeAcknowledger{})
+	close(jobs)
+	batch, open := nextBatch(jobs)
+	if len(batch) != 2 || open {
+		t.Fatalf("nextBatch() = %d deliveries, open %v; want 2, false", len(batch), open)
+	}
+	if batch, open := nextBatch(jobs); len(batch) != 0 || open {
+		t.Errorf("nextBatch() on a drained queue = %d deliveries, open %v; want 0, false", len(batch), open)
+	}
+}
+
+func TestHandleFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		attempts   int
+		publishErr error
+		wantQueue  string
+	}{
+		{name: "transient error is retried", err: errors.New("embedding API unavailable"), attempts: 0, wantQueue: queueName},
+		{name: "permanent error is dead-lettered", err: fmt.Errorf("%w: README gone", embedding.ErrPermanent), attempts: 0, wantQueue: deadLetterQueueName},
+		{name: "last attempt is dead-lettered", err: errors.New("embedding API unavailable"), attempts: maxDeliveryAttempts - 1, wantQueue: deadLetterQueueName},
+		{name: "failed publish requeues the delivery", err: errors.New("embedding API unavailable"), publishErr: errors.New("channel closed")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack := &fakeAcknowledger{}
+			publisher := &fakePublisher{err: tt.publishErr}
+			recorder := &fakeRecorder{}
+			job := &embedJob{
+				delivery: amqp.Delivery{Acknowledger: ack, DeliveryTag: 7},
+				msg:      models.ReadmeEmbedMessage{RepositoryID: 42, Attempts: tt.attempts},
+				err:      tt.err,
+			}
+
+			handleFailure(1, job, publisher, recorder)
+
+			if len(recorder.failures) != 1 || recorder.failures[0] != "42:failed" {
+				t.Errorf("Expected the failure to be recorded, got %v", recorder.failures)
+			}
+			if tt.wantQueue == "" {
+				if len(ack.nacked) != 1 || !ack.requeued[0] || len(ack.acked) != 0 {
+					t.Errorf("Expected the delivery to be requeued, got acked %v and nacked %v", ack.acked, ack.nacked)
+				}
+				return
+			}
+
+			if len(ack.acked) != 1 || len(ack.nacked) != 0 {
+				t.Errorf("Expected the delivery to be acked, got acked %v and nacked %v", ack.acked, ack.nacked)
+			}
+			if len(publisher.published) != 1 || len(publisher.published[tt.wantQueue]) != 1 {
+				t.Fatalf("Expected one message on %s, got %v", tt.wantQueue, publisher.published)
+			}
+			var msg models.ReadmeEmbedMessage
+			if err := json.Unmarshal(publisher.published[tt.wantQueue][0], &msg); err != nil {
+				t.Fatalf("Failed to unmarshal published message: %v", err)
+			}
+			if msg.RepositoryID != 42 || msg.Attempts != tt.attempts+1 {
+				t.Errorf("Expected repo 42 at attempt %d, got repo %d at attempt %d", tt.attempts+1, msg.RepositoryID, msg.Attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/mailer"
+	"github.com/teomiscia/github-trending/internal/messaging"
+	"github.com/teomiscia/github-trending/internal/models"
+	"github.com/teomiscia/github-trending/internal/notify"
+	"github.com/teomiscia/github-trending/internal/webhooks"
+)
+
+const (
+	maxRetries       = 5
+	retryDelay       = 5 * time.Second
+	writtenQueueName = "repos_written"
+
+	// Topic watches are notified when a repository enters the trending list kept by
+	// the webhook service, which is refreshed every trendingCacheTTL.
+	trendingCacheTTL = 15 * time.Minute
+)
+
+// The notification service evaluates the watches on every repository the writer
+// stores, keeps the notifications they raise for the app and delivers them by email
+// and webhook. New releases are also published as outbound webhook events.
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	var mqConnection messaging.MQConnection
+	for i := 0; i < maxRetries; i++ {
+		mqConnection, err = messaging.NewConnection(cfg.RabbitMQURL)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to RabbitMQ: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to RabbitMQ after %d retries: %v", maxRetries, err)
+	}
+	defer mqConnection.Close()
+
+	var pgConnection *database.PostgresConnection
+	for i := 0; i < maxRetries; i++ {
+		pgConnection, err = database.NewPostgresConnection(cfg.PostgresHost, "5432", cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresDB)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to PostgreSQL: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL after %d retries: %v", maxRetries, err)
+	}
+	defer pgConnection.DB.Close()
+
+	notifiers := []notify.Notifier{notify.NewWebhookNotifier(nil)}
+	if cfg.SMTPHost != "" {
+		notifiers = append(notifiers, notify.NewEmailNotifier(mailer.New(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom), cfg.PublicAPIURL))
+	} else {
+		log.Println("SMTP_HOST is not set; notifications will not be emailed.")
+	}
+	trending := notify.NewTrendingCache(pgConnection.GetTrendingRepositoryEntries, trendingCacheTTL)
+	engine := notify.NewEngine(pgConnection, trending, notifiers...)
+
+	msgs, err := mqConnection.Consume(writtenQueueName)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue %s: %v", writtenQueueName, err)
+	}
+
+	log.Printf("Notification service started. Waiting for messages on queue: %s", writtenQueueName)
+
+	for d := range msgs {
+		var event models.RepositoryWrittenMessage
+		if err := json.Unmarshal(d.Body, &event); err != nil {
+			log.Printf("Failed to unmarshal message: %v", err)
+			d.Ack(false) // Acknowledge and discard malformed message
+			continue
+		}
+		if release := notify.NewRelease(event); release != nil {
+			if err := webhooks.PublishEvent(mqConnection, webhooks.NewReleaseEvent(event.Repository, *release)); err != nil {
+				log.Printf("Failed to publish the release event for %s: %v", event.Repository.FullName, err)
+			}
+		}
+		if err := engine.Handle(context.Background(), event); err != nil {
+			log.Printf("Failed to evaluate watches: %v", err)
+			d.Nack(false, true) // Nack and requeue for another attempt
+			continue
+		}
+		d.Ack(false)
+	}
+}
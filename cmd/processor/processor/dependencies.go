@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/teomiscia/github-trending/internal/github"
+	"github.com/teomiscia/github-trending/internal/manifest"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// extractDependencies fetches the known manifests from the root of the repository's
+// default branch and returns the dependencies they declare. A malformed manifest is
+// logged and skipped, since it would fail the same way on every crawl. An error means
+// the result is incomplete and should not replace what is already stored.
+func extractDependencies(githubClient *github.GitHubClient, repo models.Repository) ([]models.Dependency, error) {
+	entries, err := githubClient.ListDirectory(repo.FullName, "", repo.DefaultBranch)
+	if err != nil {
+		if errors.Is(err, github.ErrNotFound) {
+			// Empty repositories have no contents at all.
+			return []models.Dependency{}, nil
+		}
+		return nil, fmt.Errorf("failed to list root directory: %w", err)
+	}
+
+	deps := []models.Dependency{}
+	for _, entry := range entries {
+		if entry.Type != "file" || !manifest.IsManifest(entry.Path) {
+			continue
+		}
+		content, err := githubClient.GetFileContent(repo.FullName, entry.Path, repo.DefaultBranch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", entry.Path, err)
+		}
+		parsed, err := manifest.Parse(entry.Path, content)
+		if err != nil {
+			log.Printf("Skipping a manifest of %s: %v", repo.FullName, err)
+			continue
+		}
+		deps = append(deps, parsed...)
+	}
+
+	return manifest.Dedupe(deps), nil
+}
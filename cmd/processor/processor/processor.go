@@ -1,10 +1,10 @@
 package processor
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -14,6 +14,7 @@ import (
 	"github.com/teomiscia/github-trending/internal/github"
 	"github.com/teomiscia/github-trending/internal/messaging"
 	"github.com/teomiscia/github-trending/internal/models"
+	"github.com/teomiscia/github-trending/internal/readme"
 )
 
 func RunProcessor(mqConnection messaging.MQConnection, minioConnection database.MinioClient, githubClient *github.GitHubClient, httpClient *http.Client, processQueueName, writeQueueName, readmeEmbedQueueName string) error {
@@ -34,12 +35,31 @@ func RunProcessor(mqConnection messaging.MQConnection, minioConnection database.
 
 		log.Printf("Processing data for repository: %s", crawlResult.Repository.FullName)
 
-		// Get README URL
-		readmeURL, err := githubClient.GetReadme(crawlResult.Repository.FullName)
-		if err != nil {
+		// Resolve the README through the readme endpoint, whatever its name or format
+		repoReadme, err := githubClient.GetReadme(crawlResult.Repository.FullName)
+		if errors.Is(err, github.ErrNotFound) {
+			crawlResult.Repository.ReadmeURL = sql.NullString{Valid: false}
+			crawlResult.Repository.ReadmePath = sql.NullString{Valid: false}
+			crawlResult.Repository.ReadmeFormat = sql.NullString{Valid: false}
+		} else if err != nil {
+			// The writer replaces the stored README columns, so requeue rather than
+			// clearing them because of a transient error.
 			log.Printf("Failed to get README for %s: %v", crawlResult.Repository.FullName, err)
+			d.Nack(false, true) // Requeue for another attempt
+			continue
 		} else {
-			crawlResult.Repository.ReadmeURL = sql.NullString{String: readmeURL, Valid: true}
+			readmeFormat := readme.DetectFormat(repoReadme.Path)
+			crawlResult.Repository.ReadmeURL = sql.NullString{String: repoReadme.DownloadURL, Valid: repoReadme.DownloadURL != ""}
+			crawlResult.Repository.ReadmePath = sql.NullString{String: repoReadme.Path, Valid: true}
+			crawlResult.Repository.ReadmeFormat = sql.NullString{String: readmeFormat, Valid: true}
+		}
+
+		// Extract declared dependencies from well-known manifests
+		dependencies, err := extractDependencies(githubClient, crawlResult.Repository)
+		if err != nil {
+			log.Printf("Failed to extract dependencies for %s: %v", crawlResult.Repository.FullName, err)
+		} else {
+			crawlResult.Repository.Dependencies = dependencies
 		}
 
 		// Publish to writer service to handle database inserts
@@ -58,15 +78,29 @@ func RunProcessor(mqConnection messaging.MQConnection, minioConnection database.
 		}
 
 		// Handle README storage and embedding trigger
-		if crawlResult.Repository.ReadmeURL.Valid {
-			readmeContent, err := downloadReadme(httpClient, crawlResult.Repository.ReadmeURL.String)
+		if repoReadme != nil && (repoReadme.Content != nil || crawlResult.Repository.ReadmeURL.Valid) {
+			readmeContent := repoReadme.Content
+			var err error
+			if readmeContent == nil {
+				// Large READMEs are not inlined by the API.
+				readmeContent, err = downloadReadme(httpClient, crawlResult.Repository.ReadmeURL.String)
+			}
 			if err != nil {
 				log.Printf("Failed to download README for %s: %v", crawlResult.Repository.FullName, err)
 			} else {
+				// The object key keeps its .md suffix for every format; the original
+				// format is recorded in the content type and in repositories.readme_format.
 				objectName := fmt.Sprintf("readmes/%d.md", crawlResult.Repository.ID)
-				reader := bytes.NewReader(readmeContent)
-				_, err := minioConnection.UploadFile(context.Background(), objectName, reader, int64(len(readmeContent)), "text/markdown")
+				contentHash := database.ContentHash(readmeContent)
+				storedHash, err := minioConnection.GetFileHash(context.Background(), objectName)
 				if err != nil {
+					log.Printf("Failed to get stored README hash for %s: %v", crawlResult.Repository.FullName, err)
+				}
+				if storedHash == contentHash {
+					// The stored README was embedded when it was uploaded; the writer keeps
+					// the vector payloads in sync.
+					log.Printf("README for %s is unchanged, skipping upload and embedding", crawlResult.Repository.FullName)
+				} else if _, err := minioConnection.UploadFileWithHash(context.Background(), objectName, readmeContent, readme.ContentType(crawlResult.Repository.ReadmeFormat.String)); err != nil {
 					log.Printf("Failed to upload README to MinIO for %s: %v", crawlResult.Repository.FullName, err)
 				} else {
 					// Publish message to readme_to_embed queue
@@ -74,7 +108,11 @@ func RunProcessor(mqConnection messaging.MQConnection, minioConnection database.
 						RepositoryID: int64(crawlResult.Repository.ID),
 						MinioPath:    objectName,
 						DownloadURL:  crawlResult.Repository.ReadmeURL.String,
+						ContentHash:  contentHash,
+						Format:       crawlResult.Repository.ReadmeFormat.String,
 					}
+					attributes := models.NewVectorAttributes(crawlResult.Repository)
+					embedMsg.Attributes = &attributes
 					embedMsgJSON, err := json.Marshal(embedMsg)
 					if err != nil {
 						log.Printf("Failed to marshal embed message for %s: %v", crawlResult.Repository.FullName, err)
@@ -5,22 +5,46 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/streadway/amqp"
 	"github.com/teomiscia/github-trending/internal/config"
 	"github.com/teomiscia/github-trending/internal/database"
 	"github.com/teomiscia/github-trending/internal/messaging"
 	"github.com/teomiscia/github-trending/internal/models"
+	"github.com/teomiscia/github-trending/internal/similarity"
 )
 
 const (
-	numWorkers = 2
-	maxRetries = 5
-	retryDelay = 5 * time.Second
+	numWorkers       = 4
+	maxRetries       = 5
+	retryDelay       = 5 * time.Second
+	updatedQueueName = "embedding_updated"
+
+	// Embedding updated events are handled in batches of up to eventBatchSize, collected
+	// for at most eventBatchWait, so that repositories sharing neighbours are recomputed
+	// once per batch rather than once per event.
+	eventBatchSize = 200
+	eventBatchWait = 30 * time.Second
+	// fullSweepInterval is how often every recently updated repository is recomputed,
+	// as a safety net for lost events.
+	fullSweepInterval = 7 * 24 * time.Hour
+	// Topic frequencies describe the whole corpus and change slowly, so they are
+	// reloaded at most every topicFrequenciesTTL rather than for every batch.
+	topicFrequenciesTTL = 6 * time.Hour
 )
 
+// topicFrequencies caches the corpus topic frequencies used by the topic scorer.
+var topicFrequencies struct {
+	mu          sync.Mutex
+	frequencies map[string]int
+	total       int
+	fetchedAt   time.Time
+}
+
 func main() {
 	cfg, err := config.Load()
 	if err != nil {
@@ -97,21 +121,132 @@ func main() {
 	}
 	pgConnection.WithRedis(redisClient)
 
-	// Run the similarity calculation once on startup
-	log.Println("Similarity Engine service started. Running initial similarity calculation.")
-	calculateSimilarity(pgConnection, qdrantConnection, redisClient, cfg, mqConnection, chConnection)
+	msgs, err := mqConnection.Consume(updatedQueueName)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue %s: %v", updatedQueueName, err)
+	}
 
-	// Run the similarity calculation periodically
-	ticker := time.NewTicker(6 * time.Hour) // Run every 6 hours
-	defer ticker.Stop()
+	// Run the full sweep on startup and then periodically
+	go func() {
+		calculateSimilarity(pgConnection, qdrantConnection, redisClient, cfg, mqConnection, chConnection)
+		ticker := time.NewTicker(fullSweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			calculateSimilarity(pgConnection, qdrantConnection, redisClient, cfg, mqConnection, chConnection)
+		}
+	}()
 
-	log.Println("Initial similarity calculation completed. Running similarity calculation periodically.")
+	log.Printf("Similarity Engine service started with weights %v. Waiting for messages on queue: %s", cfg.SimilarityWeights, updatedQueueName)
 
-	for range ticker.C {
-		calculateSimilarity(pgConnection, qdrantConnection, redisClient, cfg, mqConnection, chConnection)
+	for {
+		batch, ok := nextBatch(msgs)
+		if len(batch) > 0 {
+			handleUpdatedEmbeddings(batch, pgConnection, qdrantConnection, redisClient, cfg, mqConnection)
+		}
+		if !ok {
+			log.Fatalf("Queue %s was closed", updatedQueueName)
+		}
 	}
 }
 
+// nextBatch blocks until a delivery arrives, then collects more until the batch is full
+// or eventBatchWait has passed. It reports false once msgs is closed.
+func nextBatch(msgs <-chan amqp.Delivery) ([]amqp.Delivery, bool) {
+	d, ok := <-msgs
+	if !ok {
+		return nil, false
+	}
+	batch := []amqp.Delivery{d}
+	timeout := time.After(eventBatchWait)
+	for len(batch) < eventBatchSize {
+		select {
+		case d, ok := <-msgs:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, d)
+		case <-timeout:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// handleUpdatedEmbeddings recomputes the similarity lists affected by a batch of
+// embedding updated events: those of the updated repositories and of the repositories
+// whose stored list contains one of them (reverse neighbours). Repositories that may
+// now rank an updated repository among their neighbours are left to the full sweep,
+// since recomputing every new neighbour multiplies the work by the list size.
+func handleUpdatedEmbeddings(deliveries []amqp.Delivery, pgConnection *database.PostgresConnection, qdrantConnection *database.QdrantConnection, redisClient *redis.Client, cfg *config.Config, mqConnection messaging.MQConnection) {
+	// Events for collections the alias does not serve yet (during a backfill) are
+	// dropped; the next full sweep after the switch recomputes every list.
+	servedCollection, err := qdrantConnection.GetAliasTarget(context.Background(), database.RepositoriesAlias)
+	if err != nil {
+		log.Printf("Failed to look up Qdrant alias %s: %v", database.RepositoriesAlias, err)
+	}
+
+	var updated []int64
+	for _, d := range deliveries {
+		var event models.EmbeddingUpdatedMessage
+		if err := json.Unmarshal(d.Body, &event); err != nil {
+			log.Printf("Failed to unmarshal message: %v", err)
+			continue
+		}
+		if servedCollection != "" && database.VersionedCollection(database.RepositoriesCollection, event.Version) != servedCollection {
+			continue
+		}
+		updated = append(updated, event.RepositoryID)
+	}
+	updated = uniqueIDs(updated)
+
+	if len(updated) > 0 {
+		// Look up the reverse neighbours before the updated lists are overwritten.
+		reverse, err := pgConnection.GetReverseSimilarRepositoryIDs(updated)
+		if err != nil {
+			log.Printf("Failed to get reverse neighbours from PostgreSQL: %v", err)
+		}
+		recalculate(updated, pgConnection, qdrantConnection, redisClient, cfg, mqConnection)
+		affected := affectedRepositories(updated, reverse)
+		recalculate(affected, pgConnection, qdrantConnection, redisClient, cfg, mqConnection)
+		log.Printf("Recomputed similarity for %d updated and %d affected repositories.", len(updated), len(affected))
+	}
+
+	// The events only trigger work, so they are acknowledged even if some of it failed;
+	// the full sweep catches up on anything missed.
+	for _, d := range deliveries {
+		d.Ack(false)
+	}
+}
+
+// affectedRepositories returns the reverse neighbours of the updated repositories,
+// without duplicates and without the updated repositories themselves.
+func affectedRepositories(updated, reverse []int64) []int64 {
+	done := make(map[int64]bool, len(updated))
+	for _, id := range updated {
+		done[id] = true
+	}
+	var affected []int64
+	for _, id := range reverse {
+		if !done[id] {
+			done[id] = true
+			affected = append(affected, id)
+		}
+	}
+	return affected
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := ids[:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
 func calculateSimilarity(pgConnection *database.PostgresConnection, qdrantConnection *database.QdrantConnection, redisClient *redis.Client, cfg *config.Config, mqConnection messaging.MQConnection, chConnection *database.ClickHouseConnection) {
 	log.Println("Starting similarity calculation...")
 	// 1. Fetch all repository IDs from ClickHouse that have been updated within the LAST_UPDATE_CUT period.
@@ -121,13 +256,35 @@ func calculateSimilarity(pgConnection *database.PostgresConnection, qdrantConnec
 		return
 	}
 
+	recalculate(repoIDs, pgConnection, qdrantConnection, redisClient, cfg, mqConnection)
+	log.Println("Similarity calculation completed.")
+}
+
+// recalculate recomputes the similarity lists of repoIDs.
+func recalculate(repoIDs []int64, pgConnection *database.PostgresConnection, qdrantConnection *database.QdrantConnection, redisClient *redis.Client, cfg *config.Config, mqConnection messaging.MQConnection) {
+	if len(repoIDs) == 0 {
+		return
+	}
+	blend, err := newBlend(cfg.SimilarityWeights, pgConnection)
+	if err != nil {
+		log.Printf("Failed to set up similarity scorers: %v", err)
+		return
+	}
+
 	jobs := make(chan int64, len(repoIDs))
 	var wg sync.WaitGroup
 
 	// Start workers
 	for w := 1; w <= numWorkers; w++ {
 		wg.Add(1)
-		go worker(w, &wg, jobs, qdrantConnection, redisClient, cfg, pgConnection, mqConnection)
+		go func(id int) {
+			defer wg.Done()
+			for repoID := range jobs {
+				log.Printf("Worker %d started job for repo ID %d", id, repoID)
+				processRepository(repoID, blend, qdrantConnection, redisClient, cfg, pgConnection, mqConnection)
+				log.Printf("Worker %d finished job for repo ID %d", id, repoID)
+			}
+		}(w)
 	}
 
 	// Send jobs
@@ -137,21 +294,48 @@ func calculateSimilarity(pgConnection *database.PostgresConnection, qdrantConnec
 	close(jobs)
 
 	wg.Wait()
-	log.Println("Similarity calculation completed.")
 }
 
-func worker(id int, wg *sync.WaitGroup, jobs <-chan int64, qdrantConnection *database.QdrantConnection, redisClient *redis.Client, cfg *config.Config, pgConnection *database.PostgresConnection, mqConnection messaging.MQConnection) {
-	defer wg.Done()
-	for repoID := range jobs {
-		log.Printf("Worker %d started job for repo ID %d", id, repoID)
-		processRepository(repoID, qdrantConnection, redisClient, cfg, pgConnection, mqConnection)
-		log.Printf("Worker %d finished job for repo ID %d", id, repoID)
+// newBlend builds the configured blend of similarity scorers, with the cached topic
+// frequencies if the topic scorer is used.
+func newBlend(weights map[string]float64, pgConnection *database.PostgresConnection) (*similarity.Blend, error) {
+	scorers := []similarity.Scorer{
+		similarity.CosineScorer{},
+		similarity.LanguageScorer{},
+		similarity.DependencyScorer{},
+		similarity.CoViewScorer{},
 	}
+	if similarity.Uses(weights, similarity.Topics) {
+		frequencies, total, err := getTopicFrequencies(pgConnection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get topic frequencies: %w", err)
+		}
+		scorers = append(scorers, similarity.NewTopicScorer(frequencies, total))
+	}
+	return similarity.NewBlend(weights, scorers...)
 }
 
-func processRepository(repoID int64, qdrantConnection *database.QdrantConnection, redisClient *redis.Client, cfg *config.Config, pgConnection *database.PostgresConnection, mqConnection messaging.MQConnection) {
+// getTopicFrequencies returns the cached topic frequencies, reloading them once they
+// are older than topicFrequenciesTTL.
+func getTopicFrequencies(pgConnection *database.PostgresConnection) (map[string]int, int, error) {
+	topicFrequencies.mu.Lock()
+	defer topicFrequencies.mu.Unlock()
+	if topicFrequencies.frequencies == nil || time.Since(topicFrequencies.fetchedAt) > topicFrequenciesTTL {
+		frequencies, total, err := pgConnection.GetTopicFrequencies()
+		if err != nil {
+			return nil, 0, err
+		}
+		topicFrequencies.frequencies = frequencies
+		topicFrequencies.total = total
+		topicFrequencies.fetchedAt = time.Now()
+	}
+	return topicFrequencies.frequencies, topicFrequencies.total, nil
+}
+
+// processRepository recomputes and stores the similarity list of a repository.
+func processRepository(repoID int64, blend *similarity.Blend, qdrantConnection *database.QdrantConnection, redisClient *redis.Client, cfg *config.Config, pgConnection *database.PostgresConnection, mqConnection messaging.MQConnection) {
 	// 2. For each repository_id, query Qdrant to get its embedding vector.
-	vectors, err := qdrantConnection.GetVectors(context.Background(), "repositories", []uint64{uint64(repoID)})
+	vectors, err := qdrantConnection.GetVectors(context.Background(), database.RepositoriesAlias, []uint64{uint64(repoID)})
 	if err != nil || len(vectors) == 0 {
 		log.Printf("Failed to get embedding for repo %d from Qdrant: %v", repoID, err)
 		// If the embedding is not found, send a message to the readme_to_embed queue
@@ -173,52 +357,61 @@ func processRepository(repoID int64, qdrantConnection *database.QdrantConnection
 	embedding := vectors[0].GetVectors().GetVector().GetData()
 
 	// 3. Perform a similarity search in Qdrant using that vector to find the top N nearest neighbors.
-	searchResults, err := qdrantConnection.Search(context.Background(), "repositories", embedding, uint64(cfg.SimilarityListSize), uint64(repoID))
+	// Archived repositories are never recommended, so Qdrant filters them out.
+	filter := database.NewQdrantFilter().ExcludeRepositories(repoID).ExcludeArchived()
+	searchResults, err := qdrantConnection.Search(context.Background(), database.RepositoriesAlias, embedding, uint64(cfg.SimilarityListSize), filter)
 	if err != nil {
 		log.Printf("Failed to search embeddings for repo %d in Qdrant: %v", repoID, err)
 		return
 	}
+	semanticScores := make(map[int64]float64, len(searchResults))
+	for _, result := range searchResults {
+		semanticScores[int64(result.GetId().GetNum())] = float64(result.GetScore())
+	}
 
-	// 4. Get source repo topics and languages
-	sourceRepo, err := pgConnection.GetRepositoryByID(repoID)
+	// Also match against individual README sections, so that a repository whose
+	// closest section is not its intro is still found. A candidate keeps its best score.
+	chunkScores, err := qdrantConnection.SearchChunkGroups(context.Background(), database.ReadmeChunksAlias, embedding, uint32(cfg.SimilarityListSize), filter)
 	if err != nil {
-		log.Printf("Failed to get source repo %d from Postgres: %v", repoID, err)
+		log.Printf("Failed to search README chunks for repo %d in Qdrant: %v", repoID, err)
+	}
+	for candidateRepoID, score := range chunkScores {
+		if float64(score) > semanticScores[candidateRepoID] {
+			semanticScores[candidateRepoID] = float64(score)
+		}
+	}
+
+	// 4. Load the source and candidate repositories' signals, one query per signal.
+	candidateIDs := make([]int64, 0, len(semanticScores))
+	for candidateRepoID := range semanticScores {
+		candidateIDs = append(candidateIDs, candidateRepoID)
+	}
+	repos, err := loadSignals(repoID, candidateIDs, cfg.SimilarityWeights, pgConnection)
+	if err != nil {
+		log.Printf("Failed to load similarity signals for repo %d: %v", repoID, err)
+		return
+	}
+	sourceRepo, ok := repos[repoID]
+	if !ok {
+		log.Printf("Source repo %d not found in Postgres", repoID)
 		return
 	}
 
 	// 5. Calculate new similarity scores
 	var newScores []redis.Z
-	for _, result := range searchResults {
-		var finalScore float64
-		candidateRepoID := int64(result.GetId().GetNum())
-
-		// Get candidate repo topics and languages
-		candidateRepo, err := pgConnection.GetRepositoryByID(candidateRepoID)
-		if err != nil {
-			log.Printf("Failed to get candidate repo %d from Postgres: %v", candidateRepoID, err)
+	for candidateRepoID, semanticScore := range semanticScores {
+		candidateRepo, ok := repos[candidateRepoID]
+		if !ok {
+			log.Printf("Candidate repo %d not found in Postgres", candidateRepoID)
 			continue
 		}
-
-		// Calculate topic similarity
-		topicScore := jaccardSimilarity(sourceRepo.Topics, candidateRepo.Topics)
-
-		// Calculate language similarity
-		var sourceLanguages []string
-		for lang := range sourceRepo.Languages {
-			sourceLanguages = append(sourceLanguages, lang)
-		}
-		var candidateLanguages []string
-		for lang := range candidateRepo.Languages {
-			candidateLanguages = append(candidateLanguages, lang)
-		}
-		languageScore := jaccardSimilarity(sourceLanguages, candidateLanguages)
-
-		// Combine scores
-		finalScore = (0.6 * float64(result.GetScore())) + (0.3 * topicScore) + (0.1 * languageScore)
-
-		newScores = append(newScores, redis.Z{Score: finalScore, Member: candidateRepoID})
+		candidate := similarity.Candidate{Repository: candidateRepo.Repository, Semantic: semanticScore, CoViews: candidateRepo.coViews}
+		newScores = append(newScores, redis.Z{Score: blend.Score(sourceRepo.Repository, candidate), Member: candidateRepoID})
+	}
+	sort.Slice(newScores, func(i, j int) bool { return newScores[i].Score > newScores[j].Score })
+	if len(newScores) > cfg.SimilarityListSize {
+		newScores = newScores[:cfg.SimilarityListSize]
 	}
-
 	// 6. Connect to Redis and store this result in a Sorted Set.
 	redisKey := fmt.Sprintf("similar:%d", repoID)
 
@@ -252,31 +445,54 @@ func processRepository(repoID int64, qdrantConnection *database.QdrantConnection
 	}
 
 	log.Printf("Successfully calculated and stored similarity for repo ID: %d", repoID)
+	return
 }
 
-func jaccardSimilarity(a, b []string) float64 {
-	setA := make(map[string]bool)
-	for _, item := range a {
-		setA[item] = true
-	}
+// signals holds what the similarity scorers know about a repository; coViews counts
+// the sessions that viewed it and the source repository.
+type signals struct {
+	similarity.Repository
+	coViews int
+}
 
-	setB := make(map[string]bool)
-	for _, item := range b {
-		setB[item] = true
+// loadSignals loads the repository and its candidates from PostgreSQL, along with the
+// dependencies and view counts if the blend uses them.
+func loadSignals(repoID int64, candidateIDs []int64, weights map[string]float64, pgConnection *database.PostgresConnection) (map[int64]*signals, error) {
+	ids := append([]int64{repoID}, candidateIDs...)
+	reposData, err := pgConnection.GetRepositoriesDataByIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repositories: %w", err)
+	}
+	repos := make(map[int64]*signals, len(reposData))
+	for _, repoData := range reposData {
+		id := int64(repoData.Repository.ID)
+		repos[id] = &signals{Repository: similarity.Repository{
+			ID:        id,
+			Topics:    repoData.Repository.Topics,
+			Languages: repoData.Repository.Languages,
+		}}
 	}
 
-	intersection := 0
-	for item := range setA {
-		if setB[item] {
-			intersection++
+	if similarity.Uses(weights, similarity.Dependencies) {
+		dependencies, err := pgConnection.GetRuntimeDependencies(ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependencies: %w", err)
+		}
+		for id, repo := range repos {
+			repo.Dependencies = dependencies[id]
 		}
 	}
 
-	union := len(setA) + len(setB) - intersection
-
-	if union == 0 {
-		return 0.0
+	if similarity.Uses(weights, similarity.CoViews) {
+		coViews, sessions, err := pgConnection.GetCoViewCounts(repoID, candidateIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get co-view counts: %w", err)
+		}
+		for id, repo := range repos {
+			repo.Sessions = sessions[id]
+			repo.coViews = coViews[id]
+		}
 	}
 
-	return float64(intersection) / float64(union)
+	return repos, nil
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAffectedRepositories(t *testing.T) {
+	updated := []int64{1, 2}
+	reverse := []int64{3, 1, 4, 3}
+
+	got := affectedRepositories(updated, reverse)
+	want := []int64{3, 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("affectedRepositories() = %v, want %v", got, want)
+	}
+
+	if got := affectedRepositories(updated, nil); len(got) != 0 {
+		t.Errorf("Expected no affected repositories, got %v", got)
+	}
+}
+
+func TestUniqueIDs(t *testing.T) {
+	got := uniqueIDs([]int64{7, 3, 7, 1, 3})
+	want := []int64{7, 3, 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueIDs() = %v, want %v", got, want)
+	}
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/teomiscia/github-trending/internal/config"
 	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/models"
+	"github.com/teomiscia/github-trending/internal/readme"
 	"github.com/teomiscia/github-trending/internal/social"
 )
 
 const (
 	maxRetries = 5
 	retryDelay = 5 * time.Second
+	// trendingDays is the window repositories trend over.
+	trendingDays = 7
+	// maxHighlights caps the README highlights of threads.
+	maxHighlights = 4
 )
 
 func main() {
@@ -48,27 +57,103 @@ func main() {
 	}
 	defer pgConnection.DB.Close()
 
-	twitterClient := social.NewTwitterClient(cfg.TwitterApiKey, cfg.TwitterApiSecretKey, cfg.TwitterAccessToken, cfg.TwitterAccessSecret)
+	// README highlights are only needed for threads.
+	var minioConnection *database.MinioConnection
+	if cfg.SocialThreads {
+		for i := 0; i < maxRetries; i++ {
+			minioConnection, err = database.NewMinioConnection(cfg.MinioEndpoint, cfg.MinioRootUser, cfg.MinioRootPassword)
+			if err == nil {
+				break
+			}
+			log.Printf("Failed to connect to MinIO: %v. Retrying in %v...", err, retryDelay)
+			time.Sleep(retryDelay)
+		}
+		if err != nil {
+			log.Fatalf("Failed to connect to MinIO after %d retries: %v", maxRetries, err)
+		}
+	}
+
+	composer, err := social.NewComposer(cfg.SocialTemplatesDir, cfg.SocialThreads)
+	if err != nil {
+		log.Fatalf("Failed to load social post templates: %v", err)
+	}
+
+	posters, err := newPosters(cfg, composer)
+	if err != nil {
+		log.Fatalf("Failed to configure social networks: %v", err)
+	}
 
-	log.Println("Social Poster service started.")
+	log.Printf("Social Poster service started, posting to %s.", strings.Join(cfg.SocialNetworks, ", "))
 
 	// Run on startup
-	runPoster(pgConnection, chConnection, twitterClient)
+	runPoster(cfg, pgConnection, chConnection, minioConnection, composer, posters)
 
 	// Run every 4 hours
 	ticker := time.NewTicker(4 * time.Hour)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		runPoster(pgConnection, chConnection, twitterClient)
+		runPoster(cfg, pgConnection, chConnection, minioConnection, composer, posters)
+	}
+}
+
+// newPosters creates the posters of the configured social networks.
+func newPosters(cfg *config.Config, composer *social.Composer) ([]social.Poster, error) {
+	var posters []social.Poster
+	for _, network := range cfg.SocialNetworks {
+		switch network {
+		case "twitter":
+			if cfg.TwitterApiKey == "" || cfg.TwitterAccessToken == "" {
+				return nil, fmt.Errorf("TWITTER_API_KEY and TWITTER_ACCESS_TOKEN are required to post to twitter")
+			}
+			posters = append(posters, social.NewTwitterClient(cfg.TwitterApiKey, cfg.TwitterApiSecretKey, cfg.TwitterAccessToken, cfg.TwitterAccessSecret, composer))
+		case "mastodon":
+			if cfg.MastodonInstanceURL == "" || cfg.MastodonAccessToken == "" {
+				return nil, fmt.Errorf("MASTODON_INSTANCE_URL and MASTODON_ACCESS_TOKEN are required to post to mastodon")
+			}
+			posters = append(posters, social.NewMastodonClient(cfg.MastodonInstanceURL, cfg.MastodonAccessToken, composer, nil))
+		case "bluesky":
+			if cfg.BlueskyHandle == "" || cfg.BlueskyAppPassword == "" {
+				return nil, fmt.Errorf("BLUESKY_HANDLE and BLUESKY_APP_PASSWORD are required to post to bluesky")
+			}
+			posters = append(posters, social.NewBlueskyClient(cfg.BlueskyPDSURL, cfg.BlueskyHandle, cfg.BlueskyAppPassword, composer, nil))
+		case "linkedin":
+			if cfg.LinkedInAccessToken == "" || cfg.LinkedInAuthorURN == "" {
+				return nil, fmt.Errorf("LINKEDIN_ACCESS_TOKEN and LINKEDIN_AUTHOR_URN are required to post to linkedin")
+			}
+			posters = append(posters, social.NewLinkedInClient(cfg.LinkedInAccessToken, cfg.LinkedInAuthorURN, composer, nil))
+		case "postiz":
+			if cfg.PostizAPIURL == "" || cfg.PostizAPIKey == "" {
+				return nil, fmt.Errorf("POSTIZ_API_URL and POSTIZ_API_KEY are required to post through postiz")
+			}
+			options := social.PostizOptions{
+				Integrations:  cfg.PostizIntegrations,
+				PostType:      cfg.PostizPostType,
+				ScheduleDelay: cfg.PostizScheduleDelay,
+			}
+			for _, direct := range cfg.SocialNetworks {
+				if direct != "postiz" {
+					options.DirectNetworks = append(options.DirectNetworks, direct)
+				}
+			}
+			posters = append(posters, social.NewPostizClient(cfg.PostizAPIURL, cfg.PostizAPIKey, options, composer, nil))
+		}
 	}
+	return posters, nil
 }
 
-func runPoster(pgConnection *database.PostgresConnection, chConnection *database.ClickHouseConnection, poster social.Poster) {
+func runPoster(cfg *config.Config, pgConnection *database.PostgresConnection, chConnection *database.ClickHouseConnection, minioConnection *database.MinioConnection, composer *social.Composer, posters []social.Poster) {
 	log.Println("Starting social posting process...")
 
-	// Get trending repositories from the last day
-	trendingRepoIDs, err := chConnection.GetTrendingRepositoryIDsByGrowth(7)
+	// Pick up the edits of the templates since the last run.
+	if cfg.SocialTemplatesDir != "" {
+		if err := composer.Load(cfg.SocialTemplatesDir); err != nil {
+			log.Printf("Failed to reload social post templates, keeping the previous ones: %v", err)
+		}
+	}
+
+	// Get trending repositories from the last week
+	trendingRepoIDs, err := chConnection.GetTrendingRepositoryIDsByGrowth(trendingDays)
 	if err != nil {
 		log.Printf("Failed to get trending repositories: %v", err)
 		return
@@ -79,42 +164,96 @@ func runPoster(pgConnection *database.PostgresConnection, chConnection *database
 		return
 	}
 
+	// Each network gets the top trending repository not yet posted to it, so a network
+	// that failed or was added later catches up on its own. Networks posting the same
+	// repository share its content.
+	contents := make(map[int64]social.Content)
+	for _, poster := range posters {
+		postNext(pgConnection, poster, trendingRepoIDs, func(repo models.Repository) social.Content {
+			content, ok := contents[int64(repo.ID)]
+			if !ok {
+				content = newContent(cfg, chConnection, minioConnection, repo)
+				contents[int64(repo.ID)] = content
+			}
+			return content
+		})
+	}
+
+	log.Println("Finished social posting cycle.")
+}
+
+// postNext posts the first of the trending repositories not yet posted to the
+// poster's network.
+func postNext(pgConnection *database.PostgresConnection, poster social.Poster, trendingRepoIDs []int64, content func(models.Repository) social.Content) {
+	network := poster.Network()
 	for _, repoID := range trendingRepoIDs {
-		isPosted, err := pgConnection.IsRepositoryPosted(repoID)
+		isPosted, err := pgConnection.IsRepositoryPostedOn(repoID, network)
 		if err != nil {
-			log.Printf("Failed to check if repository %d was posted: %v", repoID, err)
+			log.Printf("Failed to check if repository %d was posted to %s: %v", repoID, network, err)
 			continue
 		}
 
 		if isPosted {
-			log.Printf("Repository %d has already been posted. Skipping.", repoID)
 			continue
 		}
 
-		// Get data for the top trending repository
 		repo, err := pgConnection.GetRepositoryByID(repoID)
 		if err != nil {
 			log.Printf("Failed to get repository data for ID %d: %v", repoID, err)
 			continue
 		}
 
-		log.Printf("Attempting to post repository: %s", repo.FullName)
-		// Post it
-		err = poster.Post(repo)
+		log.Printf("Attempting to post repository %s to %s", repo.FullName, network)
+		postURL, err := poster.Post(content(repo))
 		if err != nil {
-			log.Printf("Failed to post to social media: %v", err)
-			continue
+			log.Printf("Failed to post to %s: %v", network, err)
+			// A post whose thread failed is still posted.
+			if postURL == "" {
+				continue
+			}
 		}
 
-		log.Printf("Successfully posted repository: %s", repo.FullName)
-		err = pgConnection.MarkRepositoryAsPosted(repoID)
+		log.Printf("Successfully posted repository %s to %s: %s", repo.FullName, network, postURL)
+		err = pgConnection.MarkRepositoryPostedOn(repoID, network, postURL)
 		if err != nil {
-			log.Printf("Failed to mark repository %d as posted: %v", repoID, err)
+			log.Printf("Failed to mark repository %d as posted to %s: %v", repoID, network, err)
 		}
 
-		// Post only one repository per run
-		break
+		// Post only one repository per network per run
+		return
 	}
+	log.Printf("No trending repository left to post to %s.", network)
+}
 
-	log.Println("Finished social posting cycle.")
+// newContent gathers what is posted about a repository: its growth over the trending
+// window, its OG image and, for threads, its README highlights. Each is left out if
+// it cannot be fetched rather than holding the post back.
+func newContent(cfg *config.Config, chConnection *database.ClickHouseConnection, minioConnection *database.MinioConnection, repo models.Repository) social.Content {
+	content := social.Content{Repository: repo, Days: trendingDays}
+
+	growth, err := chConnection.GetRepositoriesGrowth([]int64{int64(repo.ID)}, trendingDays)
+	if err != nil {
+		log.Printf("Failed to get growth of repository %d: %v", repo.ID, err)
+	} else if len(growth) > 0 {
+		content.Growth = growth[0]
+	}
+
+	content.Image, err = social.FetchOGImage(nil, cfg.PublicAPIURL, repo.ID)
+	if err != nil {
+		log.Printf("Failed to get OG image of repository %d, posting without it: %v", repo.ID, err)
+	}
+
+	if minioConnection != nil {
+		readmeContent, err := minioConnection.GetFile(context.Background(), fmt.Sprintf("readmes/%d.md", repo.ID))
+		if err != nil {
+			log.Printf("Failed to get README of repository %d: %v", repo.ID, err)
+		} else {
+			format := readme.FormatMarkdown
+			if repo.ReadmeFormat.Valid {
+				format = repo.ReadmeFormat.String
+			}
+			content.Highlights = readme.Highlights(format, readmeContent, maxHighlights)
+		}
+	}
+	return content
 }
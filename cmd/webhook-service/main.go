@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/messaging"
+	"github.com/teomiscia/github-trending/internal/models"
+	"github.com/teomiscia/github-trending/internal/webhooks"
+)
+
+const (
+	maxRetries = 5
+	retryDelay = 5 * time.Second
+
+	// Deliveries due for a retry are queued every requeueInterval, up to
+	// requeueBatchSize at a time.
+	requeueInterval  = 15 * time.Second
+	requeueBatchSize = 100
+
+	// The trending list of the last trendingDays is diffed and star spikes are
+	// detected every detectInterval.
+	trendingDays   = 7
+	detectInterval = 30 * time.Minute
+)
+
+// The webhook service detects trending and star spike events, fans them and the
+// release events of the notification service out to the registered webhooks, and
+// sends the signed deliveries with retries.
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	var mqConnection messaging.MQConnection
+	for i := 0; i < maxRetries; i++ {
+		mqConnection, err = messaging.NewConnection(cfg.RabbitMQURL)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to RabbitMQ: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to RabbitMQ after %d retries: %v", maxRetries, err)
+	}
+	defer mqConnection.Close()
+
+	var pgConnection *database.PostgresConnection
+	for i := 0; i < maxRetries; i++ {
+		pgConnection, err = database.NewPostgresConnection(cfg.PostgresHost, "5432", cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresDB)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to PostgreSQL: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL after %d retries: %v", maxRetries, err)
+	}
+	defer pgConnection.DB.Close()
+
+	var chConnection *database.ClickHouseConnection
+	for i := 0; i < maxRetries; i++ {
+		chConnection, err = database.NewClickHouseConnection(cfg.ClickHouseHost, cfg.ClickHousePort, cfg.ClickHouseUser, cfg.ClickHousePassword, cfg.ClickHouseDB)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to ClickHouse: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to ClickHouse after %d retries: %v", maxRetries, err)
+	}
+	defer chConnection.DB.Close()
+
+	dispatcher := webhooks.NewDispatcher(pgConnection, mqConnection, nil)
+	detector := webhooks.NewDetector(pgConnection, chConnection, mqConnection, trendingDays)
+
+	events, err := mqConnection.Consume(webhooks.EventsQueue)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue %s: %v", webhooks.EventsQueue, err)
+	}
+	deliveries, err := mqConnection.Consume(webhooks.DeliveriesQueue)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue %s: %v", webhooks.DeliveriesQueue, err)
+	}
+
+	// Run the detection on startup and then periodically
+	go func() {
+		detect(detector)
+		ticker := time.NewTicker(detectInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			detect(detector)
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(requeueInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := dispatcher.RequeueDue(requeueBatchSize); err != nil {
+				log.Printf("Failed to requeue due webhook deliveries: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		for d := range events {
+			var event models.WebhookEvent
+			if err := json.Unmarshal(d.Body, &event); err != nil {
+				log.Printf("Failed to unmarshal event: %v", err)
+				d.Ack(false) // Acknowledge and discard malformed message
+				continue
+			}
+			if err := dispatcher.HandleEvent(event); err != nil {
+				log.Printf("Failed to dispatch event: %v", err)
+				d.Nack(false, true) // Nack and requeue for another attempt
+				continue
+			}
+			d.Ack(false)
+		}
+		log.Fatalf("Queue %s was closed", webhooks.EventsQueue)
+	}()
+
+	log.Printf("Webhook service started. Waiting for messages on queues: %s, %s", webhooks.EventsQueue, webhooks.DeliveriesQueue)
+
+	for d := range deliveries {
+		var message models.WebhookDeliveryMessage
+		if err := json.Unmarshal(d.Body, &message); err != nil {
+			log.Printf("Failed to unmarshal delivery: %v", err)
+			d.Ack(false) // Acknowledge and discard malformed message
+			continue
+		}
+		if err := dispatcher.Deliver(context.Background(), message.DeliveryID); err != nil {
+			log.Printf("Failed to deliver webhook: %v", err)
+			d.Nack(false, true) // Nack and requeue for another attempt
+			continue
+		}
+		d.Ack(false)
+	}
+	log.Fatalf("Queue %s was closed", webhooks.DeliveriesQueue)
+}
+
+func detect(detector *webhooks.Detector) {
+	n, err := detector.Run(time.Now())
+	if err != nil {
+		log.Printf("Failed to detect webhook events: %v", err)
+	}
+	if n > 0 {
+		log.Printf("Published %d webhook events", n)
+	}
+}
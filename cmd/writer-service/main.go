@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"time"
@@ -17,6 +18,9 @@ const (
 	maxRetries     = 5
 	retryDelay     = 5 * time.Second
 	writeQueueName = "repos_to_write"
+	// writtenQueueName receives an event for every stored repository, consumed by the
+	// notification service.
+	writtenQueueName = "repos_written"
 )
 
 func main() {
@@ -81,6 +85,20 @@ func main() {
 	}
 	defer chConnection.DB.Close()
 
+	var qdrantConnection *database.QdrantConnection
+	for i := 0; i < maxRetries; i++ {
+		qdrantConnection, err = database.NewQdrantConnection(cfg.QdrantHost, cfg.QdrantPort)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to connect to Qdrant: %v. Retrying in %v...", err, retryDelay)
+		time.Sleep(retryDelay)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to Qdrant after %d retries: %v", maxRetries, err)
+	}
+	defer qdrantConnection.Close()
+
 	msgs, err := mqConnection.Consume(writeQueueName)
 	if err != nil {
 		log.Fatalf("Failed to start consuming from queue %s: %v", writeQueueName, err)
@@ -92,14 +110,14 @@ func main() {
 
 	go func() {
 		for d := range msgs {
-			handleMessage(d, pgConnection, chConnection)
+			handleMessage(d, mqConnection, pgConnection, chConnection, qdrantConnection, cfg.EmbeddingVersion)
 		}
 	}()
 
 	<-forever
 }
 
-func handleMessage(d amqp.Delivery, pgConnection *database.PostgresConnection, chConnection *database.ClickHouseConnection) {
+func handleMessage(d amqp.Delivery, mqConnection messaging.MQConnection, pgConnection *database.PostgresConnection, chConnection *database.ClickHouseConnection, qdrantConnection *database.QdrantConnection, embeddingVersion int) {
 	var crawlResult models.CrawlResult
 	if err := json.Unmarshal(d.Body, &crawlResult); err != nil {
 		log.Printf("Failed to unmarshal message: %v", err)
@@ -109,6 +127,13 @@ func handleMessage(d amqp.Delivery, pgConnection *database.PostgresConnection, c
 
 	log.Printf("Processing data for repository: %s", crawlResult.Repository.FullName)
 
+	previous, err := pgConnection.GetRepositorySnapshot(int64(crawlResult.Repository.ID))
+	if err != nil {
+		log.Printf("Failed to read the stored state of the repository from PostgreSQL: %v", err)
+		d.Nack(false, true) // Nack and requeue for another attempt
+		return
+	}
+
 	if err := pgConnection.InsertRepository(crawlResult.Repository, crawlResult.CrawledAt); err != nil {
 		log.Printf("Failed to insert repository into PostgreSQL: %v", err)
 		d.Nack(false, true) // Nack and requeue for another attempt
@@ -121,6 +146,61 @@ func handleMessage(d amqp.Delivery, pgConnection *database.PostgresConnection, c
 		return
 	}
 
+	if activity := crawlResult.Repository.Activity; activity != nil {
+		if err := chConnection.InsertRepositoryActivity(crawlResult.Repository.ID, *activity); err != nil {
+			log.Printf("Failed to insert repository activity into ClickHouse: %v", err)
+			d.Nack(false, true) // Nack and requeue for another attempt
+			return
+		}
+	}
+
+	// Qdrant payloads are a filtering cache of the repository's attributes, so a failed
+	// update is only logged: the next crawl of the repository will try again.
+	syncVectorAttributes(qdrantConnection, crawlResult.Repository, embeddingVersion)
+
+	// Watch notifications are supplementary too, so a failed publish is only logged.
+	publishWritten(mqConnection, models.RepositoryWrittenMessage{Repository: crawlResult.Repository, Previous: previous, WrittenAt: time.Now()})
+
 	log.Printf("Successfully wrote data for: %s", crawlResult.Repository.FullName)
 	d.Ack(false)
 }
+
+func publishWritten(mqConnection messaging.MQConnection, event models.RepositoryWrittenMessage) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal write event for %s: %v", event.Repository.FullName, err)
+		return
+	}
+	if err := mqConnection.Publish(writtenQueueName, body); err != nil {
+		log.Printf("Failed to publish write event for %s: %v", event.Repository.FullName, err)
+	}
+}
+
+// syncVectorAttributes updates the repository's attribute payload in the collections
+// being written for the configured embedding version and, during a backfill, in the
+// collections the aliases still serve from.
+func syncVectorAttributes(qdrantConnection *database.QdrantConnection, repo models.Repository, embeddingVersion int) {
+	ctx := context.Background()
+	attributes := models.NewVectorAttributes(repo)
+
+	repositoriesCollections := []string{database.VersionedCollection(database.RepositoriesCollection, embeddingVersion)}
+	chunksCollections := []string{database.VersionedCollection(database.ReadmeChunksCollection, embeddingVersion)}
+	repositoriesTarget, err := qdrantConnection.GetAliasTarget(ctx, database.RepositoriesAlias)
+	if err != nil {
+		log.Printf("Failed to look up Qdrant alias %s: %v", database.RepositoriesAlias, err)
+	}
+	chunksTarget, err := qdrantConnection.GetAliasTarget(ctx, database.ReadmeChunksAlias)
+	if err != nil {
+		log.Printf("Failed to look up Qdrant alias %s: %v", database.ReadmeChunksAlias, err)
+	}
+	if repositoriesTarget != "" && chunksTarget != "" && repositoriesTarget != repositoriesCollections[0] {
+		repositoriesCollections = append(repositoriesCollections, repositoriesTarget)
+		chunksCollections = append(chunksCollections, chunksTarget)
+	}
+
+	for i := range repositoriesCollections {
+		if err := qdrantConnection.SetRepositoryAttributes(ctx, repositoriesCollections[i], chunksCollections[i], int64(repo.ID), attributes); err != nil {
+			log.Printf("Failed to update Qdrant attributes for %s: %v", repo.FullName, err)
+		}
+	}
+}
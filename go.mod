@@ -19,8 +19,14 @@ require (
 	github.com/mitchellh/hashstructure v1.1.0
 	github.com/qdrant/go-client v1.14.1
 	github.com/redis/go-redis/v9 v9.0.5
+	github.com/rivo/uniseg v0.4.7
 	github.com/streadway/amqp v1.1.0
+	github.com/yuin/goldmark v1.8.6
+	github.com/yuin/goldmark-emoji v1.0.6
+	golang.org/x/net v0.42.0
+	golang.org/x/text v0.27.0
 	google.golang.org/grpc v1.74.2
+	google.golang.org/protobuf v1.36.6
 )
 
 require (
@@ -80,11 +86,8 @@ require (
 	go.opentelemetry.io/proto/otlp v1.7.1 // indirect
 	golang.org/x/arch v0.18.0 // indirect
 	golang.org/x/crypto v0.40.0 // indirect
-	golang.org/x/net v0.42.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
-	golang.org/x/text v0.27.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250728155136-f173205681a0 // indirect
-	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	gotest.tools/v3 v3.5.1 // indirect
 )
@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -22,24 +23,106 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/teomiscia/github-trending/internal/config"
 	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/mailer"
+	"github.com/teomiscia/github-trending/internal/messaging"
 	"github.com/teomiscia/github-trending/internal/models"
+	"github.com/teomiscia/github-trending/internal/readme"
 )
 
-func NewServer(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection, minioConnection *database.MinioConnection) *gin.Engine {
+// NewServer returns the API router and a function that writes out the buffered feedback
+// events, to be called once the server has stopped serving requests.
+func NewServer(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection, minioConnection *database.MinioConnection, qdrantConnection *database.QdrantConnection, mqConnection messaging.MQConnection) (*gin.Engine, func()) {
 	router := gin.Default()
 
 	// Add CORS middleware
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowAllOrigins = true
+	corsConfig.AddAllowHeaders("Authorization")
 	router.Use(cors.New(corsConfig))
 
-	router.GET("/retrieveList", handleRetrieveList(cfg, redisClient, pgdb, chdb))
+	// Logged-in users are identified by their session token; everyone else by the
+	// anonymous sessionId their client sends.
+	if cfg.SessionSecret != "" {
+		router.Use(authenticate([]byte(cfg.SessionSecret)))
+	}
+
+	// Confirmation emails are only sent when an SMTP server is configured.
+	var emails *mailer.Mailer
+	if cfg.SMTPHost != "" {
+		emails = mailer.New(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+
+	events := newEventBuffer(chdb, eventBatchSize)
+	go events.run(eventFlushInterval)
+
+	router.GET("/retrieveList", handleRetrieveList(cfg, redisClient, pgdb, chdb, qdrantConnection))
 	router.POST("/trackOpenRepository", handleTrackOpenRepository(cfg, redisClient, pgdb))
-	router.GET("/getReadme", handleGetReadme(cfg, redisClient, minioConnection))
+	router.GET("/getReadme", handleGetReadme(cfg, redisClient, pgdb, minioConnection))
 	router.GET("/repository/:id", handleGetRepositoryDetails(cfg, redisClient, pgdb, chdb))
+	router.GET("/repository/:id/history", handleGetRepositoryHistory(cfg, redisClient, chdb))
 	router.GET("/api/og", handleGenerateOGImage(cfg))
+	router.GET("/dependents", handleGetDependents(cfg, redisClient, pgdb, chdb))
+	router.GET("/dependencies/popular", handleGetPopularDependencies(cfg, redisClient, pgdb, chdb))
+	router.GET("/embeddings/coverage", handleGetEmbeddingCoverage(cfg, pgdb))
+	router.POST("/events", handlePostEvents(cfg, redisClient, events))
+	router.GET("/feeds/trending.rss", handleTrendingFeed(cfg, redisClient, pgdb, chdb, feedRSS))
+	router.GET("/feeds/trending.atom", handleTrendingFeed(cfg, redisClient, pgdb, chdb, feedAtom))
+	router.GET("/feeds/trending.json", handleTrendingFeed(cfg, redisClient, pgdb, chdb, feedJSON))
+	importer := &starImporter{redisClient: redisClient, pgdb: pgdb, mq: mqConnection}
+
+	router.GET("/me", handleGetMe(cfg, pgdb))
+	router.POST("/me/stars/import", handleImportStars(cfg, pgdb, importer))
+	router.POST("/me/session/merge", handleMergeSession(cfg, redisClient, pgdb))
+	router.GET("/collections", handleListCollections(cfg, pgdb))
+	router.POST("/collections", handleCreateCollection(cfg, pgdb))
+	router.GET("/collections/:id", handleGetCollection(cfg, pgdb))
+	router.PATCH("/collections/:id", handleUpdateCollection(cfg, pgdb))
+	router.DELETE("/collections/:id", handleDeleteCollection(cfg, pgdb))
+	router.POST("/collections/:id/items", handleAddCollectionItem(cfg, redisClient, pgdb))
+	router.PATCH("/collections/:id/items/:repoId", handleUpdateCollectionItem(cfg, pgdb))
+	router.DELETE("/collections/:id/items/:repoId", handleRemoveCollectionItem(cfg, pgdb))
+	router.PUT("/collections/:id/order", handleReorderCollection(cfg, pgdb))
+	router.POST("/collections/:id/share", handleShareCollection(cfg, pgdb))
+	router.DELETE("/collections/:id/share", handleUnshareCollection(cfg, pgdb))
+	router.GET("/collections/:id/feed", handleGetCollectionFeed(cfg, redisClient, pgdb, chdb))
+	router.GET("/shared/:token", handleGetSharedCollection(cfg, pgdb))
+	router.GET("/shared/:token/feed", handleGetSharedCollectionFeed(cfg, redisClient, pgdb, chdb))
+	router.GET("/watches", handleListWatches(cfg, pgdb))
+	router.POST("/watches", handleCreateWatch(cfg, pgdb, emails))
+	router.DELETE("/watches/:id", handleDeleteWatch(cfg, pgdb))
+	router.GET("/watches/confirm", handleConfirmWatchEmail(cfg, pgdb))
+	router.POST("/watches/confirm", handleConfirmWatchEmail(cfg, pgdb))
+	router.GET("/watches/unsubscribe", handleUnsubscribeWatchEmail(cfg, pgdb))
+	router.POST("/watches/unsubscribe", handleUnsubscribeWatchEmail(cfg, pgdb))
+	router.GET("/notifications", handleGetNotifications(cfg, pgdb))
+	router.POST("/notifications/read", handleMarkNotificationsRead(cfg, pgdb))
+	router.GET("/digests", handleListDigests(cfg, pgdb))
+	router.POST("/digests", handleCreateDigest(cfg, pgdb, emails))
+	router.PATCH("/digests/:id", handleUpdateDigest(cfg, pgdb))
+	router.DELETE("/digests/:id", handleDeleteDigest(cfg, pgdb))
+	router.GET("/digests/confirm", handleConfirmDigest(cfg, pgdb))
+	router.POST("/digests/confirm", handleConfirmDigest(cfg, pgdb))
+	router.GET("/digests/unsubscribe", handleUnsubscribeDigest(cfg, pgdb))
+	router.POST("/digests/unsubscribe", handleUnsubscribeDigest(cfg, pgdb))
+	router.POST("/auth/logout", handleLogout(cfg))
+	if cfg.GitHubClientID != "" {
+		oauth := newGitHubOAuth(cfg)
+		router.GET("/auth/github/login", handleGitHubLogin(cfg, redisClient, oauth))
+		router.GET("/auth/github/callback", handleGitHubCallback(cfg, redisClient, pgdb, oauth, importer))
+	}
+	if cfg.AdminToken != "" {
+		admin := router.Group("/admin", requireAdmin(cfg))
+		admin.GET("/webhooks", handleListWebhooks(cfg, pgdb))
+		admin.POST("/webhooks", handleCreateWebhook(cfg, pgdb))
+		admin.GET("/webhooks/:id", handleGetWebhook(cfg, pgdb))
+		admin.PATCH("/webhooks/:id", handleUpdateWebhook(cfg, pgdb))
+		admin.DELETE("/webhooks/:id", handleDeleteWebhook(cfg, pgdb))
+		admin.GET("/webhooks/:id/deliveries", handleGetWebhookDeliveries(cfg, pgdb))
+		admin.GET("/deliveries/:id", handleGetWebhookDelivery(cfg, pgdb))
+		admin.POST("/deliveries/:id/replay", handleReplayWebhookDelivery(cfg, pgdb, mqConnection))
+	}
 
-	return router
+	return router, events.Flush
 }
 
 func handleGenerateOGImage(cfg *config.Config) gin.HandlerFunc {
@@ -80,13 +163,13 @@ func handleGetRepositoryDetails(cfg *config.Config, redisClient *redis.Client, p
 			return
 		}
 
-		sessionID := c.Query("sessionId")
+		sessionID, userID := sessionFromRequest(c, c.Query("sessionId"))
 		if sessionID == "" {
 			sessionID = uuid.New().String()
 		}
 
 		// Track the view first
-		if err := pgdb.TrackRepositoryView(sessionID, repoID); err != nil {
+		if err := pgdb.TrackRepositoryView(sessionID, userID, repoID); err != nil {
 			// Log the error but don't block the user
 			log.Printf("Failed to track repository view for repo %d: %v", repoID, err)
 		}
@@ -127,6 +210,61 @@ func handleGetRepositoryDetails(cfg *config.Config, redisClient *redis.Client, p
 	}
 }
 
+func handleGetRepositoryHistory(cfg *config.Config, redisClient *redis.Client, chdb *database.ClickHouseConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repoIDStr := c.Param("id")
+		repoID, err := strconv.ParseInt(repoIDStr, 10, 64)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid repository ID", err, cfg.Debug)
+			return
+		}
+
+		type RepositoryHistoryResponse struct {
+			Stats    []models.RepositoryStat     `json:"stats"`
+			Activity []models.RepositoryActivity `json:"activity"`
+		}
+
+		// --- Cache Check ---
+		cacheKey := fmt.Sprintf("repo_history:%d", repoID)
+		cachedHistory, err := redisClient.Get(c.Request.Context(), cacheKey).Result()
+		if err == nil {
+			decompressed, err := decompress([]byte(cachedHistory))
+			if err == nil {
+				var response RepositoryHistoryResponse
+				if err := json.Unmarshal(decompressed, &response); err == nil {
+					c.JSON(http.StatusOK, response)
+					return
+				}
+			}
+		}
+
+		stats, err := chdb.GetRepositoryStats(repoID)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve repository stats", err, cfg.Debug)
+			return
+		}
+
+		activity, err := chdb.GetRepositoryActivity(repoID)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve repository activity", err, cfg.Debug)
+			return
+		}
+
+		response := RepositoryHistoryResponse{
+			Stats:    stats,
+			Activity: activity,
+		}
+
+		// --- Cache Result ---
+		jsonBytes, err := json.Marshal(response)
+		if err == nil {
+			redisClient.Set(c.Request.Context(), cacheKey, compress(jsonBytes), 12*time.Hour)
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
 func errorResponse(c *gin.Context, code int, genericMessage string, err error, debug bool) {
 	response := gin.H{"error": genericMessage}
 	if debug && err != nil {
@@ -143,9 +281,12 @@ func decompress(data []byte) ([]byte, error) {
 	return snappy.Decode(nil, data)
 }
 
-func handleRetrieveList(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection) gin.HandlerFunc {
+// retrieveListPageSize is the number of repositories per page of /retrieveList.
+const retrieveListPageSize = 50
+
+func handleRetrieveList(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection, qdrantConnection *database.QdrantConnection) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		originalSessionID := c.Query("sessionId")
+		originalSessionID, userID := sessionFromRequest(c, c.Query("sessionId"))
 		isNewSession := originalSessionID == ""
 		sessionID := originalSessionID
 		if isNewSession {
@@ -173,10 +314,17 @@ func handleRetrieveList(cfg *config.Config, redisClient *redis.Client, pgdb *dat
 		pageStr := c.Query("page")
 		page, _ := strconv.Atoi(pageStr)
 
+		// Dismissals change the list, so their count is part of the cache keys: pages
+		// cached before the session's latest dismissal are not served again.
+		var dismissals int64
+		if !isNewSession {
+			dismissals, _ = redisClient.SCard(c.Request.Context(), dismissedKey(sessionID)).Result()
+		}
+
 		// --- Overall Response Cache Check ---
 		buildCacheKey := func(sID string) string {
 			keyBuilder := strings.Builder{}
-			keyBuilder.WriteString(fmt.Sprintf("retrieveList:%s:%s:%s:%s:%s", sID, strings.Join(languages, ","), strings.Join(tags, ","), strings.Join(topics, ","), pageStr))
+			keyBuilder.WriteString(fmt.Sprintf("retrieveList:%s:%d:%s:%s:%s:%s", sID, dismissals, strings.Join(languages, ","), strings.Join(tags, ","), strings.Join(topics, ","), pageStr))
 			return fmt.Sprintf("cache:%x", sha256.Sum256([]byte(keyBuilder.String())))
 		}
 
@@ -198,6 +346,7 @@ func handleRetrieveList(cfg *config.Config, redisClient *redis.Client, pgdb *dat
 		// --- End of Cache Check ---
 
 		var recommendedRepoIDs []int64
+		candidateScores := make(map[int64]float64)
 
 		// 1. Query the repository_views table for user history
 		userHistoryRepoIDs, err := pgdb.GetRecentClickedRepositoryIDs(sessionID, 15)
@@ -205,50 +354,52 @@ func handleRetrieveList(cfg *config.Config, redisClient *redis.Client, pgdb *dat
 			log.Printf("Failed to get user history from Postgres: %v", err)
 		}
 
-		if len(userHistoryRepoIDs) == 0 {
-			// --- Generic Trending Logic ---
-			trendingCacheKey := "trending_repo_ids_by_growth:30"
-			cachedTrending, err := redisClient.Get(c.Request.Context(), trendingCacheKey).Result()
-			if err == nil {
-				decompressed, err := decompress([]byte(cachedTrending))
-				if err == nil {
-					json.Unmarshal(decompressed, &recommendedRepoIDs)
-				}
-			}
+		// 2. Repositories saved in collections are a stronger signal than views
+		savedRepoIDs, err := pgdb.GetSavedRepositoryIDs(sessionID, maxSavedSignals)
+		if err != nil {
+			log.Printf("Failed to get saved repositories from Postgres: %v", err)
+		}
 
-			if len(recommendedRepoIDs) == 0 {
-				trendingRepoIDs, err := chdb.GetTrendingRepositoryIDsByGrowth(30)
-				if err != nil {
-					errorResponse(c, http.StatusInternalServerError, "Failed to retrieve trending repository list from ClickHouse", err, cfg.Debug)
-					return
-				}
-				recommendedRepoIDs = trendingRepoIDs
-				jsonBytes, err := json.Marshal(recommendedRepoIDs)
-				if err == nil {
-					redisClient.Set(c.Request.Context(), trendingCacheKey, compress(jsonBytes), 24*time.Hour)
-				}
+		// 3. GitHub stars seed the recommendations of logged-in users from the start
+		var starredRepoIDs []int64
+		if userID > 0 {
+			starredRepoIDs, err = pgdb.GetStarredRepositoryIDs(userID, maxStarredSignals)
+			if err != nil {
+				log.Printf("Failed to get starred repositories from Postgres: %v", err)
 			}
-		} else {
-			// --- Personalized Recommendation Logic ---
-			candidateScores := make(map[int64]float64)
-			for _, historyRepoID := range userHistoryRepoIDs {
-				similarRepos, err := pgdb.GetRepositorySimilarity(historyRepoID)
-				if err != nil {
-					log.Printf("Failed to get similar repos for %d: %v", historyRepoID, err)
-					continue
-				}
+		}
+		personalised := len(userHistoryRepoIDs) > 0 || len(savedRepoIDs) > 0 || len(starredRepoIDs) > 0
 
-				var zMembers []redis.Z
-				if err := json.Unmarshal(similarRepos, &zMembers); err != nil {
-					log.Printf("Failed to unmarshal similarity data for repo %d: %v", historyRepoID, err)
-					continue
-				}
+		// Personalised lists are re-ranked once per session and filters; the following
+		// pages are sliced from the stored order.
+		rankedKey := rankedListCacheKey(sessionID, dismissals, languages, tags, topics)
+		var finalRepoIDs []int64
+		ranked := false
+		if personalised {
+			finalRepoIDs, ranked = getRankedList(c.Request.Context(), redisClient, rankedKey)
+		}
 
-				for _, z := range zMembers {
-					repoID, _ := strconv.ParseInt(fmt.Sprintf("%.0f", z.Member), 10, 64)
-					candidateScores[repoID] += z.Score
-				}
+		if !personalised {
+			// --- Generic Trending Logic ---
+			recommendedRepoIDs, err = getTrendingRepositoryIDs(c.Request.Context(), redisClient, chdb, 30)
+			if err != nil {
+				errorResponse(c, http.StatusInternalServerError, "Failed to retrieve trending repository list from ClickHouse", err, cfg.Debug)
+				return
 			}
+		} else if !ranked {
+			// --- Personalized Recommendation Logic ---
+			// With language or topic filters, neighbours are searched in Qdrant with the
+			// filters rather than taken from the stored lists and filtered afterwards.
+			similar := pgdb.GetRepositorySimilarity
+			if qdrantConnection != nil && (len(languages) > 0 || len(topics) > 0) {
+				similar = filteredSimilarity(c.Request.Context(), qdrantConnection, cfg.SimilarityListSize, languages, topics)
+			}
+			addSignalScores(candidateScores, userHistoryRepoIDs, 1, cfg.CoViewWeight, similar, pgdb.GetRepositoryCoViews)
+			addSignalScores(candidateScores, savedRepoIDs, savedSignalWeight, cfg.CoViewWeight, similar, pgdb.GetRepositoryCoViews)
+			addSignalScores(candidateScores, starredRepoIDs, starredSignalWeight, cfg.CoViewWeight, similar, pgdb.GetRepositoryCoViews)
+
+			// Repositories the session dismissed push their neighbours down.
+			applyDismissals(c.Request.Context(), redisClient, pgdb, sessionID, candidateScores)
 
 			type scoredRepo struct {
 				ID    int64
@@ -266,53 +417,59 @@ func handleRetrieveList(cfg *config.Config, redisClient *redis.Client, pgdb *dat
 			}
 		}
 
-		// Filter if needed
-		if len(languages) > 0 || len(tags) > 0 || len(topics) > 0 {
-			keyBuilder := strings.Builder{}
-			for _, id := range recommendedRepoIDs {
-				keyBuilder.WriteString(strconv.FormatInt(id, 10))
-			}
-			filterCacheKey := fmt.Sprintf("filtered_ids:%x:%s:%s:%s", sha256.Sum256([]byte(keyBuilder.String())), strings.Join(languages, ","), strings.Join(tags, ","), strings.Join(topics, ","))
-
-			cachedFiltered, err := redisClient.Get(c.Request.Context(), filterCacheKey).Result()
-			if err == nil {
-				decompressed, err := decompress([]byte(cachedFiltered))
-				if err == nil {
-					json.Unmarshal(decompressed, &recommendedRepoIDs)
-				}
-			} else {
-				filteredRepoIDs, err := pgdb.FilterRepositoryIDs(recommendedRepoIDs, languages, tags, topics)
-				if err != nil {
-					errorResponse(c, http.StatusInternalServerError, "Failed to filter repository list", err, cfg.Debug)
-					return
+		if !ranked {
+			// Filter if needed. Tags, co-viewed and trending repositories are only filtered here.
+			if len(languages) > 0 || len(tags) > 0 || len(topics) > 0 {
+				keyBuilder := strings.Builder{}
+				for _, id := range recommendedRepoIDs {
+					keyBuilder.WriteString(strconv.FormatInt(id, 10))
 				}
-				recommendedRepoIDs = filteredRepoIDs
-				jsonBytes, err := json.Marshal(recommendedRepoIDs)
+				filterCacheKey := fmt.Sprintf("filtered_ids:%x:%s:%s:%s", sha256.Sum256([]byte(keyBuilder.String())), strings.Join(languages, ","), strings.Join(tags, ","), strings.Join(topics, ","))
+
+				cachedFiltered, err := redisClient.Get(c.Request.Context(), filterCacheKey).Result()
 				if err == nil {
-					redisClient.Set(c.Request.Context(), filterCacheKey, compress(jsonBytes), 24*time.Hour)
+					decompressed, err := decompress([]byte(cachedFiltered))
+					if err == nil {
+						json.Unmarshal(decompressed, &recommendedRepoIDs)
+					}
+				} else {
+					filteredRepoIDs, err := pgdb.FilterRepositoryIDs(recommendedRepoIDs, languages, tags, topics)
+					if err != nil {
+						errorResponse(c, http.StatusInternalServerError, "Failed to filter repository list", err, cfg.Debug)
+						return
+					}
+					recommendedRepoIDs = filteredRepoIDs
+					jsonBytes, err := json.Marshal(recommendedRepoIDs)
+					if err == nil {
+						redisClient.Set(c.Request.Context(), filterCacheKey, compress(jsonBytes), 24*time.Hour)
+					}
 				}
 			}
-		}
 
-		// Filter out seen repos
-		seenRepoIDs, _ := redisClient.SMembers(context.Background(), sessionID).Result()
-		seenRepoIDMap := make(map[int64]bool)
-		for _, idStr := range seenRepoIDs {
-			id, _ := strconv.ParseInt(idStr, 10, 64)
-			seenRepoIDMap[id] = true
-		}
+			// Filter out seen repos
+			seenRepoIDs, _ := redisClient.SMembers(context.Background(), sessionID).Result()
+			seenRepoIDMap := make(map[int64]bool)
+			for _, idStr := range seenRepoIDs {
+				id, _ := strconv.ParseInt(idStr, 10, 64)
+				seenRepoIDMap[id] = true
+			}
 
-		var finalRepoIDs []int64
-		for _, id := range recommendedRepoIDs {
-			if !seenRepoIDMap[id] {
-				finalRepoIDs = append(finalRepoIDs, id)
+			for _, id := range recommendedRepoIDs {
+				if !seenRepoIDMap[id] {
+					finalRepoIDs = append(finalRepoIDs, id)
+				}
+			}
+
+			// Spread similar repositories and owners across pages and mix in trending ones.
+			if personalised {
+				finalRepoIDs = rerankRecommendations(c.Request.Context(), cfg, redisClient, pgdb, chdb, sessionID, finalRepoIDs, candidateScores, seenRepoIDMap, languages, tags, topics)
+				setRankedList(c.Request.Context(), redisClient, rankedKey, finalRepoIDs)
 			}
 		}
 
 		// Paginate
-		pageSize := 50
-		start := page * pageSize
-		end := start + pageSize
+		start := page * retrieveListPageSize
+		end := start + retrieveListPageSize
 		if start > len(finalRepoIDs) {
 			finalRepoIDs = []int64{}
 		} else if end > len(finalRepoIDs) {
@@ -388,6 +545,81 @@ func handleRetrieveList(cfg *config.Config, redisClient *redis.Client, pgdb *dat
 	}
 }
 
+const (
+	// maxSavedSignals caps the saved repositories whose neighbours are recommended, and
+	// savedSignalWeight is their weight relative to a viewed repository.
+	maxSavedSignals   = 15
+	savedSignalWeight = 2.0
+)
+
+// addSignalScores adds the neighbours of each signal repository (viewed, saved or
+// starred) to candidateScores, multiplied by weight. Content-based neighbours (similar
+// READMEs, topics...) and behavioural ones (viewed in the same sessions) are mixed by
+// coViewWeight. similar and coViewed return a repository's stored neighbour lists.
+func addSignalScores(candidateScores map[int64]float64, repoIDs []int64, weight, coViewWeight float64, similar, coViewed func(repoID int64) ([]byte, error)) {
+	for _, repoID := range repoIDs {
+		similarRepos, err := similar(repoID)
+		if err != nil {
+			log.Printf("Failed to get similar repos for %d: %v", repoID, err)
+		} else if err := addNeighbourScores(candidateScores, similarRepos, weight*(1-coViewWeight)); err != nil {
+			log.Printf("Failed to unmarshal similarity data for repo %d: %v", repoID, err)
+		}
+
+		if coViewWeight > 0 {
+			coViewedRepos, err := coViewed(repoID)
+			if err != nil {
+				log.Printf("Failed to get co-viewed repos for %d: %v", repoID, err)
+			} else if err := addNeighbourScores(candidateScores, coViewedRepos, weight*coViewWeight); err != nil {
+				log.Printf("Failed to unmarshal co-view data for repo %d: %v", repoID, err)
+			}
+		}
+	}
+}
+
+// addNeighbourScores adds the weighted scores of a stored neighbour list (similarity or
+// co-views) to candidateScores. An empty list adds nothing.
+func addNeighbourScores(candidateScores map[int64]float64, data []byte, weight float64) error {
+	if len(data) == 0 {
+		return nil
+	}
+	var zMembers []redis.Z
+	if err := json.Unmarshal(data, &zMembers); err != nil {
+		return err
+	}
+	for _, z := range zMembers {
+		repoID, _ := strconv.ParseInt(fmt.Sprintf("%.0f", z.Member), 10, 64)
+		candidateScores[repoID] += weight * z.Score
+	}
+	return nil
+}
+
+// getTrendingRepositoryIDs returns the repositories with the highest growth over the given number of days,
+// using Redis as a cache in front of ClickHouse.
+func getTrendingRepositoryIDs(ctx context.Context, redisClient *redis.Client, chdb *database.ClickHouseConnection, days int) ([]int64, error) {
+	var trendingRepoIDs []int64
+	trendingCacheKey := fmt.Sprintf("trending_repo_ids_by_growth:%d", days)
+	cachedTrending, err := redisClient.Get(ctx, trendingCacheKey).Result()
+	if err == nil {
+		decompressed, err := decompress([]byte(cachedTrending))
+		if err == nil {
+			json.Unmarshal(decompressed, &trendingRepoIDs)
+		}
+	}
+
+	if len(trendingRepoIDs) == 0 {
+		trendingRepoIDs, err = chdb.GetTrendingRepositoryIDsByGrowth(days)
+		if err != nil {
+			return nil, err
+		}
+		jsonBytes, err := json.Marshal(trendingRepoIDs)
+		if err == nil {
+			redisClient.Set(ctx, trendingCacheKey, compress(jsonBytes), 24*time.Hour)
+		}
+	}
+
+	return trendingRepoIDs, nil
+}
+
 func handleTrackOpenRepository(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var requestBody struct {
@@ -400,19 +632,24 @@ func handleTrackOpenRepository(cfg *config.Config, redisClient *redis.Client, pg
 			return
 		}
 
-		if err := pgdb.TrackRepositoryView(requestBody.SessionID, requestBody.RepositoryID); err != nil {
+		sessionID, userID := sessionFromRequest(c, requestBody.SessionID)
+		if sessionID == "" {
+			errorResponse(c, http.StatusBadRequest, "sessionId is required", nil, cfg.Debug)
+			return
+		}
+		if err := pgdb.TrackRepositoryView(sessionID, userID, requestBody.RepositoryID); err != nil {
 			errorResponse(c, http.StatusInternalServerError, "Failed to track repository view", err, cfg.Debug)
 			return
 		}
 
 		// Add the repository to the seen set in Redis
-		redisClient.SAdd(context.Background(), requestBody.SessionID, fmt.Sprint(requestBody.RepositoryID))
+		redisClient.SAdd(context.Background(), sessionID, fmt.Sprint(requestBody.RepositoryID))
 
 		c.JSON(http.StatusOK, gin.H{"status": "success"})
 	}
 }
 
-func handleGetReadme(cfg *config.Config, redisClient *redis.Client, minioConnection *database.MinioConnection) gin.HandlerFunc {
+func handleGetReadme(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, minioConnection *database.MinioConnection) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		repoID := c.Query("repoId")
 		if repoID == "" {
@@ -441,36 +678,40 @@ func handleGetReadme(cfg *config.Config, redisClient *redis.Client, minioConnect
 			return
 		}
 
-		// Call the markup service to convert Markdown to HTML
-		reqBody, err := json.Marshal(map[string]string{
-			"text": string(readmeContent),
-		})
-		if err != nil {
-			errorResponse(c, http.StatusInternalServerError, "Failed to create request body for markup service", err, cfg.Debug)
-			return
+		// READMEs without a recorded format predate format detection and are markdown.
+		readmeFormat := readme.FormatMarkdown
+		var source readme.Source
+		if id, err := strconv.ParseInt(repoID, 10, 64); err == nil {
+			repo, err := pgdb.GetRepositoryByID(id)
+			if err != nil {
+				log.Printf("Failed to load repository %s for README rendering: %v", repoID, err)
+			} else {
+				if repo.ReadmeFormat.Valid {
+					readmeFormat = repo.ReadmeFormat.String
+				}
+				source = readme.Source{FullName: repo.FullName, DefaultBranch: repo.DefaultBranch, Path: repo.ReadmePath.String}
+			}
 		}
 
-		resp, err := http.Post("http://markup-service:80/markup", "application/json", bytes.NewBuffer(reqBody))
-		if err != nil {
-			errorResponse(c, http.StatusInternalServerError, "Failed to call markup service", err, cfg.Debug)
-			return
+		rendered, err := readme.Render(readmeFormat, readmeContent)
+		// The markup service renders markdown, which is also the best guess for
+		// formats the native renderers do not support.
+		if err != nil && (readmeFormat == readme.FormatMarkdown || errors.Is(err, readme.ErrUnsupportedFormat)) && cfg.MarkupServiceURL != "" {
+			log.Printf("Native rendering failed for repo %s, falling back to markup service: %v", repoID, err)
+			rendered, err = renderWithMarkupService(cfg.MarkupServiceURL, readmeContent)
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			errorResponse(c, http.StatusInternalServerError, fmt.Sprintf("Markup service returned non-OK status: %d, body: %s", resp.StatusCode, string(bodyBytes)), nil, cfg.Debug)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to render README", err, cfg.Debug)
 			return
 		}
 
-		var result map[string]string
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			errorResponse(c, http.StatusInternalServerError, "Failed to decode markup service response", err, cfg.Debug)
+		// Resolve relative links and images against the repository and sanitise the markup.
+		htmlContent, err := readme.PostProcess([]byte(rendered), source)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to process README HTML", err, cfg.Debug)
 			return
 		}
 
-		htmlContent := []byte(result["html"])
-
 		// --- Cache Result ---
 		compressedHTML := compress(htmlContent)
 		err = redisClient.Set(c.Request.Context(), cacheKey, compressedHTML, 24*time.Hour).Err()
@@ -481,3 +722,30 @@ func handleGetReadme(cfg *config.Config, redisClient *redis.Client, minioConnect
 		c.Data(http.StatusOK, "text/html; charset=utf-8", htmlContent)
 	}
 }
+
+// renderWithMarkupService converts markdown to HTML using the external markup service.
+func renderWithMarkupService(serviceURL string, content []byte) (string, error) {
+	reqBody, err := json.Marshal(map[string]string{
+		"text": string(content),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create request body for markup service: %w", err)
+	}
+
+	resp, err := http.Post(strings.TrimRight(serviceURL, "/")+"/markup", "application/json", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to call markup service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("markup service returned non-OK status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode markup service response: %w", err)
+	}
+	return result["html"], nil
+}
@@ -0,0 +1,442 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+const (
+	// sessionCookieName is the cookie holding the session token of a logged-in user.
+	// Clients that cannot use cookies send the token as "Authorization: Bearer <token>".
+	sessionCookieName = "session"
+	sessionTokenTTL   = 30 * 24 * time.Hour
+	// oauthStateTTL bounds the time a user has to approve the login on GitHub.
+	oauthStateTTL = 10 * time.Minute
+	// oauthStateCookieName is the cookie binding the OAuth state to the browser that
+	// started the login, so that a login cannot be completed in another browser.
+	oauthStateCookieName = "oauth_state"
+	// userIDContextKey holds the ID of the logged-in user in the gin context.
+	userIDContextKey = "userID"
+	// userSessionPrefix starts the session IDs of logged-in users; anonymous clients
+	// cannot use them.
+	userSessionPrefix = "user:"
+
+	githubAuthorizeURL = "https://github.com/login/oauth/authorize"
+	githubTokenURL     = "https://github.com/login/oauth/access_token"
+	githubAPIURL       = "https://api.github.com"
+)
+
+var errInvalidSessionToken = errors.New("invalid session token")
+
+// userSessionID is the session of a logged-in user. Views, seen repositories and
+// dismissals are recorded under it, so they follow the user across devices.
+func userSessionID(userID int64) string {
+	return fmt.Sprintf("%s%d", userSessionPrefix, userID)
+}
+
+// sessionClaims is the payload of a session token.
+type sessionClaims struct {
+	UserID int64 `json:"uid"`
+	Expiry int64 `json:"exp"`
+}
+
+// signSessionToken returns a token for the user, valid until expiry: the base64
+// encoded claims and their HMAC-SHA256, separated by a dot.
+func signSessionToken(secret []byte, userID int64, expiry time.Time) string {
+	payload, _ := json.Marshal(sessionClaims{UserID: userID, Expiry: expiry.Unix()})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(tokenSignature(secret, encoded))
+}
+
+// verifySessionToken checks a token's signature and expiry and returns its user ID.
+func verifySessionToken(secret []byte, token string, now time.Time) (int64, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, errInvalidSessionToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, tokenSignature(secret, encoded)) {
+		return 0, errInvalidSessionToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, errInvalidSessionToken
+	}
+	var claims sessionClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.UserID <= 0 {
+		return 0, errInvalidSessionToken
+	}
+	if now.Unix() >= claims.Expiry {
+		return 0, fmt.Errorf("%w: expired", errInvalidSessionToken)
+	}
+	return claims.UserID, nil
+}
+
+// randomToken returns n random bytes, hex encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func tokenSignature(secret []byte, encoded string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// authenticate identifies the logged-in user from the bearer token or the session
+// cookie. Requests without a valid token go on anonymously.
+func authenticate(secret []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := ""
+		if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			token = strings.TrimPrefix(header, "Bearer ")
+		} else if cookie, err := c.Cookie(sessionCookieName); err == nil {
+			token = cookie
+		}
+		if token != "" {
+			if userID, err := verifySessionToken(secret, token, time.Now()); err == nil {
+				c.Set(userIDContextKey, userID)
+			}
+		}
+		c.Next()
+	}
+}
+
+// sessionFromRequest returns the session of a request and its user ID: the user's
+// session when logged in, otherwise the anonymous session the client sent (user
+// sessions excepted) and 0. The session is empty when the client did not send one.
+func sessionFromRequest(c *gin.Context, requested string) (string, int64) {
+	if userID := c.GetInt64(userIDContextKey); userID > 0 {
+		return userSessionID(userID), userID
+	}
+	if strings.HasPrefix(requested, userSessionPrefix) {
+		return "", 0
+	}
+	return requested, 0
+}
+
+// requestUserSession returns the session and ID of the logged-in user, or answers 401
+// when the request is anonymous.
+func requestUserSession(c *gin.Context, cfg *config.Config) (string, int64, bool) {
+	userID := c.GetInt64(userIDContextKey)
+	if userID == 0 {
+		errorResponse(c, http.StatusUnauthorized, "Not logged in", nil, cfg.Debug)
+		return "", 0, false
+	}
+	return userSessionID(userID), userID, true
+}
+
+// githubOAuth implements GitHub's web application flow.
+type githubOAuth struct {
+	clientID     string
+	clientSecret string
+	callbackURL  string
+	authorizeURL string
+	tokenURL     string
+	apiURL       string
+	httpClient   *http.Client
+}
+
+func newGitHubOAuth(cfg *config.Config) *githubOAuth {
+	return &githubOAuth{
+		clientID:     cfg.GitHubClientID,
+		clientSecret: cfg.GitHubClientSecret,
+		callbackURL:  cfg.GitHubOAuthCallbackURL,
+		authorizeURL: githubAuthorizeURL,
+		tokenURL:     githubTokenURL,
+		apiURL:       githubAPIURL,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// authCodeURL is the GitHub page asking the user to approve the login.
+func (o *githubOAuth) authCodeURL(state string) string {
+	params := url.Values{
+		"client_id":    {o.clientID},
+		"redirect_uri": {o.callbackURL},
+		"scope":        {"read:user"},
+		"state":        {state},
+	}
+	return o.authorizeURL + "?" + params.Encode()
+}
+
+// exchange trades the code GitHub sent to the callback for an access token.
+func (o *githubOAuth) exchange(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"client_id":     {o.clientID},
+		"client_secret": {o.clientSecret},
+		"code":          {code},
+		"redirect_uri":  {o.callbackURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call GitHub token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("GitHub token endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	// GitHub reports a bad code with a 200 and an error field.
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode GitHub token response: %w", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("GitHub token endpoint returned %s: %s", result.Error, result.ErrorDescription)
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("GitHub token endpoint returned no access token")
+	}
+	return result.AccessToken, nil
+}
+
+// fetchUser returns the profile of the user the access token belongs to.
+func (o *githubOAuth) fetchUser(ctx context.Context, accessToken string) (models.User, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.apiURL+"/user", nil)
+	if err != nil {
+		return models.User{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to fetch GitHub user: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return models.User{}, fmt.Errorf("GitHub user endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var profile struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return models.User{}, fmt.Errorf("failed to decode GitHub user: %w", err)
+	}
+	if profile.ID == 0 || profile.Login == "" {
+		return models.User{}, fmt.Errorf("GitHub user has no id or login")
+	}
+	return models.User{GitHubID: profile.ID, Login: profile.Login, Name: profile.Name, AvatarURL: profile.AvatarURL}, nil
+}
+
+func oauthStateKey(state string) string {
+	return fmt.Sprintf("oauth_state:%s", state)
+}
+
+// handleGitHubLogin redirects to GitHub. The OAuth state is kept in Redis, so that it
+// can be used once, and in an HttpOnly cookie, so that the callback only completes a
+// login started by the same browser.
+func handleGitHubLogin(cfg *config.Config, redisClient *redis.Client, oauth *githubOAuth) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state, err := randomToken(32)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to start login", err, cfg.Debug)
+			return
+		}
+		if err := redisClient.Set(c.Request.Context(), oauthStateKey(state), 1, oauthStateTTL).Err(); err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to start login", err, cfg.Debug)
+			return
+		}
+
+		// GitHub redirects back with a top-level navigation, which Lax cookies survive.
+		secure := strings.HasPrefix(cfg.GitHubOAuthCallbackURL, "https://")
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(oauthStateCookieName, state, int(oauthStateTTL/time.Second), "/auth/github", "", secure, true)
+		c.Redirect(http.StatusFound, oauth.authCodeURL(state))
+	}
+}
+
+// oauthStateMatches reports whether the state GitHub sent back is the one stored in the
+// state cookie of the request.
+func oauthStateMatches(c *gin.Context, state string) bool {
+	cookie, err := c.Cookie(oauthStateCookieName)
+	if err != nil || cookie == "" || state == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) == 1
+}
+
+// handleGitHubCallback completes the login: it checks the state against the state
+// cookie, creates or updates the user, imports the stars of new users, and hands out a
+// session token both as a cookie and in the fragment of the redirect to the app.
+func handleGitHubCallback(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, oauth *githubOAuth, importer *starImporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		state, code := c.Query("state"), c.Query("code")
+		if state == "" || code == "" {
+			errorResponse(c, http.StatusBadRequest, "state and code query parameters are required", nil, cfg.Debug)
+			return
+		}
+		secure := strings.HasPrefix(cfg.GitHubOAuthCallbackURL, "https://")
+		matches := oauthStateMatches(c, state)
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(oauthStateCookieName, "", -1, "/auth/github", "", secure, true)
+		if !matches {
+			errorResponse(c, http.StatusBadRequest, "Login state does not match this browser", nil, cfg.Debug)
+			return
+		}
+		if err := redisClient.GetDel(ctx, oauthStateKey(state)).Err(); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid or expired login state", err, cfg.Debug)
+			return
+		}
+
+		accessToken, err := oauth.exchange(ctx, code)
+		if err != nil {
+			errorResponse(c, http.StatusBadGateway, "Failed to log in with GitHub", err, cfg.Debug)
+			return
+		}
+		profile, err := oauth.fetchUser(ctx, accessToken)
+		if err != nil {
+			errorResponse(c, http.StatusBadGateway, "Failed to log in with GitHub", err, cfg.Debug)
+			return
+		}
+		user, created, err := pgdb.UpsertGitHubUser(profile)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to save user", err, cfg.Debug)
+			return
+		}
+		if created {
+			log.Printf("Created user %d for GitHub user %s", user.ID, user.Login)
+		}
+
+		// Stars seed the recommendations of new users from their first page view.
+		if user.StarsImportedAt == nil {
+			if _, err := importer.Start(user.ID, user.Login, accessToken); err != nil {
+				log.Printf("Failed to start the star import of user %d: %v", user.ID, err)
+			}
+		}
+
+		token := signSessionToken([]byte(cfg.SessionSecret), user.ID, time.Now().Add(sessionTokenTTL))
+		c.SetCookie(sessionCookieName, token, int(sessionTokenTTL/time.Second), "/", "", secure, true)
+		c.Redirect(http.StatusFound, cfg.AuthRedirectURL+"#token="+url.QueryEscape(token))
+	}
+}
+
+// mergeAnonymousSession attaches the views, collections, watches, digest subscriptions,
+// seen repositories and dismissals of an anonymous session to the user. Failures are
+// logged: the login itself succeeded.
+func mergeAnonymousSession(ctx context.Context, redisClient *redis.Client, pgdb *database.PostgresConnection, anonymousSessionID string, userID int64) {
+	sessionID := userSessionID(userID)
+	merged, err := pgdb.MergeSessionViews(anonymousSessionID, sessionID, userID)
+	if err != nil {
+		log.Printf("Failed to merge views of session %s into user %d: %v", anonymousSessionID, userID, err)
+	} else if merged > 0 {
+		log.Printf("Merged %d views of session %s into user %d", merged, anonymousSessionID, userID)
+	}
+	if err := pgdb.MergeSessionCollections(anonymousSessionID, sessionID, userID); err != nil {
+		log.Printf("Failed to merge collections of session %s into user %d: %v", anonymousSessionID, userID, err)
+	}
+	if err := pgdb.MergeSessionWatches(anonymousSessionID, sessionID, userID); err != nil {
+		log.Printf("Failed to merge watches of session %s into user %d: %v", anonymousSessionID, userID, err)
+	}
+	if err := pgdb.MergeSessionDigestSubscriptions(anonymousSessionID, sessionID, userID); err != nil {
+		log.Printf("Failed to merge digest subscriptions of session %s into user %d: %v", anonymousSessionID, userID, err)
+	}
+
+	if err := redisClient.SUnionStore(ctx, sessionID, sessionID, anonymousSessionID).Err(); err != nil {
+		log.Printf("Failed to merge seen repositories of session %s into user %d: %v", anonymousSessionID, userID, err)
+	}
+	dismissed := dismissedKey(sessionID)
+	if err := redisClient.SUnionStore(ctx, dismissed, dismissed, dismissedKey(anonymousSessionID)).Err(); err != nil {
+		log.Printf("Failed to merge dismissed repositories of session %s into user %d: %v", anonymousSessionID, userID, err)
+	} else {
+		redisClient.Expire(ctx, dismissed, dismissedTTL)
+	}
+}
+
+// handleMergeSession merges the anonymous session the logged-in caller used before
+// logging in into their account. The session comes from the body of the caller's own
+// authenticated request, never from a link, so nobody can attach their history to
+// someone else's account.
+func handleMergeSession(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt64(userIDContextKey)
+		if userID == 0 {
+			errorResponse(c, http.StatusUnauthorized, "Not logged in", nil, cfg.Debug)
+			return
+		}
+		var requestBody struct {
+			SessionID string `json:"sessionId"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+		if requestBody.SessionID == "" || strings.HasPrefix(requestBody.SessionID, userSessionPrefix) {
+			errorResponse(c, http.StatusBadRequest, "sessionId must be an anonymous session", nil, cfg.Debug)
+			return
+		}
+
+		mergeAnonymousSession(c.Request.Context(), redisClient, pgdb, requestBody.SessionID, userID)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func handleGetMe(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt64(userIDContextKey)
+		if userID == 0 {
+			errorResponse(c, http.StatusUnauthorized, "Not logged in", nil, cfg.Debug)
+			return
+		}
+		user, err := pgdb.GetUserByID(userID)
+		if err != nil {
+			errorResponse(c, http.StatusUnauthorized, "Not logged in", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user": user, "sessionId": userSessionID(userID)})
+	}
+}
+
+// handleLogout clears the session cookie. Bearer clients simply drop their token, which
+// stays valid until it expires.
+func handleLogout(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secure := strings.HasPrefix(cfg.GitHubOAuthCallbackURL, "https://")
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(sessionCookieName, "", -1, "/", "", secure, true)
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionToken(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Now()
+	token := signSessionToken(secret, 42, now.Add(time.Hour))
+
+	userID, err := verifySessionToken(secret, token, now)
+	if err != nil || userID != 42 {
+		t.Fatalf("verifySessionToken() = %d, %v, want 42", userID, err)
+	}
+
+	if _, err := verifySessionToken(secret, token, now.Add(2*time.Hour)); err == nil {
+		t.Error("an expired token should be rejected")
+	}
+	if _, err := verifySessionToken([]byte("another secret"), token, now); err == nil {
+		t.Error("a token signed with another secret should be rejected")
+	}
+	forged := signSessionToken(secret, 7, now.Add(time.Hour))
+	payload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+	if _, err := verifySessionToken(secret, payload+"."+signature, now); err == nil {
+		t.Error("a token with swapped claims should be rejected")
+	}
+	for _, invalid := range []string{"", "abc", "abc.def", "."} {
+		if _, err := verifySessionToken(secret, invalid, now); err == nil {
+			t.Errorf("verifySessionToken(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestSessionFromRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	token := signSessionToken(secret, 42, time.Now().Add(time.Hour))
+
+	tests := map[string]struct {
+		header    string
+		cookie    string
+		requested string
+		session   string
+		userID    int64
+	}{
+		"anonymous":             {requested: "abc", session: "abc"},
+		"anonymous user prefix": {requested: "user:42", session: ""},
+		"bearer":                {header: "Bearer " + token, requested: "abc", session: "user:42", userID: 42},
+		"cookie":                {cookie: token, session: "user:42", userID: 42},
+		"invalid token":         {header: "Bearer nope", requested: "abc", session: "abc"},
+	}
+	for name, tt := range tests {
+		var session string
+		var userID int64
+		router := gin.New()
+		router.Use(authenticate(secret))
+		router.GET("/", func(c *gin.Context) {
+			session, userID = sessionFromRequest(c, c.Query("sessionId"))
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/?sessionId="+url.QueryEscape(tt.requested), nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tt.cookie})
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		if session != tt.session || userID != tt.userID {
+			t.Errorf("%s: sessionFromRequest() = %q, %d, want %q, %d", name, session, userID, tt.session, tt.userID)
+		}
+	}
+}
+
+func TestGitHubOAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			r.ParseForm()
+			if r.Form.Get("client_secret") != "secret" {
+				t.Errorf("unexpected client secret %q", r.Form.Get("client_secret"))
+			}
+			if r.Form.Get("code") == "good" {
+				w.Write([]byte(`{"access_token":"token123","token_type":"bearer"}`))
+			} else {
+				w.Write([]byte(`{"error":"bad_verification_code","error_description":"The code is incorrect"}`))
+			}
+		case "/user":
+			if r.Header.Get("Authorization") != "Bearer token123" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"id":583231,"login":"octocat","name":"The Octocat","avatar_url":"https://example.com/a.png"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	oauth := &githubOAuth{
+		clientID:     "client",
+		clientSecret: "secret",
+		callbackURL:  "https://api.example.com/auth/github/callback",
+		authorizeURL: server.URL + "/login/oauth/authorize",
+		tokenURL:     server.URL + "/login/oauth/access_token",
+		apiURL:       server.URL,
+		httpClient:   server.Client(),
+	}
+
+	authURL, err := url.Parse(oauth.authCodeURL("state1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := authURL.Query(); q.Get("state") != "state1" || q.Get("client_id") != "client" || q.Get("redirect_uri") != oauth.callbackURL {
+		t.Errorf("unexpected authorize URL %s", authURL)
+	}
+
+	ctx := context.Background()
+	if _, err := oauth.exchange(ctx, "bad"); err == nil {
+		t.Error("exchanging a bad code should fail")
+	}
+	accessToken, err := oauth.exchange(ctx, "good")
+	if err != nil || accessToken != "token123" {
+		t.Fatalf("exchange() = %q, %v", accessToken, err)
+	}
+
+	user, err := oauth.fetchUser(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("fetchUser() returned an error: %v", err)
+	}
+	if user.GitHubID != 583231 || user.Login != "octocat" || user.Name != "The Octocat" {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if _, err := oauth.fetchUser(ctx, "other"); err == nil {
+		t.Error("fetching the user with a bad token should fail")
+	}
+}
+
+func TestOAuthStateMatches(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := map[string]struct {
+		cookie string
+		state  string
+		want   bool
+	}{
+		"matching":       {cookie: "state1", state: "state1", want: true},
+		"other state":    {cookie: "state1", state: "state2"},
+		"no cookie":      {state: "state1"},
+		"no state":       {cookie: "state1"},
+		"nothing at all": {},
+	}
+	for name, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/auth/github/callback", nil)
+		if tt.cookie != "" {
+			c.Request.AddCookie(&http.Cookie{Name: oauthStateCookieName, Value: tt.cookie})
+		}
+		if got := oauthStateMatches(c, tt.state); got != tt.want {
+			t.Errorf("%s: oauthStateMatches() = %v, want %v", name, got, tt.want)
+		}
+	}
+}
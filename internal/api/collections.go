@@ -0,0 +1,635 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+const (
+	maxCollectionNameLength        = 100
+	maxCollectionDescriptionLength = 1000
+	maxCollectionNoteLength        = 2000
+	maxCollectionItems             = 500
+
+	// Growth feeds cover defaultFeedDays unless the days parameter asks for up to
+	// maxFeedDays.
+	defaultFeedDays = 7
+	maxFeedDays     = 90
+	feedCacheTTL    = time.Hour
+)
+
+// collectionRequest is the body of the requests creating or updating a collection.
+// Fields left out of an update keep their value.
+type collectionRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// validateCollection checks the name and description of a collection and returns them
+// trimmed.
+func validateCollection(name, description string) (string, string, error) {
+	name, description = strings.TrimSpace(name), strings.TrimSpace(description)
+	if name == "" {
+		return "", "", fmt.Errorf("name is required")
+	}
+	if utf8.RuneCountInString(name) > maxCollectionNameLength {
+		return "", "", fmt.Errorf("name is longer than %d characters", maxCollectionNameLength)
+	}
+	if utf8.RuneCountInString(description) > maxCollectionDescriptionLength {
+		return "", "", fmt.Errorf("description is longer than %d characters", maxCollectionDescriptionLength)
+	}
+	return name, description, nil
+}
+
+// parseFeedDays parses the days parameter of a growth feed.
+func parseFeedDays(value string) (int, error) {
+	if value == "" {
+		return defaultFeedDays, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 || days > maxFeedDays {
+		return 0, fmt.Errorf("days must be between 1 and %d", maxFeedDays)
+	}
+	return days, nil
+}
+
+// requestSession returns the session owning the collections or watches of the
+// request, which is the logged-in user's or the anonymous sessionId query parameter.
+func requestSession(c *gin.Context, cfg *config.Config) (string, int64, bool) {
+	sessionID, userID := sessionFromRequest(c, c.Query("sessionId"))
+	if sessionID == "" {
+		errorResponse(c, http.StatusBadRequest, "sessionId query parameter is required", nil, cfg.Debug)
+		return "", 0, false
+	}
+	return sessionID, userID, true
+}
+
+// loadCollection loads the collection of the :id parameter, which must belong to the
+// session.
+func loadCollection(c *gin.Context, cfg *config.Config, pgdb *database.PostgresConnection, sessionID string) (models.Collection, bool) {
+	collectionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "Invalid collection ID", err, cfg.Debug)
+		return models.Collection{}, false
+	}
+	collection, err := pgdb.GetCollection(collectionID, sessionID)
+	if err == sql.ErrNoRows {
+		errorResponse(c, http.StatusNotFound, "Collection not found", err, cfg.Debug)
+		return models.Collection{}, false
+	}
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Failed to retrieve collection", err, cfg.Debug)
+		return models.Collection{}, false
+	}
+	return collection, true
+}
+
+func handleListCollections(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collections, err := pgdb.GetCollections(sessionID)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve collections", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"collections": collections})
+	}
+}
+
+func handleCreateCollection(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, userID, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		var requestBody collectionRequest
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+		var name, description string
+		if requestBody.Name != nil {
+			name = *requestBody.Name
+		}
+		if requestBody.Description != nil {
+			description = *requestBody.Description
+		}
+		name, description, err := validateCollection(name, description)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err, cfg.Debug)
+			return
+		}
+
+		collection, err := pgdb.CreateCollection(sessionID, userID, name, description)
+		if err == database.ErrCollectionExists {
+			errorResponse(c, http.StatusConflict, "A collection with this name already exists", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to create collection", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusCreated, collection)
+	}
+}
+
+func handleGetCollection(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collection, ok := loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+		respondWithCollection(c, cfg, pgdb, collection)
+	}
+}
+
+func handleGetSharedCollection(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		collection, ok := loadSharedCollection(c, cfg, pgdb)
+		if !ok {
+			return
+		}
+		respondWithCollection(c, cfg, pgdb, collection)
+	}
+}
+
+func loadSharedCollection(c *gin.Context, cfg *config.Config, pgdb *database.PostgresConnection) (models.Collection, bool) {
+	collection, err := pgdb.GetSharedCollection(c.Param("token"))
+	if err == sql.ErrNoRows {
+		errorResponse(c, http.StatusNotFound, "Collection not found", err, cfg.Debug)
+		return models.Collection{}, false
+	}
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Failed to retrieve collection", err, cfg.Debug)
+		return models.Collection{}, false
+	}
+	return collection, true
+}
+
+// respondWithCollection writes a collection and its items, with the data of their
+// repositories.
+func respondWithCollection(c *gin.Context, cfg *config.Config, pgdb *database.PostgresConnection, collection models.Collection) {
+	items, err := pgdb.GetCollectionItems(collection.ID)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Failed to retrieve collection items", err, cfg.Debug)
+		return
+	}
+	repoIDs := make([]int64, len(items))
+	for i, item := range items {
+		repoIDs[i] = item.RepositoryID
+	}
+	repositories, err := repositoriesByID(pgdb, repoIDs)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Failed to retrieve full repository data", err, cfg.Debug)
+		return
+	}
+
+	type CollectionItemResponse struct {
+		models.CollectionItem
+		Repository *models.Repository `json:"repository,omitempty"`
+		Owner      *models.Owner      `json:"owner,omitempty"`
+	}
+	responseItems := make([]CollectionItemResponse, len(items))
+	for i, item := range items {
+		responseItems[i] = CollectionItemResponse{CollectionItem: item}
+		if repo, ok := repositories[item.RepositoryID]; ok {
+			responseItems[i].Repository = &repo.Repository
+			responseItems[i].Owner = &repo.Owner
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collection": collection, "items": responseItems})
+}
+
+// repositoriesByID loads the data of the given repositories, keyed by ID.
+func repositoriesByID(pgdb *database.PostgresConnection, repoIDs []int64) (map[int64]models.RepositoryData, error) {
+	repositories, err := pgdb.GetRepositoriesDataByIDs(repoIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]models.RepositoryData, len(repositories))
+	for _, repo := range repositories {
+		byID[int64(repo.Repository.ID)] = repo
+	}
+	return byID, nil
+}
+
+func handleUpdateCollection(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collection, ok := loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+		var requestBody collectionRequest
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+		name, description := collection.Name, collection.Description
+		if requestBody.Name != nil {
+			name = *requestBody.Name
+		}
+		if requestBody.Description != nil {
+			description = *requestBody.Description
+		}
+		name, description, err := validateCollection(name, description)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err, cfg.Debug)
+			return
+		}
+
+		err = pgdb.UpdateCollection(collection.ID, sessionID, name, description)
+		if err == database.ErrCollectionExists {
+			errorResponse(c, http.StatusConflict, "A collection with this name already exists", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to update collection", err, cfg.Debug)
+			return
+		}
+		collection, ok = loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, collection)
+	}
+}
+
+func handleDeleteCollection(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collection, ok := loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+		if err := pgdb.DeleteCollection(collection.ID, sessionID); err != nil && err != sql.ErrNoRows {
+			errorResponse(c, http.StatusInternalServerError, "Failed to delete collection", err, cfg.Debug)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func handleAddCollectionItem(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collection, ok := loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+		var requestBody struct {
+			RepositoryID int64  `json:"repositoryId"`
+			Note         string `json:"note"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+		if requestBody.RepositoryID <= 0 {
+			errorResponse(c, http.StatusBadRequest, "repositoryId is required", nil, cfg.Debug)
+			return
+		}
+		if utf8.RuneCountInString(requestBody.Note) > maxCollectionNoteLength {
+			errorResponse(c, http.StatusBadRequest, fmt.Sprintf("note is longer than %d characters", maxCollectionNoteLength), nil, cfg.Debug)
+			return
+		}
+		err := pgdb.AddCollectionItem(collection.ID, requestBody.RepositoryID, strings.TrimSpace(requestBody.Note), maxCollectionItems)
+		if err == database.ErrCollectionFull {
+			errorResponse(c, http.StatusBadRequest, fmt.Sprintf("a collection holds at most %d repositories", maxCollectionItems), err, cfg.Debug)
+			return
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			errorResponse(c, http.StatusNotFound, "Repository not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to save repository", err, cfg.Debug)
+			return
+		}
+
+		// Saved repositories are not recommended again.
+		redisClient.SAdd(c.Request.Context(), sessionID, fmt.Sprint(requestBody.RepositoryID))
+
+		c.JSON(http.StatusCreated, gin.H{"status": "success"})
+	}
+}
+
+// collectionItemID parses the :repoId parameter of the item routes.
+func collectionItemID(c *gin.Context, cfg *config.Config) (int64, bool) {
+	repoID, err := strconv.ParseInt(c.Param("repoId"), 10, 64)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "Invalid repository ID", err, cfg.Debug)
+		return 0, false
+	}
+	return repoID, true
+}
+
+func handleUpdateCollectionItem(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collection, ok := loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+		repoID, ok := collectionItemID(c, cfg)
+		if !ok {
+			return
+		}
+		var requestBody struct {
+			Note string `json:"note"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+		if utf8.RuneCountInString(requestBody.Note) > maxCollectionNoteLength {
+			errorResponse(c, http.StatusBadRequest, fmt.Sprintf("note is longer than %d characters", maxCollectionNoteLength), nil, cfg.Debug)
+			return
+		}
+
+		err := pgdb.UpdateCollectionItemNote(collection.ID, repoID, strings.TrimSpace(requestBody.Note))
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Repository not in collection", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to update note", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+}
+
+func handleRemoveCollectionItem(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collection, ok := loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+		repoID, ok := collectionItemID(c, cfg)
+		if !ok {
+			return
+		}
+		err := pgdb.RemoveCollectionItem(collection.ID, repoID)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Repository not in collection", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to remove repository", err, cfg.Debug)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func handleReorderCollection(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collection, ok := loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+		var requestBody struct {
+			RepositoryIDs []int64 `json:"repositoryIds"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+
+		err := pgdb.ReorderCollectionItems(collection.ID, requestBody.RepositoryIDs)
+		if err == database.ErrInvalidOrder {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to reorder collection", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+}
+
+// handleShareCollection creates the public link of a collection, or returns the
+// existing one.
+func handleShareCollection(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collection, ok := loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+
+		shareToken := collection.ShareToken
+		if shareToken == "" {
+			var err error
+			if shareToken, err = randomToken(16); err != nil {
+				errorResponse(c, http.StatusInternalServerError, "Failed to share collection", err, cfg.Debug)
+				return
+			}
+			if err := pgdb.SetCollectionShareToken(collection.ID, sessionID, shareToken); err != nil {
+				errorResponse(c, http.StatusInternalServerError, "Failed to share collection", err, cfg.Debug)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"shareToken": shareToken, "path": "/shared/" + shareToken})
+	}
+}
+
+// handleUnshareCollection disables the public link of a collection. Sharing it again
+// creates a new link.
+func handleUnshareCollection(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collection, ok := loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+		if err := pgdb.SetCollectionShareToken(collection.ID, sessionID, ""); err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to unshare collection", err, cfg.Debug)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func handleGetCollectionFeed(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		collection, ok := loadCollection(c, cfg, pgdb, sessionID)
+		if !ok {
+			return
+		}
+		respondWithCollectionFeed(c, cfg, redisClient, pgdb, chdb, collection)
+	}
+}
+
+func handleGetSharedCollectionFeed(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		collection, ok := loadSharedCollection(c, cfg, pgdb)
+		if !ok {
+			return
+		}
+		respondWithCollectionFeed(c, cfg, redisClient, pgdb, chdb, collection)
+	}
+}
+
+// respondWithCollectionFeed writes the growth of a collection's repositories over the
+// last days, fastest growing first, with the collection's total growth.
+func respondWithCollectionFeed(c *gin.Context, cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection, collection models.Collection) {
+	days, err := parseFeedDays(c.Query("days"))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error(), err, cfg.Debug)
+		return
+	}
+	items, err := pgdb.GetCollectionItems(collection.ID)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Failed to retrieve collection items", err, cfg.Debug)
+		return
+	}
+	repoIDs := make([]int64, len(items))
+	for i, item := range items {
+		repoIDs[i] = item.RepositoryID
+	}
+
+	growth, err := getRepositoriesGrowth(c.Request.Context(), redisClient, chdb, repoIDs, days)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Failed to retrieve repository growth", err, cfg.Debug)
+		return
+	}
+	rankByGrowth(growth)
+
+	growthIDs := make([]int64, len(growth))
+	for i, g := range growth {
+		growthIDs[i] = g.RepositoryID
+	}
+	repositories, err := repositoriesByID(pgdb, growthIDs)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Failed to retrieve full repository data", err, cfg.Debug)
+		return
+	}
+
+	type FeedEntry struct {
+		Repository models.Repository       `json:"repository"`
+		Owner      models.Owner            `json:"owner"`
+		Growth     models.RepositoryGrowth `json:"growth"`
+	}
+	entries := []FeedEntry{}
+	var starsGained, forksGained int64
+	for _, g := range growth {
+		repo, ok := repositories[g.RepositoryID]
+		if !ok {
+			continue
+		}
+		entries = append(entries, FeedEntry{Repository: repo.Repository, Owner: repo.Owner, Growth: g})
+		starsGained += g.StarsGained
+		forksGained += g.ForksGained
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection":   collection,
+		"days":         days,
+		"starsGained":  starsGained,
+		"forksGained":  forksGained,
+		"repositories": entries,
+	})
+}
+
+// getRepositoriesGrowth returns the growth of the given repositories, using Redis as a
+// cache in front of ClickHouse.
+func getRepositoriesGrowth(ctx context.Context, redisClient *redis.Client, chdb *database.ClickHouseConnection, repoIDs []int64, days int) ([]models.RepositoryGrowth, error) {
+	keyBuilder := strings.Builder{}
+	for _, id := range repoIDs {
+		keyBuilder.WriteString(strconv.FormatInt(id, 10) + ",")
+	}
+	cacheKey := fmt.Sprintf("repositories_growth:%d:%x", days, sha256.Sum256([]byte(keyBuilder.String())))
+
+	var growth []models.RepositoryGrowth
+	cached, err := redisClient.Get(ctx, cacheKey).Result()
+	if err == nil {
+		decompressed, err := decompress([]byte(cached))
+		if err == nil && json.Unmarshal(decompressed, &growth) == nil {
+			return growth, nil
+		}
+	}
+
+	growth, err = chdb.GetRepositoriesGrowth(repoIDs, days)
+	if err != nil {
+		return nil, err
+	}
+	if jsonBytes, err := json.Marshal(growth); err == nil {
+		if err := redisClient.Set(ctx, cacheKey, compress(jsonBytes), feedCacheTTL).Err(); err != nil {
+			log.Printf("Failed to cache repository growth: %v", err)
+		}
+	}
+	return growth, nil
+}
+
+// rankByGrowth sorts repositories by stars gained, then forks gained, fastest first.
+func rankByGrowth(growth []models.RepositoryGrowth) {
+	sort.SliceStable(growth, func(i, j int) bool {
+		if growth[i].StarsGained != growth[j].StarsGained {
+			return growth[i].StarsGained > growth[j].StarsGained
+		}
+		if growth[i].ForksGained != growth[j].ForksGained {
+			return growth[i].ForksGained > growth[j].ForksGained
+		}
+		return growth[i].RepositoryID < growth[j].RepositoryID
+	})
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func TestValidateCollection(t *testing.T) {
+	name, description, err := validateCollection("  CLI tools ", " small and sharp ")
+	if err != nil || name != "CLI tools" || description != "small and sharp" {
+		t.Errorf("validateCollection() = %q, %q, %v", name, description, err)
+	}
+
+	for _, tt := range []struct{ name, description string }{
+		{"", ""},
+		{"   ", "described"},
+		{strings.Repeat("é", maxCollectionNameLength+1), ""},
+		{"ok", strings.Repeat("x", maxCollectionDescriptionLength+1)},
+	} {
+		if _, _, err := validateCollection(tt.name, tt.description); err == nil {
+			t.Errorf("validateCollection(%q, %q) should fail", tt.name, tt.description)
+		}
+	}
+	if _, _, err := validateCollection(strings.Repeat("é", maxCollectionNameLength), ""); err != nil {
+		t.Errorf("names are limited in characters, not bytes: %v", err)
+	}
+}
+
+func TestParseFeedDays(t *testing.T) {
+	if days, err := parseFeedDays(""); err != nil || days != defaultFeedDays {
+		t.Errorf("parseFeedDays(\"\") = %d, %v", days, err)
+	}
+	if days, err := parseFeedDays("30"); err != nil || days != 30 {
+		t.Errorf("parseFeedDays(\"30\") = %d, %v", days, err)
+	}
+	for _, invalid := range []string{"0", "-1", "91", "week"} {
+		if _, err := parseFeedDays(invalid); err == nil {
+			t.Errorf("parseFeedDays(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestRankByGrowth(t *testing.T) {
+	growth := []models.RepositoryGrowth{
+		{RepositoryID: 1, StarsGained: 5},
+		{RepositoryID: 2, StarsGained: 50},
+		{RepositoryID: 3, StarsGained: 5, ForksGained: 2},
+		{RepositoryID: 4, StarsGained: 5},
+	}
+	rankByGrowth(growth)
+
+	var got []int64
+	for _, g := range growth {
+		got = append(got, g.RepositoryID)
+	}
+	if want := []int64{2, 3, 1, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rankByGrowth() order = %v, want %v", got, want)
+	}
+}
+
+func TestAddSignalScores(t *testing.T) {
+	lists := func(data map[int64][]redis.Z) func(int64) ([]byte, error) {
+		return func(repoID int64) ([]byte, error) {
+			return json.Marshal(data[repoID])
+		}
+	}
+	similar := lists(map[int64][]redis.Z{
+		1: {{Score: 1, Member: 10}},
+		2: {{Score: 1, Member: 20}},
+	})
+	coViewed := lists(map[int64][]redis.Z{
+		1: {{Score: 1, Member: 20}},
+	})
+
+	scores := make(map[int64]float64)
+	addSignalScores(scores, []int64{1}, 1, 0.25, similar, coViewed)
+	addSignalScores(scores, []int64{2}, savedSignalWeight, 0.25, similar, coViewed)
+
+	// 10 is a content neighbour of the viewed repository; 20 is a co-view neighbour of
+	// the viewed repository and a content neighbour of the saved one.
+	want := map[int64]float64{10: 0.75, 20: 0.25 + savedSignalWeight*0.75}
+	for id, score := range want {
+		if math.Abs(scores[id]-score) > 1e-9 {
+			t.Errorf("score of %d = %v, want %v", id, scores[id], score)
+		}
+	}
+}
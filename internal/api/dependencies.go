@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+)
+
+// handleGetDependents answers "trending repos that depend on X", ordered by trending rank.
+func handleGetDependents(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ecosystem := c.Query("ecosystem")
+		name := c.Query("name")
+		if ecosystem == "" || name == "" {
+			errorResponse(c, http.StatusBadRequest, "ecosystem and name query parameters are required", nil, cfg.Debug)
+			return
+		}
+
+		dependentIDs, err := pgdb.GetDependentRepositoryIDs(ecosystem, name)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve dependent repositories", err, cfg.Debug)
+			return
+		}
+		dependentIDMap := make(map[int64]bool)
+		for _, id := range dependentIDs {
+			dependentIDMap[id] = true
+		}
+
+		trendingRepoIDs, err := getTrendingRepositoryIDs(c.Request.Context(), redisClient, chdb, 30)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve trending repository list from ClickHouse", err, cfg.Debug)
+			return
+		}
+
+		repoIDs := []int64{}
+		for _, id := range trendingRepoIDs {
+			if dependentIDMap[id] {
+				repoIDs = append(repoIDs, id)
+			}
+		}
+
+		repositories, err := pgdb.GetRepositoriesDataByIDs(repoIDs)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve full repository data", err, cfg.Debug)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"repositories": repositories})
+	}
+}
+
+// handleGetPopularDependencies answers "what do trending <language> projects commonly import".
+func handleGetPopularDependencies(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ecosystem := c.Query("ecosystem")
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit <= 0 || limit > 100 {
+			errorResponse(c, http.StatusBadRequest, "limit must be between 1 and 100", err, cfg.Debug)
+			return
+		}
+
+		trendingRepoIDs, err := getTrendingRepositoryIDs(c.Request.Context(), redisClient, chdb, 30)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve trending repository list from ClickHouse", err, cfg.Debug)
+			return
+		}
+
+		if language := c.Query("language"); language != "" {
+			trendingRepoIDs, err = pgdb.FilterRepositoryIDs(trendingRepoIDs, []string{language}, nil, nil)
+			if err != nil {
+				errorResponse(c, http.StatusInternalServerError, "Failed to filter repository list", err, cfg.Debug)
+				return
+			}
+		}
+
+		counts, err := pgdb.GetCommonDependencies(trendingRepoIDs, ecosystem, limit)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve common dependencies", err, cfg.Debug)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"dependencies": counts})
+	}
+}
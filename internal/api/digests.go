@@ -0,0 +1,296 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/mailer"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+const (
+	maxDigestSubscriptions = 10
+	maxDigestFilters       = 20
+)
+
+// digestRequest is the body of the requests creating and updating a digest
+// subscription. Fields left out of an update keep their value; the email address of a
+// subscription cannot be changed.
+type digestRequest struct {
+	Email     string    `json:"email"`
+	Frequency *string   `json:"frequency"`
+	Languages *[]string `json:"languages"`
+	Topics    *[]string `json:"topics"`
+}
+
+// validateDigest applies a digest request to a subscription and checks the result.
+// Languages and topics are normalised to lowercase, as for webhooks.
+func validateDigest(request digestRequest, s models.DigestSubscription) (models.DigestSubscription, error) {
+	if request.Frequency != nil {
+		s.Frequency = *request.Frequency
+	}
+	if s.Frequency != models.DigestDaily && s.Frequency != models.DigestWeekly {
+		return models.DigestSubscription{}, fmt.Errorf("frequency must be %s or %s", models.DigestDaily, models.DigestWeekly)
+	}
+	if request.Languages != nil {
+		languages, err := digestFilter("languages", *request.Languages)
+		if err != nil {
+			return models.DigestSubscription{}, err
+		}
+		s.Languages = languages
+	}
+	if request.Topics != nil {
+		topics, err := digestFilter("topics", *request.Topics)
+		if err != nil {
+			return models.DigestSubscription{}, err
+		}
+		for _, topic := range topics {
+			if !topicRe.MatchString(topic) {
+				return models.DigestSubscription{}, fmt.Errorf("topics must be GitHub topics")
+			}
+		}
+		s.Topics = topics
+	}
+	return s, nil
+}
+
+func digestFilter(name string, values []string) ([]string, error) {
+	if len(values) > maxDigestFilters {
+		return nil, fmt.Errorf("%s can have at most %d values", name, maxDigestFilters)
+	}
+	filter := []string{}
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" {
+			return nil, fmt.Errorf("%s cannot contain empty values", name)
+		}
+		filter = append(filter, v)
+	}
+	return filter, nil
+}
+
+func handleListDigests(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		subscriptions, err := pgdb.GetDigestSubscriptions(sessionID)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve digest subscriptions", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"digests": subscriptions})
+	}
+}
+
+// digestConfirmationEmail asks the owner of a digest subscription's email address to
+// confirm it. No digest is sent until it is confirmed.
+func digestConfirmationEmail(cfg *config.Config, subscription models.DigestSubscription) mailer.Message {
+	token := url.QueryEscape(subscription.UnsubscribeToken)
+	confirmURL := cfg.PublicAPIURL + "/digests/confirm?token=" + token
+	unsubscribeURL := cfg.PublicAPIURL + "/digests/unsubscribe?token=" + token
+	return mailer.Message{
+		Subject: fmt.Sprintf("Confirm your %s GitHub trending digest", subscription.Frequency),
+		Text: fmt.Sprintf("Someone subscribed this address to a %s digest of the top trending GitHub repositories.\n\n"+
+			"Confirm to start receiving it: %s\n\n"+
+			"If you did not subscribe, ignore this email and nothing more will be sent, or unsubscribe right away: %s\n",
+			subscription.Frequency, confirmURL, unsubscribeURL),
+		UnsubscribeURL: unsubscribeURL,
+	}
+}
+
+// handleCreateDigest subscribes an email address to a digest. Digests are only sent
+// once the subscription is confirmed through the link emailed to the address.
+func handleCreateDigest(cfg *config.Config, pgdb *database.PostgresConnection, emails *mailer.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, userID, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		if emails == nil {
+			errorResponse(c, http.StatusBadRequest, "Email digests are not available", nil, cfg.Debug)
+			return
+		}
+		var requestBody digestRequest
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+		address, err := mail.ParseAddress(strings.TrimSpace(requestBody.Email))
+		if err != nil || address.Name != "" {
+			errorResponse(c, http.StatusBadRequest, "email must be an email address", err, cfg.Debug)
+			return
+		}
+		subscription, err := validateDigest(requestBody, models.DigestSubscription{Email: address.Address, Frequency: models.DigestWeekly, Languages: []string{}, Topics: []string{}})
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err, cfg.Debug)
+			return
+		}
+
+		subscriptions, err := pgdb.GetDigestSubscriptions(sessionID)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve digest subscriptions", err, cfg.Debug)
+			return
+		}
+		if len(subscriptions) >= maxDigestSubscriptions {
+			errorResponse(c, http.StatusBadRequest, fmt.Sprintf("A session can have at most %d digest subscriptions", maxDigestSubscriptions), nil, cfg.Debug)
+			return
+		}
+
+		if subscription.UnsubscribeToken, err = randomToken(16); err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to create digest subscription", err, cfg.Debug)
+			return
+		}
+		subscription, err = pgdb.CreateDigestSubscription(sessionID, userID, subscription)
+		if err == database.ErrDigestSubscriptionExists {
+			errorResponse(c, http.StatusConflict, "This email address is already subscribed", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to create digest subscription", err, cfg.Debug)
+			return
+		}
+		if err := emails.Send(subscription.Email, digestConfirmationEmail(cfg, subscription)); err != nil {
+			log.Printf("Failed to email the confirmation of digest subscription %d: %v", subscription.ID, err)
+		}
+		c.JSON(http.StatusCreated, subscription)
+	}
+}
+
+func handleUpdateDigest(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		subscriptionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid digest subscription ID", err, cfg.Debug)
+			return
+		}
+		var requestBody digestRequest
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+		if requestBody.Email != "" {
+			errorResponse(c, http.StatusBadRequest, "email cannot be changed", nil, cfg.Debug)
+			return
+		}
+
+		subscription, err := pgdb.GetDigestSubscription(subscriptionID, sessionID)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Digest subscription not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve digest subscription", err, cfg.Debug)
+			return
+		}
+		subscription, err = validateDigest(requestBody, subscription)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err, cfg.Debug)
+			return
+		}
+		err = pgdb.UpdateDigestSubscription(subscription)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Digest subscription not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to update digest subscription", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, subscription)
+	}
+}
+
+func handleDeleteDigest(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestSession(c, cfg)
+		if !ok {
+			return
+		}
+		subscriptionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid digest subscription ID", err, cfg.Debug)
+			return
+		}
+		err = pgdb.DeleteDigestSubscription(subscriptionID, sessionID)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Digest subscription not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to delete digest subscription", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+}
+
+// handleConfirmDigest confirms the digest subscription of the token query parameter,
+// which confirmation emails link to. GETs from the link answer with a page whose
+// button POSTs the confirmation.
+func handleConfirmDigest(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			errorResponse(c, http.StatusBadRequest, "token query parameter is required", nil, cfg.Debug)
+			return
+		}
+		if c.Request.Method == http.MethodGet {
+			c.Status(http.StatusOK)
+			c.Header("Content-Type", "text/html; charset=utf-8")
+			confirmEmailPage.Execute(c.Writer, confirmEmailPageData{Token: token, Action: "Confirm the digest subscription"})
+			return
+		}
+		subscription, err := pgdb.ConfirmDigest(token)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Digest subscription not found; it may have been unsubscribed", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to confirm digest subscription", err, cfg.Debug)
+			return
+		}
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(fmt.Sprintf("%s will receive the %s digest.\n", subscription.Email, subscription.Frequency)))
+	}
+}
+
+// handleUnsubscribeDigest deletes the digest subscription of the token query parameter,
+// which digest emails link to. It answers GETs from the link with a page, and POSTs
+// from mail clients' one-click unsubscribe with JSON.
+func handleUnsubscribeDigest(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			errorResponse(c, http.StatusBadRequest, "token query parameter is required", nil, cfg.Debug)
+			return
+		}
+		email, err := pgdb.UnsubscribeDigest(token)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Digest subscription not found; it may already be unsubscribed", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to unsubscribe", err, cfg.Debug)
+			return
+		}
+		if c.Request.Method == http.MethodGet {
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(fmt.Sprintf("%s is unsubscribed from the digest.\n", email)))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+}
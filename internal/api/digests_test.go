@@ -0,0 +1,51 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func TestValidateDigest(t *testing.T) {
+	daily, languages, topics := models.DigestDaily, []string{" Go ", "Rust"}, []string{"LLM"}
+	s, err := validateDigest(digestRequest{Frequency: &daily, Languages: &languages, Topics: &topics}, models.DigestSubscription{Frequency: models.DigestWeekly})
+	if err != nil {
+		t.Fatalf("validateDigest returned an error: %v", err)
+	}
+	if s.Frequency != models.DigestDaily || !reflect.DeepEqual(s.Languages, []string{"go", "rust"}) || !reflect.DeepEqual(s.Topics, []string{"llm"}) {
+		t.Errorf("unexpected subscription %+v", s)
+	}
+
+	// Fields left out of an update keep their value.
+	weekly := models.DigestWeekly
+	if updated, err := validateDigest(digestRequest{Frequency: &weekly}, s); err != nil || !reflect.DeepEqual(updated.Topics, s.Topics) || updated.Frequency != weekly {
+		t.Errorf("unexpected update %+v, %v", updated, err)
+	}
+
+	monthly, empty, spaced := "monthly", []string{""}, []string{"machine learning"}
+	for _, invalid := range []digestRequest{
+		{Frequency: &monthly},
+		{Languages: &empty},
+		{Topics: &spaced},
+	} {
+		if _, err := validateDigest(invalid, s); err == nil {
+			t.Errorf("validateDigest(%+v) should fail", invalid)
+		}
+	}
+}
+
+func TestDigestConfirmationEmail(t *testing.T) {
+	cfg := &config.Config{PublicAPIURL: "https://api.example.com"}
+	message := digestConfirmationEmail(cfg, models.DigestSubscription{Email: "dev@example.com", Frequency: models.DigestDaily, UnsubscribeToken: "tok"})
+	if message.UnsubscribeURL != "https://api.example.com/digests/unsubscribe?token=tok" {
+		t.Errorf("unexpected unsubscribe URL %s", message.UnsubscribeURL)
+	}
+	for _, want := range []string{"daily digest", "https://api.example.com/digests/confirm?token=tok", message.UnsubscribeURL} {
+		if !strings.Contains(message.Text, want) {
+			t.Errorf("the confirmation email should contain %q, got %q", want, message.Text)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+)
+
+// handleGetEmbeddingCoverage reports how many repositories have embedded, failed, empty
+// or missing README embeddings.
+func handleGetEmbeddingCoverage(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		coverage, err := pgdb.GetEmbeddingCoverage()
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve embedding coverage", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, coverage)
+	}
+}
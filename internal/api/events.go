@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+const (
+	// maxEventsPerRequest caps the events accepted by one POST /events.
+	maxEventsPerRequest = 100
+	// Events are written to ClickHouse in batches of up to eventBatchSize, at least
+	// every eventFlushInterval.
+	eventBatchSize     = 1000
+	eventFlushInterval = 5 * time.Second
+	// maxDwell caps README dwell times, so a tab left open does not count for days.
+	maxDwell = time.Hour
+	// Client timestamps further than maxEventClockSkew in the future, or older than
+	// maxEventAge, are replaced by the time the event was received.
+	maxEventClockSkew = 5 * time.Minute
+	maxEventAge       = 24 * time.Hour
+
+	// Dismissed repositories are remembered for dismissedTTL. Each candidate's score is
+	// multiplied by 1 - dismissPenalty * its similarity to a dismissed repository,
+	// using the similarity lists of up to maxDismissedLookups dismissals.
+	dismissedTTL        = 90 * 24 * time.Hour
+	dismissPenalty      = 0.5
+	maxDismissedLookups = 50
+)
+
+var eventTypes = map[string]bool{
+	models.EventImpression:    true,
+	models.EventDismiss:       true,
+	models.EventNotInterested: true,
+	models.EventStarClick:     true,
+	models.EventReadmeDwell:   true,
+}
+
+func dismissedKey(sessionID string) string {
+	return fmt.Sprintf("dismissed:%s", sessionID)
+}
+
+// eventRequest is one event in the body of POST /events.
+type eventRequest struct {
+	Type         string    `json:"type"`
+	RepositoryID int64     `json:"repositoryId"`
+	DwellMs      int       `json:"dwellMs"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// parseEvents validates the events of a request and converts them to UserEvents.
+func parseEvents(sessionID string, requests []eventRequest, now time.Time) ([]models.UserEvent, error) {
+	if sessionID == "" {
+		return nil, fmt.Errorf("sessionId is required")
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no events")
+	}
+	if len(requests) > maxEventsPerRequest {
+		return nil, fmt.Errorf("too many events: %d, at most %d are accepted", len(requests), maxEventsPerRequest)
+	}
+
+	events := make([]models.UserEvent, len(requests))
+	for i, r := range requests {
+		if !eventTypes[r.Type] {
+			return nil, fmt.Errorf("event %d: unknown type %q", i, r.Type)
+		}
+		if r.RepositoryID <= 0 {
+			return nil, fmt.Errorf("event %d: invalid repositoryId %d", i, r.RepositoryID)
+		}
+		if r.DwellMs < 0 || (r.DwellMs > 0 && r.Type != models.EventReadmeDwell) {
+			return nil, fmt.Errorf("event %d: dwellMs is only valid for %s events", i, models.EventReadmeDwell)
+		}
+
+		timestamp := r.Timestamp
+		if timestamp.IsZero() || timestamp.After(now.Add(maxEventClockSkew)) || timestamp.Before(now.Add(-maxEventAge)) {
+			timestamp = now
+		}
+		dwellMs := r.DwellMs
+		if dwellMs > int(maxDwell/time.Millisecond) {
+			dwellMs = int(maxDwell / time.Millisecond)
+		}
+
+		events[i] = models.UserEvent{
+			SessionID:    sessionID,
+			RepositoryID: r.RepositoryID,
+			Type:         r.Type,
+			DwellMs:      dwellMs,
+			Timestamp:    timestamp.UTC(),
+		}
+	}
+	return events, nil
+}
+
+// eventWriter stores batches of events.
+type eventWriter interface {
+	InsertUserEvents(events []models.UserEvent) error
+}
+
+// eventBuffer collects events from many requests so that they are written to
+// ClickHouse in large batches. The buffer is flushed when the server shuts down;
+// events still buffered when the process is killed are lost.
+type eventBuffer struct {
+	mu        sync.Mutex
+	events    []models.UserEvent
+	writer    eventWriter
+	batchSize int
+}
+
+func newEventBuffer(writer eventWriter, batchSize int) *eventBuffer {
+	return &eventBuffer{writer: writer, batchSize: batchSize}
+}
+
+// Add buffers events, writing the buffer out once it holds a full batch.
+func (b *eventBuffer) Add(events []models.UserEvent) {
+	b.mu.Lock()
+	b.events = append(b.events, events...)
+	var batch []models.UserEvent
+	if len(b.events) >= b.batchSize {
+		batch = b.events
+		b.events = nil
+	}
+	b.mu.Unlock()
+
+	b.write(batch)
+}
+
+// Flush writes out the buffered events.
+func (b *eventBuffer) Flush() {
+	b.mu.Lock()
+	batch := b.events
+	b.events = nil
+	b.mu.Unlock()
+
+	b.write(batch)
+}
+
+func (b *eventBuffer) write(batch []models.UserEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := b.writer.InsertUserEvents(batch); err != nil {
+		log.Printf("Failed to write %d user events to ClickHouse: %v", len(batch), err)
+	}
+}
+
+// run flushes the buffer every interval.
+func (b *eventBuffer) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		b.Flush()
+	}
+}
+
+func handlePostEvents(cfg *config.Config, redisClient *redis.Client, buffer *eventBuffer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var requestBody struct {
+			SessionID string         `json:"sessionId"`
+			Events    []eventRequest `json:"events"`
+		}
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+
+		sessionID, _ := sessionFromRequest(c, requestBody.SessionID)
+		events, err := parseEvents(sessionID, requestBody.Events, time.Now())
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err, cfg.Debug)
+			return
+		}
+		buffer.Add(events)
+
+		// Dismissed repositories are hidden from the session right away and remembered
+		// to down-rank their neighbours.
+		ctx := c.Request.Context()
+		key := dismissedKey(sessionID)
+		for _, e := range events {
+			if !e.IsNegative() {
+				continue
+			}
+			member := strconv.FormatInt(e.RepositoryID, 10)
+			redisClient.SAdd(ctx, sessionID, member)
+			redisClient.SAdd(ctx, key, member)
+			redisClient.Expire(ctx, key, dismissedTTL)
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"accepted": len(events)})
+	}
+}
+
+// applyDismissals removes the repositories the session dismissed from the candidate
+// scores and down-ranks their close neighbours.
+func applyDismissals(ctx context.Context, redisClient *redis.Client, pgdb *database.PostgresConnection, sessionID string, candidateScores map[int64]float64) {
+	members, err := redisClient.SMembers(ctx, dismissedKey(sessionID)).Result()
+	if err != nil {
+		log.Printf("Failed to get dismissed repositories for session %s: %v", sessionID, err)
+		return
+	}
+	var dismissed []int64
+	for _, member := range members {
+		if id, err := strconv.ParseInt(member, 10, 64); err == nil {
+			dismissed = append(dismissed, id)
+		}
+	}
+	downRankDismissed(candidateScores, dismissed, pgdb.GetRepositorySimilarity)
+}
+
+// downRankDismissed removes the dismissed repositories from candidateScores and
+// multiplies the score of each of their neighbours by 1 - dismissPenalty * similarity.
+// neighbours returns a repository's stored similarity list.
+func downRankDismissed(candidateScores map[int64]float64, dismissed []int64, neighbours func(repoID int64) ([]byte, error)) {
+	for i, dismissedID := range dismissed {
+		delete(candidateScores, dismissedID)
+		if i >= maxDismissedLookups {
+			continue
+		}
+
+		data, err := neighbours(dismissedID)
+		if err != nil {
+			log.Printf("Failed to get similar repos for dismissed repo %d: %v", dismissedID, err)
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		var zMembers []redis.Z
+		if err := json.Unmarshal(data, &zMembers); err != nil {
+			log.Printf("Failed to unmarshal similarity data for repo %d: %v", dismissedID, err)
+			continue
+		}
+		for _, z := range zMembers {
+			repoID, _ := strconv.ParseInt(fmt.Sprintf("%.0f", z.Member), 10, 64)
+			if score, ok := candidateScores[repoID]; ok {
+				similarity := z.Score
+				if similarity > 1 {
+					similarity = 1
+				} else if similarity < 0 {
+					similarity = 0
+				}
+				candidateScores[repoID] = score * (1 - dismissPenalty*similarity)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func TestParseEvents(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	events, err := parseEvents("session", []eventRequest{
+		{Type: models.EventImpression, RepositoryID: 1, Timestamp: now.Add(-time.Minute)},
+		{Type: models.EventReadmeDwell, RepositoryID: 2, DwellMs: 10 * 3600 * 1000},
+		{Type: models.EventDismiss, RepositoryID: 3, Timestamp: now.Add(time.Hour)},
+	}, now)
+	if err != nil {
+		t.Fatalf("parseEvents returned an error: %v", err)
+	}
+
+	if !events[0].Timestamp.Equal(now.Add(-time.Minute)) {
+		t.Errorf("a plausible client timestamp should be kept, got %v", events[0].Timestamp)
+	}
+	if events[1].DwellMs != int(maxDwell/time.Millisecond) {
+		t.Errorf("dwell time should be capped, got %d", events[1].DwellMs)
+	}
+	if !events[1].Timestamp.Equal(now) || !events[2].Timestamp.Equal(now) {
+		t.Errorf("missing and future timestamps should be replaced, got %v and %v", events[1].Timestamp, events[2].Timestamp)
+	}
+	if events[2].SessionID != "session" || !events[2].IsNegative() || events[0].IsNegative() {
+		t.Errorf("unexpected event %+v", events[2])
+	}
+}
+
+func TestParseEventsRejectsInvalidEvents(t *testing.T) {
+	now := time.Now()
+	tooMany := make([]eventRequest, maxEventsPerRequest+1)
+	for i := range tooMany {
+		tooMany[i] = eventRequest{Type: models.EventImpression, RepositoryID: 1}
+	}
+
+	tests := map[string]struct {
+		sessionID string
+		events    []eventRequest
+	}{
+		"no session":     {"", []eventRequest{{Type: models.EventImpression, RepositoryID: 1}}},
+		"no events":      {"s", nil},
+		"too many":       {"s", tooMany},
+		"unknown type":   {"s", []eventRequest{{Type: "like", RepositoryID: 1}}},
+		"no repository":  {"s", []eventRequest{{Type: models.EventImpression}}},
+		"dwell mismatch": {"s", []eventRequest{{Type: models.EventImpression, RepositoryID: 1, DwellMs: 5}}},
+	}
+	for name, tt := range tests {
+		if _, err := parseEvents(tt.sessionID, tt.events, now); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+type fakeEventWriter struct {
+	mu      sync.Mutex
+	batches [][]models.UserEvent
+	err     error
+}
+
+func (w *fakeEventWriter) InsertUserEvents(events []models.UserEvent) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batches = append(w.batches, events)
+	return w.err
+}
+
+func TestEventBuffer(t *testing.T) {
+	writer := &fakeEventWriter{}
+	buffer := newEventBuffer(writer, 3)
+	event := models.UserEvent{Type: models.EventImpression, RepositoryID: 1}
+
+	buffer.Add([]models.UserEvent{event, event})
+	if len(writer.batches) != 0 {
+		t.Fatalf("a partial batch should stay buffered, got %d writes", len(writer.batches))
+	}
+	buffer.Add([]models.UserEvent{event, event})
+	if len(writer.batches) != 1 || len(writer.batches[0]) != 4 {
+		t.Fatalf("a full buffer should be written at once, got %v", writer.batches)
+	}
+
+	buffer.Flush()
+	if len(writer.batches) != 1 {
+		t.Errorf("flushing an empty buffer should not write, got %d writes", len(writer.batches))
+	}
+	writer.err = errors.New("clickhouse down")
+	buffer.Add([]models.UserEvent{event})
+	buffer.Flush()
+	if len(writer.batches) != 2 {
+		t.Errorf("Flush should write the remaining events, got %d writes", len(writer.batches))
+	}
+}
+
+func TestDownRankDismissed(t *testing.T) {
+	lists := map[int64][]redis.Z{
+		10: {{Score: 0.8, Member: 1}, {Score: 0.2, Member: 2}, {Score: 0.9, Member: 20}},
+	}
+	neighbours := func(repoID int64) ([]byte, error) {
+		return json.Marshal(lists[repoID])
+	}
+	scores := map[int64]float64{1: 1, 2: 1, 3: 1, 10: 5, 20: 2}
+
+	downRankDismissed(scores, []int64{10, 20}, neighbours)
+
+	want := map[int64]float64{1: 0.6, 2: 0.9, 3: 1}
+	if len(scores) != len(want) {
+		t.Fatalf("scores = %v, want %v", scores, want)
+	}
+	for id, score := range want {
+		if math.Abs(scores[id]-score) > 1e-9 {
+			t.Errorf("score of %d = %v, want %v", id, scores[id], score)
+		}
+	}
+}
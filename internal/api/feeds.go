@@ -0,0 +1,213 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/feeds"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+const (
+	feedSize = 30
+
+	// feedMaxAge is how long clients and proxies may cache feeds.
+	feedMaxAge = 15 * time.Minute
+)
+
+// Feed formats.
+const (
+	feedRSS  = "rss"
+	feedAtom = "atom"
+	feedJSON = "json"
+)
+
+// feedWindow is a period trending feeds rank repositories over. Items are identified
+// per calendar period of the window, so a repository still trending the next day, week
+// or month shows up again.
+type feedWindow struct {
+	days   int
+	phrase string
+	// period returns the calendar period a time falls in, as its start and key.
+	period func(t time.Time) (time.Time, string)
+}
+
+var feedWindows = map[string]feedWindow{
+	"daily": {days: 1, phrase: "day", period: func(t time.Time) (time.Time, string) {
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.Format("2006-01-02")
+	}},
+	"weekly": {days: 7, phrase: "7 days", period: func(t time.Time) (time.Time, string) {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7) // Weeks start on Monday.
+		year, week := start.ISOWeek()
+		return start, fmt.Sprintf("%d-W%02d", year, week)
+	}},
+	"monthly": {days: 30, phrase: "30 days", period: func(t time.Time) (time.Time, string) {
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.Format("2006-01")
+	}},
+}
+
+// queryList splits a comma-separated query parameter, sorted so that equivalent
+// requests share their cache entries.
+func queryList(c *gin.Context, name string) []string {
+	values := []string{}
+	for _, v := range strings.Split(c.Query(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// handleTrendingFeed serves the trending list, ranked like /retrieveList for new
+// sessions, as a feed. The languages and topics parameters filter it, and window
+// (daily, weekly or monthly, the default) sets the period growth is measured over.
+func handleTrendingFeed(cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection, format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		windowName := c.DefaultQuery("window", "monthly")
+		window, ok := feedWindows[windowName]
+		if !ok {
+			errorResponse(c, http.StatusBadRequest, "window must be daily, weekly or monthly", nil, cfg.Debug)
+			return
+		}
+		languages, topics := queryList(c, "languages"), queryList(c, "topics")
+
+		repoIDs, err := getTrendingRepositoryIDs(c.Request.Context(), redisClient, chdb, window.days)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve trending repository list from ClickHouse", err, cfg.Debug)
+			return
+		}
+		if len(languages) > 0 || len(topics) > 0 {
+			repoIDs, err = pgdb.FilterRepositoryIDs(repoIDs, languages, nil, topics)
+			if err != nil {
+				errorResponse(c, http.StatusInternalServerError, "Failed to filter repository list", err, cfg.Debug)
+				return
+			}
+		}
+		if len(repoIDs) > feedSize {
+			repoIDs = repoIDs[:feedSize]
+		}
+
+		repositories, err := pgdb.GetRepositoriesDataByIDs(repoIDs)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve full repository data", err, cfg.Debug)
+			return
+		}
+		growth := make(map[int64]models.RepositoryGrowth)
+		if g, err := chdb.GetRepositoriesGrowth(repoIDs, window.days); err != nil {
+			log.Printf("Failed to get the growth of feed repositories from ClickHouse: %v", err)
+		} else {
+			for _, rg := range g {
+				growth[rg.RepositoryID] = rg
+			}
+		}
+
+		feed := trendingFeed(cfg, window, languages, topics, repoIDs, repositories, growth, time.Now())
+		feed.SelfURL = cfg.PublicAPIURL + c.Request.URL.RequestURI()
+		serveFeed(c, cfg, format, feed)
+	}
+}
+
+// trendingFeed builds the feed of the trending repositories, in the order of repoIDs.
+func trendingFeed(cfg *config.Config, window feedWindow, languages, topics []string, repoIDs []int64, repositories []models.RepositoryData, growth map[int64]models.RepositoryGrowth, now time.Time) feeds.Feed {
+	periodStart, periodKey := window.period(now)
+	feed := feeds.Feed{
+		Title:       "Trending GitHub repositories",
+		Description: fmt.Sprintf("The repositories that gained the most stars and forks in the last %s", window.phrase),
+		Updated:     periodStart,
+	}
+	if len(languages) > 0 {
+		feed.Title = fmt.Sprintf("Trending %s repositories", strings.Join(languages, ", "))
+		feed.Description += ", written in " + strings.Join(languages, ", ")
+	}
+	if len(topics) > 0 {
+		feed.Description += ", with the topics " + strings.Join(topics, ", ")
+	}
+	feed.Description += "."
+
+	byID := make(map[int64]models.RepositoryData, len(repositories))
+	for _, data := range repositories {
+		byID[int64(data.Repository.ID)] = data
+	}
+	for _, id := range repoIDs {
+		data, ok := byID[id]
+		if !ok {
+			continue
+		}
+		repo := data.Repository
+		summary := repo.Description.String
+		if g, ok := growth[id]; ok {
+			if summary != "" {
+				summary += "\n\n"
+			}
+			summary += fmt.Sprintf("%d stars (+%d in the last %s), %d forks (+%d).", g.Stars, g.StarsGained, window.phrase, g.Forks, g.ForksGained)
+		}
+		item := feeds.Item{
+			ID:        feeds.GUID("trending/"+periodKey, id),
+			Title:     repo.FullName,
+			URL:       repo.HTMLURL,
+			Summary:   summary,
+			ImageURL:  cfg.PublicAPIURL + "/api/og?id=" + strconv.FormatInt(id, 10),
+			Author:    data.Owner.Login,
+			Published: periodStart,
+			Updated:   periodStart,
+		}
+		if language := models.NewEventRepository(repo).Language; language != "" {
+			item.Tags = append(item.Tags, language)
+		}
+		item.Tags = append(item.Tags, repo.Topics...)
+		// Repositories change when they are crawled, which is also when their growth is
+		// measured.
+		if repo.LastCrawledAt.After(item.Updated) {
+			item.Updated = repo.LastCrawledAt
+		}
+		if item.Updated.After(feed.Updated) {
+			feed.Updated = item.Updated
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed
+}
+
+// serveFeed renders a feed in a format and serves it with an ETag and Last-Modified,
+// answering conditional requests with 304 Not Modified.
+func serveFeed(c *gin.Context, cfg *config.Config, format string, feed feeds.Feed) {
+	var body []byte
+	var contentType string
+	var err error
+	switch format {
+	case feedRSS:
+		body, err = feeds.RSS(feed)
+		contentType = feeds.RSSContentType
+	case feedAtom:
+		body, err = feeds.Atom(feed)
+		contentType = feeds.AtomContentType
+	default:
+		body, err = feeds.JSON(feed)
+		contentType = feeds.JSONContentType
+	}
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Failed to render feed", err, cfg.Debug)
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(body)))
+	header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(feedMaxAge.Seconds())))
+	http.ServeContent(c.Writer, c.Request, "", feed.Updated, bytes.NewReader(body))
+}
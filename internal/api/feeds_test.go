@@ -0,0 +1,102 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func TestTrendingFeed(t *testing.T) {
+	cfg := &config.Config{PublicAPIURL: "https://api.example.com"}
+	now := time.Date(2025, 6, 5, 12, 0, 0, 0, time.UTC) // A Thursday.
+	crawled := time.Date(2025, 6, 4, 8, 0, 0, 0, time.UTC)
+	repositories := []models.RepositoryData{
+		{Repository: models.Repository{ID: 2, FullName: "bolt/llm-kit", HTMLURL: "https://github.com/bolt/llm-kit", Topics: []string{"llm"}}, Owner: models.Owner{Login: "bolt"}},
+		{Repository: models.Repository{ID: 1, FullName: "acme/rocket", Description: sql.NullString{String: "Fast", Valid: true}, Language: sql.NullString{String: "Go", Valid: true}, LastCrawledAt: crawled}},
+	}
+	growth := map[int64]models.RepositoryGrowth{1: {RepositoryID: 1, Stars: 1200, StarsGained: 300, Forks: 40, ForksGained: 2}}
+
+	feed := trendingFeed(cfg, feedWindows["weekly"], []string{"Go"}, nil, []int64{1, 2, 3}, repositories, growth, now)
+	if len(feed.Items) != 2 || feed.Items[0].Title != "acme/rocket" || feed.Items[1].Title != "bolt/llm-kit" {
+		t.Fatalf("items should follow the ranking and skip missing repositories, got %+v", feed.Items)
+	}
+	if feed.Title != "Trending Go repositories" || !feed.Updated.Equal(crawled) {
+		t.Errorf("unexpected feed %q updated %v", feed.Title, feed.Updated)
+	}
+	item := feed.Items[0]
+	if item.Summary != "Fast\n\n1200 stars (+300 in the last 7 days), 40 forks (+2)." || item.ImageURL != "https://api.example.com/api/og?id=1" || item.Tags[0] != "Go" {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if want := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC); !item.Published.Equal(want) || !feed.Items[1].Updated.Equal(want) {
+		t.Errorf("items should be published at the start of the week, got %v", item.Published)
+	}
+
+	// GUIDs are stable within a week and change the next one.
+	sameWeek := trendingFeed(cfg, feedWindows["weekly"], nil, nil, []int64{1}, repositories, nil, now.AddDate(0, 0, 3))
+	nextWeek := trendingFeed(cfg, feedWindows["weekly"], nil, nil, []int64{1}, repositories, nil, now.AddDate(0, 0, 4))
+	if sameWeek.Items[0].ID != item.ID || nextWeek.Items[0].ID == item.ID {
+		t.Errorf("unexpected GUIDs %s, %s, %s", item.ID, sameWeek.Items[0].ID, nextWeek.Items[0].ID)
+	}
+	if daily := trendingFeed(cfg, feedWindows["daily"], nil, nil, []int64{1}, repositories, nil, now); daily.Items[0].ID == item.ID {
+		t.Error("GUIDs should differ between windows")
+	}
+}
+
+func TestServeFeed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{PublicAPIURL: "https://api.example.com"}
+	updated := time.Date(2025, 6, 4, 8, 0, 0, 0, time.UTC)
+	feed := trendingFeed(cfg, feedWindows["monthly"], nil, nil, nil, nil, nil, updated)
+	feed.Updated = updated
+
+	router := gin.New()
+	router.GET("/feed.rss", func(c *gin.Context) { serveFeed(c, cfg, feedRSS, feed) })
+	router.GET("/feed.json", func(c *gin.Context) { serveFeed(c, cfg, feedJSON, feed) })
+
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/feed.rss", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/rss+xml") || etag == "" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Last-Modified") != "Wed, 04 Jun 2025 08:00:00 GMT" || !strings.Contains(w.Header().Get("Cache-Control"), "max-age=900") {
+		t.Errorf("unexpected caching headers %v", w.Header())
+	}
+	if json := get("/feed.json", nil); json.Header().Get("ETag") == etag || json.Header().Get("Content-Type") != "application/feed+json; charset=utf-8" {
+		t.Errorf("formats should have their own ETag and content type, got %v", json.Header())
+	}
+
+	for name, header := range map[string]http.Header{
+		"If-None-Match":     {"If-None-Match": {etag}},
+		"If-Modified-Since": {"If-Modified-Since": {"Wed, 04 Jun 2025 09:00:00 GMT"}},
+	} {
+		if w := get("/feed.rss", header); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s: got status %d, want 304", name, w.Code)
+		}
+	}
+	for name, header := range map[string]http.Header{
+		"stale ETag":              {"If-None-Match": {`"stale"`}},
+		"older If-Modified-Since": {"If-Modified-Since": {"Tue, 03 Jun 2025 09:00:00 GMT"}},
+		"ETag wins over the date": {"If-None-Match": {`"stale"`}, "If-Modified-Since": {"Wed, 04 Jun 2025 09:00:00 GMT"}},
+	} {
+		if w := get("/feed.rss", header); w.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want 200", name, w.Code)
+		}
+	}
+}
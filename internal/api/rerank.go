@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/recommend"
+)
+
+const (
+	// maxRerankPool is the number of best candidates that are re-ranked; the rest keep
+	// their order after them.
+	maxRerankPool = 500
+	// explorationPoolSize is the number of trending repositories that may fill
+	// exploration slots.
+	explorationPoolSize = 100
+	// rankedListTTL is how long a re-ranked list is kept, matching the cached pages
+	// of /retrieveList.
+	rankedListTTL = 24 * time.Hour
+)
+
+// rankedListCacheKey returns the key of the re-ranked list of a session for a set of
+// (sorted) filters, after the given number of dismissals.
+func rankedListCacheKey(sessionID string, dismissals int64, languages, tags, topics []string) string {
+	key := fmt.Sprintf("rankedList:%s:%d:%s:%s:%s", sessionID, dismissals, strings.Join(languages, ","), strings.Join(tags, ","), strings.Join(topics, ","))
+	return fmt.Sprintf("ranked:%x", sha256.Sum256([]byte(key)))
+}
+
+// getRankedList returns the stored re-ranked list, if any.
+func getRankedList(ctx context.Context, redisClient *redis.Client, key string) ([]int64, bool) {
+	cached, err := redisClient.Get(ctx, key).Result()
+	if err != nil {
+		return nil, false
+	}
+	decompressed, err := decompress([]byte(cached))
+	if err != nil {
+		return nil, false
+	}
+	var repoIDs []int64
+	if err := json.Unmarshal(decompressed, &repoIDs); err != nil {
+		return nil, false
+	}
+	return repoIDs, true
+}
+
+// setRankedList stores a re-ranked list so that the following pages are sliced from
+// it rather than re-ranked again.
+func setRankedList(ctx context.Context, redisClient *redis.Client, key string, repoIDs []int64) {
+	jsonBytes, err := json.Marshal(repoIDs)
+	if err != nil {
+		return
+	}
+	if err := redisClient.Set(ctx, key, compress(jsonBytes), rankedListTTL).Err(); err != nil {
+		log.Printf("Failed to cache re-ranked list: %v", err)
+	}
+}
+
+// rerankRecommendations re-orders a personalised list of repository IDs, sorted by
+// decreasing score, for diversity: similar repositories and repositories of the same
+// owner are spread across pages, and some slots go to trending repositories matching
+// the filters. The exploration slots are seeded by the session, so that pages stay
+// consistent. On error the list is returned unchanged.
+func rerankRecommendations(ctx context.Context, cfg *config.Config, redisClient *redis.Client, pgdb *database.PostgresConnection, chdb *database.ClickHouseConnection, sessionID string, repoIDs []int64, scores map[int64]float64, seen map[int64]bool, languages, tags, topics []string) []int64 {
+	pool := repoIDs
+	if len(pool) > maxRerankPool {
+		pool = repoIDs[:maxRerankPool]
+	}
+	inPool := make(map[int64]bool, len(pool))
+	for _, id := range pool {
+		inPool[id] = true
+	}
+
+	var explorationIDs []int64
+	if cfg.RerankEpsilon > 0 {
+		trendingIDs, err := getTrendingRepositoryIDs(ctx, redisClient, chdb, 30)
+		if err != nil {
+			log.Printf("Failed to get trending repositories for exploration: %v", err)
+		}
+		for _, id := range trendingIDs {
+			if len(explorationIDs) == explorationPoolSize {
+				break
+			}
+			if !seen[id] && !inPool[id] {
+				explorationIDs = append(explorationIDs, id)
+			}
+		}
+		explorationIDs, err = pgdb.FilterRepositoryIDs(explorationIDs, languages, tags, topics)
+		if err != nil {
+			log.Printf("Failed to filter exploration repositories: %v", err)
+			explorationIDs = nil
+		}
+	}
+
+	reposData, err := pgdb.GetRepositoriesDataByIDs(append(append([]int64(nil), pool...), explorationIDs...))
+	if err != nil {
+		log.Printf("Failed to get repositories to re-rank: %v", err)
+		return repoIDs
+	}
+	items := make(map[int64]recommend.Item, len(reposData))
+	for _, repoData := range reposData {
+		id := int64(repoData.Repository.ID)
+		repoLanguages := make([]string, 0, len(repoData.Repository.Languages))
+		for language := range repoData.Repository.Languages {
+			repoLanguages = append(repoLanguages, language)
+		}
+		items[id] = recommend.Item{
+			ID:        id,
+			Score:     scores[id],
+			Owner:     repoData.Owner.Login,
+			Topics:    repoData.Repository.Topics,
+			Languages: repoLanguages,
+		}
+	}
+
+	candidates := make([]recommend.Item, 0, len(pool))
+	for _, id := range pool {
+		if item, ok := items[id]; ok {
+			candidates = append(candidates, item)
+		} else {
+			candidates = append(candidates, recommend.Item{ID: id, Score: scores[id]})
+		}
+	}
+	var exploration []recommend.Item
+	for _, id := range explorationIDs {
+		if item, ok := items[id]; ok {
+			exploration = append(exploration, item)
+		}
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(sessionID))
+	rng := rand.New(rand.NewSource(int64(hash.Sum64())))
+
+	reranked := recommend.Rerank(candidates, exploration, recommend.Options{
+		Lambda:      cfg.RerankLambda,
+		Epsilon:     cfg.RerankEpsilon,
+		MaxPerOwner: cfg.RerankMaxPerOwner,
+		PageSize:    retrieveListPageSize,
+	}, rng)
+	return append(reranked, repoIDs[len(pool):]...)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/database"
+)
+
+// filteredSimilarity returns a function listing the neighbours of a repository that
+// use one of the given languages and carry one of the given topics, in the format of
+// the stored similarity lists. The stored lists are computed without filters, so
+// filtering them afterwards leaves few candidates for narrow filters; here Qdrant
+// applies the filters while searching.
+func filteredSimilarity(ctx context.Context, qdrantConnection *database.QdrantConnection, limit int, languages, topics []string) func(repoID int64) ([]byte, error) {
+	return func(repoID int64) ([]byte, error) {
+		vectors, err := qdrantConnection.GetVectors(ctx, database.RepositoriesAlias, []uint64{uint64(repoID)})
+		if err != nil || len(vectors) == 0 {
+			return nil, err
+		}
+		embedding := vectors[0].GetVectors().GetVector().GetData()
+
+		filter := database.NewQdrantFilter().
+			Languages(languages...).
+			Topics(topics...).
+			ExcludeArchived().
+			ExcludeRepositories(repoID)
+		results, err := qdrantConnection.Search(ctx, database.RepositoriesAlias, embedding, uint64(limit), filter)
+		if err != nil {
+			return nil, err
+		}
+
+		neighbours := make([]redis.Z, 0, len(results))
+		for _, result := range results {
+			neighbours = append(neighbours, redis.Z{Score: float64(result.GetScore()), Member: result.GetId().GetNum()})
+		}
+		return json.Marshal(neighbours)
+	}
+}
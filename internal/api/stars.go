@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/github"
+	"github.com/teomiscia/github-trending/internal/messaging"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+const (
+	// crawlQueueName is the discovery queue; starred repositories that are not in the
+	// database yet are crawled like discovered ones.
+	crawlQueueName = "repos_to_crawl"
+	// maxImportedStars caps the stars imported per user, most recent first.
+	maxImportedStars = 1000
+	// starsImportLockTTL bounds an import, including rate limit waits.
+	starsImportLockTTL = 30 * time.Minute
+
+	// maxStarredSignals caps the starred repositories whose neighbours are recommended,
+	// and starredSignalWeight is their weight relative to a viewed repository.
+	maxStarredSignals   = 15
+	starredSignalWeight = 1.0
+)
+
+// starStore stores imported stars.
+type starStore interface {
+	ReplaceStarredRepositories(userID int64, stars []models.StarredRepository) error
+	GetMissingRepositoryIDs(repoIDs []int64) ([]int64, error)
+}
+
+// importStars imports the GitHub stars of login for the user and queues the starred
+// repositories missing from the database for crawling. It returns the IDs of the
+// starred repositories and how many were queued. When the rate limit stops the listing,
+// the stars fetched so far are imported.
+func importStars(client *github.GitHubClient, store starStore, mq messaging.MQConnection, userID int64, login string) ([]int64, int, error) {
+	starred, err := client.ListStarredRepositories(login, maxImportedStars)
+	if err != nil {
+		if !errors.Is(err, github.ErrRateLimited) || len(starred) == 0 {
+			return nil, 0, fmt.Errorf("failed to list stars of %s: %w", login, err)
+		}
+		log.Printf("Importing %d stars of %s only: %v", len(starred), login, err)
+	}
+
+	stars := make([]models.StarredRepository, len(starred))
+	repoIDs := make([]int64, len(starred))
+	byID := make(map[int64]models.Repository, len(starred))
+	for i, s := range starred {
+		repoIDs[i] = int64(s.Repository.ID)
+		stars[i] = models.StarredRepository{RepositoryID: repoIDs[i], StarredAt: s.StarredAt}
+		byID[repoIDs[i]] = s.Repository
+	}
+	if err := store.ReplaceStarredRepositories(userID, stars); err != nil {
+		return nil, 0, fmt.Errorf("failed to save stars of %s: %w", login, err)
+	}
+
+	missing, err := store.GetMissingRepositoryIDs(repoIDs)
+	if err != nil {
+		return repoIDs, 0, fmt.Errorf("failed to find uncrawled stars of %s: %w", login, err)
+	}
+	queued := 0
+	for _, id := range missing {
+		body, err := json.Marshal(models.DiscoveryMessage{Repository: byID[id], DiscoveredAt: time.Now()})
+		if err != nil {
+			log.Printf("Failed to marshal starred repository %d: %v", id, err)
+			continue
+		}
+		if err := mq.Publish(crawlQueueName, body); err != nil {
+			log.Printf("Failed to queue starred repository %d for crawling: %v", id, err)
+			continue
+		}
+		queued++
+	}
+	return repoIDs, queued, nil
+}
+
+// starImporter runs star imports in the background, one at a time per user.
+type starImporter struct {
+	redisClient *redis.Client
+	pgdb        *database.PostgresConnection
+	mq          messaging.MQConnection
+}
+
+func starsImportLockKey(userID int64) string {
+	return fmt.Sprintf("stars_import:%d", userID)
+}
+
+// Start imports the stars of the user in the background with the given GitHub token. It
+// returns false if an import of the user's stars is already running.
+func (i *starImporter) Start(userID int64, login, token string) (bool, error) {
+	ctx := context.Background()
+	lockKey := starsImportLockKey(userID)
+	acquired, err := i.redisClient.SetNX(ctx, lockKey, time.Now().Unix(), starsImportLockTTL).Result()
+	if err != nil || !acquired {
+		return false, err
+	}
+
+	go func() {
+		defer i.redisClient.Del(ctx, lockKey)
+
+		repoIDs, queued, err := importStars(github.NewGitHubClient(token, nil), i.pgdb, i.mq, userID, login)
+		if err != nil {
+			log.Printf("Failed to import stars of user %d: %v", userID, err)
+			return
+		}
+		// Starred repositories are known to the user and not recommended.
+		if len(repoIDs) > 0 {
+			members := make([]interface{}, len(repoIDs))
+			for j, id := range repoIDs {
+				members[j] = fmt.Sprint(id)
+			}
+			i.redisClient.SAdd(ctx, userSessionID(userID), members...)
+		}
+		log.Printf("Imported %d stars of user %d, %d queued for crawling", len(repoIDs), userID, queued)
+	}()
+	return true, nil
+}
+
+// handleImportStars re-imports the logged-in user's public stars with the server's
+// GitHub token.
+func handleImportStars(cfg *config.Config, pgdb *database.PostgresConnection, importer *starImporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt64(userIDContextKey)
+		if userID == 0 {
+			errorResponse(c, http.StatusUnauthorized, "Not logged in", nil, cfg.Debug)
+			return
+		}
+		user, err := pgdb.GetUserByID(userID)
+		if err != nil {
+			errorResponse(c, http.StatusUnauthorized, "Not logged in", err, cfg.Debug)
+			return
+		}
+		started, err := importer.Start(user.ID, user.Login, cfg.GitHubToken)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to start the import", err, cfg.Debug)
+			return
+		}
+		if !started {
+			errorResponse(c, http.StatusConflict, "An import is already running", nil, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"status": "importing"})
+	}
+}
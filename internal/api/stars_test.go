@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/teomiscia/github-trending/internal/github"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+type fakeStarStore struct {
+	stars []models.StarredRepository
+	known map[int64]bool
+}
+
+func (s *fakeStarStore) ReplaceStarredRepositories(userID int64, stars []models.StarredRepository) error {
+	s.stars = stars
+	return nil
+}
+
+func (s *fakeStarStore) GetMissingRepositoryIDs(repoIDs []int64) ([]int64, error) {
+	var missing []int64
+	for _, id := range repoIDs {
+		if !s.known[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+type fakeMQ struct {
+	published map[string][][]byte
+}
+
+func (m *fakeMQ) Consume(queueName string) (<-chan amqp.Delivery, error) { return nil, nil }
+func (m *fakeMQ) Close() error                                           { return nil }
+func (m *fakeMQ) NotifyClose(c chan *amqp.Error) chan *amqp.Error        { return c }
+func (m *fakeMQ) Publish(queueName string, body []byte) error {
+	if m.published == nil {
+		m.published = make(map[string][][]byte)
+	}
+	m.published[queueName] = append(m.published[queueName], body)
+	return nil
+}
+
+func TestImportStars(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/octocat/starred" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"starred_at":"2024-05-02T10:00:00Z","repo":{"id":1,"full_name":"a/known"}},
+			{"starred_at":"2024-05-01T10:00:00Z","repo":{"id":2,"full_name":"b/new"}}
+		]`)
+	}))
+	defer server.Close()
+
+	client := github.NewGitHubClient("token", server.Client())
+	client.SetBaseURL(server.URL)
+	store := &fakeStarStore{known: map[int64]bool{1: true}}
+	mq := &fakeMQ{}
+
+	repoIDs, queued, err := importStars(client, store, mq, 42, "octocat")
+	if err != nil {
+		t.Fatalf("importStars returned an error: %v", err)
+	}
+	if want := []int64{1, 2}; !reflect.DeepEqual(repoIDs, want) {
+		t.Errorf("repoIDs = %v, want %v", repoIDs, want)
+	}
+	if len(store.stars) != 2 || store.stars[1].RepositoryID != 2 || store.stars[1].StarredAt.IsZero() {
+		t.Errorf("unexpected stored stars %+v", store.stars)
+	}
+
+	// Only the repository missing from the database is crawled.
+	if queued != 1 || len(mq.published[crawlQueueName]) != 1 {
+		t.Fatalf("expected one crawl message, got %d queued, %v", queued, mq.published)
+	}
+	var message models.DiscoveryMessage
+	if err := json.Unmarshal(mq.published[crawlQueueName][0], &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Repository.FullName != "b/new" || message.DiscoveredAt.IsZero() {
+		t.Errorf("unexpected crawl message %+v", message)
+	}
+
+	if _, _, err := importStars(client, store, mq, 42, "nobody"); err == nil {
+		t.Error("importing the stars of an unknown user should fail")
+	}
+}
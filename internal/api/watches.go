@@ -0,0 +1,318 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/mailer"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+const (
+	maxWatches = 100
+
+	defaultNotificationsLimit = 50
+	maxNotificationsLimit     = 200
+)
+
+// Watch targets follow GitHub's naming rules for logins, repositories and topics.
+var (
+	ownerLoginRe     = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,38})$`)
+	repositoryNameRe = regexp.MustCompile(`^[a-z0-9._-]{1,100}$`)
+	topicRe          = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,49}$`)
+)
+
+// watchRequest is the body of the requests creating a watch.
+type watchRequest struct {
+	Kind       string `json:"kind"`
+	Target     string `json:"target"`
+	Email      string `json:"email"`
+	WebhookURL string `json:"webhookUrl"`
+}
+
+// validateWatch checks a watch request and returns the watch with its target
+// normalised to lowercase.
+func validateWatch(request watchRequest) (models.Watch, error) {
+	watch := models.Watch{Kind: request.Kind, Target: strings.ToLower(strings.TrimSpace(request.Target))}
+	switch watch.Kind {
+	case models.WatchRepository:
+		owner, name, ok := strings.Cut(watch.Target, "/")
+		if !ok || !ownerLoginRe.MatchString(owner) || !repositoryNameRe.MatchString(name) {
+			return models.Watch{}, fmt.Errorf("target must be a repository full name like owner/name")
+		}
+	case models.WatchOwner:
+		if !ownerLoginRe.MatchString(watch.Target) {
+			return models.Watch{}, fmt.Errorf("target must be a GitHub login")
+		}
+	case models.WatchTopic:
+		if !topicRe.MatchString(watch.Target) {
+			return models.Watch{}, fmt.Errorf("target must be a GitHub topic")
+		}
+	default:
+		return models.Watch{}, fmt.Errorf("kind must be %s, %s or %s", models.WatchRepository, models.WatchOwner, models.WatchTopic)
+	}
+
+	if email := strings.TrimSpace(request.Email); email != "" {
+		address, err := mail.ParseAddress(email)
+		if err != nil || address.Name != "" {
+			return models.Watch{}, fmt.Errorf("email must be an email address")
+		}
+		watch.Email = address.Address
+	}
+	if webhookURL := strings.TrimSpace(request.WebhookURL); webhookURL != "" {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return models.Watch{}, fmt.Errorf("webhookUrl must be an http or https URL")
+		}
+		watch.WebhookURL = u.String()
+	}
+	return watch, nil
+}
+
+func handleListWatches(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestUserSession(c, cfg)
+		if !ok {
+			return
+		}
+		watches, err := pgdb.GetWatches(sessionID)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve watches", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"watches": watches})
+	}
+}
+
+// watchConfirmationEmail asks the owner of a watch's email address to confirm it.
+// Nothing is emailed to the address until it is confirmed.
+func watchConfirmationEmail(cfg *config.Config, watch models.Watch) mailer.Message {
+	token := url.QueryEscape(watch.EmailToken)
+	confirmURL := cfg.PublicAPIURL + "/watches/confirm?token=" + token
+	unsubscribeURL := cfg.PublicAPIURL + "/watches/unsubscribe?token=" + token
+	return mailer.Message{
+		Subject: fmt.Sprintf("Confirm notifications about the %s %s", watch.Kind, watch.Target),
+		Text: fmt.Sprintf("Someone asked to email this address when the GitHub %s %s has news: star milestones, releases, archiving or trending.\n\n"+
+			"Confirm to start receiving these emails: %s\n\n"+
+			"If you did not ask for them, ignore this email and nothing more will be sent, or remove the address right away: %s\n",
+			watch.Kind, watch.Target, confirmURL, unsubscribeURL),
+		UnsubscribeURL: unsubscribeURL,
+	}
+}
+
+// handleCreateWatch creates a watch of the logged-in user. An email address is only
+// notified once confirmed through the link emailed to it.
+func handleCreateWatch(cfg *config.Config, pgdb *database.PostgresConnection, emails *mailer.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, userID, ok := requestUserSession(c, cfg)
+		if !ok {
+			return
+		}
+		var requestBody watchRequest
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+		watch, err := validateWatch(requestBody)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err, cfg.Debug)
+			return
+		}
+		if watch.Email != "" {
+			if emails == nil {
+				errorResponse(c, http.StatusBadRequest, "Email notifications are not available", nil, cfg.Debug)
+				return
+			}
+			if watch.EmailToken, err = randomToken(16); err != nil {
+				errorResponse(c, http.StatusInternalServerError, "Failed to create watch", err, cfg.Debug)
+				return
+			}
+		}
+
+		watches, err := pgdb.GetWatches(sessionID)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve watches", err, cfg.Debug)
+			return
+		}
+		if len(watches) >= maxWatches {
+			errorResponse(c, http.StatusBadRequest, fmt.Sprintf("A session can have at most %d watches", maxWatches), nil, cfg.Debug)
+			return
+		}
+
+		watch, err = pgdb.CreateWatch(sessionID, userID, watch)
+		if err == database.ErrWatchExists {
+			errorResponse(c, http.StatusConflict, "This target is already watched", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to create watch", err, cfg.Debug)
+			return
+		}
+		if watch.Email != "" {
+			if err := emails.Send(watch.Email, watchConfirmationEmail(cfg, watch)); err != nil {
+				log.Printf("Failed to email the confirmation of watch %d: %v", watch.ID, err)
+			}
+		}
+		c.JSON(http.StatusCreated, watch)
+	}
+}
+
+func handleDeleteWatch(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestUserSession(c, cfg)
+		if !ok {
+			return
+		}
+		watchID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid watch ID", err, cfg.Debug)
+			return
+		}
+		err = pgdb.DeleteWatch(watchID, sessionID)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Watch not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to delete watch", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+}
+
+// confirmEmailPage asks for a click before confirming an email address, so that mail
+// scanners following the link do not confirm it.
+var confirmEmailPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html><body>
+<form method="post" action="?token={{.Token}}"><button type="submit">{{.Action}}</button></form>
+</body></html>
+`))
+
+// confirmEmailPageData is what confirmEmailPage renders: the token of the link and the
+// button's label.
+type confirmEmailPageData struct {
+	Token  string
+	Action string
+}
+
+// handleConfirmWatchEmail confirms the email address of the watch of the token query
+// parameter, which confirmation emails link to. GETs from the link answer with a page
+// whose button POSTs the confirmation.
+func handleConfirmWatchEmail(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			errorResponse(c, http.StatusBadRequest, "token query parameter is required", nil, cfg.Debug)
+			return
+		}
+		if c.Request.Method == http.MethodGet {
+			c.Status(http.StatusOK)
+			c.Header("Content-Type", "text/html; charset=utf-8")
+			confirmEmailPage.Execute(c.Writer, confirmEmailPageData{Token: token, Action: "Confirm notification emails"})
+			return
+		}
+		watch, err := pgdb.ConfirmWatchEmail(token)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Watch not found; its email address may have been removed", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to confirm email address", err, cfg.Debug)
+			return
+		}
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(fmt.Sprintf("%s will be emailed about the %s %s.\n", watch.Email, watch.Kind, watch.Target)))
+	}
+}
+
+// handleUnsubscribeWatchEmail removes the email address of the watch of the token query
+// parameter, which notification emails link to. It answers GETs from the link with a
+// page, and POSTs from mail clients' one-click unsubscribe with JSON.
+func handleUnsubscribeWatchEmail(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			errorResponse(c, http.StatusBadRequest, "token query parameter is required", nil, cfg.Debug)
+			return
+		}
+		email, err := pgdb.UnsubscribeWatchEmail(token)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Watch not found; its email address may already be removed", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to unsubscribe", err, cfg.Debug)
+			return
+		}
+		if c.Request.Method == http.MethodGet {
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(fmt.Sprintf("%s will no longer be emailed about this watch.\n", email)))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+}
+
+// handleGetNotifications lists the notifications of a session, most recent first. The
+// unread parameter restricts them to unread ones.
+func handleGetNotifications(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestUserSession(c, cfg)
+		if !ok {
+			return
+		}
+		unreadOnly, _ := strconv.ParseBool(c.Query("unread"))
+		limit := defaultNotificationsLimit
+		if v := c.Query("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > maxNotificationsLimit {
+				errorResponse(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxNotificationsLimit), err, cfg.Debug)
+				return
+			}
+		}
+
+		notifications, err := pgdb.GetNotifications(sessionID, unreadOnly, limit)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve notifications", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"notifications": notifications})
+	}
+}
+
+// handleMarkNotificationsRead marks the listed notifications of a session as read, or
+// all of them when no ID is given.
+func handleMarkNotificationsRead(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, _, ok := requestUserSession(c, cfg)
+		if !ok {
+			return
+		}
+		var requestBody struct {
+			IDs []int64 `json:"ids"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.BindJSON(&requestBody); err != nil {
+				errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+				return
+			}
+		}
+		marked, err := pgdb.MarkNotificationsRead(sessionID, requestBody.IDs)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to mark notifications as read", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success", "marked": marked})
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func TestValidateWatch(t *testing.T) {
+	watch, err := validateWatch(watchRequest{Kind: models.WatchRepository, Target: " Acme/Rocket.js ", Email: "Dev@Example.com", WebhookURL: "https://hooks.example.com/x"})
+	if err != nil {
+		t.Fatalf("validateWatch returned an error: %v", err)
+	}
+	if watch.Target != "acme/rocket.js" || watch.Email != "Dev@Example.com" || watch.WebhookURL != "https://hooks.example.com/x" {
+		t.Errorf("unexpected watch %+v", watch)
+	}
+	if watch, err := validateWatch(watchRequest{Kind: models.WatchTopic, Target: "LLM"}); err != nil || watch.Target != "llm" {
+		t.Errorf("validateWatch(topic LLM) = %+v, %v", watch, err)
+	}
+
+	for _, invalid := range []watchRequest{
+		{Kind: "language", Target: "go"},
+		{Kind: models.WatchRepository, Target: "acme"},
+		{Kind: models.WatchRepository, Target: "acme/rocket/extra"},
+		{Kind: models.WatchOwner, Target: "-acme"},
+		{Kind: models.WatchTopic, Target: "machine learning"},
+		{Kind: models.WatchOwner, Target: "acme", Email: "Dev <dev@example.com>"},
+		{Kind: models.WatchOwner, Target: "acme", Email: "not an address"},
+		{Kind: models.WatchOwner, Target: "acme", WebhookURL: "ftp://example.com"},
+		{Kind: models.WatchOwner, Target: "acme", WebhookURL: "/relative"},
+	} {
+		if _, err := validateWatch(invalid); err == nil {
+			t.Errorf("validateWatch(%+v) should fail", invalid)
+		}
+	}
+}
+
+func TestWatchConfirmationEmail(t *testing.T) {
+	cfg := &config.Config{PublicAPIURL: "https://api.example.com"}
+	message := watchConfirmationEmail(cfg, models.Watch{Kind: models.WatchRepository, Target: "acme/rocket", Email: "dev@example.com", EmailToken: "tok"})
+	if message.UnsubscribeURL != "https://api.example.com/watches/unsubscribe?token=tok" {
+		t.Errorf("unexpected unsubscribe URL %s", message.UnsubscribeURL)
+	}
+	for _, want := range []string{"acme/rocket", "https://api.example.com/watches/confirm?token=tok", message.UnsubscribeURL} {
+		if !strings.Contains(message.Text, want) {
+			t.Errorf("the confirmation email should contain %q, got %q", want, message.Text)
+		}
+	}
+}
+
+func TestConfirmWatchEmailPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// The page only links to the confirmation, so it does not touch the database.
+	router.GET("/watches/confirm", handleConfirmWatchEmail(&config.Config{}, nil))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/watches/confirm?token=%22%3Ex", nil))
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || !strings.Contains(body, `<form method="post"`) {
+		t.Fatalf("unexpected response %d %q", recorder.Code, body)
+	}
+	if strings.Contains(body, `">x`) {
+		t.Errorf("the token should be escaped, got %q", body)
+	}
+}
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/database"
+	"github.com/teomiscia/github-trending/internal/messaging"
+	"github.com/teomiscia/github-trending/internal/models"
+	"github.com/teomiscia/github-trending/internal/webhooks"
+)
+
+const (
+	maxWebhookFilters = 50
+
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 200
+)
+
+// requireAdmin rejects requests without the admin bearer token.
+func requireAdmin(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) != 1 {
+			errorResponse(c, http.StatusUnauthorized, "Admin token required", nil, cfg.Debug)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// webhookRequest is the body of the requests creating and updating a webhook. Fields
+// left out of an update keep their value.
+type webhookRequest struct {
+	URL         *string   `json:"url"`
+	Description *string   `json:"description"`
+	Secret      *string   `json:"secret"`
+	EventTypes  *[]string `json:"event_types"`
+	Languages   *[]string `json:"languages"`
+	Topics      *[]string `json:"topics"`
+	Active      *bool     `json:"active"`
+}
+
+// validateWebhook applies a webhook request to a webhook and checks the result.
+// Languages and topics are normalised to lowercase.
+func validateWebhook(request webhookRequest, h models.Webhook) (models.Webhook, error) {
+	if request.URL != nil {
+		u, err := url.Parse(strings.TrimSpace(*request.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return models.Webhook{}, fmt.Errorf("url must be an http or https URL")
+		}
+		h.URL = u.String()
+	}
+	if h.URL == "" {
+		return models.Webhook{}, fmt.Errorf("url is required")
+	}
+	if request.Description != nil {
+		h.Description = strings.TrimSpace(*request.Description)
+	}
+	if request.EventTypes != nil {
+		h.EventTypes = []string{}
+		for _, t := range *request.EventTypes {
+			if !contains(webhooks.EventTypes, t) {
+				return models.Webhook{}, fmt.Errorf("event_types must be among %s", strings.Join(webhooks.EventTypes, ", "))
+			}
+			h.EventTypes = append(h.EventTypes, t)
+		}
+	}
+	for _, filter := range []struct {
+		name   string
+		values *[]string
+		dest   *[]string
+	}{
+		{"languages", request.Languages, &h.Languages},
+		{"topics", request.Topics, &h.Topics},
+	} {
+		if filter.values == nil {
+			continue
+		}
+		if len(*filter.values) > maxWebhookFilters {
+			return models.Webhook{}, fmt.Errorf("%s can have at most %d values", filter.name, maxWebhookFilters)
+		}
+		*filter.dest = []string{}
+		for _, v := range *filter.values {
+			v = strings.ToLower(strings.TrimSpace(v))
+			if v == "" {
+				return models.Webhook{}, fmt.Errorf("%s cannot contain empty values", filter.name)
+			}
+			*filter.dest = append(*filter.dest, v)
+		}
+	}
+	if request.Active != nil {
+		h.Active = *request.Active
+	}
+	return h, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookID parses the webhook ID of the request, responding with an error if it is
+// invalid.
+func webhookID(c *gin.Context, cfg *config.Config, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, fmt.Sprintf("Invalid %s ID", name), err, cfg.Debug)
+		return 0, false
+	}
+	return id, true
+}
+
+func handleListWebhooks(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hooks, err := pgdb.GetWebhooks(false)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve webhooks", err, cfg.Debug)
+			return
+		}
+		for i := range hooks {
+			hooks[i].Secret = ""
+		}
+		c.JSON(http.StatusOK, gin.H{"webhooks": hooks})
+	}
+}
+
+// handleCreateWebhook registers a webhook. Its secret, generated unless one is given,
+// is only returned here.
+func handleCreateWebhook(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var requestBody webhookRequest
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+		h, err := validateWebhook(requestBody, models.Webhook{Active: true, EventTypes: []string{}, Languages: []string{}, Topics: []string{}})
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err, cfg.Debug)
+			return
+		}
+		if requestBody.Secret != nil && *requestBody.Secret != "" {
+			h.Secret = *requestBody.Secret
+		} else if h.Secret, err = randomToken(32); err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to generate webhook secret", err, cfg.Debug)
+			return
+		}
+
+		h, err = pgdb.CreateWebhook(h)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to create webhook", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusCreated, h)
+	}
+}
+
+func handleGetWebhook(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := webhookID(c, cfg, "webhook")
+		if !ok {
+			return
+		}
+		h, err := pgdb.GetWebhook(id)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Webhook not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve webhook", err, cfg.Debug)
+			return
+		}
+		h.Secret = ""
+		c.JSON(http.StatusOK, h)
+	}
+}
+
+// handleUpdateWebhook updates the URL, description, filters or state of a webhook. The
+// secret cannot be changed; register a new webhook to rotate it.
+func handleUpdateWebhook(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := webhookID(c, cfg, "webhook")
+		if !ok {
+			return
+		}
+		var requestBody webhookRequest
+		if err := c.BindJSON(&requestBody); err != nil {
+			errorResponse(c, http.StatusBadRequest, "Invalid request body", err, cfg.Debug)
+			return
+		}
+		if requestBody.Secret != nil {
+			errorResponse(c, http.StatusBadRequest, "secret cannot be changed", nil, cfg.Debug)
+			return
+		}
+		h, err := pgdb.GetWebhook(id)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Webhook not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve webhook", err, cfg.Debug)
+			return
+		}
+		h, err = validateWebhook(requestBody, h)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err, cfg.Debug)
+			return
+		}
+		err = pgdb.UpdateWebhook(h)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Webhook not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to update webhook", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+}
+
+func handleDeleteWebhook(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := webhookID(c, cfg, "webhook")
+		if !ok {
+			return
+		}
+		err := pgdb.DeleteWebhook(id)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Webhook not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to delete webhook", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+}
+
+// handleGetWebhookDeliveries lists the deliveries of a webhook, most recent first. The
+// status parameter restricts them to one status.
+func handleGetWebhookDeliveries(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := webhookID(c, cfg, "webhook")
+		if !ok {
+			return
+		}
+		status := c.Query("status")
+		switch status {
+		case "", models.DeliveryPending, models.DeliverySending, models.DeliveryRetrying, models.DeliverySucceeded, models.DeliveryFailed:
+		default:
+			errorResponse(c, http.StatusBadRequest, "Invalid status", nil, cfg.Debug)
+			return
+		}
+		limit := defaultDeliveriesLimit
+		if v := c.Query("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > maxDeliveriesLimit {
+				errorResponse(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxDeliveriesLimit), err, cfg.Debug)
+				return
+			}
+		}
+
+		deliveries, err := pgdb.GetWebhookDeliveries(id, status, limit)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve deliveries", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+	}
+}
+
+// handleGetWebhookDelivery returns a delivery with the log of its attempts.
+func handleGetWebhookDelivery(cfg *config.Config, pgdb *database.PostgresConnection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := webhookID(c, cfg, "delivery")
+		if !ok {
+			return
+		}
+		delivery, err := pgdb.GetWebhookDelivery(id)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Delivery not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to retrieve delivery", err, cfg.Debug)
+			return
+		}
+		c.JSON(http.StatusOK, delivery)
+	}
+}
+
+// handleReplayWebhookDelivery sends a delivery again with a fresh retry budget,
+// whatever its status.
+func handleReplayWebhookDelivery(cfg *config.Config, pgdb *database.PostgresConnection, mqConnection messaging.MQConnection) gin.HandlerFunc {
+	dispatcher := webhooks.NewDispatcher(pgdb, mqConnection, nil)
+	return func(c *gin.Context) {
+		id, ok := webhookID(c, cfg, "delivery")
+		if !ok {
+			return
+		}
+		err := pgdb.ResetWebhookDelivery(id)
+		if err == sql.ErrNoRows {
+			errorResponse(c, http.StatusNotFound, "Delivery not found", err, cfg.Debug)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, "Failed to reset delivery", err, cfg.Debug)
+			return
+		}
+		dispatcher.Enqueue(id)
+		c.JSON(http.StatusAccepted, gin.H{"status": "success"})
+	}
+}
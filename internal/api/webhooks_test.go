@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/teomiscia/github-trending/internal/config"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func TestValidateWebhook(t *testing.T) {
+	url, events, languages := " https://hooks.example.com/x ", []string{models.EventReleasePublished}, []string{" Go ", "Rust"}
+	h, err := validateWebhook(webhookRequest{URL: &url, EventTypes: &events, Languages: &languages}, models.Webhook{Active: true, Topics: []string{}})
+	if err != nil {
+		t.Fatalf("validateWebhook returned an error: %v", err)
+	}
+	if h.URL != "https://hooks.example.com/x" || !reflect.DeepEqual(h.Languages, []string{"go", "rust"}) || !reflect.DeepEqual(h.EventTypes, events) || !h.Active {
+		t.Errorf("unexpected webhook %+v", h)
+	}
+
+	// Fields left out of an update keep their value.
+	inactive := false
+	updated, err := validateWebhook(webhookRequest{Active: &inactive}, h)
+	if err != nil || updated.URL != h.URL || !reflect.DeepEqual(updated.Languages, h.Languages) || updated.Active {
+		t.Errorf("unexpected update %+v, %v", updated, err)
+	}
+
+	ftp, unknown, empty := "ftp://example.com", []string{"repository.starred"}, []string{" "}
+	for _, invalid := range []webhookRequest{
+		{},
+		{URL: &ftp},
+		{URL: &url, EventTypes: &unknown},
+		{URL: &url, Topics: &empty},
+	} {
+		if _, err := validateWebhook(invalid, models.Webhook{}); err == nil {
+			t.Errorf("validateWebhook(%+v) should fail", invalid)
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{AdminToken: "0123456789abcdef0123456789abcdef"}
+	router := gin.New()
+	router.GET("/admin", requireAdmin(cfg), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for header, want := range map[string]int{
+		"":                         http.StatusUnauthorized,
+		"Bearer wrong":             http.StatusUnauthorized,
+		cfg.AdminToken:             http.StatusUnauthorized,
+		"Bearer " + cfg.AdminToken: http.StatusNoContent,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("Authorization %q: got status %d, want %d", header, w.Code, want)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
 )
 
 // Config holds application configuration.
@@ -40,6 +43,93 @@ type Config struct {
 	TwitterApiSecretKey      string
 	TwitterAccessToken       string
 	TwitterAccessSecret      string
+	// SocialNetworks are the networks the social poster posts trending repositories to,
+	// e.g. SOCIAL_NETWORKS=twitter,mastodon,bluesky,linkedin,postiz.
+	SocialNetworks []string
+	// SocialTemplatesDir holds .tmpl files overriding the built-in social post
+	// templates (post.tmpl, thread.tmpl, or <network>.post.tmpl and
+	// <network>.thread.tmpl for one network). They are reloaded before each run.
+	SocialTemplatesDir string
+	// SocialThreads follows posts with a thread of their README highlights, on the
+	// networks that have threads.
+	SocialThreads bool
+	// MastodonInstanceURL is the Mastodon server the account posting is on, and
+	// MastodonAccessToken an access token of one of its applications with the
+	// write:statuses scope.
+	MastodonInstanceURL string
+	MastodonAccessToken string
+	// BlueskyHandle and BlueskyAppPassword log the poster into the Bluesky PDS at
+	// BlueskyPDSURL.
+	BlueskyHandle      string
+	BlueskyAppPassword string
+	BlueskyPDSURL      string
+	// LinkedInAccessToken is an OAuth token with the w_member_social (or
+	// w_organization_social) scope, posting as LinkedInAuthorURN, e.g.
+	// urn:li:organization:123.
+	LinkedInAccessToken string
+	LinkedInAuthorURN   string
+	// PostizAPIURL is the public API of the Postiz instance posts are scheduled
+	// through, e.g. https://postiz.example.com/api/public/v1, and PostizAPIKey the API
+	// key from its settings.
+	PostizAPIURL string
+	PostizAPIKey string
+	// PostizIntegrations are the IDs of the Postiz channels posted to; empty posts to
+	// all the enabled channels. None may be on a network of SocialNetworks.
+	PostizIntegrations []string
+	// PostizPostType is how posts are created in Postiz: "schedule"d PostizScheduleDelay
+	// after they are created, as a "draft" to schedule by hand, or published "now".
+	PostizPostType      string
+	PostizScheduleDelay time.Duration
+	// MarkupServiceURL is the optional markup-service used when native README
+	// rendering fails. Empty disables the fallback.
+	MarkupServiceURL string
+	// EmbeddingVersion selects the versioned Qdrant collections the embedding service
+	// writes to (e.g. repositories_v2). Readers go through unversioned aliases.
+	EmbeddingVersion int
+	// EmbeddingVectorSize is the dimension of the embedding model's vectors.
+	EmbeddingVectorSize int
+	// EmbeddingAliasThreshold is the share of repositories that must be embedded at
+	// EmbeddingVersion before the backfill job switches the aliases to it.
+	EmbeddingAliasThreshold float64
+	// SimilarityWeights maps similarity scorer names to their weight in the blend,
+	// e.g. SIMILARITY_WEIGHTS=cosine=0.5,topics=0.3,coviews=0.2.
+	SimilarityWeights map[string]float64
+	// CoViewWeight is the share of a personalised recommendation's score that comes
+	// from co-view (behavioural) neighbours rather than content similarity.
+	CoViewWeight float64
+	// RerankLambda trades relevance (1) against diversity (0) when re-ranking
+	// personalised lists.
+	RerankLambda float64
+	// RerankEpsilon is the share of personalised slots given to trending repositories.
+	RerankEpsilon float64
+	// RerankMaxPerOwner caps the repositories of one owner per page; 0 disables it.
+	RerankMaxPerOwner int
+	// GitHubClientID and GitHubClientSecret identify the GitHub OAuth app used for login.
+	// An empty GitHubClientID disables login.
+	GitHubClientID     string
+	GitHubClientSecret string
+	// GitHubOAuthCallbackURL is the API's /auth/github/callback URL registered with the
+	// OAuth app.
+	GitHubOAuthCallbackURL string
+	// AuthRedirectURL is the app URL users are sent back to after logging in.
+	AuthRedirectURL string
+	// SessionSecret signs the session tokens of logged-in users.
+	SessionSecret string
+	// SMTPHost and SMTPPort are the mail server notifications are emailed through. An
+	// empty SMTPHost disables email delivery; SMTPUsername may be empty for servers
+	// without authentication.
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPFrom is the sender address of the emails.
+	SMTPFrom string
+	// PublicAPIURL is the URL the API is reachable at from outside, which emails link
+	// to for OG images, confirming and unsubscribing.
+	PublicAPIURL string
+	// AdminToken is the bearer token of the admin endpoints. An empty AdminToken
+	// disables them.
+	AdminToken string
 }
 
 // ParseDuration parses a duration string with support for "months".
@@ -95,6 +185,183 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid EMBEDDING_API_IDLE_TIMEOUT: %w", err)
 	}
 
+	embeddingVersion := 1
+	if v := os.Getenv("EMBEDDING_VERSION"); v != "" {
+		embeddingVersion, err = strconv.Atoi(v)
+		if err != nil || embeddingVersion < 1 {
+			return nil, fmt.Errorf("invalid EMBEDDING_VERSION: %s", v)
+		}
+	}
+
+	embeddingVectorSize := 384
+	if v := os.Getenv("EMBEDDING_VECTOR_SIZE"); v != "" {
+		embeddingVectorSize, err = strconv.Atoi(v)
+		if err != nil || embeddingVectorSize < 1 {
+			return nil, fmt.Errorf("invalid EMBEDDING_VECTOR_SIZE: %s", v)
+		}
+	}
+
+	embeddingAliasThreshold := 0.95
+	if v := os.Getenv("EMBEDDING_ALIAS_THRESHOLD"); v != "" {
+		embeddingAliasThreshold, err = strconv.ParseFloat(v, 64)
+		if err != nil || embeddingAliasThreshold <= 0 || embeddingAliasThreshold > 1 {
+			return nil, fmt.Errorf("invalid EMBEDDING_ALIAS_THRESHOLD: %s", v)
+		}
+	}
+
+	similarityWeightsStr := models.DefaultSimilarityWeights
+	if v := os.Getenv("SIMILARITY_WEIGHTS"); v != "" {
+		similarityWeightsStr = v
+	}
+	similarityWeights, err := models.ParseSimilarityWeights(similarityWeightsStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SIMILARITY_WEIGHTS: %w", err)
+	}
+
+	coViewWeight := 0.3
+	if v := os.Getenv("COVIEW_WEIGHT"); v != "" {
+		coViewWeight, err = strconv.ParseFloat(v, 64)
+		if err != nil || coViewWeight < 0 || coViewWeight > 1 {
+			return nil, fmt.Errorf("invalid COVIEW_WEIGHT: %s", v)
+		}
+	}
+
+	rerankLambda := 0.7
+	if v := os.Getenv("RERANK_LAMBDA"); v != "" {
+		rerankLambda, err = strconv.ParseFloat(v, 64)
+		if err != nil || rerankLambda < 0 || rerankLambda > 1 {
+			return nil, fmt.Errorf("invalid RERANK_LAMBDA: %s", v)
+		}
+	}
+
+	rerankEpsilon := 0.1
+	if v := os.Getenv("RERANK_EPSILON"); v != "" {
+		rerankEpsilon, err = strconv.ParseFloat(v, 64)
+		if err != nil || rerankEpsilon < 0 || rerankEpsilon > 1 {
+			return nil, fmt.Errorf("invalid RERANK_EPSILON: %s", v)
+		}
+	}
+
+	rerankMaxPerOwner := 2
+	if v := os.Getenv("RERANK_MAX_PER_OWNER"); v != "" {
+		rerankMaxPerOwner, err = strconv.Atoi(v)
+		if err != nil || rerankMaxPerOwner < 0 {
+			return nil, fmt.Errorf("invalid RERANK_MAX_PER_OWNER: %s", v)
+		}
+	}
+
+	githubClientID := os.Getenv("GITHUB_CLIENT_ID")
+	sessionSecret := os.Getenv("SESSION_SECRET")
+	if githubClientID != "" {
+		if os.Getenv("GITHUB_CLIENT_SECRET") == "" || os.Getenv("GITHUB_OAUTH_CALLBACK_URL") == "" {
+			return nil, fmt.Errorf("GITHUB_CLIENT_SECRET and GITHUB_OAUTH_CALLBACK_URL are required with GITHUB_CLIENT_ID")
+		}
+		if len(sessionSecret) < 32 {
+			return nil, fmt.Errorf("invalid SESSION_SECRET: at least 32 characters are required with GITHUB_CLIENT_ID")
+		}
+	}
+
+	authRedirectURL := "/"
+	if v := os.Getenv("AUTH_REDIRECT_URL"); v != "" {
+		authRedirectURL = v
+	}
+
+	smtpPort := "25"
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		if port, err := strconv.Atoi(v); err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %s", v)
+		}
+		smtpPort = v
+	}
+
+	smtpFrom := "notifications@localhost"
+	if v := os.Getenv("SMTP_FROM"); v != "" {
+		smtpFrom = v
+	}
+
+	publicAPIURL := "http://localhost:8080"
+	if v := os.Getenv("PUBLIC_API_URL"); v != "" {
+		if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid PUBLIC_API_URL: %s", v)
+		}
+		publicAPIURL = strings.TrimSuffix(v, "/")
+	}
+
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken != "" && len(adminToken) < 32 {
+		return nil, fmt.Errorf("invalid ADMIN_TOKEN: at least 32 characters are required")
+	}
+
+	socialNetworks := []string{"twitter"}
+	if v := os.Getenv("SOCIAL_NETWORKS"); v != "" {
+		socialNetworks = nil
+		for _, network := range strings.Split(v, ",") {
+			network = strings.ToLower(strings.TrimSpace(network))
+			switch network {
+			case "twitter", "mastodon", "bluesky", "linkedin", "postiz":
+				socialNetworks = append(socialNetworks, network)
+			default:
+				return nil, fmt.Errorf("invalid SOCIAL_NETWORKS: %s", v)
+			}
+		}
+	}
+
+	socialTemplatesDir := os.Getenv("SOCIAL_TEMPLATES_DIR")
+	if socialTemplatesDir != "" {
+		if info, err := os.Stat(socialTemplatesDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("invalid SOCIAL_TEMPLATES_DIR: %s", socialTemplatesDir)
+		}
+	}
+
+	socialThreads := false
+	if v := os.Getenv("SOCIAL_THREADS"); v != "" {
+		socialThreads, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SOCIAL_THREADS: %s", v)
+		}
+	}
+
+	blueskyPDSURL := "https://bsky.social"
+	if v := os.Getenv("BLUESKY_PDS_URL"); v != "" {
+		if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid BLUESKY_PDS_URL: %s", v)
+		}
+		blueskyPDSURL = strings.TrimSuffix(v, "/")
+	}
+
+	postizAPIURL := os.Getenv("POSTIZ_API_URL")
+	if postizAPIURL != "" {
+		if u, err := url.Parse(postizAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid POSTIZ_API_URL: %s", postizAPIURL)
+		}
+		postizAPIURL = strings.TrimSuffix(postizAPIURL, "/")
+	}
+
+	var postizIntegrations []string
+	if v := os.Getenv("POSTIZ_INTEGRATIONS"); v != "" {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				postizIntegrations = append(postizIntegrations, id)
+			}
+		}
+	}
+
+	postizPostType := "schedule"
+	if v := os.Getenv("POSTIZ_POST_TYPE"); v != "" {
+		if v != "schedule" && v != "draft" && v != "now" {
+			return nil, fmt.Errorf("invalid POSTIZ_POST_TYPE: %s", v)
+		}
+		postizPostType = v
+	}
+
+	postizScheduleDelay := time.Hour
+	if v := os.Getenv("POSTIZ_SCHEDULE_DELAY"); v != "" {
+		postizScheduleDelay, err = time.ParseDuration(v)
+		if err != nil || postizScheduleDelay < 0 {
+			return nil, fmt.Errorf("invalid POSTIZ_SCHEDULE_DELAY: %s", v)
+		}
+	}
+
 	debug, _ := strconv.ParseBool(os.Getenv("DEBUG"))
 
 	config := &Config{
@@ -128,6 +395,42 @@ func Load() (*Config, error) {
 		TwitterApiSecretKey:      os.Getenv("TWITTER_API_SECRET_KEY"),
 		TwitterAccessToken:       os.Getenv("TWITTER_ACCESS_TOKEN"),
 		TwitterAccessSecret:      os.Getenv("TWITTER_ACCESS_SECRET"),
+		SocialNetworks:           socialNetworks,
+		SocialTemplatesDir:       socialTemplatesDir,
+		SocialThreads:            socialThreads,
+		MastodonInstanceURL:      strings.TrimSuffix(os.Getenv("MASTODON_INSTANCE_URL"), "/"),
+		MastodonAccessToken:      os.Getenv("MASTODON_ACCESS_TOKEN"),
+		BlueskyHandle:            os.Getenv("BLUESKY_HANDLE"),
+		BlueskyAppPassword:       os.Getenv("BLUESKY_APP_PASSWORD"),
+		BlueskyPDSURL:            blueskyPDSURL,
+		LinkedInAccessToken:      os.Getenv("LINKEDIN_ACCESS_TOKEN"),
+		LinkedInAuthorURN:        os.Getenv("LINKEDIN_AUTHOR_URN"),
+		PostizAPIURL:             postizAPIURL,
+		PostizAPIKey:             os.Getenv("POSTIZ_API_KEY"),
+		PostizIntegrations:       postizIntegrations,
+		PostizPostType:           postizPostType,
+		PostizScheduleDelay:      postizScheduleDelay,
+		MarkupServiceURL:         os.Getenv("MARKUP_SERVICE_URL"),
+		EmbeddingVersion:         embeddingVersion,
+		EmbeddingVectorSize:      embeddingVectorSize,
+		EmbeddingAliasThreshold:  embeddingAliasThreshold,
+		SimilarityWeights:        similarityWeights,
+		CoViewWeight:             coViewWeight,
+		RerankLambda:             rerankLambda,
+		RerankEpsilon:            rerankEpsilon,
+		RerankMaxPerOwner:        rerankMaxPerOwner,
+		GitHubClientID:           githubClientID,
+		GitHubClientSecret:       os.Getenv("GITHUB_CLIENT_SECRET"),
+		GitHubOAuthCallbackURL:   os.Getenv("GITHUB_OAUTH_CALLBACK_URL"),
+		AuthRedirectURL:          authRedirectURL,
+		SessionSecret:            sessionSecret,
+		SMTPHost:                 os.Getenv("SMTP_HOST"),
+		SMTPPort:                 smtpPort,
+		SMTPUsername:             os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:             os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:                 smtpFrom,
+		PublicAPIURL:             publicAPIURL,
+		AdminToken:               adminToken,
 	}
 
 	if os.Getenv("LOCAL_ENV") == "true" {
@@ -253,3 +253,207 @@ func (ch *ClickHouseConnection) GetTrendingRepositoryIDsByGrowth(days int) ([]in
 	// log.Printf("ClickHouse trending query returned %d repository IDs.", len(ids))
 	return ids, nil
 }
+
+// GetRepositoriesGrowth retrieves the latest stars and forks of the given repositories
+// and how many they gained over the last days. Repositories without stats are omitted;
+// those without stats older than the period count their growth from their first stat.
+func (ch *ClickHouseConnection) GetRepositoriesGrowth(repoIDs []int64, days int) ([]models.RepositoryGrowth, error) {
+	if len(repoIDs) == 0 {
+		return nil, nil
+	}
+	startTime := time.Now().AddDate(0, 0, -days)
+
+	query := `
+		SELECT
+			repository_id,
+			argMax(stargazers_count, event_time) AS latest_stars,
+			argMax(forks_count, event_time) AS latest_forks,
+			if(countIf(event_time <= ?) > 0, argMaxIf(stargazers_count, event_time, event_time <= ?), argMin(stargazers_count, event_time)) AS past_stars,
+			if(countIf(event_time <= ?) > 0, argMaxIf(forks_count, event_time, event_time <= ?), argMin(forks_count, event_time)) AS past_forks
+		FROM repository_stats
+		WHERE repository_id IN (?)
+		GROUP BY repository_id
+	`
+	rows, err := ch.DB.Query(query, startTime, startTime, startTime, startTime, repoIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var growth []models.RepositoryGrowth
+	for rows.Next() {
+		var repoID, stars, forks, pastStars, pastForks uint64
+		if err := rows.Scan(&repoID, &stars, &forks, &pastStars, &pastForks); err != nil {
+			return nil, err
+		}
+		growth = append(growth, models.RepositoryGrowth{
+			RepositoryID: int64(repoID),
+			Stars:        int64(stars),
+			StarsGained:  int64(stars) - int64(pastStars),
+			Forks:        int64(forks),
+			ForksGained:  int64(forks) - int64(pastForks),
+		})
+	}
+	return growth, rows.Err()
+}
+
+// GetTopRepositoriesGrowthSince retrieves the limit repositories that gained the most
+// stars since the given time, with their latest stars and forks. Repositories without
+// stats that old count their growth from their first stat.
+func (ch *ClickHouseConnection) GetTopRepositoriesGrowthSince(since time.Time, limit int) ([]models.RepositoryGrowth, error) {
+	query := `
+		SELECT
+			repository_id,
+			argMax(stargazers_count, event_time) AS latest_stars,
+			argMax(forks_count, event_time) AS latest_forks,
+			if(countIf(event_time <= ?) > 0, argMaxIf(stargazers_count, event_time, event_time <= ?), argMin(stargazers_count, event_time)) AS past_stars,
+			if(countIf(event_time <= ?) > 0, argMaxIf(forks_count, event_time, event_time <= ?), argMin(forks_count, event_time)) AS past_forks
+		FROM repository_stats
+		GROUP BY repository_id
+		HAVING latest_stars > past_stars
+		ORDER BY toInt64(latest_stars) - toInt64(past_stars) DESC, repository_id
+		LIMIT ?
+	`
+	rows, err := ch.DB.Query(query, since, since, since, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var growth []models.RepositoryGrowth
+	for rows.Next() {
+		var repoID, stars, forks, pastStars, pastForks uint64
+		if err := rows.Scan(&repoID, &stars, &forks, &pastStars, &pastForks); err != nil {
+			return nil, err
+		}
+		growth = append(growth, models.RepositoryGrowth{
+			RepositoryID: int64(repoID),
+			Stars:        int64(stars),
+			StarsGained:  int64(stars) - int64(pastStars),
+			Forks:        int64(forks),
+			ForksGained:  int64(forks) - int64(pastForks),
+		})
+	}
+	return growth, rows.Err()
+}
+
+// GetStarWindows retrieves the star count of the repositories crawled in the last day,
+// a day ago and eight days ago, for the repositories with stats that old in the last
+// 30 days.
+func (ch *ClickHouseConnection) GetStarWindows(now time.Time) ([]models.StarWindow, error) {
+	dayAgo := now.AddDate(0, 0, -1)
+	weekAgo := dayAgo.AddDate(0, 0, -7)
+
+	query := `
+		SELECT
+			repository_id,
+			argMax(stargazers_count, event_time) AS latest_stars,
+			argMaxIf(stargazers_count, event_time, event_time <= ?) AS day_ago_stars,
+			argMaxIf(stargazers_count, event_time, event_time <= ?) AS week_ago_stars
+		FROM repository_stats
+		WHERE event_time >= ?
+		GROUP BY repository_id
+		HAVING max(event_time) > ? AND countIf(event_time <= ?) > 0
+	`
+	rows, err := ch.DB.Query(query, dayAgo, weekAgo, now.AddDate(0, 0, -30), dayAgo, weekAgo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var windows []models.StarWindow
+	for rows.Next() {
+		var repoID, latest, dayAgoStars, weekAgoStars uint64
+		if err := rows.Scan(&repoID, &latest, &dayAgoStars, &weekAgoStars); err != nil {
+			return nil, err
+		}
+		windows = append(windows, models.StarWindow{
+			RepositoryID: int64(repoID),
+			Latest:       int64(latest),
+			DayAgo:       int64(dayAgoStars),
+			WeekAgo:      int64(weekAgoStars),
+		})
+	}
+	return windows, rows.Err()
+}
+
+// InsertRepositoryActivity inserts an issue and pull request activity snapshot into the database.
+func (ch *ClickHouseConnection) InsertRepositoryActivity(repoID int, activity models.RepositoryActivity) error {
+	tx, err := ch.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	stmt, err := tx.Prepare("INSERT INTO repository_activity (event_date, event_time, repository_id, window_days, issues_opened, issues_closed, prs_opened, prs_closed, prs_merged, median_first_response_seconds, merge_rate) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(
+		activity.EventDate,
+		activity.EventTime,
+		repoID,
+		activity.WindowDays,
+		activity.IssuesOpened,
+		activity.IssuesClosed,
+		activity.PullRequestsOpened,
+		activity.PullRequestsClosed,
+		activity.PullRequestsMerged,
+		activity.MedianFirstResponseSeconds,
+		activity.MergeRate,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRepositoryActivity retrieves all activity snapshots for a given repository from ClickHouse.
+func (ch *ClickHouseConnection) GetRepositoryActivity(repoID int64) ([]models.RepositoryActivity, error) {
+	rows, err := ch.DB.Query("SELECT event_date, event_time, window_days, issues_opened, issues_closed, prs_opened, prs_closed, prs_merged, median_first_response_seconds, merge_rate FROM repository_activity WHERE repository_id = ? ORDER BY event_time DESC", repoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []models.RepositoryActivity
+	for rows.Next() {
+		var a models.RepositoryActivity
+		if err := rows.Scan(&a.EventDate, &a.EventTime, &a.WindowDays, &a.IssuesOpened, &a.IssuesClosed, &a.PullRequestsOpened, &a.PullRequestsClosed, &a.PullRequestsMerged, &a.MedianFirstResponseSeconds, &a.MergeRate); err != nil {
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+
+	return activities, nil
+}
+
+// InsertUserEvents inserts a batch of user feedback events into the database.
+func (ch *ClickHouseConnection) InsertUserEvents(events []models.UserEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := ch.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	stmt, err := tx.Prepare("INSERT INTO user_events (event_date, event_time, session_id, repository_id, event_type, dwell_ms) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		if _, err := stmt.Exec(e.Timestamp, e.Timestamp, e.SessionID, uint64(e.RepositoryID), e.Type, uint32(e.DwellMs)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
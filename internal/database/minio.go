@@ -1,7 +1,10 @@
 package database
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
@@ -10,10 +13,16 @@ import (
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// contentHashMetadataKey is the user metadata key holding the SHA-256 of an object's
+// content. MinIO returns user metadata keys in canonical header form.
+const contentHashMetadataKey = "Content-Sha256"
+
 // MinioClient defines the interface for MinIO client operations.
 type MinioClient interface {
 	UploadFile(ctx context.Context, objectName string, reader io.Reader, objectSize int64, contentType string) (minio.UploadInfo, error)
+	UploadFileWithHash(ctx context.Context, objectName string, content []byte, contentType string) (minio.UploadInfo, error)
 	GetFile(ctx context.Context, objectName string) ([]byte, error)
+	GetFileHash(ctx context.Context, objectName string) (string, error)
 	Close() error
 }
 
@@ -90,3 +99,37 @@ func (mc *MinioConnection) GetFile(ctx context.Context, objectName string) ([]by
 
 	return data, nil
 }
+
+// ContentHash returns the hex-encoded SHA-256 of content, as stored in object metadata
+// and in repositories.readme_hash.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// UploadFileWithHash uploads content to MinIO and records its content hash in the
+// object metadata, so later uploads of identical content can be skipped.
+func (mc *MinioConnection) UploadFileWithHash(ctx context.Context, objectName string, content []byte, contentType string) (minio.UploadInfo, error) {
+	opts := minio.PutObjectOptions{
+		ContentType:  contentType,
+		UserMetadata: map[string]string{contentHashMetadataKey: ContentHash(content)},
+	}
+	info, err := mc.Client.PutObject(ctx, mc.Bucket, objectName, bytes.NewReader(content), int64(len(content)), opts)
+	if err != nil {
+		return minio.UploadInfo{}, fmt.Errorf("failed to upload file to MinIO: %w", err)
+	}
+	return info, nil
+}
+
+// GetFileHash returns the content hash recorded for an object. It returns an empty
+// string if the object does not exist or was uploaded without a hash.
+func (mc *MinioConnection) GetFileHash(ctx context.Context, objectName string) (string, error) {
+	info, err := mc.Client.StatObject(ctx, mc.Bucket, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to stat object in MinIO: %w", err)
+	}
+	return info.UserMetadata[contentHashMetadataKey], nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -64,12 +65,73 @@ func (pc *PostgresConnection) Close() error {
 	return pc.DB.Close()
 }
 
-// TrackRepositoryView inserts a record of a repository view into the database.
-func (pc *PostgresConnection) TrackRepositoryView(sessionID string, repositoryID int64) error {
-	_, err := pc.DB.Exec("INSERT INTO repository_views (session_id, repository_id) VALUES ($1, $2)", sessionID, repositoryID)
+// TrackRepositoryView inserts a record of a repository view into the database. userID is
+// 0 for anonymous sessions.
+func (pc *PostgresConnection) TrackRepositoryView(sessionID string, userID, repositoryID int64) error {
+	_, err := pc.DB.Exec("INSERT INTO repository_views (session_id, repository_id, user_id) VALUES ($1, $2, $3)", sessionID, repositoryID, sql.NullInt64{Int64: userID, Valid: userID > 0})
 	return err
 }
 
+// UpsertGitHubUser creates the user with the given GitHub ID, or updates its profile and
+// last login time if it already exists. It returns the stored user and whether it was
+// created.
+func (pc *PostgresConnection) UpsertGitHubUser(user models.User) (models.User, bool, error) {
+	var created bool
+	var starsImportedAt sql.NullTime
+	err := pc.DB.QueryRow(`
+		INSERT INTO users (github_id, login, name, avatar_url, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (github_id) DO UPDATE SET
+			login = EXCLUDED.login,
+			name = EXCLUDED.name,
+			avatar_url = EXCLUDED.avatar_url,
+			last_login_at = NOW()
+		RETURNING id, created_at, last_login_at, stars_imported_at, xmax = 0
+	`, user.GitHubID, user.Login, user.Name, user.AvatarURL).Scan(&user.ID, &user.CreatedAt, &user.LastLoginAt, &starsImportedAt, &created)
+	if err != nil {
+		return models.User{}, false, err
+	}
+	if starsImportedAt.Valid {
+		user.StarsImportedAt = &starsImportedAt.Time
+	}
+	return user, created, nil
+}
+
+// GetUserByID retrieves a user. It returns sql.ErrNoRows if the user does not exist.
+func (pc *PostgresConnection) GetUserByID(userID int64) (models.User, error) {
+	var user models.User
+	var name, avatarURL sql.NullString
+	var starsImportedAt sql.NullTime
+	err := pc.DB.QueryRow(`
+		SELECT id, github_id, login, name, avatar_url, created_at, last_login_at, stars_imported_at
+		FROM users
+		WHERE id = $1
+	`, userID).Scan(&user.ID, &user.GitHubID, &user.Login, &name, &avatarURL, &user.CreatedAt, &user.LastLoginAt, &starsImportedAt)
+	if err != nil {
+		return models.User{}, err
+	}
+	user.Name = name.String
+	user.AvatarURL = avatarURL.String
+	if starsImportedAt.Valid {
+		user.StarsImportedAt = &starsImportedAt.Time
+	}
+	return user, nil
+}
+
+// MergeSessionViews moves the views of an anonymous session to a user's session and
+// attributes them to the user. It returns the number of views moved.
+func (pc *PostgresConnection) MergeSessionViews(fromSessionID, toSessionID string, userID int64) (int64, error) {
+	result, err := pc.DB.Exec(`
+		UPDATE repository_views
+		SET session_id = $2, user_id = $3
+		WHERE session_id = $1 AND user_id IS NULL
+	`, fromSessionID, toSessionID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // GetTrendingRepositoryIDs retrieves a list of trending repository IDs, filtered by languages, tags and seen repositories.
 func (pc *PostgresConnection) GetTrendingRepositoryIDs(languages, tags, topics, seenRepoIDs []string) ([]int64, error) {
 	if pc.RedisClient != nil {
@@ -164,6 +226,7 @@ func (pc *PostgresConnection) getTrendingRepositoryIDsFromDB(languages, tags, to
 }
 
 // FilterRepositoryIDs filters a list of repository IDs based on languages, tags, and topics.
+// Languages match case-insensitively, so "go" matches GitHub's "Go".
 func (pc *PostgresConnection) FilterRepositoryIDs(repoIDs []int64, languages, tags, topics []string) ([]int64, error) {
 	if len(repoIDs) == 0 || (len(languages) == 0 && len(tags) == 0 && len(topics) == 0) {
 		return repoIDs, nil
@@ -174,8 +237,12 @@ func (pc *PostgresConnection) FilterRepositoryIDs(repoIDs []int64, languages, ta
 	argCounter := 2
 
 	if len(languages) > 0 {
-		query += fmt.Sprintf(" AND id IN (SELECT repository_id FROM repository_languages rl JOIN languages l ON rl.language_id = l.id WHERE l.name = ANY($%d))", argCounter)
-		args = append(args, pq.Array(languages))
+		query += fmt.Sprintf(" AND id IN (SELECT repository_id FROM repository_languages rl JOIN languages l ON rl.language_id = l.id WHERE lower(l.name) = ANY($%d))", argCounter)
+		lowered := make([]string, len(languages))
+		for i, language := range languages {
+			lowered[i] = strings.ToLower(language)
+		}
+		args = append(args, pq.Array(lowered))
 		argCounter++
 	}
 
@@ -259,6 +326,34 @@ func (pc *PostgresConnection) GetLastCrawlTime(repoID int64) (time.Time, error)
 	return lastCrawledAt, nil
 }
 
+// GetRepositorySnapshot retrieves the stored state of a repository that a crawl is
+// compared against. It returns nil if the repository is not stored yet.
+func (pc *PostgresConnection) GetRepositorySnapshot(repoID int64) (*models.RepositorySnapshot, error) {
+	var snapshot models.RepositorySnapshot
+	var stars sql.NullInt64
+	var archived sql.NullBool
+	var releaseTag sql.NullString
+	var lastCrawledAt sql.NullTime
+	err := pc.DB.QueryRow(`
+		SELECT stargazers_count, is_archived, latest_release_tag, last_crawled_at
+		FROM repositories WHERE id = $1
+	`, repoID).Scan(&stars, &archived, &releaseTag, &lastCrawledAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stars.Valid {
+		count := int(stars.Int64)
+		snapshot.Stars = &count
+	}
+	snapshot.Archived = archived.Bool
+	snapshot.LatestReleaseTag = releaseTag.String
+	snapshot.LastCrawledAt = lastCrawledAt.Time
+	return &snapshot, nil
+}
+
 // InsertRepository inserts or updates a repository and its related data in a single transaction.
 func (pc *PostgresConnection) InsertRepository(repo models.Repository, lastCrawledAt time.Time) error {
 	tx, err := pc.DB.Begin()
@@ -298,11 +393,18 @@ func (pc *PostgresConnection) InsertRepository(repo models.Repository, lastCrawl
 		}
 	}
 
+	var releaseTag sql.NullString
+	var releasePublishedAt sql.NullTime
+	if repo.LatestRelease != nil {
+		releaseTag = sql.NullString{String: repo.LatestRelease.TagName, Valid: true}
+		releasePublishedAt = sql.NullTime{Time: repo.LatestRelease.PublishedAt, Valid: true}
+	}
+
 	// Insert or update repository.
 	// OPTIMIZATION: The WHERE clause prevents "empty" updates, reducing write load and lock contention.
 	_, err = tx.Exec(`
-		INSERT INTO repositories (id, node_id, name, full_name, owner_id, description, html_url, homepage, default_branch, license_key, readme_url, created_at, is_fork, is_template, is_archived, is_disabled, last_crawled_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		INSERT INTO repositories (id, node_id, name, full_name, owner_id, description, html_url, homepage, default_branch, license_key, readme_url, readme_path, readme_format, created_at, is_fork, is_template, is_archived, is_disabled, last_crawled_at, stargazers_count, latest_release_tag, latest_release_published_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
 		ON CONFLICT (id) DO UPDATE SET
 			node_id = EXCLUDED.node_id, 
 			name = EXCLUDED.name, 
@@ -314,18 +416,27 @@ func (pc *PostgresConnection) InsertRepository(repo models.Repository, lastCrawl
 			default_branch = EXCLUDED.default_branch, 
 			license_key = EXCLUDED.license_key, 
 			readme_url = EXCLUDED.readme_url, 
+			readme_path = EXCLUDED.readme_path, 
+			readme_format = EXCLUDED.readme_format, 
 			is_fork = EXCLUDED.is_fork, 
 			is_template = EXCLUDED.is_template, 
 			is_archived = EXCLUDED.is_archived, 
 			is_disabled = EXCLUDED.is_disabled, 
-			last_crawled_at = EXCLUDED.last_crawled_at
+			last_crawled_at = EXCLUDED.last_crawled_at,
+			stargazers_count = EXCLUDED.stargazers_count,
+			-- A release the crawler failed to fetch keeps the stored one.
+			latest_release_tag = COALESCE(EXCLUDED.latest_release_tag, repositories.latest_release_tag),
+			latest_release_published_at = COALESCE(EXCLUDED.latest_release_published_at, repositories.latest_release_published_at)
 		WHERE repositories.description IS DISTINCT FROM EXCLUDED.description
 		   OR repositories.homepage IS DISTINCT FROM EXCLUDED.homepage
 		   OR repositories.license_key IS DISTINCT FROM EXCLUDED.license_key
 		   OR repositories.is_archived IS DISTINCT FROM EXCLUDED.is_archived
 		   OR repositories.is_disabled IS DISTINCT FROM EXCLUDED.is_disabled
+		   OR repositories.readme_path IS DISTINCT FROM EXCLUDED.readme_path
 		   OR repositories.last_crawled_at < EXCLUDED.last_crawled_at
-	`, repo.ID, repo.NodeID, repo.Name, repo.FullName, repo.Owner.ID, repo.Description, repo.HTMLURL, repo.Homepage, repo.DefaultBranch, repo.License.Key, repo.ReadmeURL, repo.CreatedAt, repo.Fork, repo.IsTemplate, repo.Archived, repo.Disabled, lastCrawledAt)
+		   OR repositories.stargazers_count IS DISTINCT FROM EXCLUDED.stargazers_count
+		   OR (EXCLUDED.latest_release_tag IS NOT NULL AND repositories.latest_release_tag IS DISTINCT FROM EXCLUDED.latest_release_tag)
+	`, repo.ID, repo.NodeID, repo.Name, repo.FullName, repo.Owner.ID, repo.Description, repo.HTMLURL, repo.Homepage, repo.DefaultBranch, repo.License.Key, repo.ReadmeURL, repo.ReadmePath, repo.ReadmeFormat, repo.CreatedAt, repo.Fork, repo.IsTemplate, repo.Archived, repo.Disabled, lastCrawledAt, repo.StargazersCount, releaseTag, releasePublishedAt)
 	if err != nil {
 		log.Printf("Failed to insert repository: %v", err)
 		return err
@@ -379,6 +490,30 @@ func (pc *PostgresConnection) InsertRepository(repo models.Repository, lastCrawl
 		}
 	}
 
+	if repo.Dependencies != nil {
+		// Manifests are re-read on every crawl, so the stored set is replaced rather than merged.
+		if _, err = tx.Exec("DELETE FROM repository_dependencies WHERE repository_id = $1", repo.ID); err != nil {
+			log.Printf("Failed to clear repository_dependencies: %v", err)
+			return err
+		}
+		depUpsertQuery := "INSERT INTO dependencies (ecosystem, name) VALUES ($1, $2) ON CONFLICT (ecosystem, name) DO UPDATE SET name = EXCLUDED.name RETURNING id"
+		for _, dep := range repo.Dependencies {
+			var depID int
+			if err = tx.QueryRow(depUpsertQuery, dep.Ecosystem, dep.Name).Scan(&depID); err != nil {
+				log.Printf("Failed to upsert dependency: %v", err)
+				return err
+			}
+			_, err = tx.Exec(`
+				INSERT INTO repository_dependencies (repository_id, dependency_id, version_constraint, manifest_path, is_dev) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (repository_id, dependency_id) DO NOTHING
+			`, repo.ID, depID, dep.Version, dep.Manifest, dep.Dev)
+			if err != nil {
+				log.Printf("Failed to insert repository_dependency: %v", err)
+				return err
+			}
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return err
 	}
@@ -440,16 +575,17 @@ func (pc *PostgresConnection) getRepositoryByIDFromDB(repoID int64) (models.Repo
 	var ownerNodeID sql.NullString
 	var siteAdmin sql.NullBool
 	var licenseName sql.NullString
-	var tagsJSON, topicsJSON, languagesJSON []byte
+	var tagsJSON, topicsJSON, languagesJSON, dependenciesJSON []byte
 
 	row := pc.DB.QueryRow(`
 		SELECT
-			r.id, r.node_id, r.name, r.full_name, r.owner_id, r.description, r.html_url, r.homepage, r.default_branch, r.license_key, r.readme_url, r.created_at, r.is_fork, r.is_template, r.is_archived, r.is_disabled, r.last_crawled_at,
+			r.id, r.node_id, r.name, r.full_name, r.owner_id, r.description, r.html_url, r.homepage, r.default_branch, r.license_key, r.readme_url, r.readme_path, r.readme_format, r.created_at, r.is_fork, r.is_template, r.is_archived, r.is_disabled, r.last_crawled_at, COALESCE(r.stargazers_count, 0),
 			o.login, o.node_id, o.avatar_url, o.html_url, o.type, o.site_admin,
 			l.name, l.spdx_id, l.url, l.node_id,
 			COALESCE(tags.data, '[]'::jsonb) AS tags,
 			COALESCE(topics.data, '[]'::jsonb) AS topics,
-			COALESCE(languages.data, '{}'::jsonb) AS languages
+			COALESCE(languages.data, '{}'::jsonb) AS languages,
+			COALESCE(dependencies.data, '[]'::jsonb) AS dependencies
 		FROM
 			repositories r
 		JOIN
@@ -462,15 +598,17 @@ func (pc *PostgresConnection) getRepositoryByIDFromDB(repoID int64) (models.Repo
 			(SELECT repository_id, jsonb_agg(t.name) AS data FROM repository_topics rt JOIN topics t ON rt.topic_id = t.id GROUP BY repository_id) AS topics ON r.id = topics.repository_id
 		LEFT JOIN
 			(SELECT repository_id, jsonb_object_agg(l.name, rl.size) AS data FROM repository_languages rl JOIN languages l ON rl.language_id = l.id GROUP BY repository_id) AS languages ON r.id = languages.repository_id
+		LEFT JOIN
+			(SELECT repository_id, jsonb_agg(jsonb_build_object('ecosystem', d.ecosystem, 'name', d.name, 'version', COALESCE(rd.version_constraint, ''), 'manifest', COALESCE(rd.manifest_path, ''), 'dev', rd.is_dev)) AS data FROM repository_dependencies rd JOIN dependencies d ON rd.dependency_id = d.id GROUP BY repository_id) AS dependencies ON r.id = dependencies.repository_id
 		WHERE
 			r.id = $1
 	`, repoID)
 
 	err := row.Scan(
-		&repo.ID, &repoNodeID, &repo.Name, &repo.FullName, &ownerID, &repo.Description, &repo.HTMLURL, &repo.Homepage, &repo.DefaultBranch, &licenseKey, &repo.ReadmeURL, &repo.CreatedAt, &repo.Fork, &repo.IsTemplate, &repo.Archived, &repo.Disabled, &repo.LastCrawledAt,
+		&repo.ID, &repoNodeID, &repo.Name, &repo.FullName, &ownerID, &repo.Description, &repo.HTMLURL, &repo.Homepage, &repo.DefaultBranch, &licenseKey, &repo.ReadmeURL, &repo.ReadmePath, &repo.ReadmeFormat, &repo.CreatedAt, &repo.Fork, &repo.IsTemplate, &repo.Archived, &repo.Disabled, &repo.LastCrawledAt, &repo.StargazersCount,
 		&repo.Owner.Login, &ownerNodeID, &repo.Owner.AvatarURL, &repo.Owner.HTMLURL, &repo.Owner.Type, &siteAdmin,
 		&licenseName, &repo.License.SpdxID, &repo.License.URL, &repo.License.NodeID,
-		&tagsJSON, &topicsJSON, &languagesJSON,
+		&tagsJSON, &topicsJSON, &languagesJSON, &dependenciesJSON,
 	)
 
 	if err != nil {
@@ -503,6 +641,9 @@ func (pc *PostgresConnection) getRepositoryByIDFromDB(repoID int64) (models.Repo
 	if err := json.Unmarshal(languagesJSON, &repo.Languages); err != nil {
 		return models.Repository{}, fmt.Errorf("failed to unmarshal languages: %w", err)
 	}
+	if err := json.Unmarshal(dependenciesJSON, &repo.Dependencies); err != nil {
+		return models.Repository{}, fmt.Errorf("failed to unmarshal dependencies: %w", err)
+	}
 
 	return repo, nil
 }
@@ -627,6 +768,110 @@ func (pc *PostgresConnection) UpsertRepositorySimilarity(repoID int64, data []by
 	return err
 }
 
+// GetReverseSimilarRepositoryIDs returns the repositories whose stored similarity list
+// contains any of repoIDs.
+func (pc *PostgresConnection) GetReverseSimilarRepositoryIDs(repoIDs []int64) ([]int64, error) {
+	if len(repoIDs) == 0 {
+		return nil, nil
+	}
+
+	// Similarity lists are stored as JSON arrays of {"Score": ..., "Member": id}.
+	members := make([]string, len(repoIDs))
+	for i, id := range repoIDs {
+		members[i] = fmt.Sprintf(`[{"Member": %d}]`, id)
+	}
+	query := `
+		SELECT DISTINCT s.id
+		FROM repository_similarity s
+		JOIN unnest($1::jsonb[]) AS m(member) ON s.data @> m.member
+	`
+	rows, err := pc.DB.Query(query, pq.Array(members))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reverse similar repositories: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan repository id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetRepositoryViewsSince retrieves the repository views recorded since the given time.
+func (pc *PostgresConnection) GetRepositoryViewsSince(since time.Time) ([]models.RepositoryView, error) {
+	rows, err := pc.DB.Query("SELECT session_id, repository_id, viewed_at FROM repository_views WHERE viewed_at >= $1", since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var views []models.RepositoryView
+	for rows.Next() {
+		var v models.RepositoryView
+		if err := rows.Scan(&v.SessionID, &v.RepositoryID, &v.ViewedAt); err != nil {
+			return nil, err
+		}
+		views = append(views, v)
+	}
+
+	return views, rows.Err()
+}
+
+// ReplaceRepositoryCoViews replaces all the co-view lists with the given ones, keyed by
+// repository ID, in a single transaction.
+func (pc *PostgresConnection) ReplaceRepositoryCoViews(lists map[int64][]byte) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	if _, err := tx.Exec("DELETE FROM repository_coviews"); err != nil {
+		return fmt.Errorf("failed to clear co-views: %w", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO repository_coviews (id, data) VALUES ($1, $2)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for repoID, data := range lists {
+		if _, err := stmt.Exec(repoID, data); err != nil {
+			return fmt.Errorf("failed to insert co-views for %d: %w", repoID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRepositoryCoViews retrieves the co-view list of a repository, or nil if it has none.
+func (pc *PostgresConnection) GetRepositoryCoViews(repoID int64) ([]byte, error) {
+	key := fmt.Sprintf("coviews:%d", repoID)
+	if pc.RedisClient != nil {
+		val, err := pc.RedisClient.Get(context.Background(), key).Result()
+		if err == nil {
+			return decompress([]byte(val))
+		}
+	}
+
+	var data []byte
+	err := pc.DB.QueryRow("SELECT data FROM repository_coviews WHERE id = $1", repoID).Scan(&data)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// Lists are rebuilt daily, so an empty result is cached too.
+	if pc.RedisClient != nil {
+		pc.RedisClient.Set(context.Background(), key, compress(data), 6*time.Hour)
+	}
+
+	return data, nil
+}
+
 // GetRepositorySimilarity retrieves the similarity data for a repository.
 func (pc *PostgresConnection) GetRepositorySimilarity(repoID int64) ([]byte, error) {
 	if pc.RedisClient != nil {
@@ -716,7 +961,7 @@ func (pc *PostgresConnection) getRepositoriesDataByIDsFromDB(repoIDs []int64) ([
 
 	query := `
 		SELECT
-			r.id, r.node_id, r.name, r.full_name, r.description, r.html_url, r.homepage, r.default_branch, r.license_key, r.readme_url, r.created_at, r.is_fork, r.is_template, r.is_archived, r.is_disabled, r.last_crawled_at,
+			r.id, r.node_id, r.name, r.full_name, r.description, r.html_url, r.homepage, r.default_branch, r.license_key, r.readme_url, r.readme_path, r.readme_format, r.created_at, r.is_fork, r.is_template, r.is_archived, r.is_disabled, r.last_crawled_at,
 			o.id, o.login, o.node_id, o.avatar_url, o.html_url, o.type,
 			l.name, l.spdx_id, l.url, l.node_id,
 			COALESCE(tags.data, '[]'::jsonb) AS tags,
@@ -755,7 +1000,7 @@ func (pc *PostgresConnection) getRepositoriesDataByIDsFromDB(repoIDs []int64) ([
 		var tagsJSON, topicsJSON, languagesJSON []byte
 
 		err := rows.Scan(
-			&repoData.Repository.ID, &repoNodeID, &repoData.Repository.Name, &repoData.Repository.FullName, &repoData.Repository.Description, &repoData.Repository.HTMLURL, &repoData.Repository.Homepage, &repoData.Repository.DefaultBranch, &licenseKey, &repoData.Repository.ReadmeURL, &repoData.Repository.CreatedAt, &repoData.Repository.Fork, &repoData.Repository.IsTemplate, &repoData.Repository.Archived, &repoData.Repository.Disabled, &repoData.Repository.LastCrawledAt,
+			&repoData.Repository.ID, &repoNodeID, &repoData.Repository.Name, &repoData.Repository.FullName, &repoData.Repository.Description, &repoData.Repository.HTMLURL, &repoData.Repository.Homepage, &repoData.Repository.DefaultBranch, &licenseKey, &repoData.Repository.ReadmeURL, &repoData.Repository.ReadmePath, &repoData.Repository.ReadmeFormat, &repoData.Repository.CreatedAt, &repoData.Repository.Fork, &repoData.Repository.IsTemplate, &repoData.Repository.Archived, &repoData.Repository.Disabled, &repoData.Repository.LastCrawledAt,
 			&ownerID, &repoData.Owner.Login, &ownerNodeID, &repoData.Owner.AvatarURL, &repoData.Owner.HTMLURL, &repoData.Owner.Type,
 			&licenseName, &repoData.Repository.License.SpdxID, &repoData.Repository.License.URL, &repoData.Repository.License.NodeID,
 			&tagsJSON, &topicsJSON, &languagesJSON,
@@ -794,19 +1039,1433 @@ func (pc *PostgresConnection) getRepositoriesDataByIDsFromDB(repoIDs []int64) ([
 	return repositoriesData, nil
 }
 
-// IsRepositoryPosted checks if a repository has already been posted.
-func (pc *PostgresConnection) IsRepositoryPosted(repoID int64) (bool, error) {
+// IsRepositoryPostedOn checks if a repository has already been posted to a social
+// network.
+func (pc *PostgresConnection) IsRepositoryPostedOn(repoID int64, network string) (bool, error) {
 	var exists bool
-	err := pc.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM posted_repositories WHERE repository_id = $1)", repoID).Scan(&exists)
+	err := pc.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM social_posts WHERE repository_id = $1 AND network = $2)", repoID, network).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
 	return exists, nil
 }
 
-// MarkRepositoryAsPosted marks a repository as posted.
-func (pc *PostgresConnection) MarkRepositoryAsPosted(repoID int64) error {
-	_, err := pc.DB.Exec("INSERT INTO posted_repositories (repository_id) VALUES ($1) ON CONFLICT (repository_id) DO NOTHING", repoID)
+// MarkRepositoryPostedOn records that a repository was posted to a social network, at
+// postURL.
+func (pc *PostgresConnection) MarkRepositoryPostedOn(repoID int64, network, postURL string) error {
+	_, err := pc.DB.Exec(`
+		INSERT INTO social_posts (repository_id, network, post_url) VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (repository_id, network) DO NOTHING`, repoID, network, postURL)
+	return err
+}
+
+// GetEmbeddedReadme retrieves the content hash of the README last embedded for a
+// repository and the embedding version it was embedded at. It returns an empty hash and
+// version 0 if no README has been embedded yet.
+func (pc *PostgresConnection) GetEmbeddedReadme(repoID int64) (string, int, error) {
+	var hash sql.NullString
+	var version sql.NullInt64
+	err := pc.DB.QueryRow(`
+		SELECT r.readme_hash, e.version
+		FROM repositories r
+		LEFT JOIN repository_embeddings e ON e.repository_id = r.id
+		WHERE r.id = $1
+	`, repoID).Scan(&hash, &version)
+	if err != nil && err != sql.ErrNoRows {
+		return "", 0, err
+	}
+	return hash.String, int(version.Int64), nil
+}
+
+// UpdateReadmeHash records the content hash of the README that was just embedded.
+func (pc *PostgresConnection) UpdateReadmeHash(repoID int64, hash string) error {
+	_, err := pc.DB.Exec("UPDATE repositories SET readme_hash = $2 WHERE id = $1", repoID, hash)
+	return err
+}
+
+// Embedding statuses stored in repository_embeddings.status.
+const (
+	EmbeddingStatusEmbedded = "embedded"
+	EmbeddingStatusFailed   = "failed"
+	// EmbeddingStatusEmpty marks READMEs that contain no text to embed.
+	EmbeddingStatusEmpty = "empty"
+)
+
+// EmbeddingCoverage counts repositories by the outcome of their last embedding attempt.
+// Missing repositories have never been attempted.
+type EmbeddingCoverage struct {
+	Total    int `json:"total"`
+	Embedded int `json:"embedded"`
+	Failed   int `json:"failed"`
+	Empty    int `json:"empty"`
+	Missing  int `json:"missing"`
+}
+
+// RecordEmbeddingSuccess records that a repository's README was embedded with the given
+// model and pipeline version.
+func (pc *PostgresConnection) RecordEmbeddingSuccess(repoID int64, model string, version, chunkCount int) error {
+	_, err := pc.DB.Exec(`
+		INSERT INTO repository_embeddings (repository_id, status, model, version, chunk_count, attempts, last_error, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 0, NULL, NOW())
+		ON CONFLICT (repository_id) DO UPDATE SET
+			status = EXCLUDED.status,
+			model = EXCLUDED.model,
+			version = EXCLUDED.version,
+			chunk_count = EXCLUDED.chunk_count,
+			attempts = 0,
+			last_error = NULL,
+			updated_at = NOW()
+	`, repoID, EmbeddingStatusEmbedded, model, version, chunkCount)
+	return err
+}
+
+// RecordEmbeddingFailure records a failed embedding attempt, or with EmbeddingStatusEmpty
+// a README that had nothing to embed. The model and version of an earlier successful
+// embedding are kept.
+func (pc *PostgresConnection) RecordEmbeddingFailure(repoID int64, status, reason string) error {
+	_, err := pc.DB.Exec(`
+		INSERT INTO repository_embeddings (repository_id, status, attempts, last_error, updated_at)
+		VALUES ($1, $2, 1, $3, NOW())
+		ON CONFLICT (repository_id) DO UPDATE SET
+			status = EXCLUDED.status,
+			attempts = repository_embeddings.attempts + 1,
+			last_error = EXCLUDED.last_error,
+			updated_at = NOW()
+	`, repoID, status, reason)
 	return err
 }
 
+// GetEmbeddingCoverage counts repositories by embedding status.
+func (pc *PostgresConnection) GetEmbeddingCoverage() (EmbeddingCoverage, error) {
+	var coverage EmbeddingCoverage
+	err := pc.DB.QueryRow(`
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE e.status = $1),
+			COUNT(*) FILTER (WHERE e.status = $2),
+			COUNT(*) FILTER (WHERE e.status = $3),
+			COUNT(*) FILTER (WHERE e.repository_id IS NULL)
+		FROM repositories r
+		LEFT JOIN repository_embeddings e ON e.repository_id = r.id
+	`, EmbeddingStatusEmbedded, EmbeddingStatusFailed, EmbeddingStatusEmpty).Scan(
+		&coverage.Total, &coverage.Embedded, &coverage.Failed, &coverage.Empty, &coverage.Missing,
+	)
+	return coverage, err
+}
+
+// GetEmbeddingVersionCoverage counts the repositories embedded at the given version and
+// all repositories with an embedded or failed README, whatever their version.
+func (pc *PostgresConnection) GetEmbeddingVersionCoverage(version int) (embedded, total int, err error) {
+	err = pc.DB.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE status = $1 AND version = $3),
+			COUNT(*) FILTER (WHERE status IN ($1, $2))
+		FROM repository_embeddings
+	`, EmbeddingStatusEmbedded, EmbeddingStatusFailed, version).Scan(&embedded, &total)
+	return embedded, total, err
+}
+
+// ReembedCandidate is a repository whose README has to be embedded at a new version.
+type ReembedCandidate struct {
+	RepositoryID int64
+	ReadmeFormat string
+}
+
+// GetReembedCandidates returns up to limit repositories with an ID greater than afterID
+// that have a README which was never embedded or was embedded at a version other than
+// the given one, ordered by ID.
+func (pc *PostgresConnection) GetReembedCandidates(version int, afterID int64, limit int) ([]ReembedCandidate, error) {
+	rows, err := pc.DB.Query(`
+		SELECT r.id, COALESCE(r.readme_format, '')
+		FROM repositories r
+		LEFT JOIN repository_embeddings e ON e.repository_id = r.id
+		WHERE r.id > $1
+			AND ((e.repository_id IS NULL AND r.readme_path IS NOT NULL) OR (e.status = $2 AND e.version IS DISTINCT FROM $3))
+		ORDER BY r.id
+		LIMIT $4
+	`, afterID, EmbeddingStatusEmbedded, version, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []ReembedCandidate
+	for rows.Next() {
+		var candidate ReembedCandidate
+		if err := rows.Scan(&candidate.RepositoryID, &candidate.ReadmeFormat); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// DependencyCount is the number of repositories that declare a given dependency.
+type DependencyCount struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Count     int    `json:"count"`
+}
+
+// GetDependentRepositoryIDs retrieves the IDs of repositories that declare a dependency on the given package.
+func (pc *PostgresConnection) GetDependentRepositoryIDs(ecosystem, name string) ([]int64, error) {
+	rows, err := pc.DB.Query(`
+		SELECT rd.repository_id
+		FROM repository_dependencies rd
+		JOIN dependencies d ON rd.dependency_id = d.id
+		WHERE d.ecosystem = $1 AND d.name = $2
+	`, ecosystem, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// GetCommonDependencies retrieves the runtime dependencies most frequently declared by the given repositories.
+// An empty ecosystem matches every ecosystem.
+func (pc *PostgresConnection) GetCommonDependencies(repoIDs []int64, ecosystem string, limit int) ([]DependencyCount, error) {
+	if len(repoIDs) == 0 {
+		return []DependencyCount{}, nil
+	}
+
+	rows, err := pc.DB.Query(`
+		SELECT d.ecosystem, d.name, COUNT(DISTINCT rd.repository_id) AS cnt
+		FROM repository_dependencies rd
+		JOIN dependencies d ON rd.dependency_id = d.id
+		WHERE rd.repository_id = ANY($1) AND NOT rd.is_dev AND ($2 = '' OR d.ecosystem = $2)
+		GROUP BY d.ecosystem, d.name
+		ORDER BY cnt DESC, d.name
+		LIMIT $3
+	`, pq.Array(repoIDs), ecosystem, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []DependencyCount{}
+	for rows.Next() {
+		var dc DependencyCount
+		if err := rows.Scan(&dc.Ecosystem, &dc.Name, &dc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, dc)
+	}
+
+	return counts, nil
+}
+
+// GetTopicFrequencies retrieves the number of repositories tagged with each topic and
+// the total number of repositories.
+func (pc *PostgresConnection) GetTopicFrequencies() (map[string]int, int, error) {
+	var total int
+	if err := pc.DB.QueryRow("SELECT COUNT(*) FROM repositories").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := pc.DB.Query(`
+		SELECT t.name, COUNT(*)
+		FROM repository_topics rt
+		JOIN topics t ON rt.topic_id = t.id
+		GROUP BY t.name
+	`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	frequencies := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, 0, err
+		}
+		frequencies[name] = count
+	}
+
+	return frequencies, total, rows.Err()
+}
+
+// GetRuntimeDependencies retrieves the runtime dependencies of the given repositories,
+// each formatted as "ecosystem:name".
+func (pc *PostgresConnection) GetRuntimeDependencies(repoIDs []int64) (map[int64][]string, error) {
+	dependencies := make(map[int64][]string)
+	if len(repoIDs) == 0 {
+		return dependencies, nil
+	}
+
+	rows, err := pc.DB.Query(`
+		SELECT rd.repository_id, d.ecosystem || ':' || d.name
+		FROM repository_dependencies rd
+		JOIN dependencies d ON rd.dependency_id = d.id
+		WHERE rd.repository_id = ANY($1) AND NOT rd.is_dev
+	`, pq.Array(repoIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var dependency string
+		if err := rows.Scan(&id, &dependency); err != nil {
+			return nil, err
+		}
+		dependencies[id] = append(dependencies[id], dependency)
+	}
+
+	return dependencies, rows.Err()
+}
+
+// GetCoViewCounts retrieves, for each of candidateIDs, the number of sessions that
+// viewed both it and repoID, and the number of sessions that viewed each of repoID and
+// candidateIDs.
+func (pc *PostgresConnection) GetCoViewCounts(repoID int64, candidateIDs []int64) (coViews map[int64]int, sessions map[int64]int, err error) {
+	coViews = make(map[int64]int)
+	sessions = make(map[int64]int)
+	if len(candidateIDs) == 0 {
+		return coViews, sessions, nil
+	}
+
+	rows, err := pc.DB.Query(`
+		SELECT b.repository_id, COUNT(DISTINCT a.session_id)
+		FROM repository_views a
+		JOIN repository_views b ON a.session_id = b.session_id
+		WHERE a.repository_id = $1 AND b.repository_id = ANY($2)
+		GROUP BY b.repository_id
+	`, repoID, pq.Array(candidateIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, nil, err
+		}
+		coViews[id] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = pc.DB.Query(`
+		SELECT repository_id, COUNT(DISTINCT session_id)
+		FROM repository_views
+		WHERE repository_id = ANY($1)
+		GROUP BY repository_id
+	`, pq.Array(append([]int64{repoID}, candidateIDs...)))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, nil, err
+		}
+		sessions[id] = count
+	}
+
+	return coViews, sessions, rows.Err()
+}
+
+// ErrCollectionExists is returned when a session already has a collection of that name.
+var ErrCollectionExists = errors.New("collection already exists")
+
+// ErrCollectionFull is returned when adding a repository to a collection that already
+// holds the maximum number of repositories.
+var ErrCollectionFull = errors.New("collection is full")
+
+// ErrInvalidOrder is returned when a new order of a collection's items is not a
+// permutation of its items.
+var ErrInvalidOrder = errors.New("order must list every item of the collection exactly once")
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+const collectionColumns = `
+	c.id, c.name, COALESCE(c.description, ''), COALESCE(c.share_token, ''), c.created_at, c.updated_at,
+	(SELECT COUNT(*) FROM collection_items i WHERE i.collection_id = c.id)
+`
+
+func scanCollection(scanner interface{ Scan(dest ...any) error }) (models.Collection, error) {
+	var c models.Collection
+	err := scanner.Scan(&c.ID, &c.Name, &c.Description, &c.ShareToken, &c.CreatedAt, &c.UpdatedAt, &c.ItemCount)
+	return c, err
+}
+
+// CreateCollection creates an empty collection owned by a session. userID is 0 for
+// anonymous sessions.
+func (pc *PostgresConnection) CreateCollection(sessionID string, userID int64, name, description string) (models.Collection, error) {
+	var id int64
+	err := pc.DB.QueryRow(`
+		INSERT INTO collections (session_id, user_id, name, description)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id
+	`, sessionID, sql.NullInt64{Int64: userID, Valid: userID > 0}, name, description).Scan(&id)
+	if isUniqueViolation(err) {
+		return models.Collection{}, ErrCollectionExists
+	}
+	if err != nil {
+		return models.Collection{}, err
+	}
+	return pc.GetCollection(id, sessionID)
+}
+
+// GetCollections retrieves the collections of a session, most recently updated first.
+func (pc *PostgresConnection) GetCollections(sessionID string) ([]models.Collection, error) {
+	rows, err := pc.DB.Query("SELECT "+collectionColumns+" FROM collections c WHERE c.session_id = $1 ORDER BY c.updated_at DESC", sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []models.Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+// GetCollection retrieves a collection of a session. It returns sql.ErrNoRows if the
+// collection does not exist or belongs to another session.
+func (pc *PostgresConnection) GetCollection(collectionID int64, sessionID string) (models.Collection, error) {
+	return scanCollection(pc.DB.QueryRow("SELECT "+collectionColumns+" FROM collections c WHERE c.id = $1 AND c.session_id = $2", collectionID, sessionID))
+}
+
+// GetSharedCollection retrieves the collection with the given share token. It returns
+// sql.ErrNoRows if no collection is shared with that token.
+func (pc *PostgresConnection) GetSharedCollection(shareToken string) (models.Collection, error) {
+	return scanCollection(pc.DB.QueryRow("SELECT "+collectionColumns+" FROM collections c WHERE c.share_token = $1", shareToken))
+}
+
+// UpdateCollection renames a collection of a session and replaces its description.
+func (pc *PostgresConnection) UpdateCollection(collectionID int64, sessionID, name, description string) error {
+	result, err := pc.DB.Exec(`
+		UPDATE collections
+		SET name = $3, description = NULLIF($4, ''), updated_at = NOW()
+		WHERE id = $1 AND session_id = $2
+	`, collectionID, sessionID, name, description)
+	if isUniqueViolation(err) {
+		return ErrCollectionExists
+	}
+	return expectRow(result, err)
+}
+
+// DeleteCollection deletes a collection of a session and its items.
+func (pc *PostgresConnection) DeleteCollection(collectionID int64, sessionID string) error {
+	return expectRow(pc.DB.Exec("DELETE FROM collections WHERE id = $1 AND session_id = $2", collectionID, sessionID))
+}
+
+// SetCollectionShareToken shares a collection of a session under shareToken, or stops
+// sharing it when shareToken is empty.
+func (pc *PostgresConnection) SetCollectionShareToken(collectionID int64, sessionID, shareToken string) error {
+	return expectRow(pc.DB.Exec(`
+		UPDATE collections SET share_token = NULLIF($3, ''), updated_at = NOW()
+		WHERE id = $1 AND session_id = $2
+	`, collectionID, sessionID, shareToken))
+}
+
+// expectRow turns an update or delete that matched no row into sql.ErrNoRows.
+func expectRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetCollectionItems retrieves the items of a collection in their order.
+func (pc *PostgresConnection) GetCollectionItems(collectionID int64) ([]models.CollectionItem, error) {
+	rows, err := pc.DB.Query(`
+		SELECT repository_id, position, COALESCE(note, ''), added_at
+		FROM collection_items
+		WHERE collection_id = $1
+		ORDER BY position, added_at
+	`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.CollectionItem{}
+	for rows.Next() {
+		var item models.CollectionItem
+		if err := rows.Scan(&item.RepositoryID, &item.Position, &item.Note, &item.AddedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// AddCollectionItem adds a repository at the end of a collection holding fewer than
+// maxItems repositories, otherwise it returns ErrCollectionFull. Adding a repository
+// that is already in the collection only replaces its note, if one is given.
+func (pc *PostgresConnection) AddCollectionItem(collectionID, repositoryID int64, note string, maxItems int) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	// Lock the collection so that concurrent additions see each other's items when
+	// checking the size and picking the position.
+	if _, err := tx.Exec("SELECT id FROM collections WHERE id = $1 FOR UPDATE", collectionID); err != nil {
+		return err
+	}
+
+	var count, lastPosition int
+	var exists bool
+	if err := tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(MAX(position), 0), COALESCE(BOOL_OR(repository_id = $2), FALSE)
+		FROM collection_items
+		WHERE collection_id = $1
+	`, collectionID, repositoryID).Scan(&count, &lastPosition, &exists); err != nil {
+		return err
+	}
+
+	if exists {
+		if _, err := tx.Exec(`
+			UPDATE collection_items SET note = COALESCE(NULLIF($3, ''), note)
+			WHERE collection_id = $1 AND repository_id = $2
+		`, collectionID, repositoryID, note); err != nil {
+			return err
+		}
+	} else {
+		if count >= maxItems {
+			return ErrCollectionFull
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO collection_items (collection_id, repository_id, position, note)
+			VALUES ($1, $2, $3, NULLIF($4, ''))
+		`, collectionID, repositoryID, lastPosition+1, note); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE collections SET updated_at = NOW() WHERE id = $1", collectionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateCollectionItemNote replaces the note of a collection item.
+func (pc *PostgresConnection) UpdateCollectionItemNote(collectionID, repositoryID int64, note string) error {
+	if err := expectRow(pc.DB.Exec(`
+		UPDATE collection_items SET note = NULLIF($3, '')
+		WHERE collection_id = $1 AND repository_id = $2
+	`, collectionID, repositoryID, note)); err != nil {
+		return err
+	}
+	return pc.touchCollection(collectionID)
+}
+
+// RemoveCollectionItem removes a repository from a collection.
+func (pc *PostgresConnection) RemoveCollectionItem(collectionID, repositoryID int64) error {
+	if err := expectRow(pc.DB.Exec("DELETE FROM collection_items WHERE collection_id = $1 AND repository_id = $2", collectionID, repositoryID)); err != nil {
+		return err
+	}
+	return pc.touchCollection(collectionID)
+}
+
+// ReorderCollectionItems sets the order of a collection's items. repositoryIDs must
+// list every item of the collection exactly once, otherwise ErrInvalidOrder is returned.
+func (pc *PostgresConnection) ReorderCollectionItems(collectionID int64, repositoryIDs []int64) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	// Lock the collection, as AddCollectionItem does, so that concurrent additions wait
+	// for the new order.
+	if _, err := tx.Exec("SELECT id FROM collections WHERE id = $1 FOR UPDATE", collectionID); err != nil {
+		return err
+	}
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM collection_items WHERE collection_id = $1", collectionID).Scan(&count); err != nil {
+		return err
+	}
+	if count != len(repositoryIDs) {
+		return ErrInvalidOrder
+	}
+
+	result, err := tx.Exec(`
+		UPDATE collection_items i
+		SET position = o.position
+		FROM unnest($2::bigint[]) WITH ORDINALITY AS o(repository_id, position)
+		WHERE i.collection_id = $1 AND i.repository_id = o.repository_id
+	`, collectionID, pq.Array(repositoryIDs))
+	if err != nil {
+		return err
+	}
+	// Duplicated or unknown IDs leave some items without a position.
+	if n, err := result.RowsAffected(); err != nil || int(n) != count {
+		if err != nil {
+			return err
+		}
+		return ErrInvalidOrder
+	}
+	if _, err := tx.Exec("UPDATE collections SET updated_at = NOW() WHERE id = $1", collectionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (pc *PostgresConnection) touchCollection(collectionID int64) error {
+	_, err := pc.DB.Exec("UPDATE collections SET updated_at = NOW() WHERE id = $1", collectionID)
+	return err
+}
+
+// GetSavedRepositoryIDs retrieves the repositories a session saved most recently in any
+// of its collections.
+func (pc *PostgresConnection) GetSavedRepositoryIDs(sessionID string, limit int) ([]int64, error) {
+	rows, err := pc.DB.Query(`
+		SELECT i.repository_id
+		FROM collection_items i
+		JOIN collections c ON c.id = i.collection_id
+		WHERE c.session_id = $1
+		GROUP BY i.repository_id
+		ORDER BY MAX(i.added_at) DESC
+		LIMIT $2
+	`, sessionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MergeSessionCollections moves the collections of an anonymous session to a user's
+// session. Items of a collection whose name the user already uses are appended to the
+// user's collection.
+func (pc *PostgresConnection) MergeSessionCollections(fromSessionID, toSessionID string, userID int64) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	_, err = tx.Exec(`
+		INSERT INTO collection_items (collection_id, repository_id, position, note, added_at)
+		SELECT dst.id, i.repository_id,
+			(SELECT COALESCE(MAX(position), 0) FROM collection_items WHERE collection_id = dst.id) + i.position,
+			i.note, i.added_at
+		FROM collections src
+		JOIN collections dst ON dst.session_id = $2 AND dst.name = src.name
+		JOIN collection_items i ON i.collection_id = src.id
+		WHERE src.session_id = $1
+		ON CONFLICT (collection_id, repository_id) DO NOTHING
+	`, fromSessionID, toSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to merge collection items: %w", err)
+	}
+	_, err = tx.Exec(`
+		DELETE FROM collections src
+		USING collections dst
+		WHERE src.session_id = $1 AND dst.session_id = $2 AND dst.name = src.name
+	`, fromSessionID, toSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete merged collections: %w", err)
+	}
+	_, err = tx.Exec("UPDATE collections SET session_id = $2, user_id = $3 WHERE session_id = $1", fromSessionID, toSessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to move collections: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ReplaceStarredRepositories replaces the GitHub stars of a user and records the time
+// of the import.
+func (pc *PostgresConnection) ReplaceStarredRepositories(userID int64, stars []models.StarredRepository) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	if _, err := tx.Exec("DELETE FROM user_starred_repositories WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to clear stars: %w", err)
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO user_starred_repositories (user_id, repository_id, starred_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, repository_id) DO NOTHING
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, star := range stars {
+		if _, err := stmt.Exec(userID, star.RepositoryID, star.StarredAt); err != nil {
+			return fmt.Errorf("failed to insert star of repository %d: %w", star.RepositoryID, err)
+		}
+	}
+	if _, err := tx.Exec("UPDATE users SET stars_imported_at = NOW() WHERE id = $1", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetStarredRepositoryIDs retrieves the repositories a user starred most recently on
+// GitHub, among those that have been crawled.
+func (pc *PostgresConnection) GetStarredRepositoryIDs(userID int64, limit int) ([]int64, error) {
+	rows, err := pc.DB.Query(`
+		SELECT s.repository_id
+		FROM user_starred_repositories s
+		JOIN repositories r ON r.id = s.repository_id
+		WHERE s.user_id = $1
+		ORDER BY s.starred_at DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetMissingRepositoryIDs returns the repositories among repoIDs that are not in the
+// database yet.
+func (pc *PostgresConnection) GetMissingRepositoryIDs(repoIDs []int64) ([]int64, error) {
+	if len(repoIDs) == 0 {
+		return nil, nil
+	}
+	rows, err := pc.DB.Query(`
+		SELECT ids.id
+		FROM unnest($1::bigint[]) AS ids(id)
+		WHERE NOT EXISTS (SELECT 1 FROM repositories r WHERE r.id = ids.id)
+	`, pq.Array(repoIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ErrWatchExists is returned when a session already watches that target.
+var ErrWatchExists = errors.New("watch already exists")
+
+const watchColumns = "id, session_id, kind, target, COALESCE(email, ''), email_confirmed_at IS NOT NULL, COALESCE(email_token, ''), COALESCE(webhook_url, ''), created_at"
+
+func scanWatch(scanner interface{ Scan(dest ...any) error }) (models.Watch, error) {
+	var w models.Watch
+	err := scanner.Scan(&w.ID, &w.SessionID, &w.Kind, &w.Target, &w.Email, &w.EmailConfirmed, &w.EmailToken, &w.WebhookURL, &w.CreatedAt)
+	return w, err
+}
+
+// CreateWatch creates a watch owned by a session. userID is 0 for anonymous sessions.
+// Its email address, if any, is unconfirmed.
+func (pc *PostgresConnection) CreateWatch(sessionID string, userID int64, watch models.Watch) (models.Watch, error) {
+	created, err := scanWatch(pc.DB.QueryRow(`
+		INSERT INTO watches (session_id, user_id, kind, target, email, email_token, webhook_url)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''))
+		RETURNING `+watchColumns,
+		sessionID, sql.NullInt64{Int64: userID, Valid: userID > 0}, watch.Kind, watch.Target, watch.Email, watch.EmailToken, watch.WebhookURL))
+	if isUniqueViolation(err) {
+		return models.Watch{}, ErrWatchExists
+	}
+	return created, err
+}
+
+// GetWatches retrieves the watches of a session, most recent first.
+func (pc *PostgresConnection) GetWatches(sessionID string) ([]models.Watch, error) {
+	rows, err := pc.DB.Query("SELECT "+watchColumns+" FROM watches WHERE session_id = $1 ORDER BY created_at DESC, id DESC", sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	watches := []models.Watch{}
+	for rows.Next() {
+		w, err := scanWatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+	return watches, rows.Err()
+}
+
+// DeleteWatch deletes a watch of a session and its notifications.
+func (pc *PostgresConnection) DeleteWatch(watchID int64, sessionID string) error {
+	return expectRow(pc.DB.Exec("DELETE FROM watches WHERE id = $1 AND session_id = $2", watchID, sessionID))
+}
+
+// ConfirmWatchEmail confirms the email address of the watch with the given token and
+// returns the watch. It returns sql.ErrNoRows if no watch has the token.
+func (pc *PostgresConnection) ConfirmWatchEmail(token string) (models.Watch, error) {
+	return scanWatch(pc.DB.QueryRow(`
+		UPDATE watches SET email_confirmed_at = COALESCE(email_confirmed_at, NOW())
+		WHERE email_token = $1 AND email IS NOT NULL
+		RETURNING `+watchColumns, token))
+}
+
+// UnsubscribeWatchEmail removes the email address of the watch with the given token,
+// which keeps notifying in the app, and returns the address. It returns sql.ErrNoRows
+// if no watch has the token.
+func (pc *PostgresConnection) UnsubscribeWatchEmail(token string) (string, error) {
+	var email string
+	err := pc.DB.QueryRow(`
+		UPDATE watches w SET email = NULL, email_token = NULL, email_confirmed_at = NULL
+		FROM watches old
+		WHERE w.id = old.id AND w.email_token = $1
+		RETURNING COALESCE(old.email, '')
+	`, token).Scan(&email)
+	return email, err
+}
+
+// GetMatchingWatches retrieves the watches on a repository: those on its full name,
+// its owner or one of its topics. Targets are stored lowercase.
+func (pc *PostgresConnection) GetMatchingWatches(fullName, ownerLogin string, topics []string) ([]models.Watch, error) {
+	lowerTopics := make([]string, len(topics))
+	for i, topic := range topics {
+		lowerTopics[i] = strings.ToLower(topic)
+	}
+	rows, err := pc.DB.Query(`
+		SELECT `+watchColumns+` FROM watches
+		WHERE (kind = 'repository' AND target = $1)
+		   OR (kind = 'owner' AND target = $2)
+		   OR (kind = 'topic' AND target = ANY($3))
+	`, strings.ToLower(fullName), strings.ToLower(ownerLogin), pq.Array(lowerTopics))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var watches []models.Watch
+	for rows.Next() {
+		w, err := scanWatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+	return watches, rows.Err()
+}
+
+// InsertNotification stores a notification of a watch, setting its ID and creation
+// time. It returns false, without error, if the watch already raised the event or no
+// longer exists.
+func (pc *PostgresConnection) InsertNotification(n *models.Notification) (bool, error) {
+	err := pc.DB.QueryRow(`
+		INSERT INTO notifications (watch_id, session_id, repository_id, kind, event_key, title, body, url)
+		SELECT w.id, w.session_id, $2, $3, $4, $5, $6, NULLIF($7, '')
+		FROM watches w WHERE w.id = $1
+		ON CONFLICT (watch_id, repository_id, event_key) DO NOTHING
+		RETURNING id, created_at
+	`, n.WatchID, n.RepositoryID, n.Kind, n.EventKey, n.Title, n.Body, n.URL).Scan(&n.ID, &n.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetNotifications retrieves up to limit notifications of a session, most recent
+// first, optionally only the unread ones.
+func (pc *PostgresConnection) GetNotifications(sessionID string, unreadOnly bool, limit int) ([]models.Notification, error) {
+	rows, err := pc.DB.Query(`
+		SELECT id, COALESCE(watch_id, 0), repository_id, kind, event_key, title, body, COALESCE(url, ''), created_at, read_at
+		FROM notifications
+		WHERE session_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`, sessionID, unreadOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.WatchID, &n.RepositoryID, &n.Kind, &n.EventKey, &n.Title, &n.Body, &n.URL, &n.CreatedAt, &readAt); err != nil {
+			return nil, err
+		}
+		if readAt.Valid {
+			n.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkNotificationsRead marks the given notifications of a session as read, or all of
+// them when notificationIDs is empty. It returns how many were marked.
+func (pc *PostgresConnection) MarkNotificationsRead(sessionID string, notificationIDs []int64) (int64, error) {
+	result, err := pc.DB.Exec(`
+		UPDATE notifications SET read_at = NOW()
+		WHERE session_id = $1 AND read_at IS NULL AND (cardinality($2::bigint[]) = 0 OR id = ANY($2))
+	`, sessionID, pq.Array(notificationIDs))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MergeSessionWatches moves the watches and notifications of an anonymous session to a
+// user's session. Watches the user already has are dropped with their notifications.
+func (pc *PostgresConnection) MergeSessionWatches(fromSessionID, toSessionID string, userID int64) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	_, err = tx.Exec(`
+		DELETE FROM watches src
+		USING watches dst
+		WHERE src.session_id = $1 AND dst.session_id = $2 AND dst.kind = src.kind AND dst.target = src.target
+	`, fromSessionID, toSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete merged watches: %w", err)
+	}
+	if _, err = tx.Exec("UPDATE watches SET session_id = $2, user_id = $3 WHERE session_id = $1", fromSessionID, toSessionID, userID); err != nil {
+		return fmt.Errorf("failed to move watches: %w", err)
+	}
+	if _, err = tx.Exec("UPDATE notifications SET session_id = $2 WHERE session_id = $1", fromSessionID, toSessionID); err != nil {
+		return fmt.Errorf("failed to move notifications: %w", err)
+	}
+	return tx.Commit()
+}
+
+const webhookColumns = "id, url, COALESCE(description, ''), secret, event_types, languages, topics, active, created_at, updated_at"
+
+func scanWebhook(scanner interface{ Scan(dest ...any) error }) (models.Webhook, error) {
+	var h models.Webhook
+	err := scanner.Scan(&h.ID, &h.URL, &h.Description, &h.Secret, pq.Array(&h.EventTypes), pq.Array(&h.Languages), pq.Array(&h.Topics), &h.Active, &h.CreatedAt, &h.UpdatedAt)
+	return h, err
+}
+
+// CreateWebhook registers a webhook.
+func (pc *PostgresConnection) CreateWebhook(h models.Webhook) (models.Webhook, error) {
+	return scanWebhook(pc.DB.QueryRow(`
+		INSERT INTO webhooks (url, description, secret, event_types, languages, topics, active)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7)
+		RETURNING `+webhookColumns,
+		h.URL, h.Description, h.Secret, pq.Array(h.EventTypes), pq.Array(h.Languages), pq.Array(h.Topics), h.Active))
+}
+
+// GetWebhooks retrieves the registered webhooks, oldest first. With activeOnly, only
+// the active ones are returned.
+func (pc *PostgresConnection) GetWebhooks(activeOnly bool) ([]models.Webhook, error) {
+	rows, err := pc.DB.Query("SELECT "+webhookColumns+" FROM webhooks WHERE active OR NOT $1 ORDER BY id", activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		h, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, h)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhook retrieves a webhook. It returns sql.ErrNoRows if it does not exist.
+func (pc *PostgresConnection) GetWebhook(webhookID int64) (models.Webhook, error) {
+	return scanWebhook(pc.DB.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", webhookID))
+}
+
+// UpdateWebhook replaces the URL, description, filters and state of a webhook.
+func (pc *PostgresConnection) UpdateWebhook(h models.Webhook) error {
+	return expectRow(pc.DB.Exec(`
+		UPDATE webhooks
+		SET url = $2, description = NULLIF($3, ''), event_types = $4, languages = $5, topics = $6, active = $7, updated_at = NOW()
+		WHERE id = $1
+	`, h.ID, h.URL, h.Description, pq.Array(h.EventTypes), pq.Array(h.Languages), pq.Array(h.Topics), h.Active))
+}
+
+// DeleteWebhook deletes a webhook and its deliveries.
+func (pc *PostgresConnection) DeleteWebhook(webhookID int64) error {
+	return expectRow(pc.DB.Exec("DELETE FROM webhooks WHERE id = $1", webhookID))
+}
+
+// InsertWebhookEvent stores an event, setting its ID, and returns false, without error,
+// if an event with the same key was already stored. The stored payload is the event
+// with its ID, which is what deliveries send.
+func (pc *PostgresConnection) InsertWebhookEvent(event *models.WebhookEvent) (bool, error) {
+	var id int64
+	if err := pc.DB.QueryRow("SELECT nextval('webhook_events_id_seq')").Scan(&id); err != nil {
+		return false, err
+	}
+	event.ID = id
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return false, err
+	}
+	result, err := pc.DB.Exec(`
+		INSERT INTO webhook_events (id, type, event_key, repository_id, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (event_key) DO NOTHING
+	`, id, event.Type, event.Key, event.Repository.ID, string(payload))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CreateWebhookDeliveries creates pending deliveries of an event to the given webhooks
+// and returns their IDs. Deliveries that already exist are left as they are.
+func (pc *PostgresConnection) CreateWebhookDeliveries(eventID int64, webhookIDs []int64) ([]int64, error) {
+	if len(webhookIDs) == 0 {
+		return nil, nil
+	}
+	rows, err := pc.DB.Query(`
+		INSERT INTO webhook_deliveries (webhook_id, event_id, status)
+		SELECT w.id, $1, 'pending' FROM unnest($2::bigint[]) AS w(id)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+		RETURNING id
+	`, eventID, pq.Array(webhookIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+const webhookDeliveryColumns = `
+	d.id, d.webhook_id, d.event_id, e.type, d.status, d.attempts, d.next_attempt_at,
+	COALESCE(d.last_error, ''), d.created_at, d.updated_at, d.delivered_at
+`
+
+func scanWebhookDelivery(scanner interface{ Scan(dest ...any) error }) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var nextAttemptAt, deliveredAt sql.NullTime
+	err := scanner.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &nextAttemptAt, &d.LastError, &d.CreatedAt, &d.UpdatedAt, &deliveredAt)
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return d, err
+}
+
+// GetWebhookDeliveryForSending retrieves a delivery with its webhook and the payload of
+// its event. It returns sql.ErrNoRows if the delivery does not exist.
+func (pc *PostgresConnection) GetWebhookDeliveryForSending(deliveryID int64) (models.WebhookDelivery, models.Webhook, []byte, error) {
+	delivery, err := scanWebhookDelivery(pc.DB.QueryRow("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries d JOIN webhook_events e ON e.id = d.event_id WHERE d.id = $1", deliveryID))
+	if err != nil {
+		return models.WebhookDelivery{}, models.Webhook{}, nil, err
+	}
+	webhook, err := pc.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		return models.WebhookDelivery{}, models.Webhook{}, nil, err
+	}
+	var payload []byte
+	if err := pc.DB.QueryRow("SELECT payload FROM webhook_events WHERE id = $1", delivery.EventID).Scan(&payload); err != nil {
+		return models.WebhookDelivery{}, models.Webhook{}, nil, err
+	}
+	return delivery, webhook, payload, nil
+}
+
+// RecordWebhookDeliveryAttempt logs an attempt at a delivery and moves the delivery to
+// the given status. nextAttemptAt is only kept for retrying deliveries.
+func (pc *PostgresConnection) RecordWebhookDeliveryAttempt(deliveryID int64, attempt models.WebhookDeliveryAttempt, status string, nextAttemptAt time.Time) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	_, err = tx.Exec(`
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempted_at, status_code, error, response, duration_ms)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, ''), NULLIF($5, ''), $6)
+	`, deliveryID, attempt.AttemptedAt, attempt.StatusCode, attempt.Error, attempt.Response, attempt.DurationMs)
+	if err != nil {
+		return fmt.Errorf("failed to log delivery attempt: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1,
+			next_attempt_at = CASE WHEN $2 = 'retrying' THEN $3::timestamptz END,
+			last_error = NULLIF($4, ''),
+			delivered_at = CASE WHEN $2 = 'succeeded' THEN $5::timestamptz ELSE delivered_at END,
+			updated_at = NOW()
+		WHERE id = $1
+	`, deliveryID, status, nextAttemptAt, attempt.Error, attempt.AttemptedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ClaimWebhookDelivery moves a pending delivery to sending and reports whether it was
+// pending, so that a delivery is only sent by the dispatcher that claimed it.
+func (pc *PostgresConnection) ClaimWebhookDelivery(deliveryID int64) (bool, error) {
+	result, err := pc.DB.Exec("UPDATE webhook_deliveries SET status = 'sending', updated_at = NOW() WHERE id = $1 AND status = 'pending'", deliveryID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// RetryWebhookDeliveryAt schedules a pending delivery that could not be queued.
+func (pc *PostgresConnection) RetryWebhookDeliveryAt(deliveryID int64, at time.Time) error {
+	_, err := pc.DB.Exec("UPDATE webhook_deliveries SET status = 'retrying', next_attempt_at = $2, updated_at = NOW() WHERE id = $1", deliveryID, at)
+	return err
+}
+
+// ClaimDueWebhookDeliveries moves up to limit retrying deliveries whose next attempt is
+// due, and deliveries sending since before sendingBefore, back to pending and returns
+// their IDs, to be queued again.
+func (pc *PostgresConnection) ClaimDueWebhookDeliveries(limit int, sendingBefore time.Time) ([]int64, error) {
+	rows, err := pc.DB.Query(`
+		UPDATE webhook_deliveries SET status = 'pending', next_attempt_at = NULL, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE (status = 'retrying' AND next_attempt_at <= NOW())
+				OR (status = 'sending' AND updated_at < $2)
+			ORDER BY COALESCE(next_attempt_at, updated_at)
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`, limit, sendingBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetWebhookDeliveries retrieves up to limit deliveries of a webhook, most recent
+// first, optionally only those with the given status.
+func (pc *PostgresConnection) GetWebhookDeliveries(webhookID int64, status string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := pc.DB.Query(`
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries d JOIN webhook_events e ON e.id = d.event_id
+		WHERE d.webhook_id = $1 AND ($2 = '' OR d.status = $2)
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $3
+	`, webhookID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetWebhookDelivery retrieves a delivery with the log of its attempts. It returns
+// sql.ErrNoRows if the delivery does not exist.
+func (pc *PostgresConnection) GetWebhookDelivery(deliveryID int64) (models.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(pc.DB.QueryRow("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries d JOIN webhook_events e ON e.id = d.event_id WHERE d.id = $1", deliveryID))
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	rows, err := pc.DB.Query(`
+		SELECT attempted_at, COALESCE(status_code, 0), COALESCE(error, ''), COALESCE(response, ''), duration_ms
+		FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY attempted_at, id
+	`, deliveryID)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	defer rows.Close()
+
+	delivery.Log = []models.WebhookDeliveryAttempt{}
+	for rows.Next() {
+		var a models.WebhookDeliveryAttempt
+		if err := rows.Scan(&a.AttemptedAt, &a.StatusCode, &a.Error, &a.Response, &a.DurationMs); err != nil {
+			return models.WebhookDelivery{}, err
+		}
+		delivery.Log = append(delivery.Log, a)
+	}
+	return delivery, rows.Err()
+}
+
+// ResetWebhookDelivery moves a delivery back to pending with a fresh retry budget, so
+// that it is sent again. The log of its earlier attempts is kept.
+func (pc *PostgresConnection) ResetWebhookDelivery(deliveryID int64) error {
+	return expectRow(pc.DB.Exec("UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = NULL, updated_at = NOW() WHERE id = $1", deliveryID))
+}
+
+// ReplaceTrendingRepositories stores the current trending list, in rank order.
+// Repositories that stay on the list keep their entry time. publish is called with
+// the previous list while it is locked, and the list is only replaced when publish
+// succeeds.
+func (pc *PostgresConnection) ReplaceTrendingRepositories(repoIDs []int64, publish func(previous []int64) error) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	rows, err := tx.Query("SELECT repository_id FROM trending_repositories ORDER BY rank FOR UPDATE")
+	if err != nil {
+		return err
+	}
+	var previous []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		previous = append(previous, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := publish(previous); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM trending_repositories WHERE repository_id <> ALL($1::bigint[])", pq.Array(repoIDs)); err != nil {
+		return fmt.Errorf("failed to remove repositories that left the trending list: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO trending_repositories (repository_id, rank)
+		SELECT id, rank FROM unnest($1::bigint[]) WITH ORDINALITY AS t(id, rank)
+		ON CONFLICT (repository_id) DO UPDATE SET rank = EXCLUDED.rank
+	`, pq.Array(repoIDs))
+	if err != nil {
+		return fmt.Errorf("failed to store the trending list: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetTrendingRepositoryEntries returns the repositories of the stored trending list
+// with the time they entered it.
+func (pc *PostgresConnection) GetTrendingRepositoryEntries() (map[int64]time.Time, error) {
+	rows, err := pc.DB.Query("SELECT repository_id, entered_at FROM trending_repositories")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make(map[int64]time.Time)
+	for rows.Next() {
+		var id int64
+		var enteredAt time.Time
+		if err := rows.Scan(&id, &enteredAt); err != nil {
+			return nil, err
+		}
+		entries[id] = enteredAt
+	}
+	return entries, rows.Err()
+}
+
+// ErrDigestSubscriptionExists is returned when a session already subscribed that email
+// address.
+var ErrDigestSubscriptionExists = errors.New("digest subscription already exists")
+
+const digestSubscriptionColumns = "id, session_id, email, frequency, languages, topics, unsubscribe_token, confirmed_at IS NOT NULL, last_sent_at, created_at"
+
+func scanDigestSubscription(scanner interface{ Scan(dest ...any) error }) (models.DigestSubscription, error) {
+	var s models.DigestSubscription
+	var lastSentAt sql.NullTime
+	err := scanner.Scan(&s.ID, &s.SessionID, &s.Email, &s.Frequency, pq.Array(&s.Languages), pq.Array(&s.Topics), &s.UnsubscribeToken, &s.Confirmed, &lastSentAt, &s.CreatedAt)
+	if lastSentAt.Valid {
+		s.LastSentAt = &lastSentAt.Time
+	}
+	return s, err
+}
+
+func queryDigestSubscriptions(db *sql.DB, query string, args ...any) ([]models.DigestSubscription, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []models.DigestSubscription{}
+	for rows.Next() {
+		s, err := scanDigestSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	return subscriptions, rows.Err()
+}
+
+// CreateDigestSubscription creates a digest subscription owned by a session. userID is
+// 0 for anonymous sessions.
+func (pc *PostgresConnection) CreateDigestSubscription(sessionID string, userID int64, s models.DigestSubscription) (models.DigestSubscription, error) {
+	created, err := scanDigestSubscription(pc.DB.QueryRow(`
+		INSERT INTO digest_subscriptions (session_id, user_id, email, frequency, languages, topics, unsubscribe_token)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+digestSubscriptionColumns,
+		sessionID, sql.NullInt64{Int64: userID, Valid: userID > 0}, s.Email, s.Frequency, pq.Array(s.Languages), pq.Array(s.Topics), s.UnsubscribeToken))
+	if isUniqueViolation(err) {
+		return models.DigestSubscription{}, ErrDigestSubscriptionExists
+	}
+	return created, err
+}
+
+// GetDigestSubscriptions retrieves the digest subscriptions of a session, oldest first.
+func (pc *PostgresConnection) GetDigestSubscriptions(sessionID string) ([]models.DigestSubscription, error) {
+	return queryDigestSubscriptions(pc.DB, "SELECT "+digestSubscriptionColumns+" FROM digest_subscriptions WHERE session_id = $1 ORDER BY id", sessionID)
+}
+
+// GetDigestSubscription retrieves a digest subscription of a session. It returns
+// sql.ErrNoRows if the session has no such subscription.
+func (pc *PostgresConnection) GetDigestSubscription(subscriptionID int64, sessionID string) (models.DigestSubscription, error) {
+	return scanDigestSubscription(pc.DB.QueryRow("SELECT "+digestSubscriptionColumns+" FROM digest_subscriptions WHERE id = $1 AND session_id = $2", subscriptionID, sessionID))
+}
+
+// UpdateDigestSubscription replaces the frequency and filters of a digest subscription
+// of a session.
+func (pc *PostgresConnection) UpdateDigestSubscription(s models.DigestSubscription) error {
+	return expectRow(pc.DB.Exec(`
+		UPDATE digest_subscriptions SET frequency = $3, languages = $4, topics = $5
+		WHERE id = $1 AND session_id = $2
+	`, s.ID, s.SessionID, s.Frequency, pq.Array(s.Languages), pq.Array(s.Topics)))
+}
+
+// DeleteDigestSubscription deletes a digest subscription of a session.
+func (pc *PostgresConnection) DeleteDigestSubscription(subscriptionID int64, sessionID string) error {
+	return expectRow(pc.DB.Exec("DELETE FROM digest_subscriptions WHERE id = $1 AND session_id = $2", subscriptionID, sessionID))
+}
+
+// ConfirmDigest confirms the digest subscription with the given unsubscribe token and
+// returns it. It returns sql.ErrNoRows if there is none.
+func (pc *PostgresConnection) ConfirmDigest(token string) (models.DigestSubscription, error) {
+	return scanDigestSubscription(pc.DB.QueryRow(`
+		UPDATE digest_subscriptions SET confirmed_at = COALESCE(confirmed_at, NOW())
+		WHERE unsubscribe_token = $1
+		RETURNING `+digestSubscriptionColumns, token))
+}
+
+// UnsubscribeDigest deletes the digest subscription with the given unsubscribe token
+// and returns its email address. It returns sql.ErrNoRows if there is none.
+func (pc *PostgresConnection) UnsubscribeDigest(token string) (string, error) {
+	var email string
+	err := pc.DB.QueryRow("DELETE FROM digest_subscriptions WHERE unsubscribe_token = $1 RETURNING email", token).Scan(&email)
+	return email, err
+}
+
+// GetDueDigestSubscriptions retrieves up to limit confirmed digest subscriptions never
+// sent or last sent before the cutoff of their frequency.
+func (pc *PostgresConnection) GetDueDigestSubscriptions(dailyCutoff, weeklyCutoff time.Time, limit int) ([]models.DigestSubscription, error) {
+	return queryDigestSubscriptions(pc.DB, `
+		SELECT `+digestSubscriptionColumns+`
+		FROM digest_subscriptions
+		WHERE confirmed_at IS NOT NULL AND (
+			last_sent_at IS NULL
+			OR (frequency = 'daily' AND last_sent_at <= $1)
+			OR (frequency = 'weekly' AND last_sent_at <= $2)
+		)
+		ORDER BY last_sent_at NULLS FIRST, id
+		LIMIT $3
+	`, dailyCutoff, weeklyCutoff, limit)
+}
+
+// MarkDigestSent records when the digest of a subscription was last sent.
+func (pc *PostgresConnection) MarkDigestSent(subscriptionID int64, sentAt time.Time) error {
+	return expectRow(pc.DB.Exec("UPDATE digest_subscriptions SET last_sent_at = $2 WHERE id = $1", subscriptionID, sentAt))
+}
+
+// MergeSessionDigestSubscriptions moves the digest subscriptions of an anonymous
+// session to a user's session. Email addresses the user already subscribed are dropped.
+func (pc *PostgresConnection) MergeSessionDigestSubscriptions(fromSessionID, toSessionID string, userID int64) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback is a no-op if the transaction is committed.
+
+	_, err = tx.Exec(`
+		DELETE FROM digest_subscriptions src
+		USING digest_subscriptions dst
+		WHERE src.session_id = $1 AND dst.session_id = $2 AND dst.email = src.email
+	`, fromSessionID, toSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete merged digest subscriptions: %w", err)
+	}
+	if _, err = tx.Exec("UPDATE digest_subscriptions SET session_id = $2, user_id = $3 WHERE session_id = $1", fromSessionID, toSessionID, userID); err != nil {
+		return fmt.Errorf("failed to move digest subscriptions: %w", err)
+	}
+	return tx.Commit()
+}
@@ -4,12 +4,74 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	qdrant_go_client "github.com/qdrant/go-client/qdrant" // Alias to avoid name collision
+	"github.com/teomiscia/github-trending/internal/models"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// Vectors are written to collections named after their kind and embedding version
+// (see VersionedCollection). Collections predating versioning are named after their
+// kind alone.
+const (
+	// RepositoriesCollection holds one aggregated README vector per repository.
+	RepositoriesCollection = "repositories"
+	// ReadmeChunksCollection holds one point per README chunk. Each point carries the
+	// repository_id, heading, offset and chunk_index of its chunk in its payload.
+	ReadmeChunksCollection = "readme_chunks"
+)
+
+// Readers query these aliases. Each points at the collection that currently serves, so
+// that a new embedding model can be backfilled without downtime. Aliases and
+// collections share one namespace, so the aliases are not named like the collections
+// predating versioning, which they point at until the first backfill.
+const (
+	RepositoriesAlias = "repositories_current"
+	ReadmeChunksAlias = "readme_chunks_current"
+)
+
+// AliasCollections maps each alias to the kind of collection it points at.
+var AliasCollections = map[string]string{
+	RepositoriesAlias: RepositoriesCollection,
+	ReadmeChunksAlias: ReadmeChunksCollection,
+}
+
+// Payload keys of repository and README chunk points.
+const (
+	PayloadID           = "id"
+	PayloadRepositoryID = "repository_id"
+	PayloadLanguage     = "language"
+	PayloadLanguages    = "languages"
+	PayloadTopics       = "topics"
+	PayloadStarsBucket  = "stars_bucket"
+	PayloadArchived     = "archived"
+	PayloadFork         = "fork"
+	PayloadCreatedAt    = "created_at"
+)
+
+// payloadIndexes are created on every collection so that filtered searches do not
+// scan the whole payload.
+var payloadIndexes = map[string]qdrant_go_client.FieldType{
+	PayloadID:           qdrant_go_client.FieldType_FieldTypeInteger,
+	PayloadRepositoryID: qdrant_go_client.FieldType_FieldTypeInteger,
+	PayloadLanguage:     qdrant_go_client.FieldType_FieldTypeKeyword,
+	PayloadLanguages:    qdrant_go_client.FieldType_FieldTypeKeyword,
+	PayloadTopics:       qdrant_go_client.FieldType_FieldTypeKeyword,
+	PayloadStarsBucket:  qdrant_go_client.FieldType_FieldTypeInteger,
+	PayloadArchived:     qdrant_go_client.FieldType_FieldTypeBool,
+	PayloadFork:         qdrant_go_client.FieldType_FieldTypeBool,
+	PayloadCreatedAt:    qdrant_go_client.FieldType_FieldTypeDatetime,
+}
+
+// VersionedCollection returns the collection of a kind for an embedding version, e.g.
+// repositories_v2.
+func VersionedCollection(collection string, version int) string {
+	return fmt.Sprintf("%s_v%d", collection, version)
+}
+
 type QdrantConnection struct {
 	conn              *grpc.ClientConn
 	pointsClient      qdrant_go_client.PointsClient
@@ -41,36 +103,132 @@ func (c *QdrantConnection) Close() {
 	}
 }
 
+// CreateCollection creates a collection and its payload indexes. Indexes are also
+// created on an existing collection, so older collections gain new indexes.
 func (c *QdrantConnection) CreateCollection(ctx context.Context, collectionName string, vectorSize uint64) error {
 	_, err := c.collectionsClient.Get(ctx, &qdrant_go_client.GetCollectionInfoRequest{
 		CollectionName: collectionName,
 	})
 	if err == nil {
 		log.Printf("Collection %s already exists", collectionName)
-		return nil
-	}
-
-	_, err = c.collectionsClient.Create(ctx, &qdrant_go_client.CreateCollection{
-		CollectionName: collectionName,
-		VectorsConfig: &qdrant_go_client.VectorsConfig{
-			Config: &qdrant_go_client.VectorsConfig_Params{
-				Params: &qdrant_go_client.VectorParams{
-					Size:     vectorSize,
-					Distance: qdrant_go_client.Distance_Cosine,
+	} else {
+		_, err = c.collectionsClient.Create(ctx, &qdrant_go_client.CreateCollection{
+			CollectionName: collectionName,
+			VectorsConfig: &qdrant_go_client.VectorsConfig{
+				Config: &qdrant_go_client.VectorsConfig_Params{
+					Params: &qdrant_go_client.VectorParams{
+						Size:     vectorSize,
+						Distance: qdrant_go_client.Distance_Cosine,
+					},
 				},
 			},
-		},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	wait := true
+	for field, fieldType := range payloadIndexes {
+		fieldType := fieldType
+		_, err := c.pointsClient.CreateFieldIndex(ctx, &qdrant_go_client.CreateFieldIndexCollection{
+			CollectionName: collectionName,
+			Wait:           &wait,
+			FieldName:      field,
+			FieldType:      &fieldType,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create payload index %s on %s: %w", field, collectionName, err)
+		}
+	}
+	return nil
+}
+
+// GetAliasTarget returns the collection an alias points to, or an empty string if the
+// alias does not exist.
+func (c *QdrantConnection) GetAliasTarget(ctx context.Context, alias string) (string, error) {
+	res, err := c.collectionsClient.ListAliases(ctx, &qdrant_go_client.ListAliasesRequest{})
+	if err != nil {
+		return "", err
+	}
+	for _, description := range res.GetAliases() {
+		if description.GetAliasName() == alias {
+			return description.GetCollectionName(), nil
+		}
+	}
+	return "", nil
+}
+
+// collectionExists reports whether a real collection (not an alias) has the given name.
+func (c *QdrantConnection) collectionExists(ctx context.Context, name string) (bool, error) {
+	res, err := c.collectionsClient.List(ctx, &qdrant_go_client.ListCollectionsRequest{})
+	if err != nil {
+		return false, err
+	}
+	for _, description := range res.GetCollections() {
+		if description.GetName() == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// EnsureAlias points alias at collectionName unless the alias already exists. When a
+// legacy collection predating versioning exists, the alias points at it instead, so it
+// keeps serving until the backfill switches the alias.
+func (c *QdrantConnection) EnsureAlias(ctx context.Context, alias, legacyCollection, collectionName string) error {
+	target, err := c.GetAliasTarget(ctx, alias)
+	if err != nil || target != "" {
+		return err
+	}
+	legacy, err := c.collectionExists(ctx, legacyCollection)
+	if err != nil {
+		return err
+	}
+	if legacy {
+		collectionName = legacyCollection
+	}
+	_, err = c.collectionsClient.UpdateAliases(ctx, &qdrant_go_client.ChangeAliases{
+		Actions: []*qdrant_go_client.AliasOperations{qdrant_go_client.NewAliasCreate(alias, collectionName)},
 	})
 	return err
 }
 
+// SwitchAlias repoints alias at collectionName in a single update, so readers never
+// see the alias missing. It returns the collection the alias pointed at before, or an
+// empty string.
+func (c *QdrantConnection) SwitchAlias(ctx context.Context, alias, collectionName string) (string, error) {
+	previous, err := c.GetAliasTarget(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	var actions []*qdrant_go_client.AliasOperations
+	if previous != "" {
+		actions = append(actions, qdrant_go_client.NewAliasDelete(alias))
+	}
+	actions = append(actions, qdrant_go_client.NewAliasCreate(alias, collectionName))
+	if _, err := c.collectionsClient.UpdateAliases(ctx, &qdrant_go_client.ChangeAliases{Actions: actions}); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// DeleteCollection deletes a collection.
+func (c *QdrantConnection) DeleteCollection(ctx context.Context, collectionName string) error {
+	_, err := c.collectionsClient.Delete(ctx, &qdrant_go_client.DeleteCollection{CollectionName: collectionName})
+	return err
+}
+
 func (c *QdrantConnection) UpsertVectors(ctx context.Context, collectionName string, points []*qdrant_go_client.PointStruct) error {
-	// Add the id to the payload
+	// Add the id to the payload of numerically keyed points
 	for _, point := range points {
+		if _, ok := point.GetId().GetPointIdOptions().(*qdrant_go_client.PointId_Num); !ok {
+			continue
+		}
 		if point.Payload == nil {
 			point.Payload = make(map[string]*qdrant_go_client.Value)
 		}
-		point.Payload["id"] = &qdrant_go_client.Value{
+		point.Payload[PayloadID] = &qdrant_go_client.Value{
 			Kind: &qdrant_go_client.Value_IntegerValue{
 				IntegerValue: int64(point.GetId().GetNum()),
 			},
@@ -86,27 +244,13 @@ func (c *QdrantConnection) UpsertVectors(ctx context.Context, collectionName str
 	return err
 }
 
-func (c *QdrantConnection) Search(ctx context.Context, collectionName string, vector []float32, limit uint64, excludeID uint64) ([]*qdrant_go_client.ScoredPoint, error) {
+// Search returns the repositories nearest to vector that match filter, which may be nil.
+func (c *QdrantConnection) Search(ctx context.Context, collectionName string, vector []float32, limit uint64, filter *QdrantFilter) ([]*qdrant_go_client.ScoredPoint, error) {
 	res, err := c.pointsClient.Search(ctx, &qdrant_go_client.SearchPoints{
 		CollectionName: collectionName,
 		Vector:         vector,
 		Limit:          limit,
-		Filter: &qdrant_go_client.Filter{
-			MustNot: []*qdrant_go_client.Condition{
-				{
-					ConditionOneOf: &qdrant_go_client.Condition_Field{
-						Field: &qdrant_go_client.FieldCondition{
-							Key: "id",
-							Match: &qdrant_go_client.Match{
-								MatchValue: &qdrant_go_client.Match_Integer{
-									Integer: int64(excludeID),
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+		Filter:         filter.build(PayloadID),
 		WithVectors: &qdrant_go_client.WithVectorsSelector{
 			SelectorOptions: &qdrant_go_client.WithVectorsSelector_Enable{
 				Enable: true,
@@ -146,4 +290,232 @@ func (c *QdrantConnection) GetVectors(ctx context.Context, collectionName string
 		return nil, err
 	}
 	return res.GetResult(), nil
-}
\ No newline at end of file
+}
+
+// DeleteRepositoryPoints removes every point whose repository_id payload matches repoID.
+func (c *QdrantConnection) DeleteRepositoryPoints(ctx context.Context, collectionName string, repoID int64) error {
+	wait := true
+	_, err := c.pointsClient.Delete(ctx, &qdrant_go_client.DeletePoints{
+		CollectionName: collectionName,
+		Wait:           &wait,
+		Points: &qdrant_go_client.PointsSelector{
+			PointsSelectorOneOf: &qdrant_go_client.PointsSelector_Filter{
+				Filter: &qdrant_go_client.Filter{
+					Must: []*qdrant_go_client.Condition{repositoryIDCondition(repoID)},
+				},
+			},
+		},
+	})
+	return err
+}
+
+// SearchChunkGroups searches the chunk collection and groups hits by repository_id,
+// returning the best chunk score for up to limit repositories that match filter.
+func (c *QdrantConnection) SearchChunkGroups(ctx context.Context, collectionName string, vector []float32, limit uint32, filter *QdrantFilter) (map[int64]float32, error) {
+	res, err := c.pointsClient.SearchGroups(ctx, &qdrant_go_client.SearchPointGroups{
+		CollectionName: collectionName,
+		Vector:         vector,
+		Limit:          limit,
+		GroupBy:        PayloadRepositoryID,
+		GroupSize:      1,
+		Filter:         filter.build(PayloadRepositoryID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[int64]float32)
+	for _, group := range res.GetResult().GetGroups() {
+		hits := group.GetHits()
+		if len(hits) == 0 {
+			continue
+		}
+		var repoID int64
+		switch id := group.GetId().GetKind().(type) {
+		case *qdrant_go_client.GroupId_IntegerValue:
+			repoID = id.IntegerValue
+		case *qdrant_go_client.GroupId_UnsignedValue:
+			repoID = int64(id.UnsignedValue)
+		default:
+			continue
+		}
+		scores[repoID] = hits[0].GetScore()
+	}
+	return scores, nil
+}
+
+func repositoryIDCondition(repoID int64) *qdrant_go_client.Condition {
+	return &qdrant_go_client.Condition{
+		ConditionOneOf: &qdrant_go_client.Condition_Field{
+			Field: &qdrant_go_client.FieldCondition{
+				Key: PayloadRepositoryID,
+				Match: &qdrant_go_client.Match{
+					MatchValue: &qdrant_go_client.Match_Integer{
+						Integer: repoID,
+					},
+				},
+			},
+		},
+	}
+}
+
+// GetPayloads returns the payload of each existing point among ids, keyed by ID.
+func (c *QdrantConnection) GetPayloads(ctx context.Context, collectionName string, ids []uint64) (map[uint64]map[string]*qdrant_go_client.Value, error) {
+	var pointIds []*qdrant_go_client.PointId
+	for _, id := range ids {
+		pointIds = append(pointIds, qdrant_go_client.NewIDNum(id))
+	}
+	res, err := c.pointsClient.Get(ctx, &qdrant_go_client.GetPoints{
+		CollectionName: collectionName,
+		Ids:            pointIds,
+		WithPayload:    qdrant_go_client.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payloads := make(map[uint64]map[string]*qdrant_go_client.Value)
+	for _, point := range res.GetResult() {
+		payloads[point.GetId().GetNum()] = point.GetPayload()
+	}
+	return payloads, nil
+}
+
+// SetRepositoryAttributes updates the attribute payload of a repository's point in
+// repositoriesCollection and of its README chunks in chunksCollection. Points that do
+// not exist yet are left alone.
+func (c *QdrantConnection) SetRepositoryAttributes(ctx context.Context, repositoriesCollection, chunksCollection string, repoID int64, attrs models.VectorAttributes) error {
+	wait := true
+	targets := []struct {
+		collection string
+		key        string
+	}{
+		{repositoriesCollection, PayloadID},
+		{chunksCollection, PayloadRepositoryID},
+	}
+	for _, target := range targets {
+		_, err := c.pointsClient.SetPayload(ctx, &qdrant_go_client.SetPayloadPoints{
+			CollectionName: target.collection,
+			Wait:           &wait,
+			Payload:        AttributesPayload(attrs),
+			PointsSelector: qdrant_go_client.NewPointsSelectorFilter(&qdrant_go_client.Filter{
+				Must: []*qdrant_go_client.Condition{qdrant_go_client.NewMatchInt(target.key, repoID)},
+			}),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set payload in %s: %w", target.collection, err)
+		}
+	}
+	return nil
+}
+
+// StarsBucket maps a star count to its order of magnitude: 0 below 10 stars, 1 below
+// 100 and so on, up to 5 for 100k stars or more.
+func StarsBucket(stars int) int {
+	bucket := 0
+	for stars >= 10 && bucket < 5 {
+		stars /= 10
+		bucket++
+	}
+	return bucket
+}
+
+// AttributesPayload converts repository attributes into a Qdrant payload.
+func AttributesPayload(attrs models.VectorAttributes) map[string]*qdrant_go_client.Value {
+	payload := map[string]*qdrant_go_client.Value{
+		PayloadLanguage:    qdrant_go_client.NewValueString(attrs.Language),
+		PayloadLanguages:   stringListValue(attrs.Languages),
+		PayloadTopics:      stringListValue(attrs.Topics),
+		PayloadStarsBucket: qdrant_go_client.NewValueInt(int64(StarsBucket(attrs.Stars))),
+		PayloadArchived:    qdrant_go_client.NewValueBool(attrs.Archived),
+		PayloadFork:        qdrant_go_client.NewValueBool(attrs.Fork),
+	}
+	if !attrs.CreatedAt.IsZero() {
+		payload[PayloadCreatedAt] = qdrant_go_client.NewValueString(attrs.CreatedAt.UTC().Format(time.RFC3339))
+	}
+	return payload
+}
+
+func stringListValue(values []string) *qdrant_go_client.Value {
+	list := &qdrant_go_client.ListValue{}
+	for _, v := range values {
+		list.Values = append(list.Values, qdrant_go_client.NewValueString(v))
+	}
+	return qdrant_go_client.NewValueList(list)
+}
+
+// QdrantFilter builds a filter over the repository payload for Search and
+// SearchChunkGroups. A nil *QdrantFilter matches everything.
+type QdrantFilter struct {
+	must       []*qdrant_go_client.Condition
+	mustNot    []*qdrant_go_client.Condition
+	excludeIDs []int64
+}
+
+// NewQdrantFilter returns an empty filter.
+func NewQdrantFilter() *QdrantFilter {
+	return &QdrantFilter{}
+}
+
+// Languages keeps repositories using any of the given languages.
+func (f *QdrantFilter) Languages(languages ...string) *QdrantFilter {
+	if len(languages) > 0 {
+		f.must = append(f.must, qdrant_go_client.NewMatchKeywords(PayloadLanguages, languages...))
+	}
+	return f
+}
+
+// Topics keeps repositories tagged with any of the given topics.
+func (f *QdrantFilter) Topics(topics ...string) *QdrantFilter {
+	if len(topics) > 0 {
+		f.must = append(f.must, qdrant_go_client.NewMatchKeywords(PayloadTopics, topics...))
+	}
+	return f
+}
+
+// MinStarsBucket keeps repositories whose StarsBucket is at least bucket.
+func (f *QdrantFilter) MinStarsBucket(bucket int) *QdrantFilter {
+	gte := float64(bucket)
+	f.must = append(f.must, qdrant_go_client.NewRange(PayloadStarsBucket, &qdrant_go_client.Range{Gte: &gte}))
+	return f
+}
+
+// ExcludeArchived drops archived repositories.
+func (f *QdrantFilter) ExcludeArchived() *QdrantFilter {
+	f.mustNot = append(f.mustNot, qdrant_go_client.NewMatchBool(PayloadArchived, true))
+	return f
+}
+
+// ExcludeForks drops forks.
+func (f *QdrantFilter) ExcludeForks() *QdrantFilter {
+	f.mustNot = append(f.mustNot, qdrant_go_client.NewMatchBool(PayloadFork, true))
+	return f
+}
+
+// CreatedAfter keeps repositories created after t.
+func (f *QdrantFilter) CreatedAfter(t time.Time) *QdrantFilter {
+	f.must = append(f.must, qdrant_go_client.NewDatetimeRange(PayloadCreatedAt, &qdrant_go_client.DatetimeRange{Gt: timestamppb.New(t)}))
+	return f
+}
+
+// ExcludeRepositories drops the given repositories.
+func (f *QdrantFilter) ExcludeRepositories(ids ...int64) *QdrantFilter {
+	f.excludeIDs = append(f.excludeIDs, ids...)
+	return f
+}
+
+// build returns the Qdrant filter, matching repository IDs on idKey, which differs
+// between the repository and chunk collections.
+func (f *QdrantFilter) build(idKey string) *qdrant_go_client.Filter {
+	if f == nil {
+		return nil
+	}
+	filter := &qdrant_go_client.Filter{
+		Must:    append([]*qdrant_go_client.Condition(nil), f.must...),
+		MustNot: append([]*qdrant_go_client.Condition(nil), f.mustNot...),
+	}
+	if len(f.excludeIDs) > 0 {
+		filter.MustNot = append(filter.MustNot, qdrant_go_client.NewMatchInts(idKey, f.excludeIDs...))
+	}
+	return filter
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func TestStarsBucket(t *testing.T) {
+	tests := map[int]int{
+		0:       0,
+		9:       0,
+		10:      1,
+		99:      1,
+		100:     2,
+		54321:   4,
+		100000:  5,
+		9999999: 5,
+	}
+	for stars, want := range tests {
+		if got := StarsBucket(stars); got != want {
+			t.Errorf("StarsBucket(%d) = %d, want %d", stars, got, want)
+		}
+	}
+}
+
+func TestAttributesPayload(t *testing.T) {
+	payload := AttributesPayload(models.VectorAttributes{
+		Language:  "Go",
+		Languages: []string{"Go", "Shell"},
+		Topics:    []string{"cli"},
+		Stars:     1500,
+		Archived:  true,
+		CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+
+	if got := payload[PayloadStarsBucket].GetIntegerValue(); got != 3 {
+		t.Errorf("stars bucket = %d, want 3", got)
+	}
+	if got := len(payload[PayloadLanguages].GetListValue().GetValues()); got != 2 {
+		t.Errorf("languages has %d values, want 2", got)
+	}
+	if !payload[PayloadArchived].GetBoolValue() || payload[PayloadFork].GetBoolValue() {
+		t.Errorf("unexpected archived/fork flags: %v, %v", payload[PayloadArchived], payload[PayloadFork])
+	}
+	if got := payload[PayloadCreatedAt].GetStringValue(); got != "2020-01-02T03:04:05Z" {
+		t.Errorf("created_at = %q", got)
+	}
+
+	if _, ok := AttributesPayload(models.VectorAttributes{})[PayloadCreatedAt]; ok {
+		t.Error("created_at should be omitted when unknown")
+	}
+}
+
+func TestQdrantFilterBuild(t *testing.T) {
+	var nilFilter *QdrantFilter
+	if nilFilter.build(PayloadID) != nil {
+		t.Error("a nil filter should build to nil")
+	}
+
+	filter := NewQdrantFilter().
+		Languages("Go").
+		Topics().
+		MinStarsBucket(2).
+		ExcludeArchived().
+		ExcludeForks().
+		ExcludeRepositories(1, 2)
+
+	built := filter.build(PayloadRepositoryID)
+	if len(built.GetMust()) != 2 {
+		t.Errorf("expected 2 must conditions, got %d", len(built.GetMust()))
+	}
+	if len(built.GetMustNot()) != 3 {
+		t.Fatalf("expected 3 must_not conditions, got %d", len(built.GetMustNot()))
+	}
+	if key := built.GetMustNot()[2].GetField().GetKey(); key != PayloadRepositoryID {
+		t.Errorf("excluded IDs matched on %q, want %q", key, PayloadRepositoryID)
+	}
+
+	// Building for another collection must not leak conditions between the two.
+	if key := filter.build(PayloadID).GetMustNot()[2].GetField().GetKey(); key != PayloadID {
+		t.Errorf("excluded IDs matched on %q, want %q", key, PayloadID)
+	}
+	if len(filter.build(PayloadID).GetMustNot()) != 3 {
+		t.Error("building a filter twice should not accumulate conditions")
+	}
+}
@@ -0,0 +1,212 @@
+// Package digest assembles the daily and weekly digests of the top trending
+// repositories and emails them to their subscribers.
+package digest
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/mailer"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+const (
+	// MaxRepositories is the number of repositories in a digest.
+	MaxRepositories = 10
+
+	// candidateRepositories is the number of top growing repositories the language and
+	// topic filters of a subscription are applied to.
+	candidateRepositories = 500
+
+	// batchSize is the number of due subscriptions read at a time.
+	batchSize = 100
+
+	// dueSlack lets a digest go out slightly early, so that sending digests on a ticker
+	// does not make them drift later every period.
+	dueSlack = time.Hour
+)
+
+// Period returns the time between two digests of a frequency.
+func Period(frequency string) time.Duration {
+	if frequency == models.DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Store reads the subscriptions and the repositories of the digests.
+type Store interface {
+	GetDueDigestSubscriptions(dailyCutoff, weeklyCutoff time.Time, limit int) ([]models.DigestSubscription, error)
+	MarkDigestSent(subscriptionID int64, sentAt time.Time) error
+	FilterRepositoryIDs(repoIDs []int64, languages, tags, topics []string) ([]int64, error)
+	GetRepositoryByID(repoID int64) (models.Repository, error)
+}
+
+// Stats ranks repositories by their growth.
+type Stats interface {
+	GetTopRepositoriesGrowthSince(since time.Time, limit int) ([]models.RepositoryGrowth, error)
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(to string, message mailer.Message) error
+}
+
+// Item is a repository of a digest with its growth over the digest's period.
+type Item struct {
+	Repository models.Repository
+	// Language is the repository's primary language, or its largest one.
+	Language   string
+	Growth     models.RepositoryGrowth
+	OGImageURL string
+}
+
+// Digest is the digest of a subscription, covering the repositories' growth from Since
+// to Until.
+type Digest struct {
+	Subscription   models.DigestSubscription
+	Since          time.Time
+	Until          time.Time
+	Items          []Item
+	UnsubscribeURL string
+}
+
+// Sender sends the digests that are due.
+type Sender struct {
+	store        Store
+	stats        Stats
+	mailer       Mailer
+	publicAPIURL string
+	now          func() time.Time
+}
+
+// NewSender creates a Sender. publicAPIURL is the API's public URL, which digests link
+// to for OG images and unsubscribing.
+func NewSender(store Store, stats Stats, mailer Mailer, publicAPIURL string) *Sender {
+	return &Sender{store: store, stats: stats, mailer: mailer, publicAPIURL: publicAPIURL, now: time.Now}
+}
+
+// Run sends the digests that are due and returns how many were sent. Digests without
+// any repository are not sent, but count as sent so that the next one covers the
+// following period. A digest that cannot be sent is logged and retried on the next run.
+func (s *Sender) Run() (int, error) {
+	now := s.now()
+	growth := make(map[time.Time][]models.RepositoryGrowth)
+	sent := 0
+	var failed []int64
+	for {
+		subscriptions, err := s.store.GetDueDigestSubscriptions(now.Add(dueSlack-Period(models.DigestDaily)), now.Add(dueSlack-Period(models.DigestWeekly)), batchSize+len(failed))
+		if err != nil {
+			return sent, fmt.Errorf("failed to get due digest subscriptions: %w", err)
+		}
+		handled := 0
+		for _, subscription := range subscriptions {
+			if containsID(failed, subscription.ID) {
+				continue
+			}
+			handled++
+			ok, err := s.send(subscription, now, growth)
+			if err != nil {
+				log.Printf("Failed to send the digest of subscription %d: %v", subscription.ID, err)
+				failed = append(failed, subscription.ID)
+				continue
+			}
+			if err := s.store.MarkDigestSent(subscription.ID, now); err != nil {
+				return sent, fmt.Errorf("failed to mark the digest of subscription %d sent: %w", subscription.ID, err)
+			}
+			if ok {
+				sent++
+			}
+		}
+		if handled == 0 {
+			return sent, nil
+		}
+	}
+}
+
+// send builds and emails the digest of a subscription, and reports whether it had any
+// repository to send. growth caches the growth rankings by start time.
+func (s *Sender) send(subscription models.DigestSubscription, now time.Time, growth map[time.Time][]models.RepositoryGrowth) (bool, error) {
+	digest, err := s.Build(subscription, now, growth)
+	if err != nil {
+		return false, err
+	}
+	if len(digest.Items) == 0 {
+		return false, nil
+	}
+	message, err := Render(digest)
+	if err != nil {
+		return false, err
+	}
+	return true, s.mailer.Send(subscription.Email, message)
+}
+
+// Build assembles the digest of a subscription: the repositories matching its filters
+// that gained the most stars since its last digest, or over its period for the first
+// one. growth caches the growth rankings by start time and may be nil.
+func (s *Sender) Build(subscription models.DigestSubscription, now time.Time, growth map[time.Time][]models.RepositoryGrowth) (Digest, error) {
+	since := now.Add(-Period(subscription.Frequency))
+	if subscription.LastSentAt != nil {
+		since = *subscription.LastSentAt
+	}
+	// Rounding shares the rankings between subscriptions sent about the same time.
+	since = since.Truncate(time.Hour)
+
+	ranking, ok := growth[since]
+	if !ok {
+		var err error
+		ranking, err = s.stats.GetTopRepositoriesGrowthSince(since, candidateRepositories)
+		if err != nil {
+			return Digest{}, fmt.Errorf("failed to rank repositories: %w", err)
+		}
+		if growth != nil {
+			growth[since] = ranking
+		}
+	}
+	byID := make(map[int64]models.RepositoryGrowth, len(ranking))
+	repoIDs := make([]int64, len(ranking))
+	for i, g := range ranking {
+		byID[g.RepositoryID] = g
+		repoIDs[i] = g.RepositoryID
+	}
+	repoIDs, err := s.store.FilterRepositoryIDs(repoIDs, subscription.Languages, nil, subscription.Topics)
+	if err != nil {
+		return Digest{}, fmt.Errorf("failed to filter repositories: %w", err)
+	}
+
+	digest := Digest{
+		Subscription:   subscription,
+		Since:          since,
+		Until:          now,
+		UnsubscribeURL: s.publicAPIURL + "/digests/unsubscribe?token=" + url.QueryEscape(subscription.UnsubscribeToken),
+	}
+	for _, id := range repoIDs {
+		if len(digest.Items) == MaxRepositories {
+			break
+		}
+		repo, err := s.store.GetRepositoryByID(id)
+		if err != nil {
+			log.Printf("Failed to get repository %d for a digest: %v", id, err)
+			continue
+		}
+		digest.Items = append(digest.Items, Item{
+			Repository: repo,
+			Language:   models.NewEventRepository(repo).Language,
+			Growth:     byID[id],
+			OGImageURL: s.publicAPIURL + "/api/og?id=" + strconv.FormatInt(id, 10),
+		})
+	}
+	return digest, nil
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
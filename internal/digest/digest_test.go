@@ -0,0 +1,202 @@
+package digest
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/mailer"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+type fakeStore struct {
+	subscriptions []models.DigestSubscription
+	sent          map[int64]time.Time
+	topics        map[int64][]string
+	cutoffs       [2]time.Time
+}
+
+func (s *fakeStore) GetDueDigestSubscriptions(dailyCutoff, weeklyCutoff time.Time, limit int) ([]models.DigestSubscription, error) {
+	s.cutoffs = [2]time.Time{dailyCutoff, weeklyCutoff}
+	var due []models.DigestSubscription
+	for _, sub := range s.subscriptions {
+		if _, ok := s.sent[sub.ID]; !ok && len(due) < limit {
+			due = append(due, sub)
+		}
+	}
+	return due, nil
+}
+
+func (s *fakeStore) MarkDigestSent(subscriptionID int64, sentAt time.Time) error {
+	s.sent[subscriptionID] = sentAt
+	return nil
+}
+
+func (s *fakeStore) FilterRepositoryIDs(repoIDs []int64, languages, tags, topics []string) ([]int64, error) {
+	if len(topics) == 0 {
+		return repoIDs, nil
+	}
+	var ids []int64
+	for _, id := range repoIDs {
+		for _, topic := range s.topics[id] {
+			if topic == topics[0] {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (s *fakeStore) GetRepositoryByID(repoID int64) (models.Repository, error) {
+	names := map[int64]string{1: "acme/rocket", 2: "acme/<script>", 3: "bolt/llm-kit"}
+	return models.Repository{
+		ID:          int(repoID),
+		FullName:    names[repoID],
+		HTMLURL:     "https://github.com/" + names[repoID],
+		Description: sql.NullString{String: "Fast & small", Valid: true},
+		Language:    sql.NullString{String: "Go", Valid: true},
+	}, nil
+}
+
+type fakeStats struct {
+	since []time.Time
+}
+
+func (s *fakeStats) GetTopRepositoriesGrowthSince(since time.Time, limit int) ([]models.RepositoryGrowth, error) {
+	s.since = append(s.since, since)
+	return []models.RepositoryGrowth{
+		{RepositoryID: 1, Stars: 12345, StarsGained: 2500, Forks: 300, ForksGained: 20},
+		{RepositoryID: 2, Stars: 800, StarsGained: 400, Forks: 10, ForksGained: 1},
+		{RepositoryID: 3, Stars: 500, StarsGained: 300},
+	}, nil
+}
+
+// fakeMailer records the emails sent, and fails for bounce@example.com.
+type fakeMailer struct {
+	messages []mailer.Message
+}
+
+func (m *fakeMailer) Send(to string, message mailer.Message) error {
+	if to == "bounce@example.com" {
+		return errors.New("mailbox unavailable")
+	}
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func newTestSender(store *fakeStore, stats *fakeStats, now time.Time) (*Sender, *fakeMailer) {
+	sent := &fakeMailer{}
+	sender := NewSender(store, stats, sent, "https://api.example.com")
+	sender.now = func() time.Time { return now }
+	return sender, sent
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2025, 6, 1, 8, 30, 0, 0, time.UTC)
+	stats := &fakeStats{}
+	store := &fakeStore{topics: map[int64][]string{3: {"llm"}}}
+	sender, _ := newTestSender(store, stats, now)
+
+	lastSent := now.Add(-26 * time.Hour)
+	digest, err := sender.Build(models.DigestSubscription{Frequency: models.DigestDaily, Topics: []string{"llm"}, UnsubscribeToken: "tok", LastSentAt: &lastSent}, now, nil)
+	if err != nil {
+		t.Fatalf("Build returned an error: %v", err)
+	}
+	if want := lastSent.Truncate(time.Hour); !digest.Since.Equal(want) || !stats.since[0].Equal(want) {
+		t.Errorf("digest since %v, ranked since %v, want %v", digest.Since, stats.since[0], want)
+	}
+	if len(digest.Items) != 1 || digest.Items[0].Repository.FullName != "bolt/llm-kit" || digest.Items[0].Growth.StarsGained != 300 || digest.Items[0].Language != "Go" {
+		t.Errorf("unexpected items %+v", digest.Items)
+	}
+	if digest.Items[0].OGImageURL != "https://api.example.com/api/og?id=3" || digest.UnsubscribeURL != "https://api.example.com/digests/unsubscribe?token=tok" {
+		t.Errorf("unexpected URLs %s, %s", digest.Items[0].OGImageURL, digest.UnsubscribeURL)
+	}
+
+	// The first weekly digest covers the last week.
+	digest, err = sender.Build(models.DigestSubscription{Frequency: models.DigestWeekly}, now, nil)
+	if err != nil || len(digest.Items) != 3 || !digest.Since.Equal(time.Date(2025, 5, 25, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected weekly digest %+v, %v", digest, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	now := time.Date(2025, 6, 1, 8, 30, 0, 0, time.UTC)
+	stats := &fakeStats{}
+	store := &fakeStore{
+		sent: map[int64]time.Time{},
+		subscriptions: []models.DigestSubscription{
+			{ID: 1, Email: "dev@example.com", Frequency: models.DigestDaily, UnsubscribeToken: "a"},
+			{ID: 2, Email: "none@example.com", Frequency: models.DigestDaily, Topics: []string{"web"}, UnsubscribeToken: "b"},
+			{ID: 3, Email: "bounce@example.com", Frequency: models.DigestDaily, UnsubscribeToken: "c"},
+		},
+	}
+	sender, sent := newTestSender(store, stats, now)
+
+	n, err := sender.Run()
+	if err != nil || n != 1 || len(sent.messages) != 1 {
+		t.Fatalf("Run() = %d, %v with %d emails", n, err, len(sent.messages))
+	}
+	if !store.cutoffs[0].Equal(now.Add(-23*time.Hour)) || !store.cutoffs[1].Equal(now.Add(-7*24*time.Hour+time.Hour)) {
+		t.Errorf("unexpected cutoffs %v", store.cutoffs)
+	}
+	// A digest without repositories counts as sent; a failed one is retried next run.
+	if _, ok := store.sent[2]; !ok {
+		t.Error("an empty digest should be marked sent")
+	}
+	if _, ok := store.sent[3]; ok {
+		t.Error("a failed digest should not be marked sent")
+	}
+	if len(stats.since) != 1 {
+		t.Errorf("subscriptions due together should share the ranking, got %d rankings", len(stats.since))
+	}
+}
+
+func TestRenderAndSend(t *testing.T) {
+	now := time.Date(2025, 6, 1, 8, 30, 0, 0, time.UTC)
+	store := &fakeStore{}
+	sender, sent := newTestSender(store, &fakeStats{}, now)
+	subscription := models.DigestSubscription{Email: "dev@example.com", Frequency: models.DigestDaily, Languages: []string{"Go"}, UnsubscribeToken: "tok"}
+	digest, err := sender.Build(subscription, now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := Render(digest)
+	if err != nil {
+		t.Fatalf("Render returned an error: %v", err)
+	}
+	if message.Subject != "Your daily GitHub trending digest: acme/rocket and 2 more" {
+		t.Errorf("unexpected subject %q", message.Subject)
+	}
+	for _, want := range []string{"1. acme/rocket", "12,345 stars (+2,500)", "https://github.com/acme/rocket", "Unsubscribe: https://api.example.com/digests/unsubscribe?token=tok", "Go"} {
+		if !strings.Contains(message.Text, want) {
+			t.Errorf("the text digest should contain %q:\n%s", want, message.Text)
+		}
+	}
+	for _, want := range []string{`src="https://api.example.com/api/og?id=1"`, "acme/&lt;script&gt;", "Fast &amp; small", "+2,500"} {
+		if !strings.Contains(message.HTML, want) {
+			t.Errorf("the HTML digest should contain %q", want)
+		}
+	}
+
+	if message.UnsubscribeURL != "https://api.example.com/digests/unsubscribe?token=tok" {
+		t.Errorf("unexpected unsubscribe URL %s", message.UnsubscribeURL)
+	}
+
+	if ok, err := sender.send(subscription, now, nil); err != nil || !ok {
+		t.Fatalf("send() = %v, %v", ok, err)
+	}
+	if len(sent.messages) != 1 || sent.messages[0] != message {
+		t.Errorf("the rendered digest should be sent, got %+v", sent.messages)
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	for n, want := range map[int64]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567", -4500: "-4,500"} {
+		if got := formatNumber(n); got != want {
+			t.Errorf("formatNumber(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
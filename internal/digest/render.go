@@ -0,0 +1,83 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/teomiscia/github-trending/internal/mailer"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var funcs = map[string]any{
+	"inc":    func(i int) int { return i + 1 },
+	"number": formatNumber,
+}
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("digest.html.tmpl").Funcs(funcs).ParseFS(templates, "templates/digest.html.tmpl"))
+	textTemplate = texttemplate.Must(texttemplate.New("digest.txt.tmpl").Funcs(funcs).ParseFS(templates, "templates/digest.txt.tmpl"))
+)
+
+// templateData is what the templates render: the digest and its headings.
+type templateData struct {
+	Digest
+	Title   string
+	Period  string
+	Filters string
+}
+
+// Render renders the subject and the plain-text and HTML bodies of a digest, which
+// are sent with its unsubscribe URL.
+func Render(d Digest) (mailer.Message, error) {
+	data := templateData{
+		Digest: d,
+		Title:  fmt.Sprintf("Your %s GitHub trending digest", d.Subscription.Frequency),
+		Period: fmt.Sprintf("Top repositories by stars gained from %s to %s", d.Since.UTC().Format("Jan 2, 15:04"), d.Until.UTC().Format("Jan 2, 15:04 UTC")),
+	}
+	var filters []string
+	if len(d.Subscription.Languages) > 0 {
+		filters = append(filters, strings.Join(d.Subscription.Languages, ", "))
+	}
+	if len(d.Subscription.Topics) > 0 {
+		filters = append(filters, "topics "+strings.Join(d.Subscription.Topics, ", "))
+	}
+	data.Filters = strings.Join(filters, "; ")
+
+	message := mailer.Message{Subject: data.Title, UnsubscribeURL: d.UnsubscribeURL}
+	if len(d.Items) > 0 {
+		message.Subject = fmt.Sprintf("%s: %s", data.Title, d.Items[0].Repository.FullName)
+		if len(d.Items) > 1 {
+			message.Subject += fmt.Sprintf(" and %d more", len(d.Items)-1)
+		}
+	}
+
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, data); err != nil {
+		return mailer.Message{}, fmt.Errorf("failed to render the text digest: %w", err)
+	}
+	if err := htmlTemplate.Execute(&html, data); err != nil {
+		return mailer.Message{}, fmt.Errorf("failed to render the HTML digest: %w", err)
+	}
+	message.Text, message.HTML = text.String(), html.String()
+	return message, nil
+}
+
+// formatNumber formats a number with thousands separators.
+func formatNumber(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return sign + s
+}
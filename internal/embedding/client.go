@@ -0,0 +1,127 @@
+// Package embedding is a client for the embedding API service.
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ErrPermanent marks failures that retrying will not fix, such as a request the API
+// rejects or a malformed response. Callers should dead-letter the job.
+var ErrPermanent = errors.New("permanent embedding failure")
+
+const (
+	defaultMaxAttempts    = 4
+	defaultInitialBackoff = 2 * time.Second
+)
+
+// Client calls the embedding API's batch endpoint, retrying transient failures
+// (network errors, 429 and 5xx responses) with exponential backoff.
+type Client struct {
+	httpClient     *http.Client
+	baseURL        string
+	maxAttempts    int
+	initialBackoff time.Duration
+}
+
+// Result holds one embedding per input text, in input order, and the name of the
+// model that produced them.
+type Result struct {
+	Embeddings [][]float32
+	Model      string
+}
+
+// NewClient creates a Client for the embedding API at baseURL.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 2 * time.Minute}
+	}
+	return &Client{
+		httpClient:     httpClient,
+		baseURL:        baseURL,
+		maxAttempts:    defaultMaxAttempts,
+		initialBackoff: defaultInitialBackoff,
+	}
+}
+
+// SetRetryPolicy overrides the number of attempts per request and the delay before
+// the first retry. Useful for testing.
+func (c *Client) SetRetryPolicy(maxAttempts int, initialBackoff time.Duration) {
+	c.maxAttempts = maxAttempts
+	c.initialBackoff = initialBackoff
+}
+
+// EmbedBatch embeds texts in a single API call. Errors wrapping ErrPermanent should
+// not be retried; any other error is transient and survived every retry.
+func (c *Client) EmbedBatch(ctx context.Context, texts []string) (*Result, error) {
+	if len(texts) == 0 {
+		return &Result{}, nil
+	}
+	body, err := json.Marshal(map[string][]string{"texts": texts})
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to marshal request body: %v", ErrPermanent, err)
+	}
+
+	backoff := c.initialBackoff
+	for attempt := 1; ; attempt++ {
+		result, err := c.embedBatch(ctx, body, len(texts))
+		if err == nil || errors.Is(err, ErrPermanent) || attempt >= c.maxAttempts {
+			return result, err
+		}
+
+		log.Printf("Embedding request failed (attempt %d/%d): %v. Retrying in %v...", attempt, c.maxAttempts, err, backoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) embedBatch(ctx context.Context, body []byte, count int) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/embed_batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create request: %v", ErrPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embedding API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		err := fmt.Errorf("embedding API returned non-OK status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+
+	var decoded struct {
+		Embeddings [][]float32 `json:"embeddings"`
+		Model      string      `json:"model"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode embedding response: %v", ErrPermanent, err)
+	}
+	if len(decoded.Embeddings) != count {
+		return nil, fmt.Errorf("%w: expected %d embeddings, got %d", ErrPermanent, count, len(decoded.Embeddings))
+	}
+	for i, embedding := range decoded.Embeddings {
+		if len(embedding) == 0 {
+			return nil, fmt.Errorf("%w: embedding %d is empty", ErrPermanent, i)
+		}
+	}
+	return &Result{Embeddings: decoded.Embeddings, Model: decoded.Model}, nil
+}
@@ -0,0 +1,121 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, handler func(w http.ResponseWriter, texts []string, call int32)) (*Client, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embed_batch" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var body struct {
+			Texts []string `json:"texts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		handler(w, body.Texts, atomic.AddInt32(&calls, 1))
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL, server.Client())
+	client.SetRetryPolicy(3, time.Millisecond)
+	return client, &calls
+}
+
+func writeEmbeddings(w http.ResponseWriter, texts []string) {
+	embeddings := make([][]float32, len(texts))
+	for i := range texts {
+		embeddings[i] = []float32{float32(i), 1}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": embeddings, "model": "test-model"})
+}
+
+func TestEmbedBatch(t *testing.T) {
+	client, calls := newTestServer(t, func(w http.ResponseWriter, texts []string, call int32) {
+		writeEmbeddings(w, texts)
+	})
+
+	result, err := client.EmbedBatch(context.Background(), []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("EmbedBatch returned an error: %v", err)
+	}
+	if len(result.Embeddings) != 3 || result.Embeddings[2][0] != 2 {
+		t.Errorf("Unexpected embeddings: %v", result.Embeddings)
+	}
+	if result.Model != "test-model" {
+		t.Errorf("Model = %q, want test-model", result.Model)
+	}
+	if *calls != 1 {
+		t.Errorf("Expected 1 call, got %d", *calls)
+	}
+}
+
+func TestEmbedBatchRetriesTransientErrors(t *testing.T) {
+	client, calls := newTestServer(t, func(w http.ResponseWriter, texts []string, call int32) {
+		if call < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		writeEmbeddings(w, texts)
+	})
+
+	if _, err := client.EmbedBatch(context.Background(), []string{"a"}); err != nil {
+		t.Fatalf("EmbedBatch returned an error: %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", *calls)
+	}
+}
+
+func TestEmbedBatchGivesUpAfterMaxAttempts(t *testing.T) {
+	client, calls := newTestServer(t, func(w http.ResponseWriter, texts []string, call int32) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+
+	_, err := client.EmbedBatch(context.Background(), []string{"a"})
+	if err == nil || errors.Is(err, ErrPermanent) {
+		t.Fatalf("Expected a transient error, got %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", *calls)
+	}
+}
+
+func TestEmbedBatchPermanentErrors(t *testing.T) {
+	tests := map[string]func(w http.ResponseWriter, texts []string){
+		"bad request": func(w http.ResponseWriter, texts []string) {
+			http.Error(w, "bad", http.StatusBadRequest)
+		},
+		"count mismatch": func(w http.ResponseWriter, texts []string) {
+			writeEmbeddings(w, texts[:1])
+		},
+		"empty vector": func(w http.ResponseWriter, texts []string) {
+			json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": [][]float32{{}, {}}})
+		},
+	}
+	for name, handler := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, calls := newTestServer(t, func(w http.ResponseWriter, texts []string, call int32) {
+				handler(w, texts)
+			})
+			_, err := client.EmbedBatch(context.Background(), []string{"a", "b"})
+			if !errors.Is(err, ErrPermanent) {
+				t.Fatalf("Expected ErrPermanent, got %v", err)
+			}
+			if *calls != 1 {
+				t.Errorf("Permanent errors should not be retried, got %d calls", *calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package feeds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() Feed {
+	published := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	return Feed{
+		Title:       "Trending repositories",
+		Description: "Weekly trending <Go> repositories",
+		SelfURL:     "https://api.example.com/feeds/trending.atom?window=weekly",
+		Updated:     published.Add(2 * time.Hour),
+		Items: []Item{{
+			ID:        GUID("weekly/2025-06-01", 42),
+			Title:     "acme/rocket",
+			URL:       "https://github.com/acme/rocket",
+			Summary:   "Fast & small. 1200 stars (+300).",
+			ImageURL:  "https://api.example.com/api/og?id=42",
+			Author:    "acme",
+			Tags:      []string{"Go", "cli"},
+			Published: published,
+			Updated:   published.Add(time.Hour),
+		}},
+	}
+}
+
+func TestGUID(t *testing.T) {
+	id := GUID("weekly/2025-06-01", 42)
+	if !strings.HasPrefix(id, "urn:uuid:") || id != GUID("weekly/2025-06-01", 42) {
+		t.Errorf("GUID should be a stable URN, got %s", id)
+	}
+	if id == GUID("weekly/2025-06-08", 42) || id == GUID("weekly/2025-06-01", 43) {
+		t.Error("GUID should differ between lists and repositories")
+	}
+}
+
+func TestRSS(t *testing.T) {
+	body, err := RSS(testFeed())
+	if err != nil {
+		t.Fatalf("RSS returned an error: %v", err)
+	}
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			Title    string `xml:"title"`
+			AtomLink struct {
+				Href string `xml:"href,attr"`
+			} `xml:"http://www.w3.org/2005/Atom link"`
+			Items []struct {
+				GUID struct {
+					IsPermaLink string `xml:"isPermaLink,attr"`
+					Value       string `xml:",chardata"`
+				} `xml:"guid"`
+				Description string   `xml:"description"`
+				Categories  []string `xml:"category"`
+				PubDate     string   `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("invalid RSS: %v\n%s", err, body)
+	}
+	item := doc.Channel.Items[0]
+	if doc.Version != "2.0" || doc.Channel.AtomLink.Href != testFeed().SelfURL || item.GUID.Value != testFeed().Items[0].ID || item.GUID.IsPermaLink != "false" {
+		t.Errorf("unexpected RSS document %+v", doc)
+	}
+	if item.Description != "Fast & small. 1200 stars (+300)." || len(item.Categories) != 2 || item.PubDate != "Sun, 01 Jun 2025 00:00:00 +0000" {
+		t.Errorf("unexpected RSS item %+v", item)
+	}
+}
+
+func TestAtom(t *testing.T) {
+	body, err := Atom(testFeed())
+	if err != nil {
+		t.Fatalf("Atom returned an error: %v", err)
+	}
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Updated string `xml:"updated"`
+			Links   []struct {
+				Href string `xml:"href,attr"`
+				Rel  string `xml:"rel,attr"`
+			} `xml:"link"`
+			Author struct {
+				Name string `xml:"name"`
+			} `xml:"author"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("invalid Atom: %v\n%s", err, body)
+	}
+	entry := doc.Entries[0]
+	if doc.ID != testFeed().SelfURL || doc.Updated != "2025-06-01T02:00:00Z" || entry.ID != testFeed().Items[0].ID || entry.Updated != "2025-06-01T01:00:00Z" || entry.Author.Name != "acme" {
+		t.Errorf("unexpected Atom document %+v", doc)
+	}
+	if len(entry.Links) != 2 || entry.Links[0].Href != "https://github.com/acme/rocket" || entry.Links[1].Rel != "enclosure" {
+		t.Errorf("unexpected Atom links %+v", entry.Links)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	body, err := JSON(testFeed())
+	if err != nil {
+		t.Fatalf("JSON returned an error: %v", err)
+	}
+	var doc struct {
+		Version string `json:"version"`
+		FeedURL string `json:"feed_url"`
+		Items   []struct {
+			ID            string `json:"id"`
+			Image         string `json:"image"`
+			DatePublished string `json:"date_published"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("invalid JSON Feed: %v", err)
+	}
+	if doc.Version != "https://jsonfeed.org/version/1.1" || doc.FeedURL != testFeed().SelfURL || doc.Items[0].Image != testFeed().Items[0].ImageURL || doc.Items[0].DatePublished != "2025-06-01T00:00:00Z" {
+		t.Errorf("unexpected JSON Feed %+v", doc)
+	}
+
+	// An empty feed still has an items array.
+	body, _ = JSON(Feed{Title: "empty"})
+	if !strings.Contains(string(body), `"items": []`) {
+		t.Errorf("expected an empty items array, got %s", body)
+	}
+}
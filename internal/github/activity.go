@@ -0,0 +1,161 @@
+package github
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	models "github.com/teomiscia/github-trending/internal/models"
+)
+
+// maxActivityPages caps how many pages of issues and comments are read per repository.
+// The activity of a repository with more items in the window is computed from the
+// first pages only, which is logged.
+const maxActivityPages = 5
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+type githubIssue struct {
+	Number      int        `json:"number"`
+	User        githubUser `json:"user"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+	PullRequest *struct {
+		MergedAt *time.Time `json:"merged_at"`
+	} `json:"pull_request"`
+}
+
+type githubIssueComment struct {
+	IssueURL  string     `json:"issue_url"`
+	User      githubUser `json:"user"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// GetIssueActivity collects issue and pull request activity for a repository over the
+// window ending now. The issues endpoint returns both issues and pull requests, so they
+// are told apart by the pull_request field.
+func (c *GitHubClient) GetIssueActivity(repoFullName string, window time.Duration) (*models.RepositoryActivity, error) {
+	now := time.Now()
+	since := now.Add(-window)
+	sinceStr := since.UTC().Format(time.RFC3339)
+
+	var issues []githubIssue
+	for page := 1; page <= maxActivityPages; page++ {
+		var batch []githubIssue
+		path := fmt.Sprintf("/repos/%s/issues?state=all&since=%s&per_page=100&page=%d", repoFullName, sinceStr, page)
+		if err := c.getJSON(path, repoFullName, &batch); err != nil {
+			return nil, err
+		}
+		issues = append(issues, batch...)
+		if len(batch) < 100 {
+			break
+		}
+		if page == maxActivityPages {
+			log.Printf("Activity of %s is computed from the first %d pages of issues only", repoFullName, maxActivityPages)
+		}
+	}
+
+	var comments []githubIssueComment
+	for page := 1; page <= maxActivityPages; page++ {
+		var batch []githubIssueComment
+		path := fmt.Sprintf("/repos/%s/issues/comments?sort=created&direction=asc&since=%s&per_page=100&page=%d", repoFullName, sinceStr, page)
+		if err := c.getJSON(path, repoFullName, &batch); err != nil {
+			return nil, err
+		}
+		comments = append(comments, batch...)
+		if len(batch) < 100 {
+			break
+		}
+		if page == maxActivityPages {
+			log.Printf("Activity of %s is computed from the first %d pages of comments only", repoFullName, maxActivityPages)
+		}
+	}
+
+	activity := summarizeActivity(issues, comments, since)
+	activity.EventDate = now
+	activity.EventTime = now
+	activity.WindowDays = int(window.Hours() / 24)
+	return activity, nil
+}
+
+// summarizeActivity computes the activity counters for items created or closed after since.
+func summarizeActivity(issues []githubIssue, comments []githubIssueComment, since time.Time) *models.RepositoryActivity {
+	activity := &models.RepositoryActivity{}
+
+	// Only items opened inside the window are eligible for time-to-first-response.
+	openedInWindow := make(map[int]githubIssue)
+	for _, issue := range issues {
+		isPR := issue.PullRequest != nil
+		if !issue.CreatedAt.Before(since) {
+			openedInWindow[issue.Number] = issue
+			if isPR {
+				activity.PullRequestsOpened++
+			} else {
+				activity.IssuesOpened++
+			}
+		}
+		if issue.ClosedAt != nil && !issue.ClosedAt.Before(since) {
+			if isPR {
+				activity.PullRequestsClosed++
+				if issue.PullRequest.MergedAt != nil {
+					activity.PullRequestsMerged++
+				}
+			} else {
+				activity.IssuesClosed++
+			}
+		}
+	}
+
+	if activity.PullRequestsClosed > 0 {
+		activity.MergeRate = float64(activity.PullRequestsMerged) / float64(activity.PullRequestsClosed)
+	}
+
+	// Comments are sorted by creation time, so the first non-author comment seen is the first response.
+	firstResponse := make(map[int]time.Duration)
+	for _, comment := range comments {
+		number, err := issueNumberFromURL(comment.IssueURL)
+		if err != nil {
+			continue
+		}
+		issue, ok := openedInWindow[number]
+		if !ok || comment.User.Login == issue.User.Login {
+			continue
+		}
+		if _, seen := firstResponse[number]; !seen {
+			firstResponse[number] = comment.CreatedAt.Sub(issue.CreatedAt)
+		}
+	}
+
+	var responseTimes []float64
+	for _, d := range firstResponse {
+		responseTimes = append(responseTimes, d.Seconds())
+	}
+	activity.MedianFirstResponseSeconds = median(responseTimes)
+
+	return activity
+}
+
+func issueNumberFromURL(issueURL string) (int, error) {
+	idx := strings.LastIndex(issueURL, "/")
+	if idx < 0 {
+		return 0, fmt.Errorf("invalid issue URL: %s", issueURL)
+	}
+	return strconv.Atoi(issueURL[idx+1:])
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
@@ -3,19 +3,29 @@ package github
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	models "github.com/teomiscia/github-trending/internal/models"
 )
 
+// ErrNotFound is returned when the GitHub API responds with 404 Not Found.
+var ErrNotFound = errors.New("not found")
+
 const (
 	defaultGitHubAPIURL = "https://api.github.com"
 	// You might want to make this configurable or more dynamic
 	defaultSearchQuery = "stars:>50"
+	// defaultRequestDelay is the pause after each successful request.
+	defaultRequestDelay = 2 * time.Second
+	// maxCachedReleases bounds the number of latest releases kept for conditional
+	// requests.
+	maxCachedReleases = 100000
 )
 
 // GitHubClient provides methods for interacting with the GitHub API.
@@ -23,6 +33,20 @@ type GitHubClient struct {
 	httpClient *http.Client
 	token      string
 	baseURL    string // Add this field
+	// requestDelay is the pause after each successful request, keeping the crawl
+	// under the secondary rate limits.
+	requestDelay time.Duration
+
+	// releasesMu guards releases, the latest release of each repository with the
+	// ETag it was served with.
+	releasesMu sync.Mutex
+	releases   map[string]cachedRelease
+}
+
+// cachedRelease is a latest release together with the ETag of its response.
+type cachedRelease struct {
+	etag    string
+	release *models.Release
 }
 
 // NewGitHubClient creates a new GitHubClient.
@@ -31,9 +55,11 @@ func NewGitHubClient(token string, httpClient *http.Client) *GitHubClient {
 		httpClient = &http.Client{Timeout: 10 * time.Second}
 	}
 	return &GitHubClient{
-		httpClient: httpClient,
-		token:      token,
-		baseURL:    defaultGitHubAPIURL, // Initialize with default
+		httpClient:   httpClient,
+		token:        token,
+		baseURL:      defaultGitHubAPIURL, // Initialize with default
+		requestDelay: defaultRequestDelay,
+		releases:     make(map[string]cachedRelease),
 	}
 }
 
@@ -42,6 +68,11 @@ func (c *GitHubClient) SetBaseURL(url string) {
 	c.baseURL = url
 }
 
+// SetRequestDelay sets the pause after each successful request. Useful for testing.
+func (c *GitHubClient) SetRequestDelay(delay time.Duration) {
+	c.requestDelay = delay
+}
+
 // GithubOwner represents the owner of a repository as returned by the GitHub API.
 type GithubOwner struct {
 	Login     string  `json:"login"`
@@ -252,7 +283,7 @@ func (c *GitHubClient) SearchRepositories(query string, page int) (*SearchReposi
 			Items:             modelRepos,
 		}
 
-		time.Sleep(2 * time.Second) // Wait 2 seconds between successful requests
+		time.Sleep(c.requestDelay) // Wait between successful requests
 		return &searchResp, nil
 	}
 }
@@ -317,7 +348,7 @@ func (c *GitHubClient) GetTags(repoFullName string) ([]string, error) {
 			tagNames = append(tagNames, tag.Name)
 		}
 
-		time.Sleep(2 * time.Second) // Wait 2 seconds between successful requests
+		time.Sleep(c.requestDelay) // Wait between successful requests
 		return tagNames, nil
 	}
 }
@@ -369,28 +400,128 @@ func (c *GitHubClient) GetLanguages(repoFullName string) (map[string]int, error)
 			return nil, fmt.Errorf("failed to unmarshal response for %s: %w", repoFullName, err)
 		}
 
-		time.Sleep(2 * time.Second) // Wait 2 seconds between successful requests
+		time.Sleep(c.requestDelay) // Wait between successful requests
 		return languages, nil
 	}
 }
 
-// GetReadme fetches the README for a repository.
-func (c *GitHubClient) GetReadme(repoFullName string) (string, error) {
+// Readme is a repository README resolved through the GitHub readme endpoint, which
+// picks the preferred README regardless of its name, extension or directory.
+type Readme struct {
+	Path        string
+	DownloadURL string
+	// Content is nil when the API did not inline the file (files over 1 MB);
+	// callers should fall back to DownloadURL.
+	Content []byte
+}
+
+// GetReadme fetches the README for a repository. It returns ErrNotFound when the
+// repository has no README.
+func (c *GitHubClient) GetReadme(repoFullName string) (*Readme, error) {
+	var entry ContentEntry
+	if err := c.getJSON(fmt.Sprintf("/repos/%s/readme", repoFullName), repoFullName, &entry); err != nil {
+		return nil, err
+	}
+
+	readme := &Readme{Path: entry.Path, DownloadURL: entry.DownloadURL}
+	if entry.Encoding != "none" {
+		content, err := decodeContent(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode README for %s: %w", repoFullName, err)
+		}
+		readme.Content = content
+	}
+	return readme, nil
+}
+
+type githubRelease struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	HTMLURL     string    `json:"html_url"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// GetLatestRelease fetches the latest published release of a repository, ignoring
+// drafts and prereleases. It returns nil when the repository has no release.
+// Requests are conditional on the ETag of the previous response, so an unchanged
+// release costs neither rate limit nor request delay.
+func (c *GitHubClient) GetLatestRelease(repoFullName string) (*models.Release, error) {
+	c.releasesMu.Lock()
+	cached, ok := c.releases[repoFullName]
+	c.releasesMu.Unlock()
+
+	var release githubRelease
+	etag, notModified, err := c.getJSONIfNoneMatch(fmt.Sprintf("/repos/%s/releases/latest", repoFullName), repoFullName, cached.etag, &release)
+	if errors.Is(err, ErrNotFound) {
+		c.cacheRelease(repoFullName, cachedRelease{})
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if notModified && ok {
+		return cached.release, nil
+	}
+
+	latest := &models.Release{
+		TagName:     release.TagName,
+		Name:        release.Name,
+		HTMLURL:     release.HTMLURL,
+		PublishedAt: release.PublishedAt,
+	}
+	c.cacheRelease(repoFullName, cachedRelease{etag: etag, release: latest})
+	return latest, nil
+}
+
+// cacheRelease records the latest release of a repository, evicting an arbitrary
+// entry when the cache is full. An entry without an ETag is dropped.
+func (c *GitHubClient) cacheRelease(repoFullName string, entry cachedRelease) {
+	c.releasesMu.Lock()
+	defer c.releasesMu.Unlock()
+	if entry.etag == "" {
+		delete(c.releases, repoFullName)
+		return
+	}
+	if _, ok := c.releases[repoFullName]; !ok && len(c.releases) >= maxCachedReleases {
+		for name := range c.releases {
+			delete(c.releases, name)
+			break
+		}
+	}
+	c.releases[repoFullName] = entry
+}
+
+// getJSON performs an authenticated GET against the GitHub API and decodes the
+// JSON response into v. It applies the same rate-limit backoff as the other
+// client methods.
+func (c *GitHubClient) getJSON(path string, repoFullName string, v interface{}) error {
+	_, _, err := c.getJSONIfNoneMatch(path, repoFullName, "", v)
+	return err
+}
+
+// getJSONIfNoneMatch is getJSON with a conditional request. When etag still
+// matches, GitHub answers 304 Not Modified, which does not count against the rate
+// limit: v is left untouched, notModified is true and there is no request delay.
+// It returns the ETag of the response.
+func (c *GitHubClient) getJSONIfNoneMatch(path string, repoFullName string, etag string, v interface{}) (string, bool, error) {
 	backoffTime := 5 * time.Second
 	for {
-		req, err := http.NewRequest("GET", fmt.Sprintf("%s/repos/%s/readme", c.baseURL, repoFullName), nil)
+		req, err := http.NewRequest("GET", c.baseURL+path, nil)
 		if err != nil {
-			return "", fmt.Errorf("failed to create request: %w", err)
+			return "", false, fmt.Errorf("failed to create request: %w", err)
 		}
 
 		req.Header.Set("Accept", "application/vnd.github.v3+json")
 		if c.token != "" {
 			req.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
 		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
 
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
-			return "", fmt.Errorf("failed to perform request: %w", err)
+			return "", false, fmt.Errorf("failed to perform request: %w", err)
 		}
 
 		if resp.StatusCode == http.StatusForbidden {
@@ -401,29 +532,26 @@ func (c *GitHubClient) GetReadme(repoFullName string) (string, error) {
 			continue
 		}
 
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotModified && etag != "" {
+			return etag, true, nil
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return "", false, fmt.Errorf("%s%s: %w", c.baseURL, path, ErrNotFound)
+		}
 		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
-			return "", fmt.Errorf("GitHub API returned non-200 status for %s: %d - %s", repoFullName, resp.StatusCode, string(bodyBytes))
+			return "", false, fmt.Errorf("GitHub API returned non-200 status for %s: %d - %s", repoFullName, resp.StatusCode, string(bodyBytes))
 		}
-
-		backoffTime = 5 * time.Second // Reset backoff time on success
-
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			resp.Body.Close()
-			return "", fmt.Errorf("failed to read response body for %s: %w", repoFullName, err)
+			return "", false, fmt.Errorf("failed to read response body for %s: %w", repoFullName, err)
 		}
-		resp.Body.Close()
 
-		var readme struct {
-			DownloadURL string `json:"download_url"`
-		}
-		if err := json.Unmarshal(bodyBytes, &readme); err != nil {
-			return "", fmt.Errorf("failed to unmarshal response for %s: %w", repoFullName, err)
+		if err := json.Unmarshal(bodyBytes, v); err != nil {
+			return "", false, fmt.Errorf("failed to unmarshal response for %s: %w", repoFullName, err)
 		}
 
-		time.Sleep(2 * time.Second) // Wait 2 seconds between successful requests
-		return readme.DownloadURL, nil
+		time.Sleep(c.requestDelay) // Wait between successful requests
+		return resp.Header.Get("ETag"), false, nil
 	}
 }
@@ -0,0 +1,62 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetLatestReleaseConditional(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/repos/acme/rocket/releases/latest" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if requests > 1 {
+			if got := r.Header.Get("If-None-Match"); got != `"v1"` {
+				t.Errorf("request %d: If-None-Match = %q", requests, got)
+			}
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"tag_name":"v1.0.0","name":"One","html_url":"https://github.com/acme/rocket/releases/v1.0.0","published_at":"2024-05-01T10:00:00Z"}`)
+	}))
+	defer server.Close()
+
+	client := NewGitHubClient("", server.Client())
+	client.SetBaseURL(server.URL)
+	client.SetRequestDelay(0)
+
+	for i := 0; i < 2; i++ {
+		release, err := client.GetLatestRelease("acme/rocket")
+		if err != nil {
+			t.Fatalf("GetLatestRelease returned an error: %v", err)
+		}
+		if release == nil || release.TagName != "v1.0.0" || !release.PublishedAt.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+			t.Errorf("call %d: unexpected release %+v", i, release)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestGetLatestReleaseNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	client := NewGitHubClient("", server.Client())
+	client.SetBaseURL(server.URL)
+	client.SetRequestDelay(0)
+
+	release, err := client.GetLatestRelease("acme/rocket")
+	if err != nil || release != nil {
+		t.Errorf("GetLatestRelease = %+v, %v; want nil, nil", release, err)
+	}
+}
@@ -0,0 +1,61 @@
+package github
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ContentEntry is a single file or directory returned by the contents API.
+type ContentEntry struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Type        string `json:"type"`
+	Size        int    `json:"size"`
+	SHA         string `json:"sha"`
+	Encoding    string `json:"encoding"`
+	Content     string `json:"content"`
+	DownloadURL string `json:"download_url"`
+}
+
+// ListDirectory lists the entries of a directory in a repository at the given ref.
+// An empty dir lists the repository root.
+func (c *GitHubClient) ListDirectory(repoFullName, dir, ref string) ([]ContentEntry, error) {
+	path := fmt.Sprintf("/repos/%s/contents/%s?ref=%s", repoFullName, strings.TrimPrefix(dir, "/"), url.QueryEscape(ref))
+	var entries []ContentEntry
+	if err := c.getJSON(path, repoFullName, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetFileContent fetches and decodes a file from a repository at the given ref.
+func (c *GitHubClient) GetFileContent(repoFullName, filePath, ref string) ([]byte, error) {
+	path := fmt.Sprintf("/repos/%s/contents/%s?ref=%s", repoFullName, strings.TrimPrefix(filePath, "/"), url.QueryEscape(ref))
+	var entry ContentEntry
+	if err := c.getJSON(path, repoFullName, &entry); err != nil {
+		return nil, err
+	}
+	return decodeContent(entry)
+}
+
+// decodeContent decodes the content field of a contents API response.
+func decodeContent(entry ContentEntry) ([]byte, error) {
+	switch entry.Encoding {
+	case "base64":
+		// GitHub wraps base64 content at 60 characters.
+		data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(entry.Content, "\n", ""))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode content of %s: %w", entry.Path, err)
+		}
+		return data, nil
+	case "":
+		return []byte(entry.Content), nil
+	case "none":
+		// Files larger than 1 MB are not inlined by the contents API.
+		return nil, fmt.Errorf("content of %s is too large to be returned inline", entry.Path)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q for %s", entry.Encoding, entry.Path)
+	}
+}
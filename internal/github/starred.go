@@ -0,0 +1,156 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	models "github.com/teomiscia/github-trending/internal/models"
+)
+
+// ErrRateLimited is returned when the rate limit is exhausted for longer than the
+// client is willing to wait.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+const (
+	starredPerPage = 100
+	// maxRateLimitWait is the longest ListStarredRepositories waits for the rate limit
+	// to reset.
+	maxRateLimitWait = 15 * time.Minute
+)
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// StarredRepository is a repository starred by a user.
+type StarredRepository struct {
+	Repository models.Repository
+	StarredAt  time.Time
+}
+
+var nextLinkRe = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// ListStarredRepositories returns up to limit repositories starred by login, most
+// recently starred first. An empty login lists the stars of the token's user. Pages
+// are followed through the Link header. When the rate limit runs out, it waits for the
+// reset if that is at most maxRateLimitWait away, and otherwise returns the stars
+// fetched so far with ErrRateLimited.
+func (c *GitHubClient) ListStarredRepositories(login string, limit int) ([]StarredRepository, error) {
+	path := "/user/starred"
+	if login != "" {
+		path = "/users/" + url.PathEscape(login) + "/starred"
+	}
+	next := fmt.Sprintf("%s%s?per_page=%d&sort=created&direction=desc", c.baseURL, path, starredPerPage)
+
+	var starred []StarredRepository
+	for next != "" && len(starred) < limit {
+		page, header, err := c.getStarredPage(next)
+		if err != nil {
+			return starred, err
+		}
+		for _, item := range page {
+			if len(starred) == limit {
+				break
+			}
+			starred = append(starred, StarredRepository{Repository: toModelsRepository(item.Repo), StarredAt: item.StarredAt})
+		}
+
+		next = ""
+		if m := nextLinkRe.FindStringSubmatch(header.Get("Link")); m != nil {
+			next = m[1]
+		}
+		// Wait for the reset before asking for the next page rather than hit the limit.
+		if next != "" && header.Get("X-RateLimit-Remaining") == "0" {
+			wait, _ := rateLimitWait(header, time.Now())
+			if wait > maxRateLimitWait {
+				return starred, fmt.Errorf("%w: resets in %v", ErrRateLimited, wait)
+			}
+			log.Printf("Rate limit exhausted while listing stars. Waiting %v for the reset...", wait)
+			sleep(wait)
+		}
+	}
+	return starred, nil
+}
+
+type githubStarredItem struct {
+	StarredAt time.Time        `json:"starred_at"`
+	Repo      GithubRepository `json:"repo"`
+}
+
+// getStarredPage fetches one page of stars, waiting for rate limits to reset.
+func (c *GitHubClient) getStarredPage(pageURL string) ([]githubStarredItem, http.Header, error) {
+	for {
+		req, err := http.NewRequest("GET", pageURL, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		// The star media type adds the time each repository was starred.
+		req.Header.Set("Accept", "application/vnd.github.star+json")
+		if c.token != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to perform request: %w", err)
+		}
+		bodyBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+			if wait, limited := rateLimitWait(resp.Header, time.Now()); limited {
+				if wait > maxRateLimitWait {
+					return nil, nil, fmt.Errorf("%w: resets in %v", ErrRateLimited, wait)
+				}
+				log.Printf("Rate limit hit while listing stars. Waiting %v before retrying...", wait)
+				sleep(wait)
+				continue
+			}
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil, fmt.Errorf("%s: %w", pageURL, ErrNotFound)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, nil, fmt.Errorf("GitHub API returned non-200 status for stars: %d - %s", resp.StatusCode, string(bodyBytes))
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+
+		var page []githubStarredItem
+		if err := json.Unmarshal(bodyBytes, &page); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal stars: %w", err)
+		}
+		return page, resp.Header, nil
+	}
+}
+
+// rateLimitWait reports whether a response says the rate limit is exhausted, and how
+// long to wait before retrying: the Retry-After of secondary rate limits, or the time
+// until X-RateLimit-Reset.
+func rateLimitWait(header http.Header, now time.Time) (time.Duration, bool) {
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Minute, true
+	}
+	wait := time.Unix(reset, 0).Sub(now) + time.Second
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
@@ -0,0 +1,109 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestListStarredRepositories(t *testing.T) {
+	var waited time.Duration
+	sleep = func(d time.Duration) { waited += d }
+	defer func() { sleep = time.Sleep }()
+
+	var requests int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/users/octocat/starred" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Accept") != "application/vnd.github.star+json" {
+			t.Errorf("unexpected Accept header %q", r.Header.Get("Accept"))
+		}
+		// The second request hits a secondary rate limit once.
+		if n == 2 {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		page := r.URL.Query().Get("page")
+		switch page {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/users/octocat/starred?page=2>; rel="next", <%s/users/octocat/starred?page=3>; rel="last"`, server.URL, server.URL))
+			fmt.Fprint(w, `[{"starred_at":"2024-05-02T10:00:00Z","repo":{"id":1,"full_name":"a/one"}},{"starred_at":"2024-05-01T10:00:00Z","repo":{"id":2,"full_name":"b/two"}}]`)
+		case "2":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/users/octocat/starred?page=3>; rel="next"`, server.URL))
+			fmt.Fprint(w, `[{"starred_at":"2024-04-01T10:00:00Z","repo":{"id":3,"full_name":"c/three"}}]`)
+		default:
+			fmt.Fprint(w, `[{"starred_at":"2024-03-01T10:00:00Z","repo":{"id":4,"full_name":"d/four"}}]`)
+		}
+	}))
+	defer server.Close()
+
+	client := NewGitHubClient("token", server.Client())
+	client.SetBaseURL(server.URL)
+
+	starred, err := client.ListStarredRepositories("octocat", 10)
+	if err != nil {
+		t.Fatalf("ListStarredRepositories returned an error: %v", err)
+	}
+	if len(starred) != 4 || starred[0].Repository.FullName != "a/one" || starred[3].Repository.ID != 4 {
+		t.Fatalf("unexpected stars %+v", starred)
+	}
+	if !starred[1].StarredAt.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected starred_at %v", starred[1].StarredAt)
+	}
+	if waited != 3*time.Second {
+		t.Errorf("expected to wait out Retry-After, waited %v", waited)
+	}
+
+	atomic.StoreInt32(&requests, 10)
+	starred, err = client.ListStarredRepositories("octocat", 1)
+	if err != nil || len(starred) != 1 {
+		t.Errorf("the limit should stop the listing, got %d stars, %v", len(starred), err)
+	}
+}
+
+func TestListStarredRepositoriesRateLimited(t *testing.T) {
+	sleep = func(time.Duration) { t.Error("should not wait for a distant reset") }
+	defer func() { sleep = time.Sleep }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewGitHubClient("", server.Client())
+	client.SetBaseURL(server.URL)
+	if _, err := client.ListStarredRepositories("", 10); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Unix(1000, 0)
+	header := http.Header{}
+	if _, limited := rateLimitWait(header, now); limited {
+		t.Error("a response without rate limit headers is not rate limited")
+	}
+
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset", "1060")
+	if wait, limited := rateLimitWait(header, now); !limited || wait != 61*time.Second {
+		t.Errorf("rateLimitWait() = %v, %v, want 61s", wait, limited)
+	}
+
+	header.Set("Retry-After", "5")
+	if wait, limited := rateLimitWait(header, now); !limited || wait != 5*time.Second {
+		t.Errorf("Retry-After should win, got %v, %v", wait, limited)
+	}
+}
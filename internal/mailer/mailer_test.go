@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+func newTestMailer(t *testing.T, sent *string) *Mailer {
+	m := New("localhost", "1025", "", "", "alerts@example.com")
+	m.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "localhost:1025" || a != nil || from != "alerts@example.com" || len(to) != 1 || to[0] != "dev@example.com" {
+			t.Errorf("unexpected SMTP parameters %s, %v, %s, %v", addr, a, from, to)
+		}
+		*sent = string(msg)
+		return nil
+	}
+	return m
+}
+
+func TestSendPlainText(t *testing.T) {
+	var sent string
+	m := newTestMailer(t, &sent)
+	if err := m.Send("dev@example.com", Message{Subject: "acme/rocket released v2\r\nBcc: x@example.com", Text: "Released.\n"}); err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+
+	headers, _, _ := strings.Cut(sent, "\r\n\r\n")
+	if !strings.Contains(headers, "Subject: acme/rocket released v2  Bcc: x@example.com\r\n") || strings.Contains(headers, "\r\nBcc:") {
+		t.Errorf("the subject should stay on one line, got headers %q", headers)
+	}
+	if strings.Contains(headers, "List-Unsubscribe") {
+		t.Errorf("no unsubscribe headers expected without an unsubscribe URL, got %q", headers)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(sent))
+	if err != nil {
+		t.Fatalf("failed to parse the email: %v", err)
+	}
+	if msg.Header.Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("unexpected content type %s", msg.Header.Get("Content-Type"))
+	}
+	if body, _ := io.ReadAll(msg.Body); !strings.Contains(string(body), "Released.") {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestSendAlternatives(t *testing.T) {
+	var sent string
+	m := newTestMailer(t, &sent)
+	message := Message{Subject: "Digest", Text: "Top repositories\n", HTML: "<p>Top repositories</p>\n", UnsubscribeURL: "https://api.example.com/unsubscribe?token=tok"}
+	if err := m.Send("dev@example.com", message); err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(sent))
+	if err != nil {
+		t.Fatalf("failed to parse the email: %v", err)
+	}
+	if msg.Header.Get("List-Unsubscribe") != "<https://api.example.com/unsubscribe?token=tok>" || msg.Header.Get("List-Unsubscribe-Post") != "List-Unsubscribe=One-Click" {
+		t.Errorf("unexpected unsubscribe headers %v", msg.Header)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("unexpected content type %s, %v", mediaType, err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{{"text/plain; charset=utf-8", message.Text}, {"text/html; charset=utf-8", message.HTML}} {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("missing %s part: %v", want.contentType, err)
+		}
+		// NextPart decodes quoted-printable, which encoded line breaks as CRLF.
+		body, _ := io.ReadAll(part)
+		if part.Header.Get("Content-Type") != want.contentType || strings.ReplaceAll(string(body), "\r\n", "\n") != want.body {
+			t.Errorf("unexpected %s part", want.contentType)
+		}
+	}
+}
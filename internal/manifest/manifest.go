@@ -0,0 +1,423 @@
+// Package manifest extracts declared dependencies from well-known package manifests.
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// Ecosystem identifiers stored alongside each dependency.
+const (
+	EcosystemGo    = "go"
+	EcosystemNPM   = "npm"
+	EcosystemCargo = "cargo"
+	EcosystemPyPI  = "pypi"
+)
+
+// KnownFiles lists the manifest file names that Parse understands.
+var KnownFiles = []string{"go.mod", "package.json", "Cargo.toml", "pyproject.toml", "requirements.txt"}
+
+// IsManifest reports whether the base name of filePath is a known manifest.
+func IsManifest(filePath string) bool {
+	base := path.Base(filePath)
+	for _, name := range KnownFiles {
+		if base == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse extracts the dependencies declared in the manifest at filePath.
+func Parse(filePath string, content []byte) ([]models.Dependency, error) {
+	var deps []models.Dependency
+	var err error
+	switch path.Base(filePath) {
+	case "go.mod":
+		deps, err = parseGoMod(content)
+	case "package.json":
+		deps, err = parsePackageJSON(content)
+	case "Cargo.toml":
+		deps = parseCargoToml(content)
+	case "pyproject.toml":
+		deps = parsePyproject(content)
+	case "requirements.txt":
+		deps = parseRequirements(content)
+	default:
+		return nil, fmt.Errorf("unsupported manifest: %s", filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+
+	for i := range deps {
+		deps[i].Manifest = filePath
+	}
+	return Dedupe(deps), nil
+}
+
+// Dedupe removes repeated dependencies, keeping the first occurrence of each
+// ecosystem and name pair. A runtime declaration wins over a dev-only one.
+func Dedupe(deps []models.Dependency) []models.Dependency {
+	index := make(map[string]int)
+	result := make([]models.Dependency, 0, len(deps))
+	for _, dep := range deps {
+		key := dep.Ecosystem + ":" + dep.Name
+		if i, ok := index[key]; ok {
+			if result[i].Dev && !dep.Dev {
+				result[i] = dep
+			}
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, dep)
+	}
+	return result
+}
+
+func parseGoMod(content []byte) ([]models.Dependency, error) {
+	var deps []models.Dependency
+	inRequire := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		indirect := strings.HasSuffix(line, "// indirect")
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case inRequire:
+			if fields[0] == ")" {
+				inRequire = false
+				continue
+			}
+		case fields[0] == "require" && len(fields) == 2 && fields[1] == "(":
+			inRequire = true
+			continue
+		case fields[0] == "require(":
+			inRequire = true
+			continue
+		case fields[0] == "require":
+			fields = fields[1:]
+		default:
+			continue
+		}
+
+		// Indirect requirements are transitive, not declared by the project itself.
+		if len(fields) < 2 || indirect {
+			continue
+		}
+		deps = append(deps, models.Dependency{Ecosystem: EcosystemGo, Name: fields[0], Version: fields[1]})
+	}
+	return deps, scanner.Err()
+}
+
+func parsePackageJSON(content []byte) ([]models.Dependency, error) {
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return nil, err
+	}
+
+	var deps []models.Dependency
+	for _, group := range []struct {
+		entries map[string]string
+		dev     bool
+	}{{pkg.Dependencies, false}, {pkg.DevDependencies, true}} {
+		names := make([]string, 0, len(group.entries))
+		for name := range group.entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			deps = append(deps, models.Dependency{Ecosystem: EcosystemNPM, Name: strings.ToLower(name), Version: group.entries[name], Dev: group.dev})
+		}
+	}
+	return deps, nil
+}
+
+func parseCargoToml(content []byte) []models.Dependency {
+	var deps []models.Dependency
+	// Dotted tables such as [dependencies.serde] carry the version inside the table body.
+	tableCrates := make(map[string]int)
+
+	for _, entry := range scanTOML(content) {
+		section, crate := cargoSection(entry.table)
+		if section == "" {
+			continue
+		}
+		dev := section == "dev-dependencies"
+
+		if crate != "" {
+			i, ok := tableCrates[entry.table]
+			if !ok {
+				i = len(deps)
+				tableCrates[entry.table] = i
+				deps = append(deps, models.Dependency{Ecosystem: EcosystemCargo, Name: normalizeCrateName(crate), Dev: dev})
+			}
+			switch entry.key {
+			case "version":
+				deps[i].Version = unquote(entry.value)
+			case "package":
+				deps[i].Name = normalizeCrateName(unquote(entry.value))
+			}
+			continue
+		}
+
+		dep := models.Dependency{Ecosystem: EcosystemCargo, Name: normalizeCrateName(entry.key), Dev: dev}
+		if strings.HasPrefix(entry.value, "{") {
+			fields := inlineTable(entry.value)
+			dep.Version = fields["version"]
+			if pkg, ok := fields["package"]; ok {
+				dep.Name = normalizeCrateName(pkg)
+			}
+		} else {
+			dep.Version = unquote(entry.value)
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+// cargoSection classifies a Cargo.toml table header. It returns the dependency section
+// name, or "" for unrelated tables, and the crate name for dotted dependency tables.
+func cargoSection(table string) (section, crate string) {
+	for _, name := range []string{"dependencies", "dev-dependencies", "build-dependencies"} {
+		switch {
+		case table == name, strings.HasSuffix(table, "."+name):
+			return name, ""
+		case strings.HasPrefix(table, name+"."):
+			return name, strings.TrimPrefix(table, name+".")
+		}
+	}
+	return "", ""
+}
+
+func normalizeCrateName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+func parsePyproject(content []byte) []models.Dependency {
+	var deps []models.Dependency
+	for _, entry := range scanTOML(content) {
+		switch {
+		case entry.table == "project" && entry.key == "dependencies":
+			for _, req := range quotedStrings(entry.value) {
+				if dep, ok := parsePEP508(req); ok {
+					deps = append(deps, dep)
+				}
+			}
+		case entry.table == "project.optional-dependencies":
+			// Optional extras are not needed at runtime, so they are treated like dev dependencies.
+			for _, req := range quotedStrings(entry.value) {
+				if dep, ok := parsePEP508(req); ok {
+					dep.Dev = true
+					deps = append(deps, dep)
+				}
+			}
+		case entry.table == "tool.poetry.dependencies",
+			entry.table == "tool.poetry.dev-dependencies",
+			strings.HasPrefix(entry.table, "tool.poetry.group.") && strings.HasSuffix(entry.table, ".dependencies"):
+			if entry.key == "python" {
+				continue
+			}
+			version := unquote(entry.value)
+			if strings.HasPrefix(entry.value, "{") {
+				version = inlineTable(entry.value)["version"]
+			}
+			deps = append(deps, models.Dependency{
+				Ecosystem: EcosystemPyPI,
+				Name:      normalizePythonName(entry.key),
+				Version:   version,
+				Dev:       entry.table != "tool.poetry.dependencies",
+			})
+		}
+	}
+	return deps
+}
+
+func parseRequirements(content []byte) []models.Dependency {
+	var deps []models.Dependency
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		// Skip comments and pip options such as -r, -e and --index-url.
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if dep, ok := parsePEP508(line); ok {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+var (
+	pep508NameRe      = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?\s*(.*)$`)
+	pythonNameSepRe   = regexp.MustCompile(`[-_.]+`)
+	quotedStringRe    = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"|'([^']*)'`)
+	inlineTableItemRe = regexp.MustCompile(`([A-Za-z0-9_-]+)\s*=\s*("(?:[^"\\]|\\.)*"|'[^']*'|[^,}]+)`)
+)
+
+// parsePEP508 parses a requirement specifier such as "requests[socks]>=2.0; python_version>'3'".
+func parsePEP508(req string) (models.Dependency, bool) {
+	if i := strings.Index(req, ";"); i >= 0 {
+		req = req[:i]
+	}
+	m := pep508NameRe.FindStringSubmatch(strings.TrimSpace(req))
+	if m == nil {
+		return models.Dependency{}, false
+	}
+	version := strings.TrimSpace(m[3])
+	if strings.HasPrefix(version, "@") {
+		// Direct URL references carry no version constraint.
+		version = ""
+	}
+	version = strings.Trim(version, "()")
+	return models.Dependency{Ecosystem: EcosystemPyPI, Name: normalizePythonName(m[1]), Version: version}, true
+}
+
+// normalizePythonName applies the PEP 503 normalisation rules.
+func normalizePythonName(name string) string {
+	return pythonNameSepRe.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// tomlEntry is a key/value pair together with the table it belongs to.
+type tomlEntry struct {
+	table string
+	key   string
+	value string
+}
+
+// scanTOML is a minimal TOML reader that understands tables, key/value pairs and
+// multi-line arrays. It is only intended for the subset of TOML used by manifests.
+func scanTOML(content []byte) []tomlEntry {
+	var entries []tomlEntry
+	table := ""
+	var pending *tomlEntry
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+
+		if pending != nil {
+			pending.value += " " + line
+			if balanced(pending.value) {
+				entries = append(entries, *pending)
+				pending = nil
+			}
+			continue
+		}
+
+		if line == "" {
+			continue
+		}
+		// Keys cannot start with a bracket, so this is always a table header.
+		if strings.HasPrefix(line, "[") {
+			table = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			continue
+		}
+		entry := tomlEntry{
+			table: table,
+			key:   unquote(strings.TrimSpace(line[:eq])),
+			value: strings.TrimSpace(line[eq+1:]),
+		}
+		if !balanced(entry.value) {
+			pending = &entry
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// stripTOMLComment removes a trailing # comment that is not inside a string.
+func stripTOMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// balanced reports whether every opening bracket or brace in value outside strings is closed.
+func balanced(value string) bool {
+	depth := 0
+	var quote rune
+	for _, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '[' || r == '{':
+			depth++
+		case r == ']' || r == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func quotedStrings(value string) []string {
+	var result []string
+	for _, m := range quotedStringRe.FindAllStringSubmatch(value, -1) {
+		if m[1] != "" {
+			result = append(result, m[1])
+		} else if m[2] != "" {
+			result = append(result, m[2])
+		}
+	}
+	return result
+}
+
+// inlineTable returns the scalar fields of a TOML inline table such as { version = "1", path = ".." }.
+func inlineTable(value string) map[string]string {
+	fields := make(map[string]string)
+	for _, m := range inlineTableItemRe.FindAllStringSubmatch(value, -1) {
+		fields[m[1]] = unquote(strings.TrimSpace(m[2]))
+	}
+	return fields
+}
@@ -0,0 +1,109 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func TestParseFixtures(t *testing.T) {
+	tests := []struct {
+		file string
+		want []models.Dependency
+	}{
+		{
+			file: "go.mod",
+			want: []models.Dependency{
+				{Ecosystem: EcosystemGo, Name: "github.com/spf13/cobra", Version: "v1.8.0"},
+				{Ecosystem: EcosystemGo, Name: "github.com/gin-gonic/gin", Version: "v1.10.1"},
+				{Ecosystem: EcosystemGo, Name: "github.com/lib/pq", Version: "v1.10.9"},
+			},
+		},
+		{
+			file: "package.json",
+			want: []models.Dependency{
+				{Ecosystem: EcosystemNPM, Name: "@tanstack/react-query", Version: "~5.0.0"},
+				{Ecosystem: EcosystemNPM, Name: "react", Version: "^18.2.0"},
+				{Ecosystem: EcosystemNPM, Name: "typescript", Version: "5.4.2", Dev: true},
+			},
+		},
+		{
+			file: "Cargo.toml",
+			want: []models.Dependency{
+				{Ecosystem: EcosystemCargo, Name: "serde", Version: "1.0"},
+				{Ecosystem: EcosystemCargo, Name: "tokio", Version: "1.36"},
+				{Ecosystem: EcosystemCargo, Name: "log-helper"},
+				{Ecosystem: EcosystemCargo, Name: "rand-core-next", Version: "0.6"},
+				{Ecosystem: EcosystemCargo, Name: "clap", Version: "4.5"},
+				{Ecosystem: EcosystemCargo, Name: "criterion", Version: "0.5", Dev: true},
+				{Ecosystem: EcosystemCargo, Name: "winapi", Version: "0.3"},
+			},
+		},
+		{
+			file: "pyproject.toml",
+			want: []models.Dependency{
+				{Ecosystem: EcosystemPyPI, Name: "requests", Version: ">=2.31"},
+				{Ecosystem: EcosystemPyPI, Name: "typing-extensions"},
+				{Ecosystem: EcosystemPyPI, Name: "numpy", Version: "==1.26.*"},
+				{Ecosystem: EcosystemPyPI, Name: "pytest", Version: ">=8", Dev: true},
+				{Ecosystem: EcosystemPyPI, Name: "httpx", Version: "^0.27"},
+				{Ecosystem: EcosystemPyPI, Name: "ruff", Version: "^0.4", Dev: true},
+			},
+		},
+		{
+			file: "requirements.txt",
+			want: []models.Dependency{
+				{Ecosystem: EcosystemPyPI, Name: "flask", Version: "==3.0.2"},
+				{Ecosystem: EcosystemPyPI, Name: "gunicorn", Version: ">=21"},
+				{Ecosystem: EcosystemPyPI, Name: "zope-interface"},
+				{Ecosystem: EcosystemPyPI, Name: "my-lib"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+
+			got, err := Parse(tt.file, content)
+			if err != nil {
+				t.Fatalf("Parse returned an error: %v", err)
+			}
+
+			for i := range tt.want {
+				tt.want[i].Manifest = tt.file
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unexpected dependencies for %s:\n got: %+v\nwant: %+v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUnsupportedManifest(t *testing.T) {
+	if _, err := Parse("pom.xml", nil); err == nil {
+		t.Error("Expected an error for an unsupported manifest")
+	}
+	if IsManifest("docs/pom.xml") {
+		t.Error("pom.xml should not be reported as a known manifest")
+	}
+	if !IsManifest("backend/go.mod") {
+		t.Error("backend/go.mod should be reported as a known manifest")
+	}
+}
+
+func TestDedupePrefersRuntimeDependency(t *testing.T) {
+	deps := Dedupe([]models.Dependency{
+		{Ecosystem: EcosystemPyPI, Name: "pytest", Dev: true, Manifest: "pyproject.toml"},
+		{Ecosystem: EcosystemPyPI, Name: "pytest", Manifest: "requirements.txt"},
+	})
+	if len(deps) != 1 || deps[0].Dev || deps[0].Manifest != "requirements.txt" {
+		t.Errorf("Expected the runtime declaration to win, got %+v", deps)
+	}
+}
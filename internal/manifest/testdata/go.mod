@@ -0,0 +1,13 @@
+module github.com/example/tool
+
+go 1.22
+
+require github.com/spf13/cobra v1.8.0
+
+require (
+	github.com/gin-gonic/gin v1.10.1
+	github.com/lib/pq v1.10.9 // pinned for pg 17
+	golang.org/x/sys v0.34.0 // indirect
+)
+
+replace github.com/lib/pq => ../pq
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Digest frequencies.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestSubscription is an email address receiving a digest of the top trending
+// repositories, optionally restricted to some languages and topics.
+type DigestSubscription struct {
+	ID        int64    `json:"id"`
+	SessionID string   `json:"-"`
+	Email     string   `json:"email"`
+	Frequency string   `json:"frequency"`
+	Languages []string `json:"languages"`
+	Topics    []string `json:"topics"`
+	// UnsubscribeToken identifies the subscription in the unsubscribe links of its
+	// emails.
+	UnsubscribeToken string `json:"-"`
+	// Confirmed is set once the owner of the email address confirmed the subscription
+	// through the link emailed to it; digests are only sent to confirmed subscriptions.
+	Confirmed  bool       `json:"confirmed"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
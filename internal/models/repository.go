@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"sort"
 	"time"
 )
 
@@ -22,11 +23,79 @@ type CrawlResult struct {
 	CrawledAt    time.Time  `json:"crawled_at"`
 }
 
+// RepositoryWrittenMessage is published by the writer once a crawled repository has
+// been stored, so that watches on it can be evaluated.
+type RepositoryWrittenMessage struct {
+	Repository Repository `json:"repository"`
+	// Previous is the state stored before this write; nil for a new repository.
+	Previous  *RepositorySnapshot `json:"previous,omitempty"`
+	WrittenAt time.Time           `json:"written_at"`
+}
+
+// RepositorySnapshot is the state of a repository the writer compares a crawl against.
+type RepositorySnapshot struct {
+	// Stars is nil for repositories stored before stars were recorded.
+	Stars            *int      `json:"stars,omitempty"`
+	Archived         bool      `json:"archived"`
+	LatestReleaseTag string    `json:"latest_release_tag,omitempty"`
+	LastCrawledAt    time.Time `json:"last_crawled_at"`
+}
+
 // ReadmeEmbedMessage is the message that triggers README embedding.
 type ReadmeEmbedMessage struct {
 	RepositoryID int64  `json:"repository_id"`
 	MinioPath    string `json:"minio_path"`
 	DownloadURL  string `json:"download_url"`
+	// ContentHash is the SHA-256 of the README; the embedding service skips READMEs
+	// whose hash matches the one last embedded.
+	ContentHash string `json:"content_hash,omitempty"`
+	// Format is the README format (see package readme), used to find section
+	// headings when chunking. Empty means markdown.
+	Format string `json:"format,omitempty"`
+	// Attempts counts earlier deliveries that failed with a transient error.
+	Attempts int `json:"attempts,omitempty"`
+	// Attributes are stored in the payload of the repository's vectors. Messages
+	// without them keep the payload already stored.
+	Attributes *VectorAttributes `json:"attributes,omitempty"`
+}
+
+// EmbeddingUpdatedMessage is published by the embedding service once a repository's
+// vectors have been written, so that its similarity list can be recomputed.
+type EmbeddingUpdatedMessage struct {
+	RepositoryID int64 `json:"repository_id"`
+	// Version is the embedding version the vectors were written at.
+	Version int `json:"version"`
+}
+
+// VectorAttributes are the repository attributes stored alongside its vectors in
+// Qdrant, so that vector searches can filter on them.
+type VectorAttributes struct {
+	Language  string    `json:"language,omitempty"`
+	Languages []string  `json:"languages,omitempty"`
+	Topics    []string  `json:"topics,omitempty"`
+	Stars     int       `json:"stars"`
+	Archived  bool      `json:"archived"`
+	Fork      bool      `json:"fork"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewVectorAttributes extracts the vector payload attributes of a crawled repository.
+func NewVectorAttributes(repo Repository) VectorAttributes {
+	languages := make([]string, 0, len(repo.Languages))
+	for language := range repo.Languages {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	return VectorAttributes{
+		Language:  repo.Language.String,
+		Languages: languages,
+		Topics:    repo.Topics,
+		Stars:     repo.StargazersCount,
+		Archived:  repo.Archived,
+		Fork:      repo.Fork,
+		CreatedAt: repo.CreatedAt,
+	}
 }
 
 type Repository struct {
@@ -66,10 +135,29 @@ type Repository struct {
 	AllowForking    bool           `json:"allow_forking"`
 	IsTemplate      bool           `json:"is_template"`
 	ReadmeURL       sql.NullString `json:"readme_url"`
+	ReadmePath      sql.NullString `json:"readme_path"`
+	ReadmeFormat    sql.NullString `json:"readme_format"`
 	Tags            []string       `json:"tags"`
 	Languages       map[string]int `json:"languages"`
 	LastCrawledAt   time.Time      `json:"last_crawled_at"`
 	LastUpdatedAt   time.Time      `json:"last_updated_at"`
+
+	// Activity is collected by the crawler and stored in ClickHouse by the writer.
+	Activity *RepositoryActivity `json:"activity,omitempty"`
+	// Dependencies are extracted from manifests by the processor. A nil slice means
+	// the manifests were not inspected and existing rows should be left untouched.
+	Dependencies []Dependency `json:"dependencies"`
+	// LatestRelease is collected by the crawler; nil when the repository has no
+	// release or it could not be fetched.
+	LatestRelease *Release `json:"latest_release,omitempty"`
+}
+
+// Release is a published GitHub release.
+type Release struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name,omitempty"`
+	HTMLURL     string    `json:"html_url"`
+	PublishedAt time.Time `json:"published_at"`
 }
 
 type RepositoryData struct {
@@ -120,3 +208,61 @@ type License struct {
 	URL    sql.NullString `json:"url"`
 	NodeID sql.NullString `json:"node_id"`
 }
+
+// RepositoryActivity summarises issue and pull request activity over a time window.
+// Unlike OpenIssuesCount, issues and pull requests are counted separately.
+type RepositoryActivity struct {
+	EventDate                  time.Time `json:"event_date"`
+	EventTime                  time.Time `json:"event_time"`
+	WindowDays                 int       `json:"window_days"`
+	IssuesOpened               int       `json:"issues_opened"`
+	IssuesClosed               int       `json:"issues_closed"`
+	PullRequestsOpened         int       `json:"pull_requests_opened"`
+	PullRequestsClosed         int       `json:"pull_requests_closed"`
+	PullRequestsMerged         int       `json:"pull_requests_merged"`
+	MedianFirstResponseSeconds float64   `json:"median_first_response_seconds"`
+	MergeRate                  float64   `json:"merge_rate"`
+}
+
+// Dependency is a package declared in one of a repository's dependency manifests.
+type Dependency struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Manifest  string `json:"manifest"`
+	Dev       bool   `json:"dev"`
+}
+
+// Types of UserEvent.
+const (
+	EventImpression    = "impression"
+	EventDismiss       = "dismiss"
+	EventNotInterested = "not_interested"
+	EventStarClick     = "star_click"
+	EventReadmeDwell   = "readme_dwell"
+)
+
+// UserEvent is a feedback event sent by the app about a repository.
+type UserEvent struct {
+	SessionID    string `json:"session_id"`
+	RepositoryID int64  `json:"repository_id"`
+	Type         string `json:"type"`
+	// DwellMs is the time spent reading the README, for readme_dwell events.
+	DwellMs   int       `json:"dwell_ms,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// IsNegative reports whether the event means the user does not want to see the
+// repository.
+func (e UserEvent) IsNegative() bool {
+	return e.Type == EventDismiss || e.Type == EventNotInterested
+}
+
+// RepositoryGrowth is the growth of a repository's stars and forks over a period.
+type RepositoryGrowth struct {
+	RepositoryID int64 `json:"repository_id"`
+	Stars        int64 `json:"stars"`
+	StarsGained  int64 `json:"stars_gained"`
+	Forks        int64 `json:"forks"`
+	ForksGained  int64 `json:"forks_gained"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of the similarity scorers, as used in weight specifications.
+const (
+	ScorerCosine       = "cosine"
+	ScorerLanguages    = "languages"
+	ScorerTopics       = "topics"
+	ScorerDependencies = "dependencies"
+	ScorerCoViews      = "coviews"
+)
+
+// DefaultSimilarityWeights is the similarity blend used when none is configured.
+const DefaultSimilarityWeights = "cosine=0.6,topics=0.3,languages=0.1"
+
+var scorerNames = []string{ScorerCosine, ScorerLanguages, ScorerTopics, ScorerDependencies, ScorerCoViews}
+
+// RepositoryView is a session's view of a repository.
+type RepositoryView struct {
+	SessionID    string
+	RepositoryID int64
+	ViewedAt     time.Time
+}
+
+// ParseSimilarityWeights parses a weight specification such as
+// "cosine=0.6,topics=0.4". Every name must be one of the scorer names and every
+// weight a non-negative number.
+func ParseSimilarityWeights(spec string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid weight %q, expected name=weight", part)
+		}
+		name = strings.TrimSpace(name)
+		if !isScorerName(name) {
+			return nil, fmt.Errorf("unknown scorer %q", name)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+			return nil, fmt.Errorf("invalid weight for %s: %q", name, value)
+		}
+		weights[name] = weight
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("no weights in %q", spec)
+	}
+	return weights, nil
+}
+
+func isScorerName(name string) bool {
+	for _, n := range scorerNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package models
+
+import "testing"
+
+func TestParseSimilarityWeights(t *testing.T) {
+	weights, err := ParseSimilarityWeights(" cosine=0.5, topics = 0.3 ,coviews=0.2,")
+	if err != nil {
+		t.Fatalf("ParseSimilarityWeights returned an error: %v", err)
+	}
+	want := map[string]float64{ScorerCosine: 0.5, ScorerTopics: 0.3, ScorerCoViews: 0.2}
+	if len(weights) != len(want) {
+		t.Fatalf("ParseSimilarityWeights = %v, want %v", weights, want)
+	}
+	for name, weight := range want {
+		if weights[name] != weight {
+			t.Errorf("weight of %s = %v, want %v", name, weights[name], weight)
+		}
+	}
+
+	for _, spec := range []string{"", "cosine", "stars=1", "cosine=-1", "cosine=abc"} {
+		if _, err := ParseSimilarityWeights(spec); err == nil {
+			t.Errorf("ParseSimilarityWeights(%q) should fail", spec)
+		}
+	}
+
+	if _, err := ParseSimilarityWeights(DefaultSimilarityWeights); err != nil {
+		t.Errorf("DefaultSimilarityWeights does not parse: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// User is an account created by logging in with GitHub.
+type User struct {
+	ID          int64     `json:"id"`
+	GitHubID    int64     `json:"github_id"`
+	Login       string    `json:"login"`
+	Name        string    `json:"name,omitempty"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	// StarsImportedAt is the last time the user's GitHub stars were imported.
+	StarsImportedAt *time.Time `json:"stars_imported_at,omitempty"`
+}
+
+// StarredRepository is a repository a user starred on GitHub.
+type StarredRepository struct {
+	RepositoryID int64     `json:"repository_id"`
+	StarredAt    time.Time `json:"starred_at"`
+}
+
+// Collection is a named list of repositories saved by a session or user.
+type Collection struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// ShareToken identifies the collection's public link; empty when not shared.
+	ShareToken string    `json:"share_token,omitempty"`
+	ItemCount  int       `json:"item_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CollectionItem is a repository saved in a collection.
+type CollectionItem struct {
+	RepositoryID int64     `json:"repository_id"`
+	Position     int       `json:"position"`
+	Note         string    `json:"note,omitempty"`
+	AddedAt      time.Time `json:"added_at"`
+}
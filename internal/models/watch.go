@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Watch kinds: a repository by full name, every repository of an owner, or the
+// repositories of a topic.
+const (
+	WatchRepository = "repository"
+	WatchOwner      = "owner"
+	WatchTopic      = "topic"
+)
+
+// Notification kinds.
+const (
+	NotificationStarMilestone = "star_milestone"
+	NotificationRelease       = "release"
+	NotificationArchived      = "archived"
+	NotificationTrending      = "trending"
+)
+
+// Watch is a repository, owner or topic a session is notified about. Email and
+// WebhookURL are optional deliveries on top of the in-app notifications; the email
+// address only receives notifications once it is confirmed.
+type Watch struct {
+	ID             int64  `json:"id"`
+	SessionID      string `json:"-"`
+	Kind           string `json:"kind"`
+	Target         string `json:"target"`
+	Email          string `json:"email,omitempty"`
+	EmailConfirmed bool   `json:"email_confirmed"`
+	// EmailToken identifies the watch's email address in the confirmation and
+	// unsubscribe links.
+	EmailToken string    `json:"-"`
+	WebhookURL string    `json:"webhook_url,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Notification is an event raised by a watch.
+type Notification struct {
+	ID           int64  `json:"id"`
+	WatchID      int64  `json:"watch_id"`
+	RepositoryID int64  `json:"repository_id"`
+	Kind         string `json:"kind"`
+	// EventKey identifies the event among the watch's notifications about the
+	// repository, e.g. "stars:1000", so that an event is only notified once.
+	EventKey  string     `json:"-"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	URL       string     `json:"url,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
@@ -0,0 +1,147 @@
+package models
+
+import "time"
+
+// Webhook event types.
+const (
+	EventTrendingEntered  = "trending.entered"
+	EventTrendingLeft     = "trending.left"
+	EventAnomalyStarSpike = "anomaly.star_spike"
+	EventReleasePublished = "release.published"
+)
+
+// Webhook delivery statuses. A delivery is pending while queued, sending while being
+// sent, retrying while it waits for its next attempt, and failed once its attempts are
+// exhausted.
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending"
+	DeliveryRetrying  = "retrying"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint registered to receive events. Empty filters match every
+// event; otherwise an event must match one value of each non-empty filter.
+type Webhook struct {
+	ID          int64  `json:"id"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+	// Secret signs the deliveries; it is only returned when the webhook is created.
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Languages  []string  `json:"languages"`
+	Topics     []string  `json:"topics"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookEvent is an event about a repository sent to the webhooks. It is published to
+// the webhook_events queue by the services that detect it, and POSTed as is.
+type WebhookEvent struct {
+	// ID is set once the event is stored.
+	ID   int64  `json:"id,omitempty"`
+	Type string `json:"type"`
+	// Key identifies the event so that one detected twice is only delivered once,
+	// e.g. "release:42:v1.0.0".
+	Key        string          `json:"key"`
+	Repository EventRepository `json:"repository"`
+	Release    *Release        `json:"release,omitempty"`
+	Trending   *TrendingChange `json:"trending,omitempty"`
+	StarSpike  *StarSpike      `json:"star_spike,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// EventRepository is the summary of a repository sent with webhook events.
+type EventRepository struct {
+	ID          int64    `json:"id"`
+	FullName    string   `json:"full_name"`
+	HTMLURL     string   `json:"html_url"`
+	Description string   `json:"description,omitempty"`
+	Language    string   `json:"language,omitempty"`
+	Topics      []string `json:"topics"`
+	Stars       int      `json:"stars"`
+}
+
+// NewEventRepository summarises a repository for webhook events. Repositories read
+// back from the database have no primary language, so the language with the most
+// bytes stands in for it.
+func NewEventRepository(repo Repository) EventRepository {
+	topics := repo.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+	language := repo.Language.String
+	if language == "" {
+		size := 0
+		for name, bytes := range repo.Languages {
+			if bytes > size || (bytes == size && name < language) {
+				language, size = name, bytes
+			}
+		}
+	}
+	return EventRepository{
+		ID:          int64(repo.ID),
+		FullName:    repo.FullName,
+		HTMLURL:     repo.HTMLURL,
+		Description: repo.Description.String,
+		Language:    language,
+		Topics:      topics,
+		Stars:       repo.StargazersCount,
+	}
+}
+
+// TrendingChange describes a repository entering or leaving the trending list.
+type TrendingChange struct {
+	// Rank is the 1-based position of a repository entering the list.
+	Rank int `json:"rank,omitempty"`
+	Days int `json:"days"`
+}
+
+// StarWindow is the star count of a repository now, a day ago and a week before that.
+type StarWindow struct {
+	RepositoryID int64
+	Latest       int64
+	DayAgo       int64
+	WeekAgo      int64
+}
+
+// StarSpike is a repository gaining stars much faster than it usually does.
+type StarSpike struct {
+	StarsGained int64 `json:"stars_gained"`
+	// DailyBaseline is the average daily gain over the previous week.
+	DailyBaseline float64 `json:"daily_baseline"`
+}
+
+// WebhookDelivery is an event delivered, or to be delivered, to a webhook.
+type WebhookDelivery struct {
+	ID            int64      `json:"id"`
+	WebhookID     int64      `json:"webhook_id"`
+	EventID       int64      `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	// Attempts are only loaded with a single delivery.
+	Log []WebhookDeliveryAttempt `json:"log,omitempty"`
+}
+
+// WebhookDeliveryAttempt is the log of one attempt at a delivery.
+type WebhookDeliveryAttempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	// Response is the beginning of the response body.
+	Response   string `json:"response,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// WebhookDeliveryMessage is the message queued for each delivery to send.
+type WebhookDeliveryMessage struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// Store stores watches and their notifications.
+type Store interface {
+	GetMatchingWatches(fullName, ownerLogin string, topics []string) ([]models.Watch, error)
+	// InsertNotification returns false if the watch already raised the event.
+	InsertNotification(n *models.Notification) (bool, error)
+}
+
+// TrendingChecker reports when a repository entered the trending list, or zero if it
+// is not trending.
+type TrendingChecker interface {
+	TrendingSince(repoID int64) (time.Time, error)
+}
+
+// Engine evaluates repository write events against watches, stores the notifications
+// they raise and delivers them through the notifiers.
+type Engine struct {
+	store     Store
+	trending  TrendingChecker
+	notifiers []Notifier
+}
+
+// NewEngine creates an Engine.
+func NewEngine(store Store, trending TrendingChecker, notifiers ...Notifier) *Engine {
+	return &Engine{store: store, trending: trending, notifiers: notifiers}
+}
+
+// Handle processes a repository write event. Notifications are stored before they are
+// delivered and an event already notified is skipped, so a redelivered event does not
+// notify twice. Delivery failures are logged: the notification stays in the app.
+func (e *Engine) Handle(ctx context.Context, event models.RepositoryWrittenMessage) error {
+	repo := event.Repository
+	watches, err := e.store.GetMatchingWatches(repo.FullName, repo.Owner.Login, repo.Topics)
+	if err != nil {
+		return fmt.Errorf("failed to find watches on %s: %w", repo.FullName, err)
+	}
+	if len(watches) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]models.Watch, len(watches))
+	watchesTopic := false
+	for _, watch := range watches {
+		byID[watch.ID] = watch
+		watchesTopic = watchesTopic || watch.Kind == models.WatchTopic
+	}
+	// Only topic watches are notified of trending repositories.
+	var trendingSince time.Time
+	if watchesTopic {
+		if trendingSince, err = e.trending.TrendingSince(int64(repo.ID)); err != nil {
+			log.Printf("Failed to check whether %s is trending: %v", repo.FullName, err)
+			trendingSince = time.Time{}
+		}
+	}
+
+	for _, n := range Evaluate(event, watches, trendingSince) {
+		created, err := e.store.InsertNotification(&n)
+		if err != nil {
+			return fmt.Errorf("failed to store notification %s of %s: %w", n.EventKey, repo.FullName, err)
+		}
+		if !created {
+			continue
+		}
+		for _, notifier := range e.notifiers {
+			if err := notifier.Notify(ctx, byID[n.WatchID], n); err != nil {
+				log.Printf("Failed to deliver notification %d: %v", n.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// TrendingCache checks repositories against a trending list, with the times they
+// entered it, fetched at most once per TTL.
+type TrendingCache struct {
+	fetch func() (map[int64]time.Time, error)
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	entries   map[int64]time.Time
+	fetchedAt time.Time
+}
+
+// NewTrendingCache creates a TrendingCache over the list returned by fetch.
+func NewTrendingCache(fetch func() (map[int64]time.Time, error), ttl time.Duration) *TrendingCache {
+	return &TrendingCache{fetch: fetch, ttl: ttl}
+}
+
+// TrendingSince returns when the repository entered the trending list, or zero if it
+// is not in it.
+func (c *TrendingCache) TrendingSince(repoID int64) (time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || time.Since(c.fetchedAt) > c.ttl {
+		entries, err := c.fetch()
+		if err != nil {
+			return time.Time{}, err
+		}
+		c.entries = entries
+		c.fetchedAt = time.Now()
+	}
+	return c.entries[repoID], nil
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+type fakeStore struct {
+	watches []models.Watch
+	stored  map[string]bool
+}
+
+func (s *fakeStore) GetMatchingWatches(fullName, ownerLogin string, topics []string) ([]models.Watch, error) {
+	return s.watches, nil
+}
+
+func (s *fakeStore) InsertNotification(n *models.Notification) (bool, error) {
+	key := fmt.Sprintf("%d/%d/%s", n.WatchID, n.RepositoryID, n.EventKey)
+	if s.stored[key] {
+		return false, nil
+	}
+	s.stored[key] = true
+	n.ID = int64(len(s.stored))
+	return true, nil
+}
+
+type fakeTrending struct {
+	calls int
+	err   error
+}
+
+func (f *fakeTrending) TrendingSince(repoID int64) (time.Time, error) {
+	f.calls++
+	if f.err != nil {
+		return time.Time{}, f.err
+	}
+	return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+type fakeNotifier struct {
+	delivered []models.Notification
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, watch models.Watch, n models.Notification) error {
+	if watch.ID != n.WatchID {
+		return errors.New("notification delivered for the wrong watch")
+	}
+	f.delivered = append(f.delivered, n)
+	return nil
+}
+
+func TestEngineHandle(t *testing.T) {
+	store := &fakeStore{
+		watches: []models.Watch{
+			{ID: 1, Kind: models.WatchOwner, Target: "acme"},
+			{ID: 2, Kind: models.WatchTopic, Target: "cli"},
+		},
+		stored: make(map[string]bool),
+	}
+	trending := &fakeTrending{}
+	notifier := &fakeNotifier{}
+	engine := NewEngine(store, trending, notifier)
+
+	event := models.RepositoryWrittenMessage{
+		Repository: models.Repository{ID: 7, FullName: "acme/rocket", Owner: models.Owner{Login: "acme"}, Archived: true, Topics: []string{"cli"}},
+		Previous:   &models.RepositorySnapshot{},
+	}
+	if err := engine.Handle(context.Background(), event); err != nil {
+		t.Fatalf("Handle returned an error: %v", err)
+	}
+	if len(notifier.delivered) != 2 || trending.calls != 1 {
+		t.Fatalf("expected the archive and trending notifications, got %+v", notifier.delivered)
+	}
+
+	// A redelivered event is not notified again.
+	if err := engine.Handle(context.Background(), event); err != nil {
+		t.Fatalf("Handle returned an error: %v", err)
+	}
+	if len(notifier.delivered) != 2 {
+		t.Errorf("redelivered event notified again: %+v", notifier.delivered)
+	}
+
+	// Failing to check the trending list only drops the trending notification.
+	store.stored = make(map[string]bool)
+	notifier.delivered = nil
+	trending.err = errors.New("postgres is down")
+	if err := engine.Handle(context.Background(), event); err != nil {
+		t.Fatalf("Handle returned an error: %v", err)
+	}
+	if len(notifier.delivered) != 1 || notifier.delivered[0].Kind != models.NotificationArchived {
+		t.Errorf("unexpected notifications %+v", notifier.delivered)
+	}
+}
+
+func TestTrendingCache(t *testing.T) {
+	fetches := 0
+	enteredAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewTrendingCache(func() (map[int64]time.Time, error) {
+		fetches++
+		return map[int64]time.Time{1: enteredAt, 2: enteredAt}, nil
+	}, time.Hour)
+
+	if since, err := cache.TrendingSince(2); err != nil || !since.Equal(enteredAt) {
+		t.Errorf("TrendingSince(2) = %v, %v", since, err)
+	}
+	if since, _ := cache.TrendingSince(3); !since.IsZero() || fetches != 1 {
+		t.Errorf("TrendingSince(3) = %v after %d fetches", since, fetches)
+	}
+}
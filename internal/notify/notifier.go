@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/mailer"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// Notifier delivers notifications outside the app. Notifiers skip watches that did
+// not ask for their delivery.
+type Notifier interface {
+	Notify(ctx context.Context, watch models.Watch, notification models.Notification) error
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(to string, message mailer.Message) error
+}
+
+// EmailNotifier emails notifications to the watches' confirmed email address.
+type EmailNotifier struct {
+	mailer       Mailer
+	publicAPIURL string
+}
+
+// NewEmailNotifier creates an EmailNotifier. publicAPIURL is the API's public URL,
+// which emails link to for unsubscribing.
+func NewEmailNotifier(mailer Mailer, publicAPIURL string) *EmailNotifier {
+	return &EmailNotifier{mailer: mailer, publicAPIURL: publicAPIURL}
+}
+
+// Notify emails the notification if the watch has a confirmed email address. Every
+// email links to the removal of the address from the watch.
+func (n *EmailNotifier) Notify(ctx context.Context, watch models.Watch, notification models.Notification) error {
+	if watch.Email == "" || !watch.EmailConfirmed {
+		return nil
+	}
+	unsubscribeURL := n.publicAPIURL + "/watches/unsubscribe?token=" + url.QueryEscape(watch.EmailToken)
+
+	var text strings.Builder
+	text.WriteString(notification.Body + "\n")
+	if notification.URL != "" {
+		text.WriteString("\n" + notification.URL + "\n")
+	}
+	fmt.Fprintf(&text, "\nYou are receiving this because you watch the %s %s.\n", watch.Kind, watch.Target)
+	fmt.Fprintf(&text, "Stop these emails: %s\n", unsubscribeURL)
+
+	return n.mailer.Send(watch.Email, mailer.Message{Subject: notification.Title, Text: text.String(), UnsubscribeURL: unsubscribeURL})
+}
+
+// WebhookNotifier POSTs notifications as JSON to the watches' webhook URL.
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a WebhookNotifier. A nil client uses a client with a
+// 10 second timeout that only connects to public addresses, since webhook URLs are
+// given by users.
+func NewWebhookNotifier(client *http.Client) *WebhookNotifier {
+	if client == nil {
+		dialer := &net.Dialer{Timeout: 5 * time.Second, Control: publicAddressesOnly}
+		client = &http.Client{
+			Timeout: 10 * time.Second,
+			// No proxy, so that the checked address is the one the request goes to.
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second},
+		}
+	}
+	return &WebhookNotifier{client: client}
+}
+
+// errForbiddenAddress is returned when dialing an address webhooks may not reach.
+var errForbiddenAddress = errors.New("address is not public")
+
+// carrierGradeNAT holds the shared address space, which some clouds use for their
+// metadata service.
+var carrierGradeNAT = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddressesOnly is a net.Dialer Control function refusing connections to
+// loopback, private, link-local (including the cloud metadata service at
+// 169.254.169.254), shared, multicast and unspecified addresses. It runs on the
+// resolved address of every connection, redirects included, so DNS names pointing to
+// internal addresses are refused too.
+func publicAddressesOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errForbiddenAddress, address)
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || carrierGradeNAT.Contains(ip) {
+		return fmt.Errorf("%w: %s", errForbiddenAddress, address)
+	}
+	return nil
+}
+
+// WebhookPayload is the body of webhook deliveries.
+type WebhookPayload struct {
+	Watch        models.Watch        `json:"watch"`
+	Notification models.Notification `json:"notification"`
+}
+
+// Notify POSTs the notification if the watch has a webhook URL. Any response other
+// than 2xx is an error.
+func (n *WebhookNotifier) Notify(ctx context.Context, watch models.Watch, notification models.Notification) error {
+	if watch.WebhookURL == "" {
+		return nil
+	}
+	body, err := json.Marshal(WebhookPayload{Watch: watch, Notification: notification})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, watch.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "github-trending-notifications")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook %s: %w", watch.WebhookURL, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", watch.WebhookURL, resp.StatusCode)
+	}
+	return nil
+}
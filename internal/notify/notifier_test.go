@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/teomiscia/github-trending/internal/mailer"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// fakeMailer records the emails sent.
+type fakeMailer struct {
+	to       []string
+	messages []mailer.Message
+}
+
+func (m *fakeMailer) Send(to string, message mailer.Message) error {
+	m.to = append(m.to, to)
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func TestEmailNotifier(t *testing.T) {
+	sent := &fakeMailer{}
+	notifier := NewEmailNotifier(sent, "https://api.example.com")
+
+	notification := models.Notification{Title: "acme/rocket released v2", Body: "Released.", URL: "https://github.com/acme/rocket"}
+	for _, skipped := range []models.Watch{
+		{Kind: models.WatchRepository, Target: "acme/rocket"},
+		{Kind: models.WatchRepository, Target: "acme/rocket", Email: "dev@example.com", EmailToken: "tok"},
+	} {
+		if err := notifier.Notify(context.Background(), skipped, notification); err != nil || len(sent.to) != 0 {
+			t.Fatalf("watches without a confirmed email should be skipped, got %v, %v", err, sent.to)
+		}
+	}
+
+	watch := models.Watch{Kind: models.WatchRepository, Target: "acme/rocket", Email: "dev@example.com", EmailConfirmed: true, EmailToken: "tok"}
+	if err := notifier.Notify(context.Background(), watch, notification); err != nil {
+		t.Fatalf("Notify returned an error: %v", err)
+	}
+	if len(sent.to) != 1 || sent.to[0] != "dev@example.com" {
+		t.Fatalf("unexpected recipients %v", sent.to)
+	}
+	message := sent.messages[0]
+	unsubscribeURL := "https://api.example.com/watches/unsubscribe?token=tok"
+	if message.Subject != notification.Title || message.UnsubscribeURL != unsubscribeURL {
+		t.Errorf("unexpected message %+v", message)
+	}
+	for _, want := range []string{"Released.", "https://github.com/acme/rocket", "watch the repository acme/rocket", unsubscribeURL} {
+		if !strings.Contains(message.Text, want) {
+			t.Errorf("the email should contain %q, got %q", want, message.Text)
+		}
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload WebhookPayload
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.Client())
+	watch := models.Watch{ID: 3, Kind: models.WatchTopic, Target: "cli", WebhookURL: server.URL}
+	notification := models.Notification{ID: 9, Kind: models.NotificationTrending, Title: "acme/rocket is trending"}
+	if err := notifier.Notify(context.Background(), watch, notification); err != nil {
+		t.Fatalf("Notify returned an error: %v", err)
+	}
+	if payload.Watch.ID != 3 || payload.Notification.ID != 9 || payload.Notification.Kind != models.NotificationTrending {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+
+	status = http.StatusInternalServerError
+	if err := notifier.Notify(context.Background(), watch, notification); err == nil {
+		t.Error("a failed delivery should return an error")
+	}
+}
+
+func TestWebhookNotifierRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the webhook should not reach a loopback address")
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(nil)
+	watch := models.Watch{ID: 3, Kind: models.WatchTopic, Target: "cli", WebhookURL: server.URL}
+	if err := notifier.Notify(context.Background(), watch, models.Notification{ID: 9}); !errors.Is(err, errForbiddenAddress) {
+		t.Errorf("Notify() = %v, want %v", err, errForbiddenAddress)
+	}
+
+	for address, allowed := range map[string]bool{
+		"93.184.216.34:443":           true,
+		"[2606:4700::1111]:443":       true,
+		"127.0.0.1:80":                false,
+		"10.0.0.5:80":                 false,
+		"172.16.3.4:80":               false,
+		"192.168.1.1:80":              false,
+		"169.254.169.254:80":          false,
+		"100.100.100.200:80":          false,
+		"0.0.0.0:80":                  false,
+		"[::1]:80":                    false,
+		"[fd00:ec2::254]:80":          false,
+		"[fe80::1]:80":                false,
+		"[::ffff:127.0.0.1]:80":       false,
+		"[::ffff:169.254.169.254]:80": false,
+	} {
+		if err := publicAddressesOnly("tcp", address, nil); (err == nil) != allowed {
+			t.Errorf("publicAddressesOnly(%s) = %v, want allowed %v", address, err, allowed)
+		}
+	}
+}
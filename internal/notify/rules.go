@@ -0,0 +1,120 @@
+// Package notify evaluates watches against repository write events and delivers the
+// notifications they raise.
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// StarMilestones are the star counts whose crossing is notified.
+var StarMilestones = []int{100, 500, 1000, 5000, 10000, 25000, 50000, 100000}
+
+// Evaluate returns the notifications a repository write raises for the watches on the
+// repository. Changes are only notified against a previous state, so the first write
+// of a repository raises no star, release or archive notification. trendingSince is
+// when the repository entered the trending list, which topic watches are notified of,
+// or zero if it is not trending.
+func Evaluate(event models.RepositoryWrittenMessage, watches []models.Watch, trendingSince time.Time) []models.Notification {
+	repo := event.Repository
+	var repoEvents, topicEvents []models.Notification
+
+	if prev := event.Previous; prev != nil {
+		if prev.Stars != nil {
+			for _, milestone := range StarMilestones {
+				if *prev.Stars < milestone && repo.StargazersCount >= milestone {
+					repoEvents = append(repoEvents, models.Notification{
+						Kind:     models.NotificationStarMilestone,
+						EventKey: fmt.Sprintf("stars:%d", milestone),
+						Title:    fmt.Sprintf("%s passed %d stars", repo.FullName, milestone),
+						Body:     fmt.Sprintf("%s now has %d stars.", repo.FullName, repo.StargazersCount),
+						URL:      repo.HTMLURL,
+					})
+				}
+			}
+		}
+
+		if release := NewRelease(event); release != nil {
+			name := release.TagName
+			if release.Name != "" && release.Name != release.TagName {
+				name = fmt.Sprintf("%s (%s)", release.Name, release.TagName)
+			}
+			repoEvents = append(repoEvents, models.Notification{
+				Kind:     models.NotificationRelease,
+				EventKey: "release:" + release.TagName,
+				Title:    fmt.Sprintf("%s released %s", repo.FullName, release.TagName),
+				Body:     fmt.Sprintf("%s published the release %s.", repo.FullName, name),
+				URL:      release.HTMLURL,
+			})
+		}
+
+		if repo.Archived && !prev.Archived {
+			repoEvents = append(repoEvents, models.Notification{
+				Kind:     models.NotificationArchived,
+				EventKey: "archived",
+				Title:    fmt.Sprintf("%s was archived", repo.FullName),
+				Body:     fmt.Sprintf("%s is now archived and read-only.", repo.FullName),
+				URL:      repo.HTMLURL,
+			})
+		}
+	}
+
+	if !trendingSince.IsZero() {
+		topicEvents = append(topicEvents, models.Notification{
+			Kind:     models.NotificationTrending,
+			EventKey: trendingEventKey(trendingSince),
+			Title:    fmt.Sprintf("%s is trending", repo.FullName),
+			URL:      repo.HTMLURL,
+		})
+	}
+
+	var notifications []models.Notification
+	for _, watch := range watches {
+		events := repoEvents
+		if watch.Kind == models.WatchTopic {
+			if !hasTopic(repo, watch.Target) {
+				continue
+			}
+			events = topicEvents
+		}
+		for _, n := range events {
+			n.WatchID = watch.ID
+			n.RepositoryID = int64(repo.ID)
+			if n.Kind == models.NotificationTrending {
+				n.Body = fmt.Sprintf("%s, a repository in the %s topic, started trending.", repo.FullName, watch.Target)
+			}
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications
+}
+
+// trendingEventKey identifies a trending notification by the time the repository
+// entered the trending list, so that a repository staying on the list is notified once
+// while one leaving it and trending again later is notified again.
+func trendingEventKey(enteredAt time.Time) string {
+	return fmt.Sprintf("trending:%d", enteredAt.Unix())
+}
+
+// NewRelease returns the release a repository write recorded, or nil if the latest
+// release is unchanged. Releases published before the previous crawl were already
+// there when the release was first recorded and are not new.
+func NewRelease(event models.RepositoryWrittenMessage) *models.Release {
+	prev, release := event.Previous, event.Repository.LatestRelease
+	if prev == nil || release == nil || release.TagName == prev.LatestReleaseTag || !release.PublishedAt.After(prev.LastCrawledAt) {
+		return nil
+	}
+	return release
+}
+
+func hasTopic(repo models.Repository, topic string) bool {
+	for _, t := range repo.Topics {
+		if strings.EqualFold(t, topic) {
+			return true
+		}
+	}
+	return false
+}
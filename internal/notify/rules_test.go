@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestEvaluate(t *testing.T) {
+	crawledAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	event := models.RepositoryWrittenMessage{
+		Repository: models.Repository{
+			ID:              7,
+			FullName:        "acme/rocket",
+			HTMLURL:         "https://github.com/acme/rocket",
+			StargazersCount: 1200,
+			Archived:        true,
+			Topics:          []string{"CLI", "go"},
+			LatestRelease:   &models.Release{TagName: "v2.0.0", HTMLURL: "https://github.com/acme/rocket/releases/tag/v2.0.0", PublishedAt: crawledAt.Add(time.Hour)},
+		},
+		Previous:  &models.RepositorySnapshot{Stars: intPtr(450), LatestReleaseTag: "v1.9.0", LastCrawledAt: crawledAt},
+		WrittenAt: crawledAt.Add(2 * time.Hour),
+	}
+	watches := []models.Watch{
+		{ID: 1, Kind: models.WatchRepository, Target: "acme/rocket"},
+		{ID: 2, Kind: models.WatchTopic, Target: "cli"},
+		{ID: 3, Kind: models.WatchTopic, Target: "rust"},
+	}
+
+	got := make(map[int64][]string)
+	enteredAt := crawledAt.Add(-24 * time.Hour)
+	for _, n := range Evaluate(event, watches, enteredAt) {
+		if n.RepositoryID != 7 || n.Title == "" || n.Body == "" {
+			t.Errorf("incomplete notification %+v", n)
+		}
+		got[n.WatchID] = append(got[n.WatchID], n.EventKey)
+	}
+	want := map[int64][]string{
+		1: {"stars:500", "stars:1000", "release:v2.0.0", "archived"},
+		2: {trendingEventKey(enteredAt)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Evaluate() = %v, want %v", got, want)
+	}
+	for id, keys := range want {
+		if len(got[id]) != len(keys) {
+			t.Errorf("watch %d: got %v, want %v", id, got[id], keys)
+			continue
+		}
+		for i := range keys {
+			if got[id][i] != keys[i] {
+				t.Errorf("watch %d: got %v, want %v", id, got[id], keys)
+			}
+		}
+	}
+}
+
+func TestEvaluateWithoutChanges(t *testing.T) {
+	watches := []models.Watch{{ID: 1, Kind: models.WatchOwner, Target: "acme"}}
+	repo := models.Repository{
+		ID:              7,
+		FullName:        "acme/rocket",
+		StargazersCount: 5000,
+		LatestRelease:   &models.Release{TagName: "v1.0.0", PublishedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	// The first write of a repository has nothing to compare against.
+	if n := Evaluate(models.RepositoryWrittenMessage{Repository: repo}, watches, time.Now()); len(n) != 0 {
+		t.Errorf("a new repository should raise no notification, got %+v", n)
+	}
+
+	// Stars unknown before this write, and a release older than the previous crawl
+	// recorded for the first time, are not news.
+	previous := &models.RepositorySnapshot{LastCrawledAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if n := Evaluate(models.RepositoryWrittenMessage{Repository: repo, Previous: previous}, watches, time.Time{}); len(n) != 0 {
+		t.Errorf("expected no notification, got %+v", n)
+	}
+}
+
+func TestTrendingEventKey(t *testing.T) {
+	enteredAt := time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC)
+	if key := trendingEventKey(enteredAt); key != "trending:1748818800" {
+		t.Errorf("trendingEventKey(%v) = %s", enteredAt, key)
+	}
+	// A repository is notified again only once it leaves the list and enters it again.
+	if trendingEventKey(enteredAt) == trendingEventKey(enteredAt.Add(72*time.Hour)) {
+		t.Error("a repository entering the trending list again should be notified again")
+	}
+}
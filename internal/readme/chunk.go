@@ -0,0 +1,224 @@
+package readme
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultChunkSize is the chunk length, in characters, that fits comfortably in the
+// embedding model's context window.
+const DefaultChunkSize = 1000
+
+// maxChunks caps the number of chunks produced for a single README so that huge
+// generated documents cannot flood the embedding API.
+const maxChunks = 64
+
+// Chunk is a section of a README sized for the embedding model.
+type Chunk struct {
+	// Heading is the title of the section the chunk belongs to; it is empty for the
+	// text before the first heading.
+	Heading string
+	// Offset is the byte offset of the chunk's first line in the original README.
+	Offset int
+	// Text is the chunk content, prefixed with its heading so that each chunk carries
+	// its own context when embedded.
+	Text string
+}
+
+var orgBlockRe = regexp.MustCompile(`(?i)^[ \t]*#\+(BEGIN|END)_`)
+
+type chunkLine struct {
+	text   string
+	offset int
+}
+
+type chunkSection struct {
+	heading string
+	lines   []chunkLine
+}
+
+// Chunks splits a README into heading-aware chunks of at most maxChars characters.
+// Sections are cut at headings outside code blocks, long sections are split on blank
+// lines and paragraphs longer than maxChars are split on whitespace.
+func Chunks(format string, content []byte, maxChars int) []Chunk {
+	if maxChars <= 0 {
+		maxChars = DefaultChunkSize
+	}
+
+	var chunks []Chunk
+	for _, section := range splitSections(format, string(content)) {
+		for _, para := range packParagraphs(paragraphs(format, section.lines), maxChars) {
+			text := para.text
+			if section.heading != "" {
+				text = section.heading + "\n\n" + text
+			}
+			chunks = append(chunks, Chunk{Heading: section.heading, Offset: para.offset, Text: text})
+			if len(chunks) == maxChunks {
+				return chunks
+			}
+		}
+	}
+	return chunks
+}
+
+// splitSections groups the README lines under the heading that precedes them.
+// Heading lines themselves are not part of the section body.
+func splitSections(format, text string) []chunkSection {
+	var lines []chunkLine
+	offset := 0
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		lines = append(lines, chunkLine{text: line, offset: offset})
+		offset += len(line) + 1
+	}
+
+	sections := []chunkSection{{}}
+	current := &sections[0]
+	startSection := func(heading string) {
+		sections = append(sections, chunkSection{heading: heading})
+		current = &sections[len(sections)-1]
+	}
+
+	inBlock := false
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i].text
+		next := ""
+		if i+1 < len(lines) {
+			next = lines[i+1].text
+		}
+
+		switch format {
+		case FormatMarkdown:
+			if fence != "" {
+				if strings.HasPrefix(strings.TrimSpace(line), fence) {
+					fence = ""
+				}
+			} else if m := mdFenceRe.FindStringSubmatch(line); m != nil {
+				fence = m[2]
+			} else if m := mdATXRe.FindStringSubmatch(line); m != nil {
+				startSection(strings.TrimSpace(m[2]))
+				continue
+			} else if strings.TrimSpace(line) != "" && mdListRe.FindString(line) == "" && mdSetextRe.MatchString(next) {
+				startSection(strings.TrimSpace(line))
+				i++
+				continue
+			}
+		case FormatAsciiDoc:
+			if strings.HasPrefix(line, "----") || strings.HasPrefix(line, "....") {
+				inBlock = !inBlock
+			} else if m := adocHeadingRe.FindStringSubmatch(line); m != nil && !inBlock {
+				startSection(strings.TrimSpace(m[2]))
+				continue
+			}
+		case FormatOrg:
+			if m := orgBlockRe.FindStringSubmatch(line); m != nil {
+				inBlock = strings.EqualFold(m[1], "BEGIN")
+			} else if m := orgHeadingRe.FindStringSubmatch(line); m != nil && !inBlock {
+				startSection(strings.TrimSpace(m[2]))
+				continue
+			}
+		case FormatRST:
+			if isRSTAdornment(line) {
+				// Overlines and underlines are not content.
+				continue
+			}
+			if strings.TrimSpace(line) != "" && isRSTAdornment(next) && len(strings.TrimSpace(next)) >= len(strings.TrimSpace(line)) {
+				startSection(strings.TrimSpace(line))
+				i++
+				continue
+			}
+		}
+		current.lines = append(current.lines, lines[i])
+	}
+	return sections
+}
+
+type chunkParagraph struct {
+	text   string
+	offset int
+}
+
+// paragraphs splits section lines on blank lines. Blank lines inside markdown code
+// fences do not end a paragraph.
+func paragraphs(format string, lines []chunkLine) []chunkParagraph {
+	var paras []chunkParagraph
+	var buf []string
+	offset := 0
+	fence := ""
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(buf, "\n"))
+		if text != "" {
+			paras = append(paras, chunkParagraph{text: text, offset: offset})
+		}
+		buf = nil
+	}
+
+	for _, line := range lines {
+		if format == FormatMarkdown {
+			if fence != "" {
+				if strings.HasPrefix(strings.TrimSpace(line.text), fence) {
+					fence = ""
+				}
+			} else if m := mdFenceRe.FindStringSubmatch(line.text); m != nil {
+				fence = m[2]
+			}
+		}
+		if fence == "" && strings.TrimSpace(line.text) == "" {
+			flush()
+			continue
+		}
+		if len(buf) == 0 {
+			offset = line.offset
+		}
+		buf = append(buf, line.text)
+	}
+	flush()
+	return paras
+}
+
+// packParagraphs merges consecutive paragraphs into pieces of at most maxChars
+// characters, splitting any paragraph that is longer than that on its own.
+func packParagraphs(paras []chunkParagraph, maxChars int) []chunkParagraph {
+	var packed []chunkParagraph
+	for _, para := range paras {
+		for _, piece := range splitLong(para, maxChars) {
+			if n := len(packed); n > 0 && utf8.RuneCountInString(packed[n-1].text)+2+utf8.RuneCountInString(piece.text) <= maxChars {
+				packed[n-1].text += "\n\n" + piece.text
+				continue
+			}
+			packed = append(packed, piece)
+		}
+	}
+	return packed
+}
+
+// splitLong cuts a paragraph into pieces of at most maxChars characters, preferring
+// to break at whitespace.
+func splitLong(para chunkParagraph, maxChars int) []chunkParagraph {
+	var pieces []chunkParagraph
+	text, offset := para.text, para.offset
+	for utf8.RuneCountInString(text) > maxChars {
+		// Byte index just past the first maxChars runes.
+		cut, runes := len(text), 0
+		for i := range text {
+			if runes == maxChars {
+				cut = i
+				break
+			}
+			runes++
+		}
+		if space := strings.LastIndexFunc(text[:cut], unicode.IsSpace); space > 0 {
+			cut = space
+		}
+		pieces = append(pieces, chunkParagraph{text: strings.TrimSpace(text[:cut]), offset: offset})
+		rest := strings.TrimLeftFunc(text[cut:], unicode.IsSpace)
+		offset += len(text) - len(rest)
+		text = rest
+	}
+	if text != "" {
+		pieces = append(pieces, chunkParagraph{text: text, offset: offset})
+	}
+	return pieces
+}
@@ -0,0 +1,93 @@
+package readme
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunksSplitsOnHeadings(t *testing.T) {
+	content := "Intro text.\n\n# Install\n\nRun `make`.\n\n```sh\n# not a heading\n\nmake install\n```\n\nUsage\n-----\n\nCall it.\n"
+
+	chunks := Chunks(FormatMarkdown, []byte(content), DefaultChunkSize)
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d: %+v", len(chunks), chunks)
+	}
+
+	wantHeadings := []string{"", "Install", "Usage"}
+	for i, want := range wantHeadings {
+		if chunks[i].Heading != want {
+			t.Errorf("Chunk %d heading = %q, want %q", i, chunks[i].Heading, want)
+		}
+	}
+	if !strings.Contains(chunks[1].Text, "# not a heading\n\nmake install") {
+		t.Errorf("Code fence was split or lost: %q", chunks[1].Text)
+	}
+	if !strings.HasPrefix(chunks[2].Text, "Usage\n\n") {
+		t.Errorf("Chunk text should start with its heading: %q", chunks[2].Text)
+	}
+	for _, chunk := range chunks {
+		body := strings.TrimPrefix(chunk.Text, chunk.Heading+"\n\n")
+		firstLine := strings.SplitN(body, "\n", 2)[0]
+		if !strings.HasPrefix(content[chunk.Offset:], firstLine) {
+			t.Errorf("Offset %d does not point at %q", chunk.Offset, firstLine)
+		}
+	}
+}
+
+func TestChunksOtherFormats(t *testing.T) {
+	tests := []struct {
+		format  string
+		content string
+		want    []string
+	}{
+		{FormatAsciiDoc, "= Project\n\nIntro.\n\n== Usage\n\n----\n== not a heading\n----\n", []string{"Project", "Usage"}},
+		{FormatOrg, "* Project\nIntro.\n#+BEGIN_SRC org\n* not a heading\n#+END_SRC\n** Usage\nRun it.\n", []string{"Project", "Usage"}},
+		{FormatRST, "=======\nProject\n=======\n\nIntro.\n\nUsage\n-----\n\nRun it.\n", []string{"Project", "Usage"}},
+		{FormatText, "Plain paragraph.\n\n# Not a heading\n", []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			chunks := Chunks(tt.format, []byte(tt.content), DefaultChunkSize)
+			var got []string
+			for _, chunk := range chunks {
+				got = append(got, chunk.Heading)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Headings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunksSplitsLongSections(t *testing.T) {
+	para := strings.Repeat("wörd ", 50)
+	content := "# Long\n\n" + para + "\n\n" + para + "\n\n" + strings.Repeat("x", 250)
+
+	chunks := Chunks(FormatMarkdown, []byte(content), 200)
+	if len(chunks) < 4 {
+		t.Fatalf("Expected the section to be split, got %d chunks", len(chunks))
+	}
+	for _, chunk := range chunks {
+		body := strings.TrimPrefix(chunk.Text, "Long\n\n")
+		if n := utf8.RuneCountInString(body); n > 200 {
+			t.Errorf("Chunk body has %d characters, want at most 200", n)
+		}
+		if !utf8.ValidString(chunk.Text) {
+			t.Errorf("Chunk split a multi-byte character: %q", chunk.Text)
+		}
+		if chunk.Heading != "Long" {
+			t.Errorf("Chunk heading = %q, want Long", chunk.Heading)
+		}
+	}
+}
+
+func TestChunksCapsCount(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 100; i++ {
+		b.WriteString("## Section\n\ntext\n\n")
+	}
+	if got := len(Chunks(FormatMarkdown, []byte(b.String()), DefaultChunkSize)); got != maxChunks {
+		t.Errorf("Expected %d chunks, got %d", maxChunks, got)
+	}
+}
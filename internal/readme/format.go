@@ -0,0 +1,49 @@
+// Package readme detects README formats, renders them to HTML and sanitises the result.
+package readme
+
+import (
+	"path"
+	"strings"
+)
+
+// Supported README formats, as stored in repositories.readme_format.
+const (
+	FormatMarkdown = "markdown"
+	FormatRST      = "rst"
+	FormatAsciiDoc = "asciidoc"
+	FormatOrg      = "org"
+	FormatText     = "text"
+)
+
+// DetectFormat infers the README format from its file name. Files without a
+// recognised extension are treated as plain text.
+func DetectFormat(filePath string) string {
+	switch strings.ToLower(path.Ext(filePath)) {
+	case ".md", ".markdown", ".mdown", ".mkdn", ".mkd":
+		return FormatMarkdown
+	case ".rst", ".rest":
+		return FormatRST
+	case ".adoc", ".asciidoc", ".asc":
+		return FormatAsciiDoc
+	case ".org":
+		return FormatOrg
+	default:
+		return FormatText
+	}
+}
+
+// ContentType returns the MIME type used when storing a README of the given format.
+func ContentType(format string) string {
+	switch format {
+	case FormatMarkdown:
+		return "text/markdown"
+	case FormatRST:
+		return "text/x-rst"
+	case FormatAsciiDoc:
+		return "text/asciidoc"
+	case FormatOrg:
+		return "text/org"
+	default:
+		return "text/plain"
+	}
+}
@@ -0,0 +1,80 @@
+package readme
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxHighlightLength skips list items too long to be highlights, which are usually
+// whole paragraphs written as lists.
+const maxHighlightLength = 200
+
+var (
+	// highlightHeadingRe matches the headings of the sections presenting a project.
+	highlightHeadingRe = regexp.MustCompile(`(?i)\b(features?|highlights?|why\b|benefits?|overview|capabilities)`)
+	// highlightItemRe matches the list items of all formats: markdown, AsciiDoc and
+	// Org bullets and numbered items.
+	highlightItemRe = regexp.MustCompile(`^[ \t]{0,3}(?:[-*+•]|\d{1,3}[.)])[ \t]+(.+)$`)
+
+	highlightImageRe  = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	highlightLinkRe   = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	highlightHTMLRe   = regexp.MustCompile(`<[^>]+>`)
+	highlightMarkupRe = regexp.MustCompile("\\*\\*|__|`|~~|^\\[[ xX]\\] ")
+)
+
+// Highlights returns up to max list items of the sections presenting a project, such
+// as "Features" or "Why X?", as plain text. Lists elsewhere, such as installation
+// steps or tables of contents, are not highlights; READMEs without such sections have
+// none.
+func Highlights(format string, content []byte, max int) []string {
+	var highlights []string
+	for _, section := range splitSections(format, string(content)) {
+		if !highlightHeadingRe.MatchString(section.heading) {
+			continue
+		}
+		fence := ""
+		for _, line := range section.lines {
+			if format == FormatMarkdown {
+				if fence != "" {
+					if strings.HasPrefix(strings.TrimSpace(line.text), fence) {
+						fence = ""
+					}
+					continue
+				}
+				if m := mdFenceRe.FindStringSubmatch(line.text); m != nil {
+					fence = m[2]
+					continue
+				}
+			}
+			m := highlightItemRe.FindStringSubmatch(line.text)
+			if m == nil {
+				continue
+			}
+			text := plainText(m[1])
+			if text == "" || len([]rune(text)) > maxHighlightLength {
+				continue
+			}
+			highlights = append(highlights, text)
+			if len(highlights) == max {
+				return highlights
+			}
+		}
+	}
+	return highlights
+}
+
+// plainText strips the inline markup of a line: images, links (keeping their text),
+// HTML tags, emphasis and code markers. Emoji shortcodes become emoji.
+func plainText(line string) string {
+	s := highlightImageRe.ReplaceAllString(line, "")
+	s = highlightLinkRe.ReplaceAllString(s, "$1")
+	s = highlightHTMLRe.ReplaceAllString(s, "")
+	s = highlightMarkupRe.ReplaceAllString(strings.TrimSpace(s), "")
+	s = mdEmojiRe.ReplaceAllStringFunc(s, func(m string) string {
+		if emoji, ok := githubEmoji.Get(strings.Trim(m, ":")); ok && emoji.IsUnicode() {
+			return string(emoji.Unicode)
+		}
+		return m
+	})
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,34 @@
+package readme
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHighlights(t *testing.T) {
+	content := "# Rocket\n\n![logo](logo.png)\n\n## Installation\n\n- Run `make install`\n\n" +
+		"## ✨ Features\n\n- **Fast**: builds in [milliseconds](docs/speed.md) :rocket:\n" +
+		"- Zero <b>config</b>\n\n```md\n- not a highlight\n```\n\n1. Plugins for `npm` and `cargo`\n\n" +
+		"## Why Rocket?\n\n* Small\n* Tested\n"
+
+	got := Highlights(FormatMarkdown, []byte(content), 4)
+	want := []string{"Fast: builds in milliseconds 🚀", "Zero config", "Plugins for npm and cargo", "Small"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Highlights() = %q, want %q", got, want)
+	}
+}
+
+func TestHighlightsWithoutFeatureSections(t *testing.T) {
+	content := "# Tool\n\n## Usage\n\n- Run it\n- Stop it\n"
+	if got := Highlights(FormatMarkdown, []byte(content), 4); len(got) != 0 {
+		t.Errorf("Highlights() = %q, want none", got)
+	}
+}
+
+func TestHighlightsOtherFormats(t *testing.T) {
+	content := "= Tool\n\n== Features\n\n* Reads AsciiDoc\n* Writes it\n"
+	got := Highlights(FormatAsciiDoc, []byte(content), 1)
+	if !reflect.DeepEqual(got, []string{"Reads AsciiDoc"}) {
+		t.Errorf("Highlights() = %q", got)
+	}
+}
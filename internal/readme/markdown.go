@@ -0,0 +1,47 @@
+package readme
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/yuin/goldmark"
+	emoji "github.com/yuin/goldmark-emoji"
+	"github.com/yuin/goldmark-emoji/definition"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown renders GitHub-flavoured markdown: CommonMark plus tables, task lists,
+// strikethrough, extended autolinks, footnotes and emoji shortcodes. Raw HTML is
+// passed through unchanged; PostProcess sanitises the result. Footnote IDs carry the
+// user-content- prefix PostProcess keeps IDs under.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.NewFootnote(extension.WithFootnoteIDPrefix(userContentPrefix)),
+		emoji.New(emoji.WithRenderingMethod(emoji.Unicode)),
+	),
+	goldmark.WithRendererOptions(html.WithUnsafe(), html.WithXHTML()),
+)
+
+// githubEmoji are the emoji shortcodes GitHub recognises.
+var githubEmoji = definition.Github()
+
+// Markdown line patterns used to split READMEs into sections and pick highlights.
+var (
+	mdFenceRe  = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	mdATXRe    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdListRe   = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])(?:( +)(.*))?$`)
+	mdSetextRe = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	mdEmojiRe  = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+)
+
+// renderMarkdown renders GitHub-flavoured markdown to HTML.
+func renderMarkdown(content []byte) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert(content, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
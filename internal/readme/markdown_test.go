@@ -0,0 +1,94 @@
+package readme
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the markdown golden files")
+
+// TestRenderMarkdownGolden renders each testdata/markdown/*.md fixture and compares it
+// with the .html file next to it. Run with -update to regenerate the golden files.
+func TestRenderMarkdownGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "markdown", "*.md"))
+	if err != nil {
+		t.Fatalf("Failed to list fixtures: %v", err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("No markdown fixtures found")
+	}
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".md")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+			got, err := Render(FormatMarkdown, input)
+			if err != nil {
+				t.Fatalf("Render returned an error: %v", err)
+			}
+
+			golden := strings.TrimSuffix(fixture, ".md") + ".html"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("Rendered HTML does not match %s:\n--- got ---\n%s\n--- want ---\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownSurvivesPostProcess(t *testing.T) {
+	rendered, err := Render(FormatMarkdown, []byte("- [x] done\n\n```js\nalert(1)\n```\n\n<script>alert(1)</script>\n"))
+	if err != nil {
+		t.Fatalf("Render returned an error: %v", err)
+	}
+	out, err := PostProcess([]byte(rendered), Source{FullName: "octo/widgets", DefaultBranch: "main"})
+	if err != nil {
+		t.Fatalf("PostProcess returned an error: %v", err)
+	}
+	got := string(out)
+	for _, want := range []string{`<input checked="" type="checkbox" disabled=""/>`, `<code class="language-js">alert(1)`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Output is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "<script>") {
+		t.Errorf("Script element survived post-processing:\n%s", got)
+	}
+}
+
+func TestRenderMarkdownFootnotes(t *testing.T) {
+	rendered, err := Render(FormatMarkdown, []byte("Fast[^1].\n\n[^1]: Measured on a laptop.\n"))
+	if err != nil {
+		t.Fatalf("Render returned an error: %v", err)
+	}
+	out, err := PostProcess([]byte(rendered), Source{FullName: "octo/widgets", DefaultBranch: "main"})
+	if err != nil {
+		t.Fatalf("PostProcess returned an error: %v", err)
+	}
+	got := string(out)
+	// The reference and the note link to each other.
+	for _, want := range []string{
+		`<sup id="user-content-fnref:1"><a href="#user-content-fn:1"`,
+		`<li id="user-content-fn:1">`,
+		`<a href="#user-content-fnref:1"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Output is missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package readme
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Source identifies where a README lives, so that relative links and images can be
+// resolved against the repository instead of our own domain.
+type Source struct {
+	FullName      string
+	DefaultBranch string
+	// Path is the README path within the repository, e.g. "docs/README.md".
+	// Relative references resolve against its directory.
+	Path string
+}
+
+const (
+	rawContentBaseURL = "https://raw.githubusercontent.com"
+	blobBaseURL       = "https://github.com"
+	// userContentPrefix prefixes the IDs kept in READMEs, as GitHub does, so that they
+	// cannot clobber the IDs of the page showing them.
+	userContentPrefix = "user-content-"
+)
+
+// allowedElements maps the permitted elements to their permitted attributes. IDs are
+// only kept on the elements footnotes link between.
+// Elements not listed are unwrapped, keeping their children; elements in
+// droppedElements are removed along with their content.
+var allowedElements = map[string][]string{
+	"a":          {"href", "title"},
+	"img":        {"src", "alt", "title", "width", "height", "align"},
+	"p":          {"align"},
+	"div":        {"align"},
+	"span":       nil,
+	"h1":         {"align"},
+	"h2":         {"align"},
+	"h3":         {"align"},
+	"h4":         {"align"},
+	"h5":         {"align"},
+	"h6":         {"align"},
+	"pre":        nil,
+	"code":       {"class"},
+	"blockquote": nil,
+	"ul":         nil,
+	"ol":         {"start"},
+	"li":         {"id"},
+	"dl":         nil,
+	"dt":         nil,
+	"dd":         nil,
+	"table":      nil,
+	"thead":      nil,
+	"tbody":      nil,
+	"tfoot":      nil,
+	"tr":         nil,
+	"th":         {"align", "colspan", "rowspan"},
+	"td":         {"align", "colspan", "rowspan"},
+	"em":         nil,
+	"strong":     nil,
+	"b":          nil,
+	"i":          nil,
+	"u":          nil,
+	"s":          nil,
+	"del":        nil,
+	"ins":        nil,
+	"sup":        {"id"},
+	"sub":        nil,
+	"kbd":        nil,
+	"br":         nil,
+	"hr":         nil,
+	"details":    {"open"},
+	"summary":    nil,
+	"input":      {"type", "checked", "disabled"},
+}
+
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"form": true, "textarea": true, "select": true, "button": true, "noscript": true,
+	"template": true, "svg": true, "math": true, "frame": true, "frameset": true,
+}
+
+var (
+	languageClassRe = regexp.MustCompile(`^language-[\w+#.-]+$`)
+	allowedSchemes  = map[string]bool{"http": true, "https": true, "mailto": true}
+)
+
+// PostProcess sanitises rendered README HTML against an allowlist, rewrites relative
+// image sources to raw.githubusercontent.com and relative links to github.com blob
+// URLs, and adds anchor IDs to headings.
+func PostProcess(content []byte, src Source) ([]byte, error) {
+	container := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(bytes.NewReader(content), container)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse README HTML: %w", err)
+	}
+	for _, n := range nodes {
+		container.AppendChild(n)
+	}
+
+	sanitizeChildren(container, src)
+	addHeadingIDs(container)
+
+	var buf bytes.Buffer
+	for c := container.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return nil, fmt.Errorf("failed to render README HTML: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func sanitizeChildren(parent *html.Node, src Source) {
+	for c := parent.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case html.ElementNode:
+			name := strings.ToLower(c.Data)
+			attrs, allowed := allowedElements[name]
+			switch {
+			case droppedElements[name]:
+				parent.RemoveChild(c)
+			case !allowed || name == "input" && !isCheckbox(c):
+				// Sanitise the children first, then hoist them in place of the element.
+				sanitizeChildren(c, src)
+				for gc := c.FirstChild; gc != nil; {
+					gcNext := gc.NextSibling
+					c.RemoveChild(gc)
+					parent.InsertBefore(gc, c)
+					gc = gcNext
+				}
+				parent.RemoveChild(c)
+			default:
+				c.Attr = sanitizeAttributes(name, c.Attr, attrs, src)
+				if name == "input" {
+					// Task list checkboxes are display-only.
+					c.Attr = append(c.Attr, html.Attribute{Key: "disabled"})
+				}
+				if name == "a" && hasAttribute(c, "href") {
+					c.Attr = append(c.Attr, html.Attribute{Key: "rel", Val: "nofollow noopener"})
+				}
+				sanitizeChildren(c, src)
+			}
+		case html.TextNode:
+		default:
+			// Comments and doctypes are dropped.
+			parent.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+func sanitizeAttributes(element string, attrs []html.Attribute, allowed []string, src Source) []html.Attribute {
+	var kept []html.Attribute
+	for _, attr := range attrs {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" || !contains(allowed, key) {
+			continue
+		}
+		switch key {
+		case "href":
+			val, ok := rewriteURL(attr.Val, src, false)
+			if !ok {
+				continue
+			}
+			attr.Val = val
+		case "src":
+			val, ok := rewriteURL(attr.Val, src, true)
+			if !ok {
+				continue
+			}
+			attr.Val = val
+		case "class":
+			var classes []string
+			for _, class := range strings.Fields(attr.Val) {
+				if languageClassRe.MatchString(class) {
+					classes = append(classes, class)
+				}
+			}
+			if len(classes) == 0 {
+				continue
+			}
+			attr.Val = strings.Join(classes, " ")
+		case "id":
+			if !strings.HasPrefix(attr.Val, userContentPrefix) {
+				attr.Val = userContentPrefix + attr.Val
+			}
+		case "disabled":
+			if element == "input" {
+				// Re-added unconditionally by the caller.
+				continue
+			}
+		}
+		attr.Key = key
+		kept = append(kept, attr)
+	}
+	return kept
+}
+
+// rewriteURL validates a link or image reference and resolves it against the
+// repository when it is relative. It reports false for references that must be
+// dropped, such as javascript: URLs.
+func rewriteURL(raw string, src Source, image bool) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" {
+		return raw, allowedSchemes[strings.ToLower(u.Scheme)]
+	}
+	if u.Host != "" {
+		// Protocol-relative URL.
+		u.Scheme = "https"
+		return u.String(), true
+	}
+	if u.Path == "" || src.FullName == "" {
+		// In-page anchors and query-only references are left alone.
+		return raw, true
+	}
+
+	var resolved string
+	if strings.HasPrefix(u.Path, "/") {
+		resolved = path.Clean(u.Path)
+	} else {
+		resolved = path.Join("/", path.Dir(src.Path), u.Path)
+	}
+	resolved = strings.TrimPrefix(resolved, "/")
+
+	branch := src.DefaultBranch
+	if branch == "" {
+		branch = "HEAD"
+	}
+
+	var target *url.URL
+	if image {
+		target, _ = url.Parse(rawContentBaseURL)
+		target.Path = fmt.Sprintf("/%s/%s/%s", src.FullName, branch, resolved)
+	} else {
+		target, _ = url.Parse(blobBaseURL)
+		target.Path = fmt.Sprintf("/%s/blob/%s/%s", src.FullName, branch, resolved)
+		target.Fragment = u.Fragment
+	}
+	target.RawQuery = u.RawQuery
+	return target.String(), true
+}
+
+// addHeadingIDs assigns GitHub-style slugs to headings, suffixing duplicates with -1, -2...
+func addHeadingIDs(root *html.Node) {
+	seen := make(map[string]int)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				slug := Slugify(textContent(n))
+				if slug != "" {
+					if count, ok := seen[slug]; ok {
+						seen[slug] = count + 1
+						slug = fmt.Sprintf("%s-%d", slug, count+1)
+					} else {
+						seen[slug] = 0
+					}
+					n.Attr = append(n.Attr, html.Attribute{Key: "id", Val: slug})
+				}
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+}
+
+// Slugify converts heading text to an anchor ID the way GitHub does: lowercase,
+// punctuation removed and spaces replaced by hyphens.
+func Slugify(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+func isCheckbox(n *html.Node) bool {
+	for _, attr := range n.Attr {
+		if strings.ToLower(attr.Key) == "type" {
+			return strings.EqualFold(attr.Val, "checkbox")
+		}
+	}
+	return false
+}
+
+func hasAttribute(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
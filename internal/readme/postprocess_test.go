@@ -0,0 +1,103 @@
+package readme
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPostProcess(t *testing.T) {
+	src := Source{FullName: "octo/widgets", DefaultBranch: "main", Path: "docs/README.md"}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantNot []string
+	}{
+		{
+			name:  "relative image",
+			input: `<p><img src="images/logo.png" alt="Logo"></p>`,
+			want:  []string{`<img src="https://raw.githubusercontent.com/octo/widgets/main/docs/images/logo.png" alt="Logo"/>`},
+		},
+		{
+			name:  "root-relative link keeps fragment",
+			input: `<a href="/CONTRIBUTING.md#setup">guide</a>`,
+			want:  []string{`<a href="https://github.com/octo/widgets/blob/main/CONTRIBUTING.md#setup" rel="nofollow noopener">guide</a>`},
+		},
+		{
+			name:  "parent directory link",
+			input: `<a href="../LICENSE">license</a>`,
+			want:  []string{`href="https://github.com/octo/widgets/blob/main/LICENSE"`},
+		},
+		{
+			name:  "absolute and anchor links untouched",
+			input: `<a href="https://example.com/x">x</a><a href="#usage">usage</a>`,
+			want:  []string{`href="https://example.com/x"`, `href="#usage"`},
+		},
+		{
+			name:    "ids prefixed and only kept on footnote elements",
+			input:   `<li id="main">a</li><sup id="user-content-fnref:1">1</sup><div id="app">b</div>`,
+			want:    []string{`<li id="user-content-main">a</li>`, `<sup id="user-content-fnref:1">1</sup>`, `<div>b</div>`},
+			wantNot: []string{`id="app"`},
+		},
+		{
+			name:    "script and handlers removed",
+			input:   `<p onclick="alert(1)">hi<script>alert(1)</script></p><a href="javascript:alert(1)">x</a><iframe src="https://evil"></iframe>`,
+			want:    []string{`<p>hi</p>`, `<a>x</a>`},
+			wantNot: []string{"script", "onclick", "javascript", "iframe"},
+		},
+		{
+			name:    "unknown elements unwrapped",
+			input:   `<center><marquee>text</marquee></center><picture><source srcset="a.png"><img src="b.png"></picture>`,
+			want:    []string{"text", `<img src="https://raw.githubusercontent.com/octo/widgets/main/docs/b.png"/>`},
+			wantNot: []string{"center", "marquee", "source"},
+		},
+		{
+			name:    "code language class kept",
+			input:   `<pre><code class="language-go evil">x</code></pre>`,
+			want:    []string{`<code class="language-go">`},
+			wantNot: []string{"evil"},
+		},
+		{
+			name:  "heading anchors",
+			input: `<h1>Getting Started!</h1><h2>Usage</h2><h2>Usage</h2><h3 id="custom">API <code>v2</code></h3>`,
+			want:  []string{`<h1 id="getting-started">`, `<h2 id="usage">`, `<h2 id="usage-1">`, `<h3 id="api-v2">`},
+		},
+		{
+			name:    "task list checkbox",
+			input:   `<li><input type="checkbox" checked> done</li><input type="text" value="x">`,
+			want:    []string{`<input type="checkbox" checked="" disabled=""/>`},
+			wantNot: []string{`type="text"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := PostProcess([]byte(tt.input), src)
+			if err != nil {
+				t.Fatalf("PostProcess returned an error: %v", err)
+			}
+			got := string(out)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Output is missing %q:\n%s", want, got)
+				}
+			}
+			for _, unwanted := range tt.wantNot {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("Output should not contain %q:\n%s", unwanted, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPostProcessWithoutSource(t *testing.T) {
+	out, err := PostProcess([]byte(`<img src="logo.png">`), Source{})
+	if err != nil {
+		t.Fatalf("PostProcess returned an error: %v", err)
+	}
+	if got := string(out); got != `<img src="logo.png"/>` {
+		t.Errorf("Expected relative reference to be left alone, got %s", got)
+	}
+}
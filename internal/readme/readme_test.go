@@ -0,0 +1,157 @@
+package readme
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{
+		"README.md":       FormatMarkdown,
+		"readme.MARKDOWN": FormatMarkdown,
+		"README.rst":      FormatRST,
+		"README.adoc":     FormatAsciiDoc,
+		"README.org":      FormatOrg,
+		"README.txt":      FormatText,
+		"README":          FormatText,
+	}
+	for path, want := range tests {
+		if got := DetectFormat(path); got != want {
+			t.Errorf("DetectFormat(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		content string
+		want    []string
+	}{
+		{
+			name:   "rst",
+			format: FormatRST,
+			content: `=======
+Project
+=======
+
+Usage
+-----
+
+Install with ` + "``pip install project``" + ` or read the ` + "`docs <https://example.com/docs>`_" + `.
+
+* **fast**
+* *small*
+
+.. code-block:: python
+
+   import project
+
+.. image:: docs/logo.png
+   :alt: Logo
+`,
+			want: []string{
+				"<h1>Project</h1>",
+				"<h2>Usage</h2>",
+				"<code>pip install project</code>",
+				`<a href="https://example.com/docs">docs</a>`,
+				"<li><strong>fast</strong></li>",
+				"<li><em>small</em></li>",
+				`<pre><code class="language-python">import project</code></pre>`,
+				`<img src="docs/logo.png" alt="Logo">`,
+			},
+		},
+		{
+			name:   "asciidoc",
+			format: FormatAsciiDoc,
+			content: `= Project
+:toc:
+
+== Usage
+
+Run ` + "`make`" + ` and see link:docs/guide.adoc[the guide].
+
+. first
+. second
+
+[source,go]
+----
+fmt.Println("<hi>")
+----
+
+image::docs/logo.png[Logo]
+`,
+			want: []string{
+				"<h1>Project</h1>",
+				"<h2>Usage</h2>",
+				"<code>make</code>",
+				`<a href="docs/guide.adoc">the guide</a>`,
+				"<ol>\n<li>first</li>\n<li>second</li>\n</ol>",
+				`<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>`,
+				`<img src="docs/logo.png" alt="Logo">`,
+			},
+		},
+		{
+			name:   "org",
+			format: FormatOrg,
+			content: `#+TITLE: Project
+
+* Usage
+Use =make= and visit [[https://example.com][the site]].
+
+- *bold* item
+- /italic/ item
+
+#+BEGIN_SRC sh
+make install
+#+END_SRC
+
+[[docs/logo.png]]
+`,
+			want: []string{
+				"<h1>Project</h1>",
+				"<h1>Usage</h1>",
+				"<code>make</code>",
+				`<a href="https://example.com">the site</a>`,
+				"<li><strong>bold</strong> item</li>",
+				"<li><em>italic</em> item</li>",
+				`<pre><code class="language-sh">make install</code></pre>`,
+				`<img src="docs/logo.png" alt="">`,
+			},
+		},
+		{
+			name:    "text",
+			format:  FormatText,
+			content: "plain <text>",
+			want:    []string{"<pre>plain &lt;text&gt;</pre>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.format, []byte(tt.content))
+			if err != nil {
+				t.Fatalf("Render returned an error: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Rendered HTML is missing %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderRejectsUnknownFormat(t *testing.T) {
+	if _, err := Render("textile", []byte("h1. Title")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestRenderRejectsInvalidEncoding(t *testing.T) {
+	if _, err := Render(FormatMarkdown, []byte("caf\xe9 latin-1")); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("Expected ErrInvalidEncoding, got %v", err)
+	}
+}
@@ -0,0 +1,485 @@
+package readme
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Render errors.
+var (
+	ErrUnsupportedFormat = errors.New("unsupported README format")
+	ErrInvalidEncoding   = errors.New("README is not valid UTF-8")
+)
+
+// Render converts a README to HTML. It returns ErrUnsupportedFormat for formats it
+// cannot render and ErrInvalidEncoding for content that is not UTF-8, so callers can
+// fall back to another renderer.
+func Render(format string, content []byte) (string, error) {
+	if !utf8.Valid(content) {
+		return "", fmt.Errorf("failed to render %s README: %w", format, ErrInvalidEncoding)
+	}
+
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	switch format {
+	case FormatMarkdown:
+		rendered, err := renderMarkdown([]byte(text))
+		if err != nil {
+			return "", fmt.Errorf("failed to render markdown README: %w", err)
+		}
+		return rendered, nil
+	case FormatRST:
+		return renderRST(text), nil
+	case FormatAsciiDoc:
+		return renderAsciiDoc(text), nil
+	case FormatOrg:
+		return renderOrg(text), nil
+	case FormatText:
+		return "<pre>" + html.EscapeString(text) + "</pre>\n", nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+// htmlBuilder accumulates block-level HTML. Paragraph lines and list items are
+// buffered until a blank line or a different block closes them.
+type htmlBuilder struct {
+	out       strings.Builder
+	inline    func(string) string
+	paragraph []string
+	listTag   string
+	listItems []string
+}
+
+func newHTMLBuilder(inline func(string) string) *htmlBuilder {
+	return &htmlBuilder{inline: inline}
+}
+
+func (b *htmlBuilder) flush() {
+	if len(b.paragraph) > 0 {
+		b.out.WriteString("<p>" + b.inline(strings.Join(b.paragraph, " ")) + "</p>\n")
+		b.paragraph = nil
+	}
+	if b.listTag != "" {
+		b.out.WriteString("<" + b.listTag + ">\n")
+		for _, item := range b.listItems {
+			b.out.WriteString("<li>" + b.inline(item) + "</li>\n")
+		}
+		b.out.WriteString("</" + b.listTag + ">\n")
+		b.listTag = ""
+		b.listItems = nil
+	}
+}
+
+func (b *htmlBuilder) text(line string) {
+	if b.listTag != "" {
+		// Continuation of the last list item.
+		b.listItems[len(b.listItems)-1] += " " + strings.TrimSpace(line)
+		return
+	}
+	b.paragraph = append(b.paragraph, strings.TrimSpace(line))
+}
+
+func (b *htmlBuilder) item(ordered bool, text string) {
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	if b.listTag != tag || len(b.paragraph) > 0 {
+		b.flush()
+		b.listTag = tag
+	}
+	b.listItems = append(b.listItems, strings.TrimSpace(text))
+}
+
+func (b *htmlBuilder) heading(level int, text string) {
+	b.flush()
+	if level < 1 {
+		level = 1
+	}
+	if level > 6 {
+		level = 6
+	}
+	fmt.Fprintf(&b.out, "<h%d>%s</h%d>\n", level, b.inline(strings.TrimSpace(text)), level)
+}
+
+func (b *htmlBuilder) code(lang string, lines []string) {
+	b.flush()
+	class := ""
+	if lang != "" {
+		class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(lang))
+	}
+	fmt.Fprintf(&b.out, "<pre><code%s>%s</code></pre>\n", class, html.EscapeString(strings.Join(lines, "\n")))
+}
+
+func (b *htmlBuilder) image(src, alt string) {
+	b.flush()
+	fmt.Fprintf(&b.out, "<p><img src=\"%s\" alt=\"%s\"></p>\n", html.EscapeString(src), html.EscapeString(alt))
+}
+
+func (b *htmlBuilder) String() string {
+	b.flush()
+	return b.out.String()
+}
+
+// inlineRule is a regular expression substitution applied to escaped inline text.
+type inlineRule struct {
+	re   *regexp.Regexp
+	repl string
+	// protect stores the replacement so that later rules cannot rewrite it.
+	protect bool
+}
+
+// applyInline escapes text and applies the rules in order. Protected replacements are
+// swapped for placeholders and restored at the end, so code spans and links are
+// not reformatted by the emphasis rules.
+func applyInline(text string, rules []inlineRule) string {
+	text = html.EscapeString(text)
+	var protected []string
+	for _, rule := range rules {
+		if !rule.protect {
+			text = rule.re.ReplaceAllString(text, rule.repl)
+			continue
+		}
+		text = rule.re.ReplaceAllStringFunc(text, func(match string) string {
+			protected = append(protected, rule.re.ReplaceAllString(match, rule.repl))
+			return fmt.Sprintf("\x00%d\x00", len(protected)-1)
+		})
+	}
+	for i, value := range protected {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), value, 1)
+	}
+	return text
+}
+
+var (
+	bulletRe  = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedRe = regexp.MustCompile(`^\s*(?:\d+|#)[.)]\s+(.*)$`)
+	autoLink  = inlineRule{re: regexp.MustCompile(`(^|[\s(])(https?://[^\s<>()"]+)`), repl: `$1<a href="$2">$2</a>`, protect: true}
+)
+
+// --- reStructuredText ---
+
+var (
+	rstDirectiveRe = regexp.MustCompile(`^\.\.\s+([\w-]+)::\s*(.*)$`)
+	rstInline      = []inlineRule{
+		{re: regexp.MustCompile("``([^`]+)``"), repl: "<code>$1</code>", protect: true},
+		{re: regexp.MustCompile("`([^`]+?)\\s*&lt;([^`]+?)&gt;`__?"), repl: `<a href="$2">$1</a>`, protect: true},
+		autoLink,
+		{re: regexp.MustCompile(`\*\*([^*]+)\*\*`), repl: "<strong>$1</strong>"},
+		{re: regexp.MustCompile(`\*([^*]+)\*`), repl: "<em>$1</em>"},
+	}
+)
+
+func renderRST(text string) string {
+	b := newHTMLBuilder(func(s string) string { return applyInline(s, rstInline) })
+	lines := strings.Split(text, "\n")
+	// Heading levels follow the order in which underline characters first appear.
+	levels := make(map[byte]int)
+	headingLevel := func(c byte) int {
+		if _, ok := levels[c]; !ok {
+			levels[c] = len(levels) + 1
+		}
+		return levels[c]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			b.flush()
+
+		// Overlined heading: ====, Title, ====
+		case isRSTAdornment(line) && i+2 < len(lines) && strings.TrimSpace(lines[i+1]) != "" && isRSTAdornment(lines[i+2]):
+			b.heading(headingLevel(line[0]+128), lines[i+1])
+			i += 2
+
+		// Underlined heading: Title, ====
+		case i+1 < len(lines) && !strings.HasPrefix(line, " ") && isRSTAdornment(lines[i+1]) && len(strings.TrimSpace(lines[i+1])) >= len(trimmed) && len(b.paragraph) == 0:
+			b.heading(headingLevel(lines[i+1][0]), line)
+			i++
+
+		case rstDirectiveRe.MatchString(trimmed):
+			m := rstDirectiveRe.FindStringSubmatch(trimmed)
+			body, next := indentedBlock(lines, i+1)
+			switch m[1] {
+			case "code-block", "code", "sourcecode":
+				b.code(m[2], stripOptions(body))
+			case "image", "figure":
+				alt := ""
+				for _, opt := range body {
+					if strings.HasPrefix(strings.TrimSpace(opt), ":alt:") {
+						alt = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(opt), ":alt:"))
+					}
+				}
+				b.image(m[2], alt)
+			}
+			// Other directives (toctree, raw, note...) are skipped with their bodies.
+			i = next - 1
+
+		case strings.HasPrefix(trimmed, ".."):
+			// Comments and link targets.
+			_, next := indentedBlock(lines, i+1)
+			i = next - 1
+
+		case bulletRe.MatchString(line) && !strings.HasPrefix(line, "  ") || len(b.listItems) > 0 && bulletRe.MatchString(line):
+			b.item(false, bulletRe.FindStringSubmatch(line)[1])
+
+		case orderedRe.MatchString(line):
+			b.item(true, orderedRe.FindStringSubmatch(line)[1])
+
+		case strings.HasSuffix(trimmed, "::"):
+			// A paragraph ending in "::" introduces a literal block.
+			if para := strings.TrimSuffix(trimmed, "::"); strings.TrimSpace(para) != "" {
+				b.text(para + ":")
+			}
+			body, next := indentedBlock(lines, i+1)
+			b.code("", body)
+			i = next - 1
+
+		default:
+			b.text(line)
+		}
+	}
+	return b.String()
+}
+
+// isRSTAdornment reports whether line is a section underline or overline: at least
+// two repetitions of a single punctuation character.
+func isRSTAdornment(line string) bool {
+	line = strings.TrimRight(line, " \t")
+	if len(line) < 2 || !strings.ContainsRune("=-~^\"'`#*+:.", rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// indentedBlock returns the indented lines starting at start (skipping leading blank
+// lines), dedented, and the index of the first line after the block.
+func indentedBlock(lines []string, start int) ([]string, int) {
+	i := start
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	var block []string
+	indent := -1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			block = append(block, "")
+			continue
+		}
+		lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if lineIndent == 0 {
+			break
+		}
+		if indent < 0 || lineIndent < indent {
+			indent = lineIndent
+		}
+		block = append(block, line)
+	}
+	for j, line := range block {
+		if len(line) >= indent && indent > 0 {
+			block[j] = line[indent:]
+		}
+	}
+	// Trailing blank lines belong to the surrounding document.
+	for len(block) > 0 && block[len(block)-1] == "" {
+		block = block[:len(block)-1]
+	}
+	if len(block) == 0 {
+		return nil, start
+	}
+	return block, i
+}
+
+// stripOptions removes the leading ":option: value" lines of a directive body.
+func stripOptions(body []string) []string {
+	for len(body) > 0 && strings.HasPrefix(body[0], ":") {
+		body = body[1:]
+	}
+	for len(body) > 0 && body[0] == "" {
+		body = body[1:]
+	}
+	return body
+}
+
+// --- AsciiDoc ---
+
+var (
+	adocHeadingRe   = regexp.MustCompile(`^(={1,6})\s+(.*)$`)
+	adocSourceRe    = regexp.MustCompile(`^\[source(?:,\s*([\w+#-]+))?.*\]$`)
+	adocImageRe     = regexp.MustCompile(`^image::([^\[]+)\[([^\]]*)\]$`)
+	adocAttributeRe = regexp.MustCompile(`^:[\w-]+:.*$`)
+	adocBulletRe    = regexp.MustCompile(`^\s*(?:\*+|-)\s+(.*)$`)
+	adocOrderedRe   = regexp.MustCompile(`^\s*(?:\.+|\d+\.)\s+(.*)$`)
+	adocInline      = []inlineRule{
+		{re: regexp.MustCompile("`([^`]+)`"), repl: "<code>$1</code>", protect: true},
+		{re: regexp.MustCompile(`image:([^\s\[]+)\[([^\]]*)\]`), repl: `<img src="$1" alt="$2">`, protect: true},
+		{re: regexp.MustCompile(`(?:link:)?(https?://[^\s\[]+)\[([^\]]+)\]`), repl: `<a href="$1">$2</a>`, protect: true},
+		{re: regexp.MustCompile(`link:([^\s\[]+)\[([^\]]+)\]`), repl: `<a href="$1">$2</a>`, protect: true},
+		autoLink,
+		{re: regexp.MustCompile(`\*([^*\s][^*]*)\*`), repl: "<strong>$1</strong>"},
+		{re: regexp.MustCompile(`(^|\W)_([^_\s][^_]*)_(\W|$)`), repl: "$1<em>$2</em>$3"},
+	}
+)
+
+func renderAsciiDoc(text string) string {
+	b := newHTMLBuilder(func(s string) string { return applyInline(s, adocInline) })
+	lines := strings.Split(text, "\n")
+	sourceLang := ""
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			b.flush()
+
+		case trimmed == "////":
+			i = skipUntil(lines, i+1, "////")
+
+		case strings.HasPrefix(trimmed, "//"), adocAttributeRe.MatchString(trimmed):
+			// Comments and document attributes produce no output.
+
+		case adocSourceRe.MatchString(trimmed):
+			sourceLang = adocSourceRe.FindStringSubmatch(trimmed)[1]
+
+		case trimmed == "----" || trimmed == "....":
+			end := skipUntil(lines, i+1, trimmed)
+			b.code(sourceLang, lines[i+1:end])
+			sourceLang = ""
+			i = end
+
+		case adocHeadingRe.MatchString(trimmed):
+			m := adocHeadingRe.FindStringSubmatch(trimmed)
+			b.heading(len(m[1]), m[2])
+
+		case adocImageRe.MatchString(trimmed):
+			m := adocImageRe.FindStringSubmatch(trimmed)
+			b.image(m[1], m[2])
+
+		case adocBulletRe.MatchString(line):
+			b.item(false, adocBulletRe.FindStringSubmatch(line)[1])
+
+		case adocOrderedRe.MatchString(line) && !strings.HasPrefix(trimmed, ".."):
+			b.item(true, adocOrderedRe.FindStringSubmatch(line)[1])
+
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			// Block attributes such as [NOTE] or [quote] are ignored.
+
+		default:
+			b.text(line)
+		}
+	}
+	return b.String()
+}
+
+// skipUntil returns the index of the next line equal to delimiter, or len(lines).
+func skipUntil(lines []string, start int, delimiter string) int {
+	for i := start; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == delimiter {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// --- Org mode ---
+
+var (
+	orgHeadingRe  = regexp.MustCompile(`^(\*+)\s+(.*)$`)
+	orgKeywordRe  = regexp.MustCompile(`^#\+(\w+):\s*(.*)$`)
+	orgBeginRe    = regexp.MustCompile(`(?i)^#\+begin_(\w+)\s*(\S*)`)
+	orgBulletRe   = regexp.MustCompile(`^\s*[-+]\s+(.*)$`)
+	orgImageExtRe = regexp.MustCompile(`(?i)\.(png|jpe?g|gif|svg|webp)$`)
+	orgBareLinkRe = regexp.MustCompile(`\[\[([^\]]+)\]\]`)
+	orgImageRe    = regexp.MustCompile("\x01img:([^\x01]+)\x01")
+	orgInline     = []inlineRule{
+		{re: regexp.MustCompile(`=([^=\s][^=]*)=`), repl: "<code>$1</code>", protect: true},
+		{re: regexp.MustCompile(`~([^~\s][^~]*)~`), repl: "<code>$1</code>", protect: true},
+		{re: regexp.MustCompile(`\[\[([^\]]+)\]\[([^\]]+)\]\]`), repl: `<a href="$1">$2</a>`, protect: true},
+		autoLink,
+		{re: regexp.MustCompile(`(^|\s)\*([^*\s][^*]*)\*`), repl: "$1<strong>$2</strong>"},
+		{re: regexp.MustCompile(`(^|\s)/([^/\s][^/]*)/`), repl: "$1<em>$2</em>"},
+	}
+)
+
+func renderOrg(text string) string {
+	b := newHTMLBuilder(func(s string) string {
+		// Bare [[link]] references become images when they point at one.
+		s = orgBareLinkRe.ReplaceAllStringFunc(s, func(m string) string {
+			target := strings.Trim(m, "[]")
+			if orgImageExtRe.MatchString(target) {
+				return "\x01img:" + target + "\x01"
+			}
+			return "[[" + target + "][" + target + "]]"
+		})
+		out := applyInline(s, orgInline)
+		return orgImageRe.ReplaceAllString(out, `<img src="$1" alt="">`)
+	})
+	lines := strings.Split(text, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			b.flush()
+
+		case orgBeginRe.MatchString(trimmed):
+			m := orgBeginRe.FindStringSubmatch(trimmed)
+			end := i + 1
+			for end < len(lines) && !strings.EqualFold(strings.TrimSpace(lines[end]), "#+end_"+m[1]) {
+				end++
+			}
+			body := lines[i+1 : end]
+			switch strings.ToLower(m[1]) {
+			case "src":
+				b.code(m[2], body)
+			case "example":
+				b.code("", body)
+			default:
+				for _, l := range body {
+					if strings.TrimSpace(l) == "" {
+						b.flush()
+					} else {
+						b.text(l)
+					}
+				}
+				b.flush()
+			}
+			i = end
+
+		case orgKeywordRe.MatchString(trimmed):
+			m := orgKeywordRe.FindStringSubmatch(trimmed)
+			if strings.EqualFold(m[1], "title") {
+				b.heading(1, m[2])
+			}
+
+		case strings.HasPrefix(trimmed, "# ") || trimmed == "#":
+			// Comment line.
+
+		case orgHeadingRe.MatchString(line):
+			m := orgHeadingRe.FindStringSubmatch(line)
+			b.heading(len(m[1]), m[2])
+
+		case orgBulletRe.MatchString(line):
+			b.item(false, orgBulletRe.FindStringSubmatch(line)[1])
+
+		case orderedRe.MatchString(line):
+			b.item(true, orderedRe.FindStringSubmatch(line)[1])
+
+		default:
+			b.text(line)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,149 @@
+// Package recommend re-ranks personalised recommendations so that a page is not made
+// of near-identical repositories.
+package recommend
+
+import (
+	"math/rand"
+
+	"github.com/teomiscia/github-trending/internal/similarity"
+)
+
+// Item is a recommendation candidate.
+type Item struct {
+	ID int64
+	// Score is the relevance of the item to the user; only its relative value matters.
+	Score     float64
+	Owner     string
+	Topics    []string
+	Languages []string
+}
+
+// Options configures Rerank.
+type Options struct {
+	// Lambda trades relevance (1) against diversity (0) in maximal marginal relevance.
+	Lambda float64
+	// Epsilon is the probability that a slot is filled with an exploration item instead
+	// of the next best candidate.
+	Epsilon float64
+	// MaxPerOwner caps the items of one owner in each page; 0 disables the cap.
+	MaxPerOwner int
+	// PageSize is the number of items over which diversity and owner caps apply.
+	PageSize int
+	// Similarity compares two items, from 0 (unrelated) to 1 (identical). Nil uses
+	// DefaultSimilarity.
+	Similarity func(a, b Item) float64
+}
+
+// DefaultSimilarity blends the Jaccard similarity of the items' topics and languages.
+func DefaultSimilarity(a, b Item) float64 {
+	return 0.7*similarity.Jaccard(a.Topics, b.Topics) + 0.3*similarity.Jaccard(a.Languages, b.Languages)
+}
+
+// Rerank orders candidates, which must be sorted by decreasing score, for display.
+// Within each page, it picks items by maximal marginal relevance: the candidate with
+// the best trade-off between its score and its similarity to the items already on the
+// page. With probability Epsilon a slot instead goes to the next unused exploration
+// item (e.g. a trending repository), and no owner gets more than MaxPerOwner items per
+// page unless nothing else is left. Every candidate is returned exactly once; unused
+// exploration items are dropped. rng makes the exploration slots reproducible.
+func Rerank(candidates, exploration []Item, opts Options, rng *rand.Rand) []int64 {
+	sim := opts.Similarity
+	if sim == nil {
+		sim = DefaultSimilarity
+	}
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = len(candidates) + len(exploration)
+	}
+
+	// Normalise relevance to [0, 1] so that it is comparable with similarity.
+	maxScore := 0.0
+	for _, c := range candidates {
+		if c.Score > maxScore {
+			maxScore = c.Score
+		}
+	}
+	relevance := make([]float64, len(candidates))
+	for i, c := range candidates {
+		if maxScore > 0 {
+			relevance[i] = c.Score / maxScore
+		}
+	}
+
+	// seen holds the candidates and the exploration items already placed, which must
+	// not be placed again.
+	seen := make(map[int64]bool, len(candidates))
+	for _, c := range candidates {
+		seen[c.ID] = true
+	}
+	remaining := make([]int, len(candidates))
+	for i := range candidates {
+		remaining[i] = i
+	}
+	// maxSim[i] is the highest similarity of candidate i to an item on the current page.
+	maxSim := make([]float64, len(candidates))
+	nextExploration := 0
+
+	result := make([]int64, 0, len(candidates))
+	pageLen := 0
+	ownerCounts := make(map[string]int)
+	ownerFull := func(owner string) bool {
+		return opts.MaxPerOwner > 0 && owner != "" && ownerCounts[owner] >= opts.MaxPerOwner
+	}
+	place := func(item Item) {
+		result = append(result, item.ID)
+		ownerCounts[item.Owner]++
+		pageLen++
+		if pageLen == pageSize {
+			pageLen = 0
+			ownerCounts = make(map[string]int)
+			for _, i := range remaining {
+				maxSim[i] = 0
+			}
+			return
+		}
+		for _, i := range remaining {
+			if s := sim(candidates[i], item); s > maxSim[i] {
+				maxSim[i] = s
+			}
+		}
+	}
+
+	for len(remaining) > 0 {
+		if opts.Epsilon > 0 && rng != nil && rng.Float64() < opts.Epsilon {
+			explored := false
+			for nextExploration < len(exploration) && !explored {
+				item := exploration[nextExploration]
+				nextExploration++
+				if seen[item.ID] || ownerFull(item.Owner) {
+					continue
+				}
+				seen[item.ID] = true
+				place(item)
+				explored = true
+			}
+			if explored {
+				continue
+			}
+		}
+
+		best, bestValue := -1, 0.0
+		for pos, i := range remaining {
+			if ownerFull(candidates[i].Owner) {
+				continue
+			}
+			value := opts.Lambda*relevance[i] - (1-opts.Lambda)*maxSim[i]
+			if best < 0 || value > bestValue {
+				best, bestValue = pos, value
+			}
+		}
+		if best < 0 {
+			// Every remaining candidate's owner is capped on this page; keep the order.
+			best = 0
+		}
+		chosen := remaining[best]
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		place(candidates[chosen])
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package recommend
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func item(id int64, score float64, owner string, topics ...string) Item {
+	return Item{ID: id, Score: score, Owner: owner, Topics: topics}
+}
+
+func TestRerankWithoutDiversityKeepsOrder(t *testing.T) {
+	candidates := []Item{
+		item(1, 3, "a", "rust", "cli"),
+		item(2, 2, "b", "rust", "cli"),
+		item(3, 1, "c", "web"),
+	}
+	got := Rerank(candidates, nil, Options{Lambda: 1}, nil)
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rerank() = %v, want %v", got, want)
+	}
+}
+
+func TestRerankPromotesDiverseItems(t *testing.T) {
+	candidates := []Item{
+		item(1, 1.0, "a", "rust", "cli"),
+		item(2, 0.95, "b", "rust", "cli"),
+		item(3, 0.9, "c", "rust", "cli"),
+		item(4, 0.8, "d", "python", "ml"),
+	}
+	got := Rerank(candidates, nil, Options{Lambda: 0.5}, nil)
+	if want := []int64{1, 4, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rerank() = %v, want %v", got, want)
+	}
+}
+
+func TestRerankOwnerCap(t *testing.T) {
+	candidates := []Item{
+		item(1, 5, "a"),
+		item(2, 4, "a"),
+		item(3, 3, "a"),
+		item(4, 2, "b"),
+		item(5, 1, "a"),
+	}
+	got := Rerank(candidates, nil, Options{Lambda: 1, MaxPerOwner: 2, PageSize: 3}, nil)
+	// The third item of "a" waits for the next page, where the cap starts over.
+	if want := []int64{1, 2, 4, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rerank() = %v, want %v", got, want)
+	}
+}
+
+func TestRerankExploration(t *testing.T) {
+	candidates := []Item{item(1, 3, "a"), item(2, 2, "b"), item(3, 1, "c")}
+	exploration := []Item{item(2, 0, "b"), item(10, 0, "x"), item(11, 0, "y")}
+
+	// With Epsilon 1 every slot explores until the exploration items run out; items
+	// already among the candidates are not explored.
+	got := Rerank(candidates, exploration, Options{Lambda: 1, Epsilon: 1}, rand.New(rand.NewSource(1)))
+	if want := []int64{10, 11, 1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rerank() = %v, want %v", got, want)
+	}
+
+	// The same seed gives the same order.
+	opts := Options{Lambda: 1, Epsilon: 0.5}
+	first := Rerank(candidates, exploration, opts, rand.New(rand.NewSource(42)))
+	second := Rerank(candidates, exploration, opts, rand.New(rand.NewSource(42)))
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Rerank is not reproducible: %v != %v", first, second)
+	}
+	for _, id := range []int64{1, 2, 3} {
+		found := false
+		for _, got := range first {
+			found = found || got == id
+		}
+		if !found {
+			t.Errorf("candidate %d missing from %v", id, first)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package similarity
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// Neighbour is a repository related to another one, with its score.
+type Neighbour struct {
+	RepositoryID int64
+	Score        float64
+}
+
+// CoViewOptions tunes BuildCoViews.
+type CoViewOptions struct {
+	// HalfLife is the age at which a view counts half as much as a view made now.
+	HalfLife time.Duration
+	// MaxSessionViews caps the views of a session that are paired, keeping the most
+	// recent ones, so that a handful of very long sessions cannot dominate.
+	MaxSessionViews int
+	// MinSessions is the number of sessions that must have viewed both repositories
+	// for them to be neighbours.
+	MinSessions int
+	// MaxNeighbours is the length of each repository's neighbour list.
+	MaxNeighbours int
+}
+
+type coView struct {
+	weight   float64
+	sessions int
+}
+
+// BuildCoViews computes item-item collaborative filtering neighbours from views:
+// repositories are related when the same sessions viewed both. Each view is weighted
+// by its age, and the co-occurrence of two repositories is normalised by their
+// popularity (the cosine of their session vectors), so that the most viewed
+// repositories are not everyone's neighbour. The result maps each repository to its
+// neighbours, best first.
+func BuildCoViews(views []models.RepositoryView, now time.Time, opts CoViewOptions) map[int64][]Neighbour {
+	type sessionView struct {
+		repoID int64
+		at     time.Time
+	}
+	// Keep the latest view of each repository per session.
+	sessions := make(map[string]map[int64]time.Time)
+	for _, v := range views {
+		repos, ok := sessions[v.SessionID]
+		if !ok {
+			repos = make(map[int64]time.Time)
+			sessions[v.SessionID] = repos
+		}
+		if at, ok := repos[v.RepositoryID]; !ok || v.ViewedAt.After(at) {
+			repos[v.RepositoryID] = v.ViewedAt
+		}
+	}
+
+	popularity := make(map[int64]float64)
+	pairs := make(map[int64]map[int64]*coView)
+	for _, repos := range sessions {
+		sessionViews := make([]sessionView, 0, len(repos))
+		for repoID, at := range repos {
+			sessionViews = append(sessionViews, sessionView{repoID: repoID, at: at})
+		}
+		sort.Slice(sessionViews, func(i, j int) bool {
+			if !sessionViews[i].at.Equal(sessionViews[j].at) {
+				return sessionViews[i].at.After(sessionViews[j].at)
+			}
+			return sessionViews[i].repoID < sessionViews[j].repoID
+		})
+		if opts.MaxSessionViews > 0 && len(sessionViews) > opts.MaxSessionViews {
+			sessionViews = sessionViews[:opts.MaxSessionViews]
+		}
+
+		weights := make([]float64, len(sessionViews))
+		for i, sv := range sessionViews {
+			weights[i] = decay(now.Sub(sv.at), opts.HalfLife)
+			popularity[sv.repoID] += weights[i] * weights[i]
+		}
+		for i := range sessionViews {
+			for j := range sessionViews {
+				if i == j {
+					continue
+				}
+				a, b := sessionViews[i].repoID, sessionViews[j].repoID
+				if pairs[a] == nil {
+					pairs[a] = make(map[int64]*coView)
+				}
+				cv := pairs[a][b]
+				if cv == nil {
+					cv = &coView{}
+					pairs[a][b] = cv
+				}
+				cv.weight += weights[i] * weights[j]
+				cv.sessions++
+			}
+		}
+	}
+
+	neighbours := make(map[int64][]Neighbour, len(pairs))
+	for a, related := range pairs {
+		var list []Neighbour
+		for b, cv := range related {
+			if cv.sessions < opts.MinSessions {
+				continue
+			}
+			norm := math.Sqrt(popularity[a] * popularity[b])
+			if norm == 0 {
+				continue
+			}
+			list = append(list, Neighbour{RepositoryID: b, Score: clamp(cv.weight / norm)})
+		}
+		if len(list) == 0 {
+			continue
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			return list[i].RepositoryID < list[j].RepositoryID
+		})
+		if opts.MaxNeighbours > 0 && len(list) > opts.MaxNeighbours {
+			list = list[:opts.MaxNeighbours]
+		}
+		neighbours[a] = list
+	}
+	return neighbours
+}
+
+// decay is the weight of a view of the given age.
+func decay(age, halfLife time.Duration) float64 {
+	if halfLife <= 0 || age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
@@ -0,0 +1,90 @@
+package similarity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+var coViewNow = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func views(sessionID string, age time.Duration, repoIDs ...int64) []models.RepositoryView {
+	var vs []models.RepositoryView
+	for _, id := range repoIDs {
+		vs = append(vs, models.RepositoryView{SessionID: sessionID, RepositoryID: id, ViewedAt: coViewNow.Add(-age)})
+	}
+	return vs
+}
+
+func neighbourIDs(list []Neighbour) []int64 {
+	ids := make([]int64, len(list))
+	for i, n := range list {
+		ids[i] = n.RepositoryID
+	}
+	return ids
+}
+
+func TestBuildCoViews(t *testing.T) {
+	var all []models.RepositoryView
+	all = append(all, views("s1", 0, 1, 2)...)
+	all = append(all, views("s2", 0, 1, 2, 3)...)
+	all = append(all, views("s3", 0, 1, 3)...)
+	all = append(all, views("s4", 0, 2)...)
+	// Repeated views of the same repository count once per session.
+	all = append(all, views("s1", time.Hour, 1)...)
+
+	got := BuildCoViews(all, coViewNow, CoViewOptions{MinSessions: 1})
+
+	if ids := neighbourIDs(got[1]); len(ids) != 2 || ids[0] != 3 || ids[1] != 2 {
+		t.Fatalf("neighbours of 1 = %v, want [3 2]", ids)
+	}
+	// 1 and 3 co-occur in 2 of their 3 and 2 sessions: 2/sqrt(3*2).
+	if score := got[1][0].Score; score < 0.816 || score > 0.817 {
+		t.Errorf("score of 1-3 = %v, want ~0.8165", score)
+	}
+	if len(got[3]) == 0 || got[3][0].RepositoryID != 1 || got[3][0].Score != got[1][0].Score {
+		t.Errorf("co-views should be symmetric, got %v", got[3])
+	}
+}
+
+func TestBuildCoViewsMinSessions(t *testing.T) {
+	var all []models.RepositoryView
+	all = append(all, views("s1", 0, 1, 2, 3)...)
+	all = append(all, views("s2", 0, 1, 2)...)
+
+	got := BuildCoViews(all, coViewNow, CoViewOptions{MinSessions: 2})
+	if ids := neighbourIDs(got[1]); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("neighbours of 1 = %v, want [2]", ids)
+	}
+	if _, ok := got[3]; ok {
+		t.Errorf("3 has too little support to have neighbours, got %v", got[3])
+	}
+}
+
+func TestBuildCoViewsDecay(t *testing.T) {
+	var all []models.RepositoryView
+	all = append(all, views("recent", 0, 1, 2)...)
+	all = append(all, views("old", 60*24*time.Hour, 1, 3)...)
+	all = append(all, views("other", 0, 2, 3)...)
+
+	got := BuildCoViews(all, coViewNow, CoViewOptions{HalfLife: 7 * 24 * time.Hour, MinSessions: 1})
+	if ids := neighbourIDs(got[1]); len(ids) != 2 || ids[0] != 2 {
+		t.Errorf("recent co-views should rank first, got %v", got[1])
+	}
+}
+
+func TestBuildCoViewsLimits(t *testing.T) {
+	var all []models.RepositoryView
+	for i := int64(1); i <= 5; i++ {
+		all = append(all, models.RepositoryView{SessionID: "long", RepositoryID: i, ViewedAt: coViewNow.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	got := BuildCoViews(all, coViewNow, CoViewOptions{MaxSessionViews: 3, MaxNeighbours: 1, MinSessions: 1})
+	if _, ok := got[5]; ok {
+		t.Error("only the 3 most recent views of a session should be paired")
+	}
+	if len(got[1]) != 1 {
+		t.Errorf("expected 1 neighbour, got %v", got[1])
+	}
+}
@@ -0,0 +1,250 @@
+// Package similarity scores how similar two repositories are by blending several
+// signals, each computed by a Scorer, with configurable weights.
+package similarity
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// Names of the scorers, as used in weight specifications.
+const (
+	Cosine       = models.ScorerCosine
+	Languages    = models.ScorerLanguages
+	Topics       = models.ScorerTopics
+	Dependencies = models.ScorerDependencies
+	CoViews      = models.ScorerCoViews
+)
+
+// Repository holds the signals of a repository that the scorers compare.
+type Repository struct {
+	ID     int64
+	Topics []string
+	// Languages maps each language to its size in bytes.
+	Languages map[string]int
+	// Dependencies identifies each runtime dependency as "ecosystem:name".
+	Dependencies []string
+	// Sessions is the number of sessions that viewed the repository.
+	Sessions int
+}
+
+// Candidate is a repository compared with the source repository, along with the
+// signals that are only defined for the pair.
+type Candidate struct {
+	Repository
+	// Semantic is the cosine similarity of the two repositories' embeddings.
+	Semantic float64
+	// CoViews is the number of sessions that viewed both repositories.
+	CoViews int
+}
+
+// Scorer scores one aspect of a candidate's similarity to the source repository, from
+// 0 (unrelated) to 1 (identical).
+type Scorer interface {
+	Name() string
+	Score(source Repository, candidate Candidate) float64
+}
+
+// Blend combines scorers into a weighted sum.
+type Blend struct {
+	scorers []Scorer
+	weights []float64
+}
+
+// NewBlend returns the blend of scorers with the given weights. Scorers without a
+// positive weight are left out; a weight without a matching scorer is an error.
+func NewBlend(weights map[string]float64, scorers ...Scorer) (*Blend, error) {
+	byName := make(map[string]Scorer, len(scorers))
+	for _, s := range scorers {
+		byName[s.Name()] = s
+	}
+
+	names := make([]string, 0, len(weights))
+	for name := range weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	blend := &Blend{}
+	for _, name := range names {
+		if weights[name] <= 0 {
+			continue
+		}
+		scorer, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("no scorer for weight %q", name)
+		}
+		blend.scorers = append(blend.scorers, scorer)
+		blend.weights = append(blend.weights, weights[name])
+	}
+	return blend, nil
+}
+
+// Uses reports whether the named scorer contributes to the blend, so that callers
+// can skip loading the signals it needs.
+func Uses(weights map[string]float64, name string) bool {
+	return weights[name] > 0
+}
+
+// Score returns the weighted sum of the scores of a candidate.
+func (b *Blend) Score(source Repository, candidate Candidate) float64 {
+	var total float64
+	for i, scorer := range b.scorers {
+		total += b.weights[i] * scorer.Score(source, candidate)
+	}
+	return total
+}
+
+// String describes the blend, e.g. "cosine=0.6 topics=0.4".
+func (b *Blend) String() string {
+	parts := make([]string, len(b.scorers))
+	for i, scorer := range b.scorers {
+		parts[i] = fmt.Sprintf("%s=%g", scorer.Name(), b.weights[i])
+	}
+	return strings.Join(parts, " ")
+}
+
+// CosineScorer passes through the embedding similarity found by the vector search.
+type CosineScorer struct{}
+
+func (CosineScorer) Name() string { return Cosine }
+
+func (CosineScorer) Score(source Repository, candidate Candidate) float64 {
+	return clamp(candidate.Semantic)
+}
+
+// LanguageScorer compares the share of bytes written in each language, so a
+// repository with a few lines of shell is not a shell project.
+type LanguageScorer struct{}
+
+func (LanguageScorer) Name() string { return Languages }
+
+func (LanguageScorer) Score(source Repository, candidate Candidate) float64 {
+	sourceTotal := totalBytes(source.Languages)
+	candidateTotal := totalBytes(candidate.Languages)
+	if sourceTotal == 0 || candidateTotal == 0 {
+		return 0
+	}
+	var overlap float64
+	for language, bytes := range source.Languages {
+		overlap += math.Min(float64(bytes)/sourceTotal, float64(candidate.Languages[language])/candidateTotal)
+	}
+	return clamp(overlap)
+}
+
+func totalBytes(languages map[string]int) float64 {
+	var total float64
+	for _, bytes := range languages {
+		if bytes > 0 {
+			total += float64(bytes)
+		}
+	}
+	return total
+}
+
+// TopicScorer is the cosine similarity of the repositories' topics weighted by
+// inverse document frequency, so sharing a rare topic counts for more than sharing
+// a common one.
+type TopicScorer struct {
+	frequencies map[string]int
+	total       int
+}
+
+// NewTopicScorer returns a TopicScorer for a corpus of total repositories, where
+// frequencies holds the number of repositories tagged with each topic.
+func NewTopicScorer(frequencies map[string]int, total int) *TopicScorer {
+	return &TopicScorer{frequencies: frequencies, total: total}
+}
+
+func (s *TopicScorer) Name() string { return Topics }
+
+func (s *TopicScorer) Score(source Repository, candidate Candidate) float64 {
+	sourceTopics := uniqueStrings(source.Topics)
+	candidateTopics := uniqueStrings(candidate.Topics)
+	if len(sourceTopics) == 0 || len(candidateTopics) == 0 {
+		return 0
+	}
+
+	var dot, sourceNorm, candidateNorm float64
+	for topic := range sourceTopics {
+		weight := s.idf(topic)
+		sourceNorm += weight * weight
+		if candidateTopics[topic] {
+			dot += weight * weight
+		}
+	}
+	for topic := range candidateTopics {
+		weight := s.idf(topic)
+		candidateNorm += weight * weight
+	}
+	return clamp(dot / math.Sqrt(sourceNorm*candidateNorm))
+}
+
+// idf is the smoothed inverse document frequency of a topic.
+func (s *TopicScorer) idf(topic string) float64 {
+	return math.Log(float64(1+s.total)/float64(1+s.frequencies[topic])) + 1
+}
+
+// DependencyScorer is the Jaccard similarity of the repositories' runtime dependencies.
+type DependencyScorer struct{}
+
+func (DependencyScorer) Name() string { return Dependencies }
+
+func (DependencyScorer) Score(source Repository, candidate Candidate) float64 {
+	return Jaccard(source.Dependencies, candidate.Dependencies)
+}
+
+// CoViewScorer is the number of sessions that viewed both repositories, normalised by
+// the geometric mean of their individual session counts so that popular repositories
+// do not match everything.
+type CoViewScorer struct{}
+
+func (CoViewScorer) Name() string { return CoViews }
+
+func (CoViewScorer) Score(source Repository, candidate Candidate) float64 {
+	if source.Sessions == 0 || candidate.Sessions == 0 {
+		return 0
+	}
+	return clamp(float64(candidate.CoViews) / math.Sqrt(float64(source.Sessions)*float64(candidate.Sessions)))
+}
+
+// Jaccard returns the size of the intersection of a and b over the size of their union.
+func Jaccard(a, b []string) float64 {
+	setA := uniqueStrings(a)
+	setB := uniqueStrings(b)
+
+	intersection := 0
+	for item := range setA {
+		if setB[item] {
+			intersection++
+		}
+	}
+
+	union := len(setA) + len(setB) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
+
+func uniqueStrings(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+func clamp(score float64) float64 {
+	if score < 0 || math.IsNaN(score) {
+		return 0
+	}
+	if score > 1 {
+		return 1
+	}
+	return score
+}
@@ -0,0 +1,105 @@
+package similarity
+
+import (
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestBlend(t *testing.T) {
+	weights := map[string]float64{Cosine: 0.6, Dependencies: 0.4, Topics: 0}
+	blend, err := NewBlend(weights, CosineScorer{}, DependencyScorer{})
+	if err != nil {
+		t.Fatalf("NewBlend returned an error: %v", err)
+	}
+	if got := blend.String(); got != "cosine=0.6 dependencies=0.4" {
+		t.Errorf("String() = %q", got)
+	}
+
+	source := Repository{Dependencies: []string{"go:a", "go:b"}}
+	candidate := Candidate{Repository: Repository{Dependencies: []string{"go:b"}}, Semantic: 0.5}
+	if got := blend.Score(source, candidate); !almostEqual(got, 0.6*0.5+0.4*0.5) {
+		t.Errorf("Score() = %v", got)
+	}
+
+	if _, err := NewBlend(map[string]float64{CoViews: 1}, CosineScorer{}); err == nil {
+		t.Error("NewBlend should fail for a weight without a scorer")
+	}
+	if !Uses(weights, Cosine) || Uses(weights, Topics) || Uses(weights, Languages) {
+		t.Error("Uses should only report scorers with a positive weight")
+	}
+}
+
+func TestLanguageScorer(t *testing.T) {
+	source := Repository{Languages: map[string]int{"Go": 900, "Shell": 100}}
+	tests := []struct {
+		languages map[string]int
+		want      float64
+	}{
+		{map[string]int{"Go": 9000, "Shell": 1000}, 1},
+		{map[string]int{"Go": 100, "Shell": 900}, 0.2},
+		{map[string]int{"Rust": 1000}, 0},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		got := LanguageScorer{}.Score(source, Candidate{Repository: Repository{Languages: tt.languages}})
+		if !almostEqual(got, tt.want) {
+			t.Errorf("Score(%v) = %v, want %v", tt.languages, got, tt.want)
+		}
+	}
+}
+
+func TestTopicScorerFavoursRareTopics(t *testing.T) {
+	scorer := NewTopicScorer(map[string]int{"cli": 500, "tui": 5, "go": 800}, 1000)
+	source := Repository{Topics: []string{"cli", "tui", "go"}}
+
+	rare := scorer.Score(source, Candidate{Repository: Repository{Topics: []string{"tui", "python"}}})
+	common := scorer.Score(source, Candidate{Repository: Repository{Topics: []string{"go", "python"}}})
+	if rare <= common {
+		t.Errorf("sharing a rare topic (%v) should score higher than a common one (%v)", rare, common)
+	}
+
+	if got := scorer.Score(source, Candidate{Repository: Repository{Topics: []string{"go", "tui", "cli", "cli"}}}); !almostEqual(got, 1) {
+		t.Errorf("identical topics scored %v, want 1", got)
+	}
+	if got := scorer.Score(source, Candidate{}); got != 0 {
+		t.Errorf("no topics scored %v, want 0", got)
+	}
+}
+
+func TestCoViewScorer(t *testing.T) {
+	source := Repository{Sessions: 100}
+	tests := []struct {
+		candidate Candidate
+		want      float64
+	}{
+		{Candidate{Repository: Repository{Sessions: 25}, CoViews: 25}, 0.5},
+		{Candidate{Repository: Repository{Sessions: 100}, CoViews: 100}, 1},
+		{Candidate{Repository: Repository{Sessions: 0}, CoViews: 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := (CoViewScorer{}).Score(source, tt.candidate); !almostEqual(got, tt.want) {
+			t.Errorf("Score(%+v) = %v, want %v", tt.candidate, got, tt.want)
+		}
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want float64
+	}{
+		{[]string{"go", "cli"}, []string{"go", "cli"}, 1},
+		{[]string{"go", "cli"}, []string{"go", "web"}, 1.0 / 3},
+		{[]string{"go"}, []string{"rust"}, 0},
+		{nil, nil, 0},
+	}
+	for _, tt := range tests {
+		if got := Jaccard(tt.a, tt.b); !almostEqual(got, tt.want) {
+			t.Errorf("Jaccard(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
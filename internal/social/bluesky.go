@@ -0,0 +1,190 @@
+package social
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BlueskyRules are the rules of Bluesky posts, counted in graphemes. The link is
+// attached as a card, since links written in the text count in full against the 300
+// characters.
+var BlueskyRules = Rules{
+	MaxLength:         300,
+	Counting:          CountGraphemes,
+	MaxHashtags:       3,
+	MaxHashtagLength:  30,
+	CamelCaseHashtags: true,
+	Threads:           true,
+}
+
+// BlueskyClient implements the Poster interface for Bluesky, through the AT protocol
+// XRPC API of the account's PDS.
+type BlueskyClient struct {
+	pdsURL   string
+	handle   string
+	password string
+	composer *Composer
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewBlueskyClient creates a BlueskyClient logging in to the PDS at pdsURL with an app
+// password. A nil composer uses the built-in templates, and a nil client a client
+// with a 30 second timeout.
+func NewBlueskyClient(pdsURL, handle, appPassword string, composer *Composer, client *http.Client) *BlueskyClient {
+	if composer == nil {
+		composer = defaultComposer()
+	}
+	return &BlueskyClient{
+		pdsURL:   strings.TrimSuffix(pdsURL, "/"),
+		handle:   handle,
+		password: appPassword,
+		composer: composer,
+		client:   newHTTPClient(client),
+		now:      time.Now,
+	}
+}
+
+// Network returns "bluesky".
+func (c *BlueskyClient) Network() string {
+	return "bluesky"
+}
+
+// blueskyFacet annotates a range of a post's text, given in UTF-8 bytes.
+type blueskyFacet struct {
+	Index struct {
+		ByteStart int `json:"byteStart"`
+		ByteEnd   int `json:"byteEnd"`
+	} `json:"index"`
+	Features []map[string]string `json:"features"`
+}
+
+// blueskyRef is a strong reference to a record, which replies point to.
+type blueskyRef struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+// Post logs in and creates an app.bsky.feed.post record for a repository, with its
+// hashtags as tag facets and its link as an external embed with the OG image as
+// thumbnail, then the replies of its thread.
+func (c *BlueskyClient) Post(content Content) (string, error) {
+	messages, err := c.composer.Compose(c.Network(), BlueskyRules, content)
+	if err != nil {
+		return "", err
+	}
+
+	var session struct {
+		AccessJwt string `json:"accessJwt"`
+		DID       string `json:"did"`
+	}
+	login := map[string]string{"identifier": c.handle, "password": c.password}
+	if _, err := postJSON(c.client, c.pdsURL+"/xrpc/com.atproto.server.createSession", nil, login, &session); err != nil {
+		return "", fmt.Errorf("bluesky login error: %w", err)
+	}
+	headers := map[string]string{"Authorization": "Bearer " + session.AccessJwt}
+
+	external := map[string]any{
+		"uri":         messages[0].Link,
+		"title":       content.Repository.FullName,
+		"description": content.Repository.Description.String,
+	}
+	if content.Image != nil {
+		blob, err := c.uploadBlob(headers, content.Image)
+		if err != nil {
+			logImageUploadFailure(c.Network(), content, err)
+		} else {
+			external["thumb"] = blob
+		}
+	}
+
+	postURL := ""
+	var root, parent *blueskyRef
+	for i, msg := range messages {
+		record := map[string]any{
+			"$type":     "app.bsky.feed.post",
+			"text":      msg.Text,
+			"createdAt": c.now().UTC().Format(time.RFC3339),
+			"langs":     []string{"en"},
+			"facets":    facets(msg),
+		}
+		if i == 0 {
+			record["embed"] = map[string]any{"$type": "app.bsky.embed.external", "external": external}
+		} else {
+			record["reply"] = map[string]*blueskyRef{"root": root, "parent": parent}
+		}
+		body := map[string]any{
+			"repo":       session.DID,
+			"collection": "app.bsky.feed.post",
+			"record":     record,
+		}
+		var created blueskyRef
+		if _, err := postJSON(c.client, c.pdsURL+"/xrpc/com.atproto.repo.createRecord", headers, body, &created); err != nil {
+			return postURL, fmt.Errorf("bluesky API error: %w", err)
+		}
+		parent = &created
+		if i == 0 {
+			root = &created
+			// The record is at at://<did>/app.bsky.feed.post/<rkey>.
+			rkey := created.URI[strings.LastIndex(created.URI, "/")+1:]
+			postURL = fmt.Sprintf("https://bsky.app/profile/%s/post/%s", session.DID, rkey)
+		}
+	}
+	return postURL, nil
+}
+
+// uploadBlob uploads an image and returns the blob record embeds refer to it with.
+func (c *BlueskyClient) uploadBlob(headers map[string]string, image []byte) (json.RawMessage, error) {
+	req, err := http.NewRequest(http.MethodPost, c.pdsURL+"/xrpc/com.atproto.repo.uploadBlob", bytes.NewReader(image))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "image/png")
+	var uploaded struct {
+		Blob json.RawMessage `json:"blob"`
+	}
+	if _, err := do(c.client, req, &uploaded); err != nil {
+		return nil, err
+	}
+	return uploaded.Blob, nil
+}
+
+// facets returns the facets making the hashtags and links of a post's text links;
+// Bluesky does not detect them in the text.
+func facets(msg Message) []blueskyFacet {
+	facets := []blueskyFacet{}
+	add := func(start, end int, feature map[string]string) {
+		var facet blueskyFacet
+		facet.Index.ByteStart = start
+		facet.Index.ByteEnd = end
+		facet.Features = []map[string]string{feature}
+		facets = append(facets, facet)
+	}
+	for _, loc := range urlRe.FindAllStringIndex(msg.Text, -1) {
+		add(loc[0], loc[1], map[string]string{"$type": "app.bsky.richtext.facet#link", "uri": msg.Text[loc[0]:loc[1]]})
+	}
+	for _, tag := range msg.Hashtags {
+		// The hashtag as a whole word, not the start of a longer one.
+		for offset := 0; offset < len(msg.Text); {
+			i := strings.Index(msg.Text[offset:], tag)
+			if i < 0 {
+				break
+			}
+			start, end := offset+i, offset+i+len(tag)
+			offset = end
+			if end < len(msg.Text) && !strings.ContainsRune(" \n\t.,;:!?)", rune(msg.Text[end])) {
+				continue
+			}
+			add(start, end, map[string]string{"$type": "app.bsky.richtext.facet#tag", "tag": strings.TrimPrefix(tag, "#")})
+			break
+		}
+	}
+	return facets
+}
@@ -0,0 +1,128 @@
+package social
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blueskyRecord is the body of a createRecord request.
+type blueskyRecord struct {
+	Repo       string `json:"repo"`
+	Collection string `json:"collection"`
+	Record     struct {
+		Text      string         `json:"text"`
+		CreatedAt string         `json:"createdAt"`
+		Facets    []blueskyFacet `json:"facets"`
+		Embed     struct {
+			External struct {
+				URI   string          `json:"uri"`
+				Thumb json.RawMessage `json:"thumb"`
+			} `json:"external"`
+		} `json:"embed"`
+		Reply map[string]blueskyRef `json:"reply"`
+	} `json:"record"`
+}
+
+func TestBlueskyClientPost(t *testing.T) {
+	var records []blueskyRecord
+	var uploaded []byte
+	mux := http.NewServeMux()
+	mux.HandleFunc("/xrpc/com.atproto.server.createSession", func(w http.ResponseWriter, r *http.Request) {
+		var login map[string]string
+		json.NewDecoder(r.Body).Decode(&login)
+		if login["identifier"] != "gitfinder.bsky.social" || login["password"] != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"accessJwt":"jwt","did":"did:plc:abc"}`))
+	})
+	mux.HandleFunc("/xrpc/com.atproto.repo.uploadBlob", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "image/png" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		uploaded, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"blob":{"$type":"blob","ref":{"$link":"bafyimage"},"mimeType":"image/png","size":10}}`))
+	})
+	mux.HandleFunc("/xrpc/com.atproto.repo.createRecord", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer jwt" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var record blueskyRecord
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+			return
+		}
+		records = append(records, record)
+		if len(records) == 1 {
+			w.Write([]byte(`{"uri":"at://did:plc:abc/app.bsky.feed.post/3kxyz","cid":"bafy1"}`))
+		} else {
+			w.Write([]byte(`{"uri":"at://did:plc:abc/app.bsky.feed.post/3kreply","cid":"bafy2"}`))
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	composer, err := NewComposer("", true)
+	if err != nil {
+		t.Fatalf("NewComposer() error = %v", err)
+	}
+	client := NewBlueskyClient(server.URL, "gitfinder.bsky.social", "app-password", composer, server.Client())
+	client.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	content := testContent()
+	content.Image = []byte("\x89PNG image")
+	content.Highlights = []string{"Builds in milliseconds"}
+	postURL, err := client.Post(content)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if postURL != "https://bsky.app/profile/did:plc:abc/post/3kxyz" {
+		t.Errorf("Post() = %q", postURL)
+	}
+	if string(uploaded) != "\x89PNG image" {
+		t.Errorf("uploaded %q, want the OG image", uploaded)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("created %d records, want the post and a reply", len(records))
+	}
+	post := records[0]
+	if post.Repo != "did:plc:abc" || post.Collection != "app.bsky.feed.post" || post.Record.CreatedAt != "2024-05-01T12:00:00Z" {
+		t.Errorf("unexpected record %+v", post)
+	}
+	if post.Record.Embed.External.URI != "https://app.gitfinder.dev/repository/42" || len(post.Record.Embed.External.Thumb) == 0 {
+		t.Errorf("embed = %+v", post.Record.Embed.External)
+	}
+
+	// Facets index the UTF-8 bytes of the text, which starts with a 4 byte emoji.
+	if len(post.Record.Facets) != 3 {
+		t.Fatalf("facets = %+v, want 3", post.Record.Facets)
+	}
+	for _, facet := range post.Record.Facets {
+		tag := post.Record.Text[facet.Index.ByteStart:facet.Index.ByteEnd]
+		if "#"+facet.Features[0]["tag"] != tag {
+			t.Errorf("facet %+v covers %q", facet, tag)
+		}
+	}
+
+	reply := records[1].Record.Reply
+	if reply["root"].CID != "bafy1" || reply["parent"].CID != "bafy1" {
+		t.Errorf("reply = %+v, want it to point to the post", reply)
+	}
+}
+
+func TestBlueskyClientPostLoginError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"AuthenticationRequired"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewBlueskyClient(server.URL, "gitfinder.bsky.social", "wrong", nil, server.Client()).Post(testContent())
+	if err == nil {
+		t.Error("Post() error = nil, want a login error")
+	}
+}
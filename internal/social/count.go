@@ -0,0 +1,130 @@
+package social
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Counting is how a network counts the characters of posts.
+type Counting int
+
+const (
+	// CountCodePoints counts Unicode code points, as Mastodon and LinkedIn do.
+	CountCodePoints Counting = iota
+	// CountGraphemes counts user-perceived characters, as Bluesky does: an emoji made
+	// of several code points counts as one.
+	CountGraphemes
+	// CountWeighted counts as Twitter does: code points count 1 in the Latin, general
+	// punctuation and similar ranges and 2 elsewhere (CJK, most emoji), and emoji
+	// sequences count 2 in all, after NFC normalization.
+	CountWeighted
+)
+
+// urlRe matches the links networks count at LinkLength.
+var urlRe = regexp.MustCompile(`https?://[^\s]+`)
+
+// length counts the characters of a post's text as the network does.
+func (r Rules) length(text string) int {
+	if r.LinkLength <= 0 {
+		return r.count(text)
+	}
+	n, last := 0, 0
+	for _, loc := range urlRe.FindAllStringIndex(text, -1) {
+		n += r.count(text[last:loc[0]]) + r.LinkLength
+		last = loc[1]
+	}
+	return n + r.count(text[last:])
+}
+
+// count counts the characters of text without links.
+func (r Rules) count(text string) int {
+	switch r.Counting {
+	case CountGraphemes:
+		return uniseg.GraphemeClusterCount(text)
+	case CountWeighted:
+		n := 0
+		state := -1
+		var cluster string
+		rest := norm.NFC.String(text)
+		for len(rest) > 0 {
+			cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+			n += weight(cluster)
+		}
+		return n
+	default:
+		return utf8.RuneCountInString(text)
+	}
+}
+
+// weight returns what a grapheme cluster counts for on Twitter.
+func weight(cluster string) int {
+	if utf8.RuneCountInString(cluster) > 1 && isEmoji(cluster) {
+		return 2
+	}
+	n := 0
+	for _, r := range cluster {
+		switch {
+		case r <= 0x10FF, r >= 0x2000 && r <= 0x200D, r >= 0x2010 && r <= 0x201F, r >= 0x2032 && r <= 0x2037:
+			n++
+		default:
+			n += 2
+		}
+	}
+	return n
+}
+
+// isEmoji reports whether a grapheme cluster of several code points is an emoji
+// sequence: a ZWJ sequence, a flag, a keycap or an emoji with a variation selector or
+// a skin tone.
+func isEmoji(cluster string) bool {
+	for _, r := range cluster {
+		switch {
+		case r == 0x200D, r == 0xFE0F, r == 0x20E3,
+			r >= 0x1F1E6 && r <= 0x1F1FF, // regional indicators
+			r >= 0x1F3FB && r <= 0x1F3FF, // skin tones
+			r >= 0xE0020 && r <= 0xE007F: // tags
+			return true
+		}
+	}
+	return false
+}
+
+// truncate shortens text so that it counts at most max characters, ending it with an
+// ellipsis on a word boundary when it has to be cut. It never cuts inside a grapheme
+// cluster, such as an emoji sequence or a letter with combining accents.
+func (r Rules) truncate(text string, max int) string {
+	if r.length(text) <= max {
+		return text
+	}
+	const ellipsis = "…"
+	budget := max - r.length(ellipsis)
+	if budget <= 0 {
+		return ""
+	}
+
+	// Byte index just past the longest prefix of whole clusters that fits.
+	cut, state := 0, -1
+	rest := text
+	var cluster string
+	for len(rest) > 0 {
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		if r.length(text[:cut+len(cluster)]) > budget {
+			break
+		}
+		cut += len(cluster)
+	}
+	prefix := text[:cut]
+	if i := strings.LastIndexFunc(prefix, unicode.IsSpace); i > len(prefix)/2 {
+		prefix = prefix[:i]
+	}
+	prefix = strings.TrimRightFunc(prefix, func(c rune) bool { return unicode.IsSpace(c) || unicode.IsPunct(c) })
+	if prefix == "" {
+		return ""
+	}
+	return prefix + ellipsis
+}
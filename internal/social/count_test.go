@@ -0,0 +1,66 @@
+package social
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRulesLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		text  string
+		want  int
+	}{
+		{"links count 23", TwitterRules, "see https://app.gitfinder.dev/repository/42", 4 + 23},
+		{"CJK counts 2", TwitterRules, "日本語", 6},
+		{"emoji sequence counts 2", TwitterRules, "👩‍💻", 2},
+		{"flag counts 2", TwitterRules, "🇮🇹", 2},
+		{"decomposed accent counts 1", TwitterRules, "é", 1},
+		{"code points", MastodonRules, "👩‍💻 ok", 3 + 3},
+		{"graphemes", BlueskyRules, "👩‍💻 🇮🇹 é", 5},
+		{"links count in full", BlueskyRules, "https://example.com", 19},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.length(tt.text); got != tt.want {
+			t.Errorf("%s: length(%q) = %d, want %d", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRulesTruncate(t *testing.T) {
+	tests := []struct {
+		rules Rules
+		text  string
+		max   int
+		want  string
+	}{
+		{BlueskyRules, "short", 10, "short"},
+		{BlueskyRules, "hello wonderful world", 16, "hello wonderful…"},
+		{BlueskyRules, "héllo wörld", 8, "héllo…"},
+		{BlueskyRules, "anything", 1, ""},
+		// Emoji sequences are kept whole or dropped, never split.
+		{BlueskyRules, "ab👩‍💻cd", 4, "ab👩‍💻…"},
+		{TwitterRules, "ab👩‍💻cd", 4, "ab…"},
+		// The ellipsis counts 2 on Twitter.
+		{TwitterRules, "日本語のテキスト", 8, "日本語…"},
+	}
+	for _, tt := range tests {
+		got := tt.rules.truncate(tt.text, tt.max)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+		if !utf8.ValidString(got) || tt.rules.length(got) > tt.max {
+			t.Errorf("truncate(%q, %d) = %q does not fit", tt.text, tt.max, got)
+		}
+	}
+}
+
+func TestRulesTruncateKeepsCombiningMarks(t *testing.T) {
+	text := strings.Repeat("é", 10)
+	got := BlueskyRules.truncate(text, 5)
+	if got != strings.Repeat("é", 4)+"…" {
+		t.Errorf("truncate() = %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package social
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxErrorBody caps the response body quoted in API errors.
+const maxErrorBody = 512
+
+// newHTTPClient returns client, or a client with a 30 second timeout if it is nil.
+func newHTTPClient(client *http.Client) *http.Client {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return client
+}
+
+// postJSON posts body as JSON to url and decodes the JSON response into out, which
+// may be nil.
+func postJSON(client *http.Client, url string, headers map[string]string, body, out any) (http.Header, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	return do(client, req, out)
+}
+
+// do sends a request and decodes the JSON response into out, which may be nil.
+// Responses other than 2xx are returned as errors quoting their body.
+func do(client *http.Client, req *http.Request, out any) (http.Header, error) {
+	url := req.URL.String()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("failed to decode response of %s: %w", url, err)
+		}
+	}
+	return resp.Header, nil
+}
@@ -0,0 +1,67 @@
+package social
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// maxOGImageSize caps the OG images attached to posts.
+const maxOGImageSize = 5 << 20
+
+// FetchOGImage downloads the OG image of a repository from the API's /api/og
+// endpoint at publicAPIURL. A nil client uses a client with a 30 second timeout.
+func FetchOGImage(client *http.Client, publicAPIURL string, repoID int) ([]byte, error) {
+	resp, err := newHTTPClient(client).Get(fmt.Sprintf("%s/api/og?id=%d", strings.TrimSuffix(publicAPIURL, "/"), repoID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OG image request returned %s", resp.Status)
+	}
+	image, err := io.ReadAll(io.LimitReader(resp.Body, maxOGImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(image) > maxOGImageSize {
+		return nil, fmt.Errorf("OG image is larger than %d bytes", maxOGImageSize)
+	}
+	return image, nil
+}
+
+// logImageUploadFailure logs that the OG image of a repository could not be uploaded
+// to a network. The image only illustrates the post, which is made without it.
+func logImageUploadFailure(network string, content Content, err error) {
+	log.Printf("Failed to upload the OG image of %s to %s, posting without it: %v", content.Repository.FullName, network, err)
+}
+
+// multipartImage returns a multipart form holding the OG image of a repository as the
+// file field, with its alternative text as the description field, and the form's
+// content type.
+func multipartImage(field string, content Content) (io.Reader, string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="repository-%d.png"`, field, content.Repository.ID))
+	header.Set("Content-Type", "image/png")
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(content.Image); err != nil {
+		return nil, "", err
+	}
+	if err := form.WriteField("description", content.ImageDescription()); err != nil {
+		return nil, "", err
+	}
+	if err := form.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, form.FormDataContentType(), nil
+}
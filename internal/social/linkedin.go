@@ -0,0 +1,164 @@
+package social
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// linkedInAPIURL is the LinkedIn REST API, and linkedInVersion the version of it posts
+// are created with.
+const (
+	linkedInAPIURL  = "https://api.linkedin.com"
+	linkedInVersion = "202409"
+)
+
+// LinkedInRules are the rules of LinkedIn posts. The link is attached as an article
+// preview rather than written in the commentary, and posts have no threads.
+var LinkedInRules = Rules{
+	MaxLength:         3000,
+	Counting:          CountCodePoints,
+	MaxHashtags:       5,
+	MaxHashtagLength:  30,
+	CamelCaseHashtags: true,
+}
+
+// LinkedInClient implements the Poster interface for LinkedIn, through the Posts API.
+type LinkedInClient struct {
+	apiURL      string
+	accessToken string
+	authorURN   string
+	composer    *Composer
+	client      *http.Client
+}
+
+// NewLinkedInClient creates a LinkedInClient posting as authorURN, a person or
+// organization URN. A nil composer uses the built-in templates, and a nil client a
+// client with a 30 second timeout.
+func NewLinkedInClient(accessToken, authorURN string, composer *Composer, client *http.Client) *LinkedInClient {
+	if composer == nil {
+		composer = defaultComposer()
+	}
+	return &LinkedInClient{
+		apiURL:      linkedInAPIURL,
+		accessToken: accessToken,
+		authorURN:   authorURN,
+		composer:    composer,
+		client:      newHTTPClient(client),
+	}
+}
+
+// Network returns "linkedin".
+func (c *LinkedInClient) Network() string {
+	return "linkedin"
+}
+
+// Post posts a repository to the author's feed, with its OG image as the thumbnail of
+// the article preview.
+func (c *LinkedInClient) Post(content Content) (string, error) {
+	messages, err := c.composer.Compose(c.Network(), LinkedInRules, content)
+	if err != nil {
+		return "", err
+	}
+	msg, repo := messages[0], content.Repository
+
+	article := map[string]string{
+		"source":      msg.Link,
+		"title":       repo.FullName,
+		"description": repo.Description.String,
+	}
+	if content.Image != nil {
+		image, err := c.uploadImage(content.Image)
+		if err != nil {
+			logImageUploadFailure(c.Network(), content, err)
+		} else {
+			article["thumbnail"] = image
+		}
+	}
+
+	body := map[string]any{
+		"author":     c.authorURN,
+		"commentary": commentary(msg),
+		"visibility": "PUBLIC",
+		"distribution": map[string]any{
+			"feedDistribution":               "MAIN_FEED",
+			"targetEntities":                 []string{},
+			"thirdPartyDistributionChannels": []string{},
+		},
+		"content":                   map[string]any{"article": article},
+		"lifecycleState":            "PUBLISHED",
+		"isReshareDisabledByAuthor": false,
+	}
+	header, err := postJSON(c.client, c.apiURL+"/rest/posts", c.headers(), body, nil)
+	if err != nil {
+		return "", fmt.Errorf("linkedin API error: %w", err)
+	}
+	// The URN of the post created, e.g. urn:li:share:123, is only in this header.
+	urn := header.Get("X-Restli-Id")
+	if urn == "" {
+		return "", fmt.Errorf("linkedin API returned no post URN")
+	}
+	return fmt.Sprintf("https://www.linkedin.com/feed/update/%s/", urn), nil
+}
+
+// uploadImage uploads an image through the Images API and returns its URN.
+func (c *LinkedInClient) uploadImage(image []byte) (string, error) {
+	body := map[string]any{"initializeUploadRequest": map[string]string{"owner": c.authorURN}}
+	var upload struct {
+		Value struct {
+			UploadURL string `json:"uploadUrl"`
+			Image     string `json:"image"`
+		} `json:"value"`
+	}
+	if _, err := postJSON(c.client, c.apiURL+"/rest/images?action=initializeUpload", c.headers(), body, &upload); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, upload.Value.UploadURL, bytes.NewReader(image))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Content-Type", "image/png")
+	if _, err := do(c.client, req, nil); err != nil {
+		return "", err
+	}
+	return upload.Value.Image, nil
+}
+
+// headers returns the headers of REST API requests.
+func (c *LinkedInClient) headers() map[string]string {
+	return map[string]string{
+		"Authorization":             "Bearer " + c.accessToken,
+		"LinkedIn-Version":          linkedInVersion,
+		"X-Restli-Protocol-Version": "2.0.0",
+	}
+}
+
+// escapedHashtagRe matches the hashtags of an escaped commentary.
+var escapedHashtagRe = regexp.MustCompile(`\\#[\pL\pN]+`)
+
+// littleTextEscaper escapes the characters reserved by LinkedIn's "little text" format
+// of commentaries, which otherwise truncate or mangle posts.
+var littleTextEscaper = strings.NewReplacer(
+	`\`, `\\`, `|`, `\|`, `{`, `\{`, `}`, `\}`, `@`, `\@`, `[`, `\[`, `]`, `\]`,
+	`(`, `\(`, `)`, `\)`, `<`, `\<`, `>`, `\>`, `#`, `\#`, `*`, `\*`, `_`, `\_`, `~`, `\~`,
+)
+
+// commentary writes a post's text in little text, with its hashtags as hashtag
+// templates.
+func commentary(msg Message) string {
+	tags := make(map[string]bool)
+	for _, tag := range msg.Hashtags {
+		tags[strings.TrimPrefix(tag, "#")] = true
+	}
+	return escapedHashtagRe.ReplaceAllStringFunc(littleTextEscaper.Replace(msg.Text), func(m string) string {
+		tag := strings.TrimPrefix(m, `\#`)
+		if !tags[tag] {
+			return m
+		}
+		return fmt.Sprintf(`{hashtag|\#|%s}`, tag)
+	})
+}
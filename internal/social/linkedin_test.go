@@ -0,0 +1,86 @@
+package social
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLinkedInClientPost(t *testing.T) {
+	var body struct {
+		Author     string `json:"author"`
+		Commentary string `json:"commentary"`
+		Content    struct {
+			Article map[string]string `json:"article"`
+		} `json:"content"`
+	}
+	var uploaded []byte
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("/rest/images", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("action") != "initializeUpload" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"value":{"uploadUrl":"` + server.URL + `/upload/1","image":"urn:li:image:1"}}`))
+	})
+	mux.HandleFunc("/upload/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("upload method = %s", r.Method)
+		}
+		uploaded, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/rest/posts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("LinkedIn-Version") == "" || r.Header.Get("X-Restli-Protocol-Version") != "2.0.0" {
+			t.Errorf("missing version headers: %v", r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+			return
+		}
+		w.Header().Set("X-Restli-Id", "urn:li:share:123")
+		w.WriteHeader(http.StatusCreated)
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewLinkedInClient("token", "urn:li:organization:1", nil, server.Client())
+	client.apiURL = server.URL
+	content := testContent()
+	content.Repository.Description.String = "Rockets (fast) for #builds"
+	content.Image = []byte("\x89PNG image")
+	postURL, err := client.Post(content)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if postURL != "https://www.linkedin.com/feed/update/urn:li:share:123/" {
+		t.Errorf("Post() = %q", postURL)
+	}
+	if string(uploaded) != "\x89PNG image" || body.Content.Article["thumbnail"] != "urn:li:image:1" {
+		t.Errorf("OG image not attached: uploaded %q, article %v", uploaded, body.Content.Article)
+	}
+
+	want := "🚀 Trending on GitHub: acme/rocket 🐹\n\nRockets \\(fast\\) for \\#builds\n\n⭐ 12.3k stars, +1.5k in the last 7 days\n\n" +
+		`{hashtag|\#|Go} {hashtag|\#|MachineLearning} {hashtag|\#|Cli} {hashtag|\#|DeveloperProductivityTools}`
+	if body.Commentary != want {
+		t.Errorf("commentary = %q, want %q", body.Commentary, want)
+	}
+	if body.Author != "urn:li:organization:1" || body.Content.Article["source"] != "https://app.gitfinder.dev/repository/42" {
+		t.Errorf("unexpected post %+v", body)
+	}
+}
+
+func TestLinkedInClientPostWithoutURN(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := NewLinkedInClient("token", "urn:li:person:1", nil, server.Client())
+	client.apiURL = server.URL
+	if _, err := client.Post(testContent()); err == nil {
+		t.Error("Post() error = nil, want an error")
+	}
+}
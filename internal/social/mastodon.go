@@ -0,0 +1,129 @@
+package social
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MastodonRules are the rules of Mastodon statuses: links count as 23 characters,
+// hashtags are camel-cased for screen readers and replies make threads.
+var MastodonRules = Rules{
+	MaxLength:         500,
+	Counting:          CountCodePoints,
+	LinkLength:        23,
+	LinkInText:        true,
+	MaxHashtags:       5,
+	MaxHashtagLength:  30,
+	CamelCaseHashtags: true,
+	Threads:           true,
+}
+
+// MastodonClient implements the Poster interface for Mastodon.
+type MastodonClient struct {
+	instanceURL string
+	accessToken string
+	composer    *Composer
+	client      *http.Client
+}
+
+// NewMastodonClient creates a MastodonClient posting to the server at instanceURL. A
+// nil composer uses the built-in templates, and a nil client a client with a 30
+// second timeout.
+func NewMastodonClient(instanceURL, accessToken string, composer *Composer, client *http.Client) *MastodonClient {
+	if composer == nil {
+		composer = defaultComposer()
+	}
+	return &MastodonClient{
+		instanceURL: strings.TrimSuffix(instanceURL, "/"),
+		accessToken: accessToken,
+		composer:    composer,
+		client:      newHTTPClient(client),
+	}
+}
+
+// Network returns "mastodon".
+func (c *MastodonClient) Network() string {
+	return "mastodon"
+}
+
+// Post posts a repository as a public status with its OG image, then the replies of
+// its thread.
+func (c *MastodonClient) Post(content Content) (string, error) {
+	messages, err := c.composer.Compose(c.Network(), MastodonRules, content)
+	if err != nil {
+		return "", err
+	}
+
+	var mediaIDs []string
+	if content.Image != nil {
+		mediaID, err := c.uploadImage(content)
+		if err != nil {
+			logImageUploadFailure(c.Network(), content, err)
+		} else {
+			mediaIDs = append(mediaIDs, mediaID)
+		}
+	}
+
+	postURL := ""
+	replyTo := ""
+	for i, msg := range messages {
+		body := map[string]any{
+			"status":     msg.Text,
+			"visibility": "public",
+			"language":   "en",
+		}
+		if i == 0 && len(mediaIDs) > 0 {
+			body["media_ids"] = mediaIDs
+		}
+		if replyTo != "" {
+			body["in_reply_to_id"] = replyTo
+		}
+		headers := c.headers()
+		// Retries of a request Mastodon already handled return the same status rather
+		// than posting it twice.
+		headers["Idempotency-Key"] = fmt.Sprintf("gitfinder-repository-%d-%d", content.Repository.ID, i)
+
+		var status struct {
+			ID  string `json:"id"`
+			URL string `json:"url"`
+		}
+		if _, err := postJSON(c.client, c.instanceURL+"/api/v1/statuses", headers, body, &status); err != nil {
+			return postURL, fmt.Errorf("mastodon API error: %w", err)
+		}
+		replyTo = status.ID
+		if i == 0 {
+			postURL = status.URL
+		}
+	}
+	return postURL, nil
+}
+
+// uploadImage uploads the OG image of a repository, with its alternative text, and
+// returns its media attachment ID.
+func (c *MastodonClient) uploadImage(content Content) (string, error) {
+	body, contentType, err := multipartImage("file", content)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.instanceURL+"/api/v2/media", body)
+	if err != nil {
+		return "", err
+	}
+	for name, value := range c.headers() {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", contentType)
+	var media struct {
+		ID string `json:"id"`
+	}
+	if _, err := do(c.client, req, &media); err != nil {
+		return "", err
+	}
+	return media.ID, nil
+}
+
+// headers returns the headers of API requests.
+func (c *MastodonClient) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + c.accessToken}
+}
@@ -0,0 +1,96 @@
+package social
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMastodonClientPost(t *testing.T) {
+	var statuses []map[string]any
+	var uploaded []byte
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/media", func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("failed to read uploaded file: %v", err)
+			return
+		}
+		uploaded, _ = io.ReadAll(file)
+		if !strings.HasPrefix(r.FormValue("description"), "acme/rocket on GitHub") {
+			t.Errorf("description = %q", r.FormValue("description"))
+		}
+		w.Write([]byte(`{"id":"media1"}`))
+	})
+	mux.HandleFunc("/api/v1/statuses", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("Idempotency-Key"); got == "" {
+			t.Error("Idempotency-Key is missing")
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+			return
+		}
+		statuses = append(statuses, body)
+		id := strconv.Itoa(len(statuses))
+		w.Write([]byte(`{"id":"` + id + `","url":"https://mastodon.example/@gitfinder/` + id + `"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	composer, err := NewComposer("", true)
+	if err != nil {
+		t.Fatalf("NewComposer() error = %v", err)
+	}
+	content := testContent()
+	content.Image = []byte("\x89PNG image")
+	content.Highlights = []string{"Builds in milliseconds", "Zero config"}
+	client := NewMastodonClient(server.URL+"/", "token", composer, server.Client())
+	postURL, err := client.Post(content)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if postURL != "https://mastodon.example/@gitfinder/1" {
+		t.Errorf("Post() = %q", postURL)
+	}
+	if string(uploaded) != "\x89PNG image" {
+		t.Errorf("uploaded %q, want the OG image", uploaded)
+	}
+
+	if len(statuses) != 3 {
+		t.Fatalf("posted %d statuses, want the post and 2 replies", len(statuses))
+	}
+	post := statuses[0]
+	if post["visibility"] != "public" || !strings.Contains(post["status"].(string), "#MachineLearning") {
+		t.Errorf("unexpected status %v", post)
+	}
+	if ids, _ := post["media_ids"].([]any); len(ids) != 1 || ids[0] != "media1" {
+		t.Errorf("media_ids = %v", post["media_ids"])
+	}
+	if statuses[1]["in_reply_to_id"] != "1" || statuses[2]["in_reply_to_id"] != "2" {
+		t.Errorf("replies are not threaded: %v", statuses[1:])
+	}
+	if statuses[2]["status"] != "3/3 ✨ Zero config" {
+		t.Errorf("reply = %q", statuses[2]["status"])
+	}
+}
+
+func TestMastodonClientPostError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"error":"Validation failed: Text character limit of 500 exceeded"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewMastodonClient(server.URL, "token", nil, server.Client()).Post(testContent())
+	if err == nil || !strings.Contains(err.Error(), "character limit") {
+		t.Errorf("Post() error = %v, want the API's error", err)
+	}
+}
@@ -0,0 +1,111 @@
+package social
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// repositoryURL is the app page posts link to.
+const repositoryURL = "https://app.gitfinder.dev/repository/%d"
+
+// Rules are the limits and conventions of a network's posts.
+type Rules struct {
+	// MaxLength is the most characters a post can have, counted with Counting.
+	MaxLength int
+	Counting  Counting
+	// LinkLength is what links count as, whatever their length, on networks that
+	// shorten links; 0 counts links as they are.
+	LinkLength int
+	// LinkInText is false on networks where the link is attached to the post as a
+	// card rather than written in its text.
+	LinkInText bool
+	// MaxHashtags caps the hashtags of a post, and MaxHashtagLength the length of the
+	// topics made into hashtags.
+	MaxHashtags      int
+	MaxHashtagLength int
+	// CamelCaseHashtags writes multi-word hashtags as #MachineLearning, which screen
+	// readers can read, rather than #machinelearning.
+	CamelCaseHashtags bool
+	// Threads is true on networks where posts can be replied to with more posts,
+	// which carry the README highlights of threads.
+	Threads bool
+}
+
+// Content is what is posted about a repository.
+type Content struct {
+	Repository models.Repository
+	// Growth is the repository's stars and forks and what it gained over the last Days.
+	Growth models.RepositoryGrowth
+	Days   int
+	// Highlights are the README highlights posted as a thread after the post.
+	Highlights []string
+	// Image is the repository's OG image as a PNG, attached to the post; nil posts
+	// without one.
+	Image []byte
+}
+
+// ImageDescription returns the alternative text of the repository's OG image.
+func (c Content) ImageDescription() string {
+	description := fmt.Sprintf("%s on GitHub", c.Repository.FullName)
+	if c.Repository.Description.String != "" {
+		description += ": " + c.Repository.Description.String
+	}
+	return description
+}
+
+// Message is a post about a repository, composed for a network.
+type Message struct {
+	Text     string
+	Link     string
+	Hashtags []string
+}
+
+// hashtags returns the hashtags of a repository: its primary language, then its topics.
+func hashtags(repo models.Repository, rules Rules) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		tag := hashtag(name, rules.CamelCaseHashtags)
+		if tag == "" || seen[strings.ToLower(tag)] || len(tags) >= rules.MaxHashtags {
+			return
+		}
+		seen[strings.ToLower(tag)] = true
+		tags = append(tags, "#"+tag)
+	}
+	if language := models.NewEventRepository(repo).Language; language != "" {
+		add(language)
+	}
+	for _, topic := range repo.Topics {
+		if rules.MaxHashtagLength > 0 && len(topic) > rules.MaxHashtagLength {
+			continue
+		}
+		add(topic)
+	}
+	return tags
+}
+
+// hashtag turns a language or topic into a hashtag, without the #. Characters that
+// cannot be part of hashtags separate words; languages such as C++ or C# have none
+// left that would make sense and are skipped.
+func hashtag(name string, camelCase bool) string {
+	if strings.ContainsAny(name, "+#") {
+		return ""
+	}
+	words := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	if camelCase {
+		for i, w := range words {
+			r, size := utf8.DecodeRuneInString(w)
+			words[i] = string(unicode.ToUpper(r)) + w[size:]
+		}
+	}
+	tag := strings.Join(words, "")
+	// Hashtags made only of digits are not linked.
+	if strings.IndexFunc(tag, unicode.IsLetter) < 0 {
+		return ""
+	}
+	return tag
+}
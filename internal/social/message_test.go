@@ -0,0 +1,53 @@
+package social
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func testRepository() models.Repository {
+	return models.Repository{
+		ID:          42,
+		FullName:    "acme/rocket",
+		Description: sql.NullString{String: "A fast rocket engine for your build pipeline", Valid: true},
+		Language:    sql.NullString{String: "Go", Valid: true},
+		Topics:      []string{"machine-learning", "go", "cli", "developer-productivity-tools", "2024"},
+	}
+}
+
+func testContent() Content {
+	return Content{
+		Repository: testRepository(),
+		Growth:     models.RepositoryGrowth{RepositoryID: 42, Stars: 12345, StarsGained: 1500},
+		Days:       7,
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		rules Rules
+		want  []string
+	}{
+		// "go" duplicates the language, topics over 15 characters are skipped and
+		// "2024" has no letters.
+		{TwitterRules, []string{"#Go", "#cli"}},
+		{MastodonRules, []string{"#Go", "#MachineLearning", "#Cli", "#DeveloperProductivityTools"}},
+		{BlueskyRules, []string{"#Go", "#MachineLearning", "#Cli"}},
+	}
+	for _, tt := range tests {
+		if got := hashtags(testRepository(), tt.rules); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("hashtags() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestHashtagSkipsSymbols(t *testing.T) {
+	for _, name := range []string{"C++", "C#", "2024"} {
+		if got := hashtag(name, true); got != "" {
+			t.Errorf("hashtag(%q) = %q, want none", name, got)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package social
 
-import "github.com/teomiscia/github-trending/internal/models"
-
 // Poster is an interface for posting to social media.
 type Poster interface {
-	Post(repo models.Repository) error
+	// Network is the name of the network posted to, under which posted repositories
+	// are recorded.
+	Network() string
+	// Post posts about a repository and returns the URL of the post. If the post is
+	// made but not all the replies of its thread, Post returns its URL with the error.
+	Post(content Content) (string, error)
 }
@@ -0,0 +1,270 @@
+package social
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Postiz post types: scheduled posts are published at their date unless they are
+// edited or deleted in Postiz first, drafts wait for someone to schedule them.
+const (
+	PostizSchedule = "schedule"
+	PostizDraft    = "draft"
+	PostizNow      = "now"
+)
+
+// postizRules are the rules of the posts to the channels Postiz connects to, by
+// provider identifier. The link is always written in the text, since Postiz posts
+// the OG image rather than a link card.
+var postizRules = map[string]Rules{
+	"x":             TwitterRules,
+	"mastodon":      MastodonRules,
+	"bluesky":       BlueskyRules,
+	"linkedin":      LinkedInRules,
+	"linkedin-page": LinkedInRules,
+	"threads":       {MaxLength: 500, MaxHashtags: 1, MaxHashtagLength: 30, CamelCaseHashtags: true, Threads: true},
+}
+
+// postizNetworks are the networks of the Postiz provider identifiers whose name
+// differs from the network's.
+var postizNetworks = map[string]string{
+	"x":             "twitter",
+	"linkedin-page": "linkedin",
+}
+
+// PostizNetwork returns the network a Postiz channel posts to, named as the posters
+// of the networks posted to directly.
+func PostizNetwork(identifier string) string {
+	if network, ok := postizNetworks[identifier]; ok {
+		return network
+	}
+	return identifier
+}
+
+// defaultPostizRules are the rules of the posts to the other channels.
+var defaultPostizRules = Rules{MaxLength: 500, MaxHashtags: 4, MaxHashtagLength: 30, CamelCaseHashtags: true}
+
+// PostizRules returns the rules of the posts to a Postiz channel.
+func PostizRules(identifier string) Rules {
+	rules, ok := postizRules[identifier]
+	if !ok {
+		rules = defaultPostizRules
+	}
+	rules.LinkInText = true
+	return rules
+}
+
+// PostizOptions select the channels repositories are posted to and how.
+type PostizOptions struct {
+	// Integrations are the IDs of the channels posted to; empty posts to all the
+	// enabled channels.
+	Integrations []string
+	// PostType is PostizSchedule, PostizDraft or PostizNow.
+	PostType string
+	// ScheduleDelay is how long after being created scheduled posts are published,
+	// leaving time to review them.
+	ScheduleDelay time.Duration
+	// DirectNetworks are the networks also posted to directly. Posts are refused while
+	// a selected channel is on one of them, since Postiz records a single "postiz"
+	// post and the repository would be posted there twice.
+	DirectNetworks []string
+}
+
+// PostizClient implements the Poster interface for Postiz, scheduling a post about a
+// repository, with its OG image, to the channels connected to Postiz through its
+// public API.
+type PostizClient struct {
+	apiURL   string
+	apiKey   string
+	options  PostizOptions
+	composer *Composer
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewPostizClient creates a PostizClient for the public API at apiURL, e.g.
+// https://postiz.example.com/api/public/v1. A nil composer uses the built-in
+// templates, and a nil client a client with a 30 second timeout.
+func NewPostizClient(apiURL, apiKey string, options PostizOptions, composer *Composer, client *http.Client) *PostizClient {
+	if options.PostType == "" {
+		options.PostType = PostizSchedule
+	}
+	if composer == nil {
+		composer = defaultComposer()
+	}
+	return &PostizClient{
+		apiURL:   strings.TrimSuffix(apiURL, "/"),
+		apiKey:   apiKey,
+		options:  options,
+		composer: composer,
+		client:   newHTTPClient(client),
+		now:      time.Now,
+	}
+}
+
+// Network returns "postiz".
+func (c *PostizClient) Network() string {
+	return "postiz"
+}
+
+// postizIntegration is a channel connected to Postiz.
+type postizIntegration struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Identifier string `json:"identifier"`
+	Disabled   bool   `json:"disabled"`
+}
+
+// postizMedia is a file uploaded to Postiz.
+type postizMedia struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+// Post creates a post about a repository on each selected channel, composed for the
+// channel's network with its templates, with the repository's OG image attached and the replies of its
+// thread as the post's following values. It returns the URL of the post's preview in
+// Postiz.
+func (c *PostizClient) Post(content Content) (string, error) {
+	integrations, err := c.integrations()
+	if err != nil {
+		return "", fmt.Errorf("postiz API error: %w", err)
+	}
+	if len(integrations) == 0 {
+		return "", fmt.Errorf("no postiz channel to post to")
+	}
+
+	images := []postizMedia{}
+	if content.Image != nil {
+		image, err := c.uploadImage(content)
+		if err != nil {
+			logImageUploadFailure(c.Network(), content, err)
+		} else {
+			images = append(images, image)
+		}
+	}
+
+	now := c.now().UTC()
+	date := now
+	if c.options.PostType == PostizSchedule {
+		date = now.Add(c.options.ScheduleDelay)
+	}
+	posts := make([]map[string]any, 0, len(integrations))
+	for _, integration := range integrations {
+		// Channels use the templates of their network, e.g. twitter.post.tmpl for X.
+		messages, err := c.composer.Compose(PostizNetwork(integration.Identifier), PostizRules(integration.Identifier), content)
+		if err != nil {
+			return "", err
+		}
+		values := make([]map[string]any, len(messages))
+		for i, msg := range messages {
+			values[i] = map[string]any{"content": msg.Text, "image": []postizMedia{}}
+		}
+		values[0]["image"] = images
+		posts = append(posts, map[string]any{
+			"integration": map[string]string{"id": integration.ID},
+			"value":       values,
+			"settings":    postizSettings(integration.Identifier),
+		})
+	}
+	body := map[string]any{
+		"type":      c.options.PostType,
+		"date":      date.Format(time.RFC3339),
+		"shortLink": false,
+		"tags":      []string{},
+		"posts":     posts,
+	}
+	var created []struct {
+		PostID      string `json:"postId"`
+		Integration string `json:"integration"`
+	}
+	if _, err := postJSON(c.client, c.apiURL+"/posts", c.headers(), body, &created); err != nil {
+		return "", fmt.Errorf("postiz API error: %w", err)
+	}
+	if len(created) == 0 {
+		return "", fmt.Errorf("postiz API created no post")
+	}
+	return fmt.Sprintf("%s/p/%s?share=true", c.appURL(), created[0].PostID), nil
+}
+
+// integrations returns the enabled channels posted to.
+func (c *PostizClient) integrations() ([]postizIntegration, error) {
+	req, err := http.NewRequest(http.MethodGet, c.apiURL+"/integrations", nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range c.headers() {
+		req.Header.Set(name, value)
+	}
+	var all []postizIntegration
+	if _, err := do(c.client, req, &all); err != nil {
+		return nil, err
+	}
+
+	var integrations []postizIntegration
+	for _, integration := range all {
+		if integration.Disabled {
+			continue
+		}
+		if len(c.options.Integrations) > 0 && !contains(c.options.Integrations, integration.ID) {
+			continue
+		}
+		if network := PostizNetwork(integration.Identifier); contains(c.options.DirectNetworks, network) {
+			return nil, fmt.Errorf("channel %s (%s) posts to %s, which is also posted to directly; select other channels or stop posting to %s directly", integration.ID, integration.Name, network, network)
+		}
+		integrations = append(integrations, integration)
+	}
+	return integrations, nil
+}
+
+// uploadImage uploads the OG image of a repository to Postiz.
+func (c *PostizClient) uploadImage(content Content) (postizMedia, error) {
+	var media postizMedia
+	body, contentType, err := multipartImage("file", content)
+	if err != nil {
+		return media, err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.apiURL+"/upload", body)
+	if err != nil {
+		return media, err
+	}
+	for name, value := range c.headers() {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", contentType)
+	_, err = do(c.client, req, &media)
+	return media, err
+}
+
+// headers returns the headers of public API requests, which take the API key as is.
+func (c *PostizClient) headers() map[string]string {
+	return map[string]string{"Authorization": c.apiKey}
+}
+
+// appURL returns the URL of the Postiz app serving the public API.
+func (c *PostizClient) appURL() string {
+	url := strings.TrimSuffix(c.apiURL, "/public/v1")
+	return strings.TrimSuffix(url, "/api")
+}
+
+// postizSettings returns the provider settings of a post, which Postiz validates
+// against the channel's provider.
+func postizSettings(identifier string) map[string]string {
+	settings := map[string]string{"__type": identifier}
+	if identifier == "x" {
+		settings["who_can_reply_post"] = "everyone"
+	}
+	return settings
+}
+
+// contains reports whether values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package social
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postizRequest is the body of a request creating posts.
+type postizRequest struct {
+	Type  string `json:"type"`
+	Date  string `json:"date"`
+	Posts []struct {
+		Integration struct {
+			ID string `json:"id"`
+		} `json:"integration"`
+		Value []struct {
+			Content string        `json:"content"`
+			Image   []postizMedia `json:"image"`
+		} `json:"value"`
+		Settings map[string]string `json:"settings"`
+	} `json:"posts"`
+}
+
+func newPostizServer(t *testing.T, created *postizRequest, uploaded *[]byte) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/public/v1/integrations", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[
+			{"id":"x1","name":"gitfinder","identifier":"x","disabled":false},
+			{"id":"m1","name":"gitfinder","identifier":"mastodon","disabled":false},
+			{"id":"l1","name":"gitfinder","identifier":"linkedin-page","disabled":true}
+		]`))
+	})
+	mux.HandleFunc("/api/public/v1/upload", func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("failed to read uploaded file: %v", err)
+			return
+		}
+		if header.Filename != "repository-42.png" {
+			t.Errorf("uploaded file name = %q", header.Filename)
+		}
+		*uploaded, _ = io.ReadAll(file)
+		w.Write([]byte(`{"id":"media1","name":"repository-42.png","path":"/uploads/repository-42.png"}`))
+	})
+	mux.HandleFunc("/api/public/v1/posts", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(created); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+			return
+		}
+		w.Write([]byte(`[{"postId":"p1","integration":"x1"},{"postId":"p2","integration":"m1"}]`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestPostizClientPost(t *testing.T) {
+	var created postizRequest
+	var uploaded []byte
+	server := newPostizServer(t, &created, &uploaded)
+	defer server.Close()
+
+	composer, err := NewComposer("", true)
+	if err != nil {
+		t.Fatalf("NewComposer() error = %v", err)
+	}
+	options := PostizOptions{PostType: PostizSchedule, ScheduleDelay: time.Hour}
+	client := NewPostizClient(server.URL+"/api/public/v1", "key", options, composer, server.Client())
+	client.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	content := testContent()
+	content.Image = []byte("\x89PNG image")
+	content.Highlights = []string{"Builds in milliseconds"}
+	postURL, err := client.Post(content)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if postURL != server.URL+"/p/p1?share=true" {
+		t.Errorf("Post() = %q", postURL)
+	}
+	if string(uploaded) != "\x89PNG image" {
+		t.Errorf("uploaded %q, want the OG image", uploaded)
+	}
+
+	if created.Type != "schedule" || created.Date != "2024-05-01T13:00:00Z" {
+		t.Errorf("type = %q, date = %q", created.Type, created.Date)
+	}
+	// The disabled LinkedIn channel is skipped.
+	if len(created.Posts) != 2 {
+		t.Fatalf("posts = %+v, want 2", created.Posts)
+	}
+	x, mastodon := created.Posts[0], created.Posts[1]
+	if x.Integration.ID != "x1" || x.Settings["__type"] != "x" || mastodon.Integration.ID != "m1" {
+		t.Errorf("unexpected posts %+v", created.Posts)
+	}
+	for _, post := range created.Posts {
+		// The reply of the thread follows the post, without the image.
+		if len(post.Value) != 2 || len(post.Value[0].Image) != 1 || post.Value[0].Image[0].ID != "media1" || len(post.Value[1].Image) != 0 {
+			t.Errorf("post %+v does not have the OG image and the thread", post)
+		}
+		if !strings.Contains(post.Value[0].Content, "https://app.gitfinder.dev/repository/42") {
+			t.Errorf("post %q does not link to the repository", post.Value[0].Content)
+		}
+	}
+	// Each channel gets the post composed for its network.
+	if !strings.Contains(x.Value[0].Content, "#cli") || !strings.Contains(mastodon.Value[0].Content, "#MachineLearning") {
+		t.Errorf("posts are not composed per network: %q, %q", x.Value[0].Content, mastodon.Value[0].Content)
+	}
+}
+
+func TestPostizClientPostSelectedIntegrations(t *testing.T) {
+	var created postizRequest
+	var uploaded []byte
+	server := newPostizServer(t, &created, &uploaded)
+	defer server.Close()
+
+	options := PostizOptions{Integrations: []string{"m1"}, PostType: PostizDraft}
+	client := NewPostizClient(server.URL+"/api/public/v1", "key", options, nil, server.Client())
+	if _, err := client.Post(testContent()); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if created.Type != "draft" || len(created.Posts) != 1 || created.Posts[0].Integration.ID != "m1" {
+		t.Errorf("unexpected request %+v", created)
+	}
+}
+
+func TestPostizClientPostWithoutOGImage(t *testing.T) {
+	var created postizRequest
+	var uploaded []byte
+	server := newPostizServer(t, &created, &uploaded)
+	defer server.Close()
+
+	client := NewPostizClient(server.URL+"/api/public/v1", "key", PostizOptions{}, nil, server.Client())
+	if _, err := client.Post(testContent()); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if uploaded != nil || len(created.Posts[0].Value[0].Image) != 0 {
+		t.Errorf("posted an image for a repository without one")
+	}
+}
+
+func TestPostizClientPostImageUploadError(t *testing.T) {
+	var created postizRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/public/v1/integrations", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":"m1","name":"gitfinder","identifier":"mastodon","disabled":false}]`))
+	})
+	mux.HandleFunc("/api/public/v1/upload", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/api/public/v1/posts", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&created)
+		w.Write([]byte(`[{"postId":"p1","integration":"m1"}]`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewPostizClient(server.URL+"/api/public/v1", "key", PostizOptions{}, nil, server.Client())
+	content := testContent()
+	content.Image = []byte("\x89PNG image")
+
+	// The post is created without the image.
+	if _, err := client.Post(content); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if len(created.Posts) != 1 || len(created.Posts[0].Value[0].Image) != 0 {
+		t.Errorf("unexpected posts %+v, want one without media", created.Posts)
+	}
+}
+
+func TestPostizClientPostUnauthorized(t *testing.T) {
+	var created postizRequest
+	var uploaded []byte
+	server := newPostizServer(t, &created, &uploaded)
+	defer server.Close()
+
+	client := NewPostizClient(server.URL+"/api/public/v1", "wrong", PostizOptions{}, nil, server.Client())
+	if _, err := client.Post(testContent()); err == nil {
+		t.Error("Post() error = nil, want an error")
+	}
+}
+
+func TestPostizClientPostRejectsDirectNetworks(t *testing.T) {
+	var created postizRequest
+	var uploaded []byte
+	server := newPostizServer(t, &created, &uploaded)
+	defer server.Close()
+
+	// The X channel would post again what the twitter poster posts.
+	options := PostizOptions{DirectNetworks: []string{"twitter"}}
+	client := NewPostizClient(server.URL+"/api/public/v1", "key", options, nil, server.Client())
+	if _, err := client.Post(testContent()); err == nil || !strings.Contains(err.Error(), "twitter") {
+		t.Errorf("Post() error = %v, want an error about twitter", err)
+	}
+	if created.Posts != nil {
+		t.Errorf("posted %+v despite the overlap", created.Posts)
+	}
+
+	// Selecting the other channels avoids the overlap.
+	options.Integrations = []string{"m1"}
+	client = NewPostizClient(server.URL+"/api/public/v1", "key", options, nil, server.Client())
+	if _, err := client.Post(testContent()); err != nil {
+		t.Errorf("Post() error = %v", err)
+	}
+}
+
+func TestPostizNetwork(t *testing.T) {
+	for identifier, want := range map[string]string{"x": "twitter", "linkedin-page": "linkedin", "linkedin": "linkedin", "mastodon": "mastodon", "threads": "threads"} {
+		if got := PostizNetwork(identifier); got != want {
+			t.Errorf("PostizNetwork(%q) = %q, want %q", identifier, got, want)
+		}
+	}
+}
+
+func TestPostizClientPostUsesNetworkTemplates(t *testing.T) {
+	var created postizRequest
+	var uploaded []byte
+	server := newPostizServer(t, &created, &uploaded)
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "twitter.post.tmpl"), []byte("On X: {{.Name}} ({{.Network}}) {{.Link}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	composer, err := NewComposer(dir, false)
+	if err != nil {
+		t.Fatalf("NewComposer() error = %v", err)
+	}
+	client := NewPostizClient(server.URL+"/api/public/v1", "key", PostizOptions{}, composer, server.Client())
+	if _, err := client.Post(testContent()); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	x, mastodon := created.Posts[0], created.Posts[1]
+	if x.Value[0].Content != "On X: acme/rocket (twitter) https://app.gitfinder.dev/repository/42" {
+		t.Errorf("the X channel should use the twitter template, got %q", x.Value[0].Content)
+	}
+	if strings.HasPrefix(mastodon.Value[0].Content, "On X") {
+		t.Errorf("the Mastodon channel should use the default template, got %q", mastodon.Value[0].Content)
+	}
+}
@@ -0,0 +1,286 @@
+package social
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// maxThreadHighlights caps the README highlights posted as replies.
+const maxThreadHighlights = 4
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+var funcs = template.FuncMap{
+	"number":   compactNumber,
+	"delta":    delta,
+	"emoji":    languageEmoji,
+	"hashtags": func(tags []string) string { return strings.Join(tags, " ") },
+}
+
+// blankLinesRe matches the blank lines optional parts of templates leave.
+var blankLinesRe = regexp.MustCompile(`\n{3,}`)
+
+// PostData is what the post templates render.
+type PostData struct {
+	Repository models.Repository
+	Network    string
+	Name       string
+	// Summary is the repository's description, cut to fit the network's limit.
+	Summary  string
+	Language string
+	Link     string
+	// LinkInText is false on networks attaching the link as a card.
+	LinkInText  bool
+	Stars       int64
+	StarsGained int64
+	ForksGained int64
+	Days        int
+	Hashtags    []string
+}
+
+// ThreadData is what the thread templates render, once per README highlight.
+type ThreadData struct {
+	PostData
+	// Highlight is cut to fit the network's limit.
+	Highlight string
+	// Index is the position of the reply in the thread, the post being 1 of Total.
+	Index int
+	Total int
+}
+
+// Composer composes the posts about repositories from text/template templates: post
+// for the post and thread for the replies carrying README highlights. A network's
+// posts use <network>.post and <network>.thread instead when they are defined.
+type Composer struct {
+	templates *template.Template
+	threads   bool
+}
+
+// NewComposer creates a Composer with the templates built in, overridden by the .tmpl
+// files of dir if it is set. Threads are only composed if threads is true.
+func NewComposer(dir string, threads bool) (*Composer, error) {
+	c := &Composer{threads: threads}
+	if err := c.Load(dir); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// defaultComposer returns a Composer with the templates built in and no threads.
+func defaultComposer() *Composer {
+	c, err := NewComposer("", false)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Load (re)loads the templates, so that they can be edited without restarting. Every
+// post and thread template is tried on an example repository, and the templates are
+// kept as they were if any does not parse or render.
+func (c *Composer) Load(dir string) error {
+	templates, err := template.New("").Funcs(funcs).ParseFS(defaultTemplates, "templates/*.tmpl")
+	if err != nil {
+		return err
+	}
+	if dir != "" {
+		// An empty directory keeps the built-in templates.
+		files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return err
+		}
+		if len(files) > 0 {
+			if templates, err = templates.ParseFiles(files...); err != nil {
+				return fmt.Errorf("failed to parse templates of %s: %w", dir, err)
+			}
+		}
+	}
+
+	candidate := &Composer{templates: templates, threads: true}
+	example := Content{
+		Repository: models.Repository{ID: 1, FullName: "octocat/hello-world", Topics: []string{"example"}},
+		Growth:     models.RepositoryGrowth{Stars: 1200, StarsGained: 300},
+		Days:       7,
+		Highlights: []string{"An example highlight"},
+	}
+	example.Repository.Description.String = "An example repository"
+	for _, network := range templateNetworks(templates) {
+		if _, err := candidate.Compose(network, Rules{MaxLength: 3000, MaxHashtags: 4, Threads: true}, example); err != nil {
+			return err
+		}
+	}
+	c.templates = templates
+	return nil
+}
+
+// templateNetworks returns the networks with a <network>.post or <network>.thread
+// template, after "" for the default ones, so that every post and thread template is
+// tried.
+func templateNetworks(templates *template.Template) []string {
+	networks := []string{""}
+	for _, t := range templates.Templates() {
+		for _, kind := range []string{".post.tmpl", ".thread.tmpl"} {
+			if network, ok := strings.CutSuffix(t.Name(), kind); ok && !contains(networks, network) {
+				networks = append(networks, network)
+			}
+		}
+	}
+	sort.Strings(networks[1:])
+	return networks
+}
+
+// Compose composes the post about a repository for a network, followed by the
+// replies of its thread when threads are enabled, the network supports them and the
+// repository has README highlights. Each fits the network's limit.
+func (c *Composer) Compose(network string, rules Rules, content Content) ([]Message, error) {
+	repo := content.Repository
+	data := PostData{
+		Repository:  repo,
+		Network:     network,
+		Name:        repo.FullName,
+		Summary:     strings.TrimSpace(repo.Description.String),
+		Language:    models.NewEventRepository(repo).Language,
+		Link:        fmt.Sprintf(repositoryURL, repo.ID),
+		LinkInText:  rules.LinkInText,
+		Stars:       content.Growth.Stars,
+		StarsGained: content.Growth.StarsGained,
+		ForksGained: content.Growth.ForksGained,
+		Days:        content.Days,
+		Hashtags:    hashtags(repo, rules),
+	}
+	text, err := c.fit(c.lookup(network, "post"), &data, &data.Summary, rules)
+	if err != nil {
+		return nil, err
+	}
+	messages := []Message{{Text: text, Link: data.Link, Hashtags: data.Hashtags}}
+
+	if !c.threads || !rules.Threads {
+		return messages, nil
+	}
+	highlights := content.Highlights
+	if len(highlights) > maxThreadHighlights {
+		highlights = highlights[:maxThreadHighlights]
+	}
+	for i, highlight := range highlights {
+		reply := ThreadData{PostData: data, Highlight: highlight, Index: i + 2, Total: len(highlights) + 1}
+		text, err := c.fit(c.lookup(network, "thread"), &reply, &reply.Highlight, rules)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, Message{Text: text, Link: data.Link})
+	}
+	return messages, nil
+}
+
+// lookup returns the network's template of a kind, or the default one.
+func (c *Composer) lookup(network, kind string) *template.Template {
+	if t := c.templates.Lookup(network + "." + kind + ".tmpl"); t != nil {
+		return t
+	}
+	return c.templates.Lookup(kind + ".tmpl")
+}
+
+// fit renders a template, cutting the field it points to until the text fits the
+// network's limit. Texts that do not fit without the field are cut at the end.
+func (c *Composer) fit(t *template.Template, data any, field *string, rules Rules) (string, error) {
+	for {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("failed to render template %s: %w", t.Name(), err)
+		}
+		text := tidy(buf.String())
+		over := rules.length(text) - rules.MaxLength
+		if over <= 0 {
+			return text, nil
+		}
+		if *field == "" {
+			return rules.truncate(text, rules.MaxLength), nil
+		}
+		*field = rules.truncate(*field, rules.length(*field)-over)
+	}
+}
+
+// tidy trims the spaces ending lines and the blank lines left by the empty parts of a
+// rendered template.
+func tidy(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(blankLinesRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// compactNumber formats a count as 950, 1.2k or 3.4M.
+func compactNumber(n int64) string {
+	abs := n
+	if abs < 0 {
+		abs = -abs
+	}
+	var s string
+	switch {
+	case abs < 1000:
+		return strconv.FormatInt(n, 10)
+	case abs < 1000000:
+		s = strconv.FormatFloat(float64(n)/1000, 'f', 1, 64) + "k"
+	default:
+		s = strconv.FormatFloat(float64(n)/1000000, 'f', 1, 64) + "M"
+	}
+	return strings.Replace(s, ".0", "", 1)
+}
+
+// delta formats a change in a count with its sign, e.g. +1.2k.
+func delta(n int64) string {
+	if n > 0 {
+		return "+" + compactNumber(n)
+	}
+	return compactNumber(n)
+}
+
+// languageEmojis are the emoji standing for the most common languages.
+var languageEmojis = map[string]string{
+	"C":                "🔧",
+	"C#":               "🎯",
+	"C++":              "⚙️",
+	"CSS":              "🎨",
+	"Dart":             "🎯",
+	"Dockerfile":       "🐳",
+	"Elixir":           "💧",
+	"Go":               "🐹",
+	"HTML":             "🌐",
+	"Haskell":          "🎓",
+	"Java":             "☕",
+	"JavaScript":       "🟨",
+	"Jupyter Notebook": "📓",
+	"Kotlin":           "🟣",
+	"Lua":              "🌙",
+	"Nix":              "❄️",
+	"PHP":              "🐘",
+	"Python":           "🐍",
+	"R":                "📊",
+	"Ruby":             "💎",
+	"Rust":             "🦀",
+	"Scala":            "🔺",
+	"Shell":            "🐚",
+	"Swift":            "🐦",
+	"TypeScript":       "🔷",
+	"Vue":              "💚",
+	"Zig":              "⚡",
+}
+
+// languageEmoji returns the emoji of a language, or 💻 for the others.
+func languageEmoji(language string) string {
+	if emoji, ok := languageEmojis[language]; ok {
+		return emoji
+	}
+	return "💻"
+}
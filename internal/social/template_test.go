@@ -0,0 +1,140 @@
+package social
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComposerCompose(t *testing.T) {
+	messages, err := defaultComposer().Compose("mastodon", MastodonRules, testContent())
+	if err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+
+	want := "🚀 Trending on GitHub: acme/rocket 🐹\n\nA fast rocket engine for your build pipeline\n\n" +
+		"⭐ 12.3k stars, +1.5k in the last 7 days\n\n🔗 https://app.gitfinder.dev/repository/42\n\n" +
+		"#Go #MachineLearning #Cli #DeveloperProductivityTools"
+	if len(messages) != 1 || messages[0].Text != want {
+		t.Errorf("Compose() = %+v, want %q", messages, want)
+	}
+}
+
+func TestComposerComposeOptionalParts(t *testing.T) {
+	content := testContent()
+	content.Repository.Description.String = ""
+	content.Growth.StarsGained = 0
+
+	messages, err := defaultComposer().Compose("bluesky", BlueskyRules, content)
+	if err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+	want := "🚀 Trending on GitHub: acme/rocket 🐹\n\n#Go #MachineLearning #Cli"
+	if messages[0].Text != want {
+		t.Errorf("Compose() = %q, want %q", messages[0].Text, want)
+	}
+}
+
+func TestComposerComposeFitsLimits(t *testing.T) {
+	content := testContent()
+	content.Repository.Description.String = strings.Repeat("très long résumé 👩‍💻 ", 40)
+	content.Highlights = []string{strings.Repeat("日本語のハイライト ", 60)}
+	composer, err := NewComposer("", true)
+	if err != nil {
+		t.Fatalf("NewComposer() error = %v", err)
+	}
+
+	for network, rules := range map[string]Rules{"twitter": TwitterRules, "mastodon": MastodonRules, "bluesky": BlueskyRules} {
+		messages, err := composer.Compose(network, rules, content)
+		if err != nil {
+			t.Fatalf("%s: Compose() error = %v", network, err)
+		}
+		if len(messages) != 2 {
+			t.Fatalf("%s: Compose() = %d messages, want the post and a reply", network, len(messages))
+		}
+		for _, msg := range messages {
+			if n := rules.length(msg.Text); n > rules.MaxLength {
+				t.Errorf("%s: %q is %d characters, limit is %d", network, msg.Text, n, rules.MaxLength)
+			}
+			if !strings.Contains(msg.Text, "…") {
+				t.Errorf("%s: %q is not marked truncated", network, msg.Text)
+			}
+		}
+		post := messages[0].Text
+		if !strings.HasPrefix(post, "🚀 Trending on GitHub: acme/rocket") || !strings.HasSuffix(post, messages[0].Hashtags[len(messages[0].Hashtags)-1]) {
+			t.Errorf("%s: %q lost its name or hashtags", network, post)
+		}
+	}
+}
+
+func TestComposerWithoutThreads(t *testing.T) {
+	content := testContent()
+	content.Highlights = []string{"Builds in milliseconds"}
+
+	// Threads are disabled by default, and LinkedIn has none.
+	if messages, _ := defaultComposer().Compose("twitter", TwitterRules, content); len(messages) != 1 {
+		t.Errorf("Compose() = %d messages without threads, want 1", len(messages))
+	}
+	composer, _ := NewComposer("", true)
+	if messages, _ := composer.Compose("linkedin", LinkedInRules, content); len(messages) != 1 {
+		t.Errorf("Compose() = %d messages on LinkedIn, want 1", len(messages))
+	}
+}
+
+func TestComposerTemplatesDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("post.tmpl", "{{.Name}} {{delta .StarsGained}} {{hashtags .Hashtags}}")
+	write("bluesky.post.tmpl", "{{emoji .Language}} {{.Name}} on Bluesky")
+
+	composer, err := NewComposer(dir, false)
+	if err != nil {
+		t.Fatalf("NewComposer() error = %v", err)
+	}
+	messages, _ := composer.Compose("twitter", TwitterRules, testContent())
+	if messages[0].Text != "acme/rocket +1.5k #Go #cli" {
+		t.Errorf("twitter post = %q", messages[0].Text)
+	}
+	messages, _ = composer.Compose("bluesky", BlueskyRules, testContent())
+	if messages[0].Text != "🐹 acme/rocket on Bluesky" {
+		t.Errorf("bluesky post = %q", messages[0].Text)
+	}
+
+	// Broken templates are reported and the loaded ones kept.
+	write("post.tmpl", "{{.Name")
+	if err := composer.Load(dir); err == nil {
+		t.Error("Load() error = nil for a template that does not parse")
+	}
+	write("post.tmpl", "{{.Missing}}")
+	if err := composer.Load(dir); err == nil {
+		t.Error("Load() error = nil for a template that does not render")
+	}
+	// Templates of networks without a poster, such as threads through Postiz, are
+	// tried too.
+	write("post.tmpl", "{{.Name}} {{delta .StarsGained}} {{hashtags .Hashtags}}")
+	write("threads.thread.tmpl", "{{.Highlight.Missing}}")
+	if err := composer.Load(dir); err == nil {
+		t.Error("Load() error = nil for a network template that does not render")
+	}
+	messages, _ = composer.Compose("twitter", TwitterRules, testContent())
+	if messages[0].Text != "acme/rocket +1.5k #Go #cli" {
+		t.Errorf("twitter post after a failed reload = %q", messages[0].Text)
+	}
+}
+
+func TestCompactNumber(t *testing.T) {
+	tests := map[int64]string{0: "0", 950: "950", 1000: "1k", 1234: "1.2k", 12345: "12.3k", 3400000: "3.4M", -1500: "-1.5k"}
+	for n, want := range tests {
+		if got := compactNumber(n); got != want {
+			t.Errorf("compactNumber(%d) = %q, want %q", n, got, want)
+		}
+	}
+	if got := delta(1500); got != "+1.5k" {
+		t.Errorf("delta(1500) = %q", got)
+	}
+}
@@ -5,11 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/dghubble/oauth1"
 	twitter "github.com/g8rswimmer/go-twitter/v2"
-	"github.com/teomiscia/github-trending/internal/models"
 )
 
 type oauth1Authorizer struct{}
@@ -18,13 +16,21 @@ func (a *oauth1Authorizer) Add(req *http.Request) {
 	// The http.Client handles authorization
 }
 
+// twitterUploadURL is the v1.1 media upload endpoint, which the v2 API has no
+// equivalent of.
+const twitterUploadURL = "https://upload.twitter.com/1.1/media/upload.json"
+
 // TwitterClient implements the Poster interface for Twitter.
 type TwitterClient struct {
-	client *twitter.Client
+	client     *twitter.Client
+	httpClient *http.Client
+	uploadURL  string
+	composer   *Composer
 }
 
-// NewTwitterClient creates a new TwitterClient.
-func NewTwitterClient(apiKey, apiSecretKey, accessToken, accessSecret string) *TwitterClient {
+// NewTwitterClient creates a new TwitterClient. A nil composer uses the built-in
+// templates.
+func NewTwitterClient(apiKey, apiSecretKey, accessToken, accessSecret string, composer *Composer) *TwitterClient {
 	config := oauth1.NewConfig(apiKey, apiSecretKey)
 	token := oauth1.NewToken(accessToken, accessSecret)
 	httpClient := config.Client(oauth1.NoContext, token)
@@ -34,67 +40,101 @@ func NewTwitterClient(apiKey, apiSecretKey, accessToken, accessSecret string) *T
 		Client:     httpClient,
 		Host:       "https://api.twitter.com",
 	}
+	if composer == nil {
+		composer = defaultComposer()
+	}
 
 	return &TwitterClient{
-		client: client,
+		client:     client,
+		httpClient: httpClient,
+		uploadURL:  twitterUploadURL,
+		composer:   composer,
 	}
 }
 
-// Post posts a repository to Twitter.
-func (c *TwitterClient) Post(repo models.Repository) error {
-	// Create a more creative message
-	summary := ""
-	if repo.Description.Valid {
-		summary = repo.Description.String
-	}
+// TwitterRules are the rules of tweets: characters are weighted, links are shortened
+// to 23 characters and replies make threads.
+var TwitterRules = Rules{
+	MaxLength:        280,
+	Counting:         CountWeighted,
+	LinkLength:       23,
+	LinkInText:       true,
+	MaxHashtags:      4,
+	MaxHashtagLength: 15,
+	Threads:          true,
+}
 
-	link := fmt.Sprintf("https://app.gitfinder.dev/repository/%d", repo.ID)
+// Network returns "twitter".
+func (c *TwitterClient) Network() string {
+	return "twitter"
+}
 
-	var hashtags []string
-	for lang := range repo.Languages {
-		hashtags = append(hashtags, "#"+strings.ReplaceAll(lang, " ", ""))
+// Post tweets about a repository with its OG image, then the replies of its thread.
+func (c *TwitterClient) Post(content Content) (string, error) {
+	messages, err := c.composer.Compose(c.Network(), TwitterRules, content)
+	if err != nil {
+		return "", err
 	}
-	if len(repo.Topics) > 0 {
-		for _, topic := range repo.Topics {
-			// Limit to 4 hashtags to avoid being spammy
-			if len(hashtags) >= 4 {
-				break
-			}
-			// avoid long topics
-			if len(topic) > 15 {
-				continue
-			}
-			hashtags = append(hashtags, "#"+strings.ReplaceAll(topic, "-", ""))
+
+	var mediaIDs []string
+	if content.Image != nil {
+		mediaID, err := c.uploadImage(content)
+		if err != nil {
+			logImageUploadFailure(c.Network(), content, err)
+		} else {
+			mediaIDs = append(mediaIDs, mediaID)
 		}
 	}
 
-	baseMessage := fmt.Sprintf("🚀 Trending on GitHub: %s\n\n%s\n\n🔗 %s\n\n%s",
-		repo.FullName,
-		"%s", // placeholder for summary
-		link,
-		strings.Join(hashtags, " "),
-	)
-
-	// 280 is the limit, but let's use 270 to be safe with URL shorteners and other twitter things
-	remainingChars := 270 - len(fmt.Sprintf(baseMessage, ""))
-	if len(summary) > remainingChars {
-		summary = summary[:remainingChars-3] + "..."
+	postURL := ""
+	replyTo := ""
+	for i, msg := range messages {
+		req := twitter.CreateTweetRequest{
+			Text: msg.Text,
+		}
+		if i == 0 && len(mediaIDs) > 0 {
+			req.Media = &twitter.CreateTweetMedia{IDs: mediaIDs}
+		}
+		if replyTo != "" {
+			req.Reply = &twitter.CreateTweetReply{InReplyToTweetID: replyTo}
+		}
+
+		resp, err := c.client.CreateTweet(context.Background(), req)
+		if err == nil && resp.Tweet == nil {
+			err = fmt.Errorf("twitter API returned no tweet")
+		}
+		if err != nil {
+			var twitterErr *twitter.ErrorResponse
+			if errors.As(err, &twitterErr) {
+				err = fmt.Errorf("twitter API error: %v", twitterErr.Errors)
+			}
+			return postURL, err
+		}
+		replyTo = resp.Tweet.ID
+		if i == 0 {
+			postURL = fmt.Sprintf("https://x.com/i/web/status/%s", resp.Tweet.ID)
+		}
 	}
 
-	message := fmt.Sprintf(baseMessage, summary)
+	return postURL, nil
+}
 
-	req := twitter.CreateTweetRequest{
-		Text: message,
+// uploadImage uploads the OG image of a repository and returns its media ID.
+func (c *TwitterClient) uploadImage(content Content) (string, error) {
+	body, contentType, err := multipartImage("media", content)
+	if err != nil {
+		return "", err
 	}
-
-	_, err := c.client.CreateTweet(context.Background(), req)
+	req, err := http.NewRequest(http.MethodPost, c.uploadURL, body)
 	if err != nil {
-		var twitterErr *twitter.ErrorResponse
-		if errors.As(err, &twitterErr) {
-			return fmt.Errorf("twitter API error: %v", twitterErr.Errors)
-		}
-		return err
+		return "", err
 	}
-
-	return nil
-}
\ No newline at end of file
+	req.Header.Set("Content-Type", contentType)
+	var media struct {
+		MediaID string `json:"media_id_string"`
+	}
+	if _, err := do(c.httpClient, req, &media); err != nil {
+		return "", err
+	}
+	return media.MediaID, nil
+}
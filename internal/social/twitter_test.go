@@ -0,0 +1,144 @@
+package social
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	twitter "github.com/g8rswimmer/go-twitter/v2"
+)
+
+func TestTwitterClientPost(t *testing.T) {
+	var tweets []twitter.CreateTweetRequest
+	var uploaded []byte
+	mux := http.NewServeMux()
+	mux.HandleFunc("/1.1/media/upload.json", func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("media")
+		if err != nil {
+			t.Errorf("failed to read uploaded file: %v", err)
+			return
+		}
+		uploaded, _ = io.ReadAll(file)
+		w.Write([]byte(`{"media_id":710511363345354753,"media_id_string":"710511363345354753"}`))
+	})
+	mux.HandleFunc("/2/tweets", func(w http.ResponseWriter, r *http.Request) {
+		var tweet twitter.CreateTweetRequest
+		if err := json.NewDecoder(r.Body).Decode(&tweet); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+			return
+		}
+		tweets = append(tweets, tweet)
+		w.WriteHeader(http.StatusCreated)
+		if len(tweets) == 1 {
+			w.Write([]byte(`{"data":{"id":"100","text":"post"}}`))
+		} else {
+			w.Write([]byte(`{"data":{"id":"101","text":"reply"}}`))
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	composer, err := NewComposer("", true)
+	if err != nil {
+		t.Fatalf("NewComposer() error = %v", err)
+	}
+	client := &TwitterClient{
+		client:     &twitter.Client{Authorizer: &oauth1Authorizer{}, Client: server.Client(), Host: server.URL},
+		httpClient: server.Client(),
+		uploadURL:  server.URL + "/1.1/media/upload.json",
+		composer:   composer,
+	}
+	content := testContent()
+	content.Image = []byte("\x89PNG image")
+	content.Highlights = []string{"Builds in milliseconds"}
+	postURL, err := client.Post(content)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if postURL != "https://x.com/i/web/status/100" {
+		t.Errorf("Post() = %q", postURL)
+	}
+	if string(uploaded) != "\x89PNG image" {
+		t.Errorf("uploaded %q, want the OG image", uploaded)
+	}
+
+	if len(tweets) != 2 {
+		t.Fatalf("posted %d tweets, want the post and a reply", len(tweets))
+	}
+	if tweets[0].Media == nil || tweets[0].Media.IDs[0] != "710511363345354753" {
+		t.Errorf("media = %+v", tweets[0].Media)
+	}
+	if !strings.Contains(tweets[0].Text, "🔗 https://app.gitfinder.dev/repository/42") {
+		t.Errorf("tweet = %q", tweets[0].Text)
+	}
+	if tweets[1].Reply == nil || tweets[1].Reply.InReplyToTweetID != "100" || tweets[1].Media != nil {
+		t.Errorf("reply = %+v", tweets[1])
+	}
+}
+
+func TestTwitterClientPostReplyError(t *testing.T) {
+	tweets := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tweets++
+		if tweets > 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"title":"Too Many Requests","detail":"Too Many Requests","type":"about:blank","status":429}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"data":{"id":"100","text":"post"}}`))
+	}))
+	defer server.Close()
+
+	composer, _ := NewComposer("", true)
+	client := &TwitterClient{
+		client:   &twitter.Client{Authorizer: &oauth1Authorizer{}, Client: server.Client(), Host: server.URL},
+		composer: composer,
+	}
+	content := testContent()
+	content.Highlights = []string{"Builds in milliseconds"}
+
+	// The post was made, so its URL is returned with the error of the reply.
+	postURL, err := client.Post(content)
+	if err == nil || postURL != "https://x.com/i/web/status/100" {
+		t.Errorf("Post() = %q, %v; want the post's URL and an error", postURL, err)
+	}
+}
+
+func TestTwitterClientPostImageUploadError(t *testing.T) {
+	var tweets []twitter.CreateTweetRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/1.1/media/upload.json", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/2/tweets", func(w http.ResponseWriter, r *http.Request) {
+		var tweet twitter.CreateTweetRequest
+		json.NewDecoder(r.Body).Decode(&tweet)
+		tweets = append(tweets, tweet)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"data":{"id":"100","text":"post"}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &TwitterClient{
+		client:     &twitter.Client{Authorizer: &oauth1Authorizer{}, Client: server.Client(), Host: server.URL},
+		httpClient: server.Client(),
+		uploadURL:  server.URL + "/1.1/media/upload.json",
+		composer:   defaultComposer(),
+	}
+	content := testContent()
+	content.Image = []byte("\x89PNG image")
+
+	// The post is made without the image.
+	postURL, err := client.Post(content)
+	if err != nil || postURL != "https://x.com/i/web/status/100" {
+		t.Fatalf("Post() = %q, %v", postURL, err)
+	}
+	if len(tweets) != 1 || tweets[0].Media != nil {
+		t.Errorf("tweets = %+v, want one without media", tweets)
+	}
+}
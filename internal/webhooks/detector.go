@@ -0,0 +1,133 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/messaging"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// TrendingStore stores the trending list and reads the repositories events are about.
+type TrendingStore interface {
+	ReplaceTrendingRepositories(repoIDs []int64, publish func(previous []int64) error) error
+	GetRepositoryByID(repoID int64) (models.Repository, error)
+}
+
+// StatsSource computes trending lists and star windows from the repository stats.
+type StatsSource interface {
+	GetTrendingRepositoryIDsByGrowth(days int) ([]int64, error)
+	GetStarWindows(now time.Time) ([]models.StarWindow, error)
+}
+
+// Detector detects repositories entering and leaving the trending list and star
+// spikes, and publishes their events to EventsQueue.
+type Detector struct {
+	store TrendingStore
+	stats StatsSource
+	mq    messaging.MQConnection
+	days  int
+}
+
+// NewDetector creates a Detector over the trending list of the last days.
+func NewDetector(store TrendingStore, stats StatsSource, mq messaging.MQConnection, days int) *Detector {
+	return &Detector{store: store, stats: stats, mq: mq, days: days}
+}
+
+// Run detects the events since the previous run and returns how many were published.
+// The first run only records the trending list. The trending list is stored only
+// once its events are published, so a failed publish is retried on the next run.
+func (d *Detector) Run(now time.Time) (int, error) {
+	published := 0
+
+	current, err := d.stats.GetTrendingRepositoryIDsByGrowth(d.days)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the trending list: %w", err)
+	}
+	// An empty list means missing stats rather than every repository leaving it.
+	if len(current) > 0 {
+		trending := 0
+		err := d.store.ReplaceTrendingRepositories(current, func(previous []int64) error {
+			if len(previous) == 0 {
+				return nil
+			}
+			entered, left := DiffTrending(previous, current)
+			for _, id := range sortedIDs(entered) {
+				ok, err := d.publish(id, func(repo models.Repository) models.WebhookEvent {
+					return NewTrendingEvent(models.EventTrendingEntered, repo, entered[id], d.days, now)
+				})
+				if err != nil {
+					return err
+				}
+				if ok {
+					trending++
+				}
+			}
+			for _, id := range left {
+				ok, err := d.publish(id, func(repo models.Repository) models.WebhookEvent {
+					return NewTrendingEvent(models.EventTrendingLeft, repo, 0, d.days, now)
+				})
+				if err != nil {
+					return err
+				}
+				if ok {
+					trending++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to store the trending list: %w", err)
+		}
+		published += trending
+	}
+
+	windows, err := d.stats.GetStarWindows(now)
+	if err != nil {
+		return published, fmt.Errorf("failed to get star windows: %w", err)
+	}
+	latest := make(map[int64]int64, len(windows))
+	for _, w := range windows {
+		latest[w.RepositoryID] = w.Latest
+	}
+	spikes := DetectStarSpikes(windows)
+	for _, id := range sortedIDs(spikes) {
+		ok, err := d.publish(id, func(repo models.Repository) models.WebhookEvent {
+			repo.StargazersCount = int(latest[id])
+			return NewStarSpikeEvent(repo, spikes[id], now)
+		})
+		if err != nil {
+			// A spike is detected again on the next run of the same day.
+			log.Printf("Failed to publish the star spike of repository %d: %v", id, err)
+		}
+		if ok {
+			published++
+		}
+	}
+	return published, nil
+}
+
+// publish publishes the event built for a repository and reports whether it was
+// published. A repository that cannot be read is logged and skipped.
+func (d *Detector) publish(repoID int64, build func(models.Repository) models.WebhookEvent) (bool, error) {
+	repo, err := d.store.GetRepositoryByID(repoID)
+	if err != nil {
+		log.Printf("Failed to get repository %d for its webhook event: %v", repoID, err)
+		return false, nil
+	}
+	if err := PublishEvent(d.mq, build(repo)); err != nil {
+		return false, fmt.Errorf("failed to publish webhook event for %s: %w", repo.FullName, err)
+	}
+	return true, nil
+}
+
+// PublishEvent publishes an event to EventsQueue.
+func PublishEvent(mq messaging.MQConnection, event models.WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return mq.Publish(EventsQueue, body)
+}
@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+type fakeTrendingStore struct {
+	trending []int64
+}
+
+func (s *fakeTrendingStore) ReplaceTrendingRepositories(repoIDs []int64, publish func(previous []int64) error) error {
+	if err := publish(s.trending); err != nil {
+		return err
+	}
+	s.trending = repoIDs
+	return nil
+}
+
+func (s *fakeTrendingStore) GetRepositoryByID(repoID int64) (models.Repository, error) {
+	return models.Repository{ID: int(repoID), FullName: fmt.Sprintf("owner/repo%d", repoID)}, nil
+}
+
+type fakeStats struct {
+	trending []int64
+	windows  []models.StarWindow
+}
+
+func (s *fakeStats) GetTrendingRepositoryIDsByGrowth(days int) ([]int64, error) {
+	return s.trending, nil
+}
+
+func (s *fakeStats) GetStarWindows(now time.Time) ([]models.StarWindow, error) {
+	return s.windows, nil
+}
+
+func TestDetector(t *testing.T) {
+	store := &fakeTrendingStore{}
+	stats := &fakeStats{trending: []int64{1, 2}}
+	mq := &fakeMQ{}
+	detector := NewDetector(store, stats, mq, 7)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// The first run only records the trending list.
+	if n, err := detector.Run(now); err != nil || n != 0 {
+		t.Fatalf("first Run() = %d, %v", n, err)
+	}
+
+	stats.trending = []int64{2, 3}
+	stats.windows = []models.StarWindow{{RepositoryID: 4, Latest: 600, DayAgo: 100, WeekAgo: 100}}
+	if n, err := detector.Run(now); err != nil || n != 3 {
+		t.Fatalf("Run() = %d, %v", n, err)
+	}
+
+	var events []models.WebhookEvent
+	for _, body := range mq.published[EventsQueue] {
+		var event models.WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	if events[0].Type != models.EventTrendingEntered || events[0].Repository.ID != 3 || events[0].Trending.Rank != 2 || events[0].Key != "trending.entered:3:2025-06-01" {
+		t.Errorf("unexpected entered event %+v", events[0])
+	}
+	if events[1].Type != models.EventTrendingLeft || events[1].Repository.ID != 1 {
+		t.Errorf("unexpected left event %+v", events[1])
+	}
+	if events[2].Type != models.EventAnomalyStarSpike || events[2].Repository.Stars != 600 || events[2].StarSpike.StarsGained != 500 || events[2].Key != "anomaly.star_spike:4:2025-06-01" {
+		t.Errorf("unexpected spike event %+v", events[2])
+	}
+
+	// A run without stats leaves the trending list alone.
+	stats.trending = nil
+	if _, err := detector.Run(now); err != nil || len(store.trending) != 2 {
+		t.Errorf("an empty trending list should be ignored, got %v, %v", store.trending, err)
+	}
+}
+
+func TestDetectorKeepsTrendingListWhenPublishFails(t *testing.T) {
+	store := &fakeTrendingStore{trending: []int64{1, 2}}
+	stats := &fakeStats{trending: []int64{2, 3}}
+	mq := &fakeMQ{err: errors.New("connection closed")}
+	detector := NewDetector(store, stats, mq, 7)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	if _, err := detector.Run(now); err == nil {
+		t.Fatal("Run() should fail when the trending events cannot be published")
+	}
+	if len(store.trending) != 2 || store.trending[0] != 1 {
+		t.Fatalf("the trending list should be kept, got %v", store.trending)
+	}
+
+	// The next run publishes the same changes, under the same keys.
+	mq.err = nil
+	if n, err := detector.Run(now.Add(time.Hour)); err != nil || n != 2 {
+		t.Fatalf("Run() = %d, %v", n, err)
+	}
+	var event models.WebhookEvent
+	if err := json.Unmarshal(mq.published[EventsQueue][0], &event); err != nil || event.Key != "trending.entered:3:2025-06-01" {
+		t.Errorf("unexpected retried event %+v, %v", event, err)
+	}
+	if len(store.trending) != 2 || store.trending[0] != 2 {
+		t.Errorf("the trending list should be replaced, got %v", store.trending)
+	}
+}
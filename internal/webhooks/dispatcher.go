@@ -0,0 +1,187 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/messaging"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+// maxLoggedResponse caps the response body kept in the delivery log.
+const maxLoggedResponse = 1024
+
+// Store stores webhooks, events and the log of their deliveries.
+type Store interface {
+	InsertWebhookEvent(event *models.WebhookEvent) (bool, error)
+	GetWebhooks(activeOnly bool) ([]models.Webhook, error)
+	CreateWebhookDeliveries(eventID int64, webhookIDs []int64) ([]int64, error)
+	GetWebhookDeliveryForSending(deliveryID int64) (models.WebhookDelivery, models.Webhook, []byte, error)
+	RecordWebhookDeliveryAttempt(deliveryID int64, attempt models.WebhookDeliveryAttempt, status string, nextAttemptAt time.Time) error
+	ClaimWebhookDelivery(deliveryID int64) (bool, error)
+	RetryWebhookDeliveryAt(deliveryID int64, at time.Time) error
+	ClaimDueWebhookDeliveries(limit int, sendingBefore time.Time) ([]int64, error)
+}
+
+// Dispatcher fans events out to the webhooks whose filters match them and sends the
+// deliveries.
+type Dispatcher struct {
+	store  Store
+	mq     messaging.MQConnection
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewDispatcher creates a Dispatcher. A nil client uses a client with a 10 second
+// timeout.
+func NewDispatcher(store Store, mq messaging.MQConnection, client *http.Client) *Dispatcher {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Dispatcher{store: store, mq: mq, client: client, now: time.Now}
+}
+
+// HandleEvent stores an event and queues its deliveries to the matching active
+// webhooks. An event already stored is ignored.
+func (d *Dispatcher) HandleEvent(event models.WebhookEvent) error {
+	created, err := d.store.InsertWebhookEvent(&event)
+	if err != nil {
+		return fmt.Errorf("failed to store event %s: %w", event.Key, err)
+	}
+	if !created {
+		return nil
+	}
+
+	webhooks, err := d.store.GetWebhooks(true)
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	var webhookIDs []int64
+	for _, webhook := range webhooks {
+		if Matches(webhook, event) {
+			webhookIDs = append(webhookIDs, webhook.ID)
+		}
+	}
+	deliveryIDs, err := d.store.CreateWebhookDeliveries(event.ID, webhookIDs)
+	if err != nil {
+		return fmt.Errorf("failed to create deliveries of event %d: %w", event.ID, err)
+	}
+	for _, id := range deliveryIDs {
+		d.Enqueue(id)
+	}
+	return nil
+}
+
+// Enqueue queues a pending delivery. A delivery that cannot be queued is retried like a
+// failed one.
+func (d *Dispatcher) Enqueue(deliveryID int64) {
+	body, err := json.Marshal(models.WebhookDeliveryMessage{DeliveryID: deliveryID})
+	if err == nil {
+		err = d.mq.Publish(DeliveriesQueue, body)
+	}
+	if err != nil {
+		log.Printf("Failed to queue webhook delivery %d: %v", deliveryID, err)
+		if err := d.store.RetryWebhookDeliveryAt(deliveryID, d.now().Add(BaseBackoff)); err != nil {
+			log.Printf("Failed to schedule webhook delivery %d: %v", deliveryID, err)
+		}
+	}
+}
+
+// RequeueDue queues up to limit retrying deliveries whose next attempt is due, and
+// deliveries sending for longer than SendingTimeout, and returns how many were queued.
+func (d *Dispatcher) RequeueDue(limit int) (int, error) {
+	ids, err := d.store.ClaimDueWebhookDeliveries(limit, d.now().Add(-SendingTimeout))
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		d.Enqueue(id)
+	}
+	return len(ids), nil
+}
+
+// Deliver sends a pending delivery and logs the attempt. A failed attempt is retried
+// after Backoff until MaxAttempts attempts were made. The delivery is claimed before it
+// is sent, so deliveries that are not pending, such as a duplicate message for one
+// already sent or being sent by another dispatcher, are skipped.
+func (d *Dispatcher) Deliver(ctx context.Context, deliveryID int64) error {
+	delivery, webhook, payload, err := d.store.GetWebhookDeliveryForSending(deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil // The webhook was deleted.
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get delivery %d: %w", deliveryID, err)
+	}
+	claimed, err := d.store.ClaimWebhookDelivery(deliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to claim delivery %d: %w", deliveryID, err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	var attempt models.WebhookDeliveryAttempt
+	if webhook.Active {
+		attempt = d.send(ctx, webhook, delivery, payload)
+	} else {
+		attempt = models.WebhookDeliveryAttempt{AttemptedAt: d.now(), Error: "webhook is inactive"}
+	}
+
+	status, nextAttemptAt := models.DeliverySucceeded, time.Time{}
+	switch {
+	case !webhook.Active:
+		status = models.DeliveryFailed
+	case attempt.Error != "" && delivery.Attempts+1 >= MaxAttempts:
+		status = models.DeliveryFailed
+	case attempt.Error != "":
+		status, nextAttemptAt = models.DeliveryRetrying, attempt.AttemptedAt.Add(Backoff(delivery.Attempts+1))
+	}
+	if err := d.store.RecordWebhookDeliveryAttempt(deliveryID, attempt, status, nextAttemptAt); err != nil {
+		return fmt.Errorf("failed to record attempt at delivery %d: %w", deliveryID, err)
+	}
+	return nil
+}
+
+// send POSTs the payload of a delivery, signed with the webhook's secret. Any response
+// other than 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, webhook models.Webhook, delivery models.WebhookDelivery, payload []byte) models.WebhookDeliveryAttempt {
+	attempt := models.WebhookDeliveryAttempt{AttemptedAt: d.now()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		attempt.Error = fmt.Sprintf("failed to create request: %v", err)
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "github-trending-webhooks")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, payload))
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	attempt.StatusCode = resp.StatusCode
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponse))
+	// PostgreSQL text holds neither invalid UTF-8 nor NUL bytes.
+	attempt.Response = strings.ReplaceAll(string(bytes.ToValidUTF8(response, nil)), "\x00", "")
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return attempt
+}
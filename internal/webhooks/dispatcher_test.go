@@ -0,0 +1,257 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+type fakeMQ struct {
+	published map[string][][]byte
+	err       error
+}
+
+func (m *fakeMQ) Consume(queueName string) (<-chan amqp.Delivery, error) { return nil, nil }
+func (m *fakeMQ) Close() error                                           { return nil }
+func (m *fakeMQ) NotifyClose(c chan *amqp.Error) chan *amqp.Error        { return c }
+func (m *fakeMQ) Publish(queueName string, body []byte) error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.published == nil {
+		m.published = make(map[string][][]byte)
+	}
+	m.published[queueName] = append(m.published[queueName], body)
+	return nil
+}
+
+type fakeStore struct {
+	webhooks   []models.Webhook
+	events     map[string]models.WebhookEvent
+	payloads   map[int64][]byte
+	deliveries map[int64]*models.WebhookDelivery
+	attempts   map[int64][]models.WebhookDeliveryAttempt
+}
+
+func newFakeStore(webhooks ...models.Webhook) *fakeStore {
+	return &fakeStore{
+		webhooks:   webhooks,
+		events:     make(map[string]models.WebhookEvent),
+		payloads:   make(map[int64][]byte),
+		deliveries: make(map[int64]*models.WebhookDelivery),
+		attempts:   make(map[int64][]models.WebhookDeliveryAttempt),
+	}
+}
+
+func (s *fakeStore) InsertWebhookEvent(event *models.WebhookEvent) (bool, error) {
+	if _, ok := s.events[event.Key]; ok {
+		return false, nil
+	}
+	event.ID = int64(len(s.events) + 1)
+	s.events[event.Key] = *event
+	s.payloads[event.ID], _ = json.Marshal(event)
+	return true, nil
+}
+
+func (s *fakeStore) GetWebhooks(activeOnly bool) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	for _, h := range s.webhooks {
+		if h.Active || !activeOnly {
+			webhooks = append(webhooks, h)
+		}
+	}
+	return webhooks, nil
+}
+
+func (s *fakeStore) CreateWebhookDeliveries(eventID int64, webhookIDs []int64) ([]int64, error) {
+	var ids []int64
+	for _, webhookID := range webhookIDs {
+		id := int64(len(s.deliveries) + 1)
+		s.deliveries[id] = &models.WebhookDelivery{ID: id, WebhookID: webhookID, EventID: eventID, EventType: "test", Status: models.DeliveryPending}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *fakeStore) GetWebhookDeliveryForSending(deliveryID int64) (models.WebhookDelivery, models.Webhook, []byte, error) {
+	d := s.deliveries[deliveryID]
+	for _, h := range s.webhooks {
+		if h.ID == d.WebhookID {
+			return *d, h, s.payloads[d.EventID], nil
+		}
+	}
+	return *d, models.Webhook{}, nil, nil
+}
+
+func (s *fakeStore) RecordWebhookDeliveryAttempt(deliveryID int64, attempt models.WebhookDeliveryAttempt, status string, nextAttemptAt time.Time) error {
+	d := s.deliveries[deliveryID]
+	d.Attempts++
+	d.Status = status
+	d.NextAttemptAt = &nextAttemptAt
+	s.attempts[deliveryID] = append(s.attempts[deliveryID], attempt)
+	return nil
+}
+
+func (s *fakeStore) ClaimWebhookDelivery(deliveryID int64) (bool, error) {
+	d := s.deliveries[deliveryID]
+	if d.Status != models.DeliveryPending {
+		return false, nil
+	}
+	d.Status = models.DeliverySending
+	d.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (s *fakeStore) RetryWebhookDeliveryAt(deliveryID int64, at time.Time) error {
+	s.deliveries[deliveryID].Status = models.DeliveryRetrying
+	return nil
+}
+
+func (s *fakeStore) ClaimDueWebhookDeliveries(limit int, sendingBefore time.Time) ([]int64, error) {
+	var ids []int64
+	for id, d := range s.deliveries {
+		if d.Status == models.DeliveryRetrying || (d.Status == models.DeliverySending && d.UpdatedAt.Before(sendingBefore)) {
+			d.Status = models.DeliveryPending
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func TestDispatcher(t *testing.T) {
+	failures := 1
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+		if failures > 0 {
+			failures--
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	store := newFakeStore(
+		models.Webhook{ID: 1, URL: server.URL, Secret: "s3cret", Languages: []string{"go"}, Active: true},
+		models.Webhook{ID: 2, URL: server.URL, Secret: "other", Languages: []string{"rust"}, Active: true},
+	)
+	mq := &fakeMQ{}
+	dispatcher := NewDispatcher(store, mq, server.Client())
+
+	event := models.WebhookEvent{Type: models.EventReleasePublished, Key: "release:1:v1", Repository: models.EventRepository{ID: 1, Language: "Go"}}
+	if err := dispatcher.HandleEvent(event); err != nil {
+		t.Fatalf("HandleEvent returned an error: %v", err)
+	}
+	if err := dispatcher.HandleEvent(event); err != nil {
+		t.Fatalf("HandleEvent returned an error: %v", err)
+	}
+	// Only the Go webhook gets the event, once.
+	if len(mq.published[DeliveriesQueue]) != 1 {
+		t.Fatalf("expected one queued delivery, got %d", len(mq.published[DeliveriesQueue]))
+	}
+	var message models.WebhookDeliveryMessage
+	if err := json.Unmarshal(mq.published[DeliveriesQueue][0], &message); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first attempt fails and is retried after the backoff.
+	if err := dispatcher.Deliver(context.Background(), message.DeliveryID); err != nil {
+		t.Fatalf("Deliver returned an error: %v", err)
+	}
+	delivery := store.deliveries[message.DeliveryID]
+	if delivery.Status != models.DeliveryRetrying || store.attempts[delivery.ID][0].StatusCode != http.StatusServiceUnavailable || store.attempts[delivery.ID][0].Response != "busy\n" {
+		t.Fatalf("unexpected delivery after a failure %+v, %+v", delivery, store.attempts[delivery.ID])
+	}
+	if wait := delivery.NextAttemptAt.Sub(store.attempts[delivery.ID][0].AttemptedAt); wait != BaseBackoff {
+		t.Errorf("retry scheduled after %v, want %v", wait, BaseBackoff)
+	}
+
+	if n, err := dispatcher.RequeueDue(10); err != nil || n != 1 {
+		t.Fatalf("RequeueDue() = %d, %v", n, err)
+	}
+	if err := dispatcher.Deliver(context.Background(), message.DeliveryID); err != nil {
+		t.Fatalf("Deliver returned an error: %v", err)
+	}
+	if delivery.Status != models.DeliverySucceeded || delivery.Attempts != 2 {
+		t.Errorf("unexpected delivery after a success %+v", delivery)
+	}
+	if header.Get(SignatureHeader) != Sign("s3cret", body) || header.Get(EventHeader) != "test" || header.Get(DeliveryHeader) != "1" {
+		t.Errorf("unexpected headers %v", header)
+	}
+	var sent models.WebhookEvent
+	if err := json.Unmarshal(body, &sent); err != nil || sent.ID != 1 || sent.Key != event.Key {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	// A duplicate message for a delivery already sent is skipped.
+	if err := dispatcher.Deliver(context.Background(), message.DeliveryID); err != nil || delivery.Attempts != 2 {
+		t.Errorf("a sent delivery should not be sent again: %v, %d attempts", err, delivery.Attempts)
+	}
+}
+
+func TestDispatcherSkipsClaimedDelivery(t *testing.T) {
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	store := newFakeStore(models.Webhook{ID: 1, URL: server.URL, Secret: "s", Active: true})
+	dispatcher := NewDispatcher(store, &fakeMQ{}, server.Client())
+	if err := dispatcher.HandleEvent(models.WebhookEvent{Type: models.EventTrendingLeft, Key: "k"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A delivery being sent by another dispatcher is skipped.
+	if claimed, err := store.ClaimWebhookDelivery(1); err != nil || !claimed {
+		t.Fatalf("ClaimWebhookDelivery() = %v, %v", claimed, err)
+	}
+	if err := dispatcher.Deliver(context.Background(), 1); err != nil || sent != 0 {
+		t.Fatalf("a claimed delivery should not be sent: %v, %d requests", err, sent)
+	}
+	if n, _ := dispatcher.RequeueDue(10); n != 0 {
+		t.Fatalf("a delivery being sent should not be requeued, got %d", n)
+	}
+
+	// One abandoned for longer than SendingTimeout is queued and sent again.
+	dispatcher.now = func() time.Time { return time.Now().Add(SendingTimeout + time.Minute) }
+	if n, err := dispatcher.RequeueDue(10); err != nil || n != 1 {
+		t.Fatalf("RequeueDue() = %d, %v", n, err)
+	}
+	if err := dispatcher.Deliver(context.Background(), 1); err != nil || sent != 1 || store.deliveries[1].Status != models.DeliverySucceeded {
+		t.Errorf("the abandoned delivery should be sent: %v, %d requests, %+v", err, sent, store.deliveries[1])
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	store := newFakeStore(models.Webhook{ID: 1, URL: server.URL, Secret: "s", Active: true})
+	dispatcher := NewDispatcher(store, &fakeMQ{}, server.Client())
+	if err := dispatcher.HandleEvent(models.WebhookEvent{Type: models.EventTrendingLeft, Key: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < MaxAttempts; i++ {
+		if err := dispatcher.Deliver(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+		dispatcher.RequeueDue(10)
+	}
+	if d := store.deliveries[1]; d.Status != models.DeliveryFailed || d.Attempts != MaxAttempts {
+		t.Errorf("expected the delivery to fail after %d attempts, got %+v", MaxAttempts, d)
+	}
+}
@@ -0,0 +1,182 @@
+// Package webhooks detects trending events and delivers them to registered webhook
+// endpoints as signed POSTs.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+const (
+	// EventsQueue receives the detected events, and DeliveriesQueue the deliveries to
+	// send.
+	EventsQueue     = "webhook_events"
+	DeliveriesQueue = "webhook_deliveries"
+
+	// MaxAttempts is the number of attempts at a delivery before it fails; attempts
+	// are spaced by a backoff doubling from BaseBackoff up to MaxBackoff.
+	MaxAttempts = 8
+	BaseBackoff = 30 * time.Second
+	MaxBackoff  = time.Hour
+
+	// SendingTimeout is how long a delivery can stay sending before it is considered
+	// abandoned, by a dispatcher that stopped while sending it, and queued again.
+	SendingTimeout = 10 * time.Minute
+
+	// Star spikes are at least MinSpikeStars stars gained in a day, and SpikeFactor
+	// times the average daily gain of the week before.
+	MinSpikeStars = 50
+	SpikeFactor   = 5.0
+)
+
+// EventTypes are the event types webhooks can filter on.
+var EventTypes = []string{models.EventTrendingEntered, models.EventTrendingLeft, models.EventAnomalyStarSpike, models.EventReleasePublished}
+
+// Headers of the deliveries. The signature is the hex HMAC-SHA256 of the body with the
+// webhook's secret, prefixed with "sha256=".
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	SignatureHeader = "X-Webhook-Signature-256"
+)
+
+// Sign returns the signature header value of a body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Matches reports whether a webhook's filters accept an event.
+func Matches(webhook models.Webhook, event models.WebhookEvent) bool {
+	if len(webhook.EventTypes) > 0 && !containsFold(webhook.EventTypes, event.Type) {
+		return false
+	}
+	if len(webhook.Languages) > 0 && !containsFold(webhook.Languages, event.Repository.Language) {
+		return false
+	}
+	if len(webhook.Topics) > 0 {
+		for _, topic := range event.Repository.Topics {
+			if containsFold(webhook.Topics, topic) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the wait after the given number of failed attempts.
+func Backoff(attempts int) time.Duration {
+	wait := BaseBackoff
+	for i := 1; i < attempts && wait < MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > MaxBackoff {
+		wait = MaxBackoff
+	}
+	return wait
+}
+
+// DiffTrending compares two trending lists, in rank order. It returns the 1-based rank
+// of the repositories that entered the list and the repositories that left it.
+func DiffTrending(previous, current []int64) (map[int64]int, []int64) {
+	inPrevious := make(map[int64]bool, len(previous))
+	for _, id := range previous {
+		inPrevious[id] = true
+	}
+	inCurrent := make(map[int64]bool, len(current))
+	entered := make(map[int64]int)
+	for i, id := range current {
+		inCurrent[id] = true
+		if !inPrevious[id] {
+			entered[id] = i + 1
+		}
+	}
+	var left []int64
+	for _, id := range previous {
+		if !inCurrent[id] {
+			left = append(left, id)
+		}
+	}
+	return entered, left
+}
+
+// DetectStarSpikes returns the star spikes among the windows, by repository.
+func DetectStarSpikes(windows []models.StarWindow) map[int64]models.StarSpike {
+	spikes := make(map[int64]models.StarSpike)
+	for _, w := range windows {
+		gained := w.Latest - w.DayAgo
+		baseline := float64(w.DayAgo-w.WeekAgo) / 7
+		if baseline < 1 {
+			baseline = 1
+		}
+		if gained >= MinSpikeStars && float64(gained) >= SpikeFactor*baseline {
+			spikes[w.RepositoryID] = models.StarSpike{StarsGained: gained, DailyBaseline: float64(w.DayAgo-w.WeekAgo) / 7}
+		}
+	}
+	return spikes
+}
+
+// NewTrendingEvent builds the event of a repository entering or leaving the trending
+// list of the last days. rank is 0 for a repository leaving it. A repository enters or
+// leaves the list at most once a day, so that a run retried after its trending list
+// failed to be stored publishes the same events.
+func NewTrendingEvent(eventType string, repo models.Repository, rank, days int, now time.Time) models.WebhookEvent {
+	return models.WebhookEvent{
+		Type:       eventType,
+		Key:        fmt.Sprintf("%s:%d:%s", eventType, repo.ID, now.UTC().Format("2006-01-02")),
+		Repository: models.NewEventRepository(repo),
+		Trending:   &models.TrendingChange{Rank: rank, Days: days},
+		OccurredAt: now,
+	}
+}
+
+// NewStarSpikeEvent builds the event of a star spike. A repository spikes at most once
+// a day.
+func NewStarSpikeEvent(repo models.Repository, spike models.StarSpike, now time.Time) models.WebhookEvent {
+	return models.WebhookEvent{
+		Type:       models.EventAnomalyStarSpike,
+		Key:        fmt.Sprintf("%s:%d:%s", models.EventAnomalyStarSpike, repo.ID, now.UTC().Format("2006-01-02")),
+		Repository: models.NewEventRepository(repo),
+		StarSpike:  &spike,
+		OccurredAt: now,
+	}
+}
+
+// NewReleaseEvent builds the event of a published release.
+func NewReleaseEvent(repo models.Repository, release models.Release) models.WebhookEvent {
+	return models.WebhookEvent{
+		Type:       models.EventReleasePublished,
+		Key:        fmt.Sprintf("%s:%d:%s", models.EventReleasePublished, repo.ID, release.TagName),
+		Repository: models.NewEventRepository(repo),
+		Release:    &release,
+		OccurredAt: release.PublishedAt,
+	}
+}
+
+// sortedIDs returns the keys of a map of repository IDs in ascending order.
+func sortedIDs[V any](m map[int64]V) []int64 {
+	ids := make([]int64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
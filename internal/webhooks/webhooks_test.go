@@ -0,0 +1,83 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/teomiscia/github-trending/internal/models"
+)
+
+func TestSign(t *testing.T) {
+	// Example from GitHub's webhook documentation.
+	got := Sign("It's a Secret to Everybody", []byte("Hello, World!"))
+	if want := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"; got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	event := models.WebhookEvent{
+		Type:       models.EventTrendingEntered,
+		Repository: models.EventRepository{Language: "Go", Topics: []string{"llm", "cli"}},
+	}
+	for _, tt := range []struct {
+		name    string
+		webhook models.Webhook
+		want    bool
+	}{
+		{"no filters", models.Webhook{}, true},
+		{"event type", models.Webhook{EventTypes: []string{models.EventTrendingEntered}}, true},
+		{"other event type", models.Webhook{EventTypes: []string{models.EventReleasePublished}}, false},
+		{"language", models.Webhook{Languages: []string{"rust", "go"}}, true},
+		{"other language", models.Webhook{Languages: []string{"rust"}}, false},
+		{"topic", models.Webhook{Topics: []string{"LLM"}}, true},
+		{"other topic", models.Webhook{Topics: []string{"web"}}, false},
+		{"all filters", models.Webhook{EventTypes: []string{models.EventTrendingEntered}, Languages: []string{"go"}, Topics: []string{"cli"}}, true},
+		{"one filter fails", models.Webhook{Languages: []string{"go"}, Topics: []string{"web"}}, false},
+	} {
+		if got := Matches(tt.webhook, event); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		if got := Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if got := Backoff(20); got != MaxBackoff {
+		t.Errorf("Backoff(20) = %v, want %v", got, MaxBackoff)
+	}
+}
+
+func TestDiffTrending(t *testing.T) {
+	entered, left := DiffTrending([]int64{1, 2, 3}, []int64{3, 4, 1, 5})
+	if want := map[int64]int{4: 2, 5: 4}; !reflect.DeepEqual(entered, want) {
+		t.Errorf("entered = %v, want %v", entered, want)
+	}
+	if want := []int64{2}; !reflect.DeepEqual(left, want) {
+		t.Errorf("left = %v, want %v", left, want)
+	}
+}
+
+func TestDetectStarSpikes(t *testing.T) {
+	spikes := DetectStarSpikes([]models.StarWindow{
+		{RepositoryID: 1, Latest: 1300, DayAgo: 1000, WeekAgo: 930},  // 300 a day against 10
+		{RepositoryID: 2, Latest: 5300, DayAgo: 5000, WeekAgo: 3600}, // 300 a day against 200
+		{RepositoryID: 3, Latest: 40, DayAgo: 0, WeekAgo: 0},         // below the minimum
+	})
+	if len(spikes) != 1 || spikes[1].StarsGained != 300 || spikes[1].DailyBaseline != 10 {
+		t.Errorf("unexpected spikes %+v", spikes)
+	}
+}
+
+func TestNewEventRepository(t *testing.T) {
+	repo := models.NewEventRepository(models.Repository{ID: 1, Languages: map[string]int{"Go": 900, "Shell": 100}})
+	if repo.Language != "Go" || repo.Topics == nil {
+		t.Errorf("unexpected event repository %+v", repo)
+	}
+}
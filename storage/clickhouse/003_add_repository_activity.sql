@@ -0,0 +1,19 @@
+-- This script adds the repository_activity table, which stores issue and pull request
+-- activity snapshots collected by the crawler.
+
+CREATE TABLE IF NOT EXISTS default.repository_activity (
+    event_date Date,
+    event_time DateTime,
+    repository_id UInt64,
+    window_days UInt16,
+    issues_opened UInt32,
+    issues_closed UInt32,
+    prs_opened UInt32,
+    prs_closed UInt32,
+    prs_merged UInt32,
+    median_first_response_seconds Float64,
+    merge_rate Float64
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMM(event_date)
+ORDER BY (repository_id, event_time);
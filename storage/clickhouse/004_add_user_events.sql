@@ -0,0 +1,14 @@
+-- This script adds the user_events table, which stores the feedback events (impressions,
+-- dismissals, star click-throughs and README dwell times) sent to POST /events.
+
+CREATE TABLE IF NOT EXISTS default.user_events (
+    event_date Date,
+    event_time DateTime,
+    session_id String,
+    repository_id UInt64,
+    event_type LowCardinality(String),
+    dwell_ms UInt32
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMM(event_date)
+ORDER BY (session_id, event_type, event_time);
@@ -11,4 +11,33 @@ CREATE TABLE IF NOT EXISTS repository_stats (
 )
 ENGINE = MergeTree
 PARTITION BY toYYYYMM(event_date)
-ORDER BY (repository_id, event_time);
\ No newline at end of file
+ORDER BY (repository_id, event_time);
+
+CREATE TABLE IF NOT EXISTS repository_activity (
+    event_date Date,
+    event_time DateTime,
+    repository_id UInt64,
+    window_days UInt16,
+    issues_opened UInt32,
+    issues_closed UInt32,
+    prs_opened UInt32,
+    prs_closed UInt32,
+    prs_merged UInt32,
+    median_first_response_seconds Float64,
+    merge_rate Float64
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMM(event_date)
+ORDER BY (repository_id, event_time);
+
+CREATE TABLE IF NOT EXISTS user_events (
+    event_date Date,
+    event_time DateTime,
+    session_id String,
+    repository_id UInt64,
+    event_type LowCardinality(String),
+    dwell_ms UInt32
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMM(event_date)
+ORDER BY (session_id, event_type, event_time);
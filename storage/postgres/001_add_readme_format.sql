@@ -0,0 +1,5 @@
+-- This script adds the readme_path and readme_format columns, which record the README
+-- resolved through the GitHub readme endpoint (README.rst, docs/README.md, ...) and its markup format.
+
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS readme_path VARCHAR(255);
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS readme_format VARCHAR(32);
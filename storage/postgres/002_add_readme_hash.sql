@@ -0,0 +1,4 @@
+-- This script adds the readme_hash column, which stores the SHA-256 of the README content
+-- last embedded so that unchanged READMEs are not embedded again.
+
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS readme_hash CHAR(64);
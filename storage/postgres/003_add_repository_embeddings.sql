@@ -0,0 +1,16 @@
+-- This script adds the repository_embeddings table, which records the outcome of the
+-- last embedding attempt for each repository so that missing and failed embeddings
+-- can be counted.
+
+CREATE TABLE IF NOT EXISTS repository_embeddings (
+    repository_id BIGINT PRIMARY KEY,
+    status VARCHAR(16) NOT NULL,
+    model VARCHAR(255),
+    version INT,
+    chunk_count INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_repository_embeddings_status ON repository_embeddings (status);
@@ -0,0 +1,4 @@
+-- This script adds a GIN index on repository_similarity.data, which lets the similarity
+-- engine find the repositories whose similarity list contains a given repository.
+
+CREATE INDEX IF NOT EXISTS idx_repository_similarity_data ON repository_similarity USING GIN (data jsonb_path_ops);
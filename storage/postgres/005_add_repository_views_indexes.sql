@@ -0,0 +1,5 @@
+-- This script adds indexes on repository_views, which let the similarity engine count
+-- the sessions that viewed a repository and the sessions that viewed two repositories.
+
+CREATE INDEX IF NOT EXISTS idx_repository_views_repository_id ON repository_views (repository_id, session_id);
+CREATE INDEX IF NOT EXISTS idx_repository_views_session_id ON repository_views (session_id, repository_id);
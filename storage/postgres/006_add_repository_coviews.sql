@@ -0,0 +1,8 @@
+-- This script adds the repository_coviews table, which stores for each repository the
+-- repositories most often viewed in the same sessions, in the format of
+-- repository_similarity.
+
+CREATE TABLE IF NOT EXISTS repository_coviews (
+    id BIGINT PRIMARY KEY,
+    data JSONB NOT NULL
+);
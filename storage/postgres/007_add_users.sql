@@ -0,0 +1,16 @@
+-- This script adds the users table, which stores the accounts created by GitHub login,
+-- and links repository_views to the user who made them.
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    github_id BIGINT UNIQUE NOT NULL,
+    login VARCHAR(255) NOT NULL,
+    name VARCHAR(255),
+    avatar_url VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE repository_views ADD COLUMN IF NOT EXISTS user_id BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_repository_views_user_id ON repository_views (user_id);
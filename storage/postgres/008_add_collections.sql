@@ -0,0 +1,25 @@
+-- This script adds the collections and collection_items tables, which store the named
+-- lists of repositories a session or user saves, their order and notes.
+
+CREATE TABLE IF NOT EXISTS collections (
+    id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    share_token VARCHAR(64) UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS collection_items (
+    collection_id BIGINT REFERENCES collections(id) ON DELETE CASCADE,
+    repository_id BIGINT REFERENCES repositories(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    note TEXT,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, repository_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_items_repository_id ON collection_items (repository_id);
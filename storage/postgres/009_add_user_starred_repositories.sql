@@ -0,0 +1,14 @@
+-- This script adds the user_starred_repositories table, which stores the repositories
+-- users starred on GitHub to seed their recommendations. Repositories may not have been
+-- crawled yet, so repository_id does not reference repositories.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS stars_imported_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS user_starred_repositories (
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    repository_id BIGINT NOT NULL,
+    starred_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (user_id, repository_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_starred_repositories_starred_at ON user_starred_repositories (user_id, starred_at DESC);
@@ -0,0 +1,38 @@
+-- This script adds the watches and notifications tables, which store the repositories,
+-- owners and topics a session watches and the notifications they raised, and the
+-- repository columns the writer compares crawls against to raise them.
+
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS stargazers_count INT;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS latest_release_tag VARCHAR(255);
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS latest_release_published_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS watches (
+    id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(32) NOT NULL,
+    target VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    webhook_url TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, kind, target)
+);
+
+CREATE INDEX IF NOT EXISTS idx_watches_kind_target ON watches (kind, target);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    watch_id BIGINT REFERENCES watches(id) ON DELETE CASCADE,
+    session_id VARCHAR(255) NOT NULL,
+    repository_id BIGINT NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    event_key VARCHAR(255) NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    url TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    read_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (watch_id, repository_id, event_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_session_created_at ON notifications (session_id, created_at DESC);
@@ -0,0 +1,18 @@
+-- This script adds the digest_subscriptions table, which stores the email addresses
+-- receiving daily or weekly digests of the top trending repositories.
+
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    frequency VARCHAR(16) NOT NULL,
+    languages TEXT[] NOT NULL DEFAULT '{}',
+    topics TEXT[] NOT NULL DEFAULT '{}',
+    unsubscribe_token VARCHAR(64) UNIQUE NOT NULL,
+    last_sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, email)
+);
+
+CREATE INDEX IF NOT EXISTS idx_digest_subscriptions_frequency_last_sent_at ON digest_subscriptions (frequency, last_sent_at);
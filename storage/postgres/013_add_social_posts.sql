@@ -0,0 +1,21 @@
+-- This script adds the social_posts table, which records the repositories posted to
+-- each social network, replacing posted_repositories, whose posts were all tweets.
+
+CREATE TABLE IF NOT EXISTS social_posts (
+    repository_id BIGINT NOT NULL,
+    network VARCHAR(32) NOT NULL,
+    post_url TEXT,
+    posted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (repository_id, network)
+);
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'posted_repositories') THEN
+        INSERT INTO social_posts (repository_id, network, posted_at)
+        SELECT repository_id, 'twitter', posted_at FROM posted_repositories
+        ON CONFLICT (repository_id, network) DO NOTHING;
+    END IF;
+END $$;
+
+DROP TABLE IF EXISTS posted_repositories;
@@ -0,0 +1,21 @@
+-- This script adds the dependencies and repository_dependencies tables, which store the
+-- dependencies declared in the manifests of repositories (go.mod, package.json, ...) by
+-- ecosystem.
+
+CREATE TABLE IF NOT EXISTS dependencies (
+    id SERIAL PRIMARY KEY,
+    ecosystem VARCHAR(32) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    UNIQUE (ecosystem, name)
+);
+
+CREATE TABLE IF NOT EXISTS repository_dependencies (
+    repository_id BIGINT REFERENCES repositories(id) ON DELETE CASCADE,
+    dependency_id INT REFERENCES dependencies(id) ON DELETE CASCADE,
+    version_constraint VARCHAR(255),
+    manifest_path VARCHAR(255),
+    is_dev BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (repository_id, dependency_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_repository_dependencies_dependency_id ON repository_dependencies (dependency_id);
@@ -0,0 +1,7 @@
+-- This script adds the columns confirming the email addresses of watches: the token of
+-- their confirmation and unsubscribe links and the time the address was confirmed.
+-- Existing addresses were never confirmed, so they stop receiving emails until their
+-- watch is created again.
+
+ALTER TABLE watches ADD COLUMN IF NOT EXISTS email_token VARCHAR(64) UNIQUE;
+ALTER TABLE watches ADD COLUMN IF NOT EXISTS email_confirmed_at TIMESTAMP WITH TIME ZONE;
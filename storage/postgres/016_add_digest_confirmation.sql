@@ -0,0 +1,5 @@
+-- This script adds the time the email address of a digest subscription was confirmed,
+-- through the link carrying its unsubscribe token. Existing subscriptions were never
+-- confirmed, so they stop receiving digests until they subscribe again.
+
+ALTER TABLE digest_subscriptions ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,5 @@
+-- This script indexes the webhook deliveries being sent. A delivery is claimed by
+-- moving it from pending to sending before it is sent, and one left sending by a
+-- dispatcher that stopped is queued again once it has been sending for too long.
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_sending ON webhook_deliveries (updated_at) WHERE status = 'sending';
@@ -26,12 +26,18 @@ CREATE TABLE IF NOT EXISTS repositories (
     default_branch VARCHAR(255),
     license_key VARCHAR(255) REFERENCES licenses(key),
     readme_url VARCHAR(255),
+    readme_path VARCHAR(255),
+    readme_format VARCHAR(32),
+    readme_hash CHAR(64),
     created_at TIMESTAMP WITH TIME ZONE,
     is_fork BOOLEAN,
     is_template BOOLEAN,
     is_archived BOOLEAN,
     is_disabled BOOLEAN,
-    last_crawled_at TIMESTAMP WITH TIME ZONE
+    last_crawled_at TIMESTAMP WITH TIME ZONE,
+    stargazers_count INT,
+    latest_release_tag VARCHAR(255),
+    latest_release_published_at TIMESTAMP WITH TIME ZONE
 );
 
 CREATE TABLE IF NOT EXISTS languages (
@@ -68,19 +74,216 @@ CREATE TABLE IF NOT EXISTS repository_topics (
     PRIMARY KEY (repository_id, topic_id)
 );
 
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    github_id BIGINT UNIQUE NOT NULL,
+    login VARCHAR(255) NOT NULL,
+    name VARCHAR(255),
+    avatar_url VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    stars_imported_at TIMESTAMP WITH TIME ZONE
+);
+
 CREATE TABLE IF NOT EXISTS repository_views (
     id SERIAL PRIMARY KEY,
     session_id VARCHAR(255) NOT NULL,
     repository_id BIGINT NOT NULL,
-    viewed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    viewed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL
 );
 
+CREATE INDEX IF NOT EXISTS idx_repository_views_repository_id ON repository_views (repository_id, session_id);
+CREATE INDEX IF NOT EXISTS idx_repository_views_session_id ON repository_views (session_id, repository_id);
+CREATE INDEX IF NOT EXISTS idx_repository_views_user_id ON repository_views (user_id);
+
 CREATE TABLE IF NOT EXISTS repository_similarity (
     id BIGINT PRIMARY KEY,
     data JSONB NOT NULL
 );
 
-CREATE TABLE IF NOT EXISTS posted_repositories (
+CREATE INDEX IF NOT EXISTS idx_repository_similarity_data ON repository_similarity USING GIN (data jsonb_path_ops);
+
+CREATE TABLE IF NOT EXISTS repository_coviews (
+    id BIGINT PRIMARY KEY,
+    data JSONB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS repository_embeddings (
+    repository_id BIGINT PRIMARY KEY,
+    status VARCHAR(16) NOT NULL,
+    model VARCHAR(255),
+    version INT,
+    chunk_count INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_repository_embeddings_status ON repository_embeddings (status);
+
+CREATE TABLE IF NOT EXISTS social_posts (
+    repository_id BIGINT NOT NULL,
+    network VARCHAR(32) NOT NULL,
+    post_url TEXT,
+    posted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (repository_id, network)
+);
+
+CREATE TABLE IF NOT EXISTS dependencies (
+    id SERIAL PRIMARY KEY,
+    ecosystem VARCHAR(32) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    UNIQUE (ecosystem, name)
+);
+
+CREATE TABLE IF NOT EXISTS repository_dependencies (
+    repository_id BIGINT REFERENCES repositories(id) ON DELETE CASCADE,
+    dependency_id INT REFERENCES dependencies(id) ON DELETE CASCADE,
+    version_constraint VARCHAR(255),
+    manifest_path VARCHAR(255),
+    is_dev BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (repository_id, dependency_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_repository_dependencies_dependency_id ON repository_dependencies (dependency_id);
+
+CREATE TABLE IF NOT EXISTS collections (
+    id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    share_token VARCHAR(64) UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS collection_items (
+    collection_id BIGINT REFERENCES collections(id) ON DELETE CASCADE,
+    repository_id BIGINT REFERENCES repositories(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    note TEXT,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, repository_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_items_repository_id ON collection_items (repository_id);
+
+CREATE TABLE IF NOT EXISTS user_starred_repositories (
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    repository_id BIGINT NOT NULL,
+    starred_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (user_id, repository_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_starred_repositories_starred_at ON user_starred_repositories (user_id, starred_at DESC);
+
+CREATE TABLE IF NOT EXISTS watches (
+    id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(32) NOT NULL,
+    target VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    email_token VARCHAR(64) UNIQUE,
+    email_confirmed_at TIMESTAMP WITH TIME ZONE,
+    webhook_url TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, kind, target)
+);
+
+CREATE INDEX IF NOT EXISTS idx_watches_kind_target ON watches (kind, target);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    watch_id BIGINT REFERENCES watches(id) ON DELETE CASCADE,
+    session_id VARCHAR(255) NOT NULL,
+    repository_id BIGINT NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    event_key VARCHAR(255) NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    url TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    read_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (watch_id, repository_id, event_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_session_created_at ON notifications (session_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    description TEXT,
+    secret VARCHAR(255) NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    languages TEXT[] NOT NULL DEFAULT '{}',
+    topics TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(64) NOT NULL,
+    event_key VARCHAR(255) UNIQUE NOT NULL,
+    repository_id BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id BIGINT REFERENCES webhook_events(id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_created_at ON webhook_deliveries (webhook_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_retrying ON webhook_deliveries (next_attempt_at) WHERE status = 'retrying';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_sending ON webhook_deliveries (updated_at) WHERE status = 'sending';
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    status_code INT,
+    error TEXT,
+    response TEXT,
+    duration_ms BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts (delivery_id);
+
+CREATE TABLE IF NOT EXISTS trending_repositories (
     repository_id BIGINT PRIMARY KEY,
-    posted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
+    rank INT NOT NULL,
+    entered_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    frequency VARCHAR(16) NOT NULL,
+    languages TEXT[] NOT NULL DEFAULT '{}',
+    topics TEXT[] NOT NULL DEFAULT '{}',
+    unsubscribe_token VARCHAR(64) UNIQUE NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    last_sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, email)
+);
+
+CREATE INDEX IF NOT EXISTS idx_digest_subscriptions_frequency_last_sent_at ON digest_subscriptions (frequency, last_sent_at);